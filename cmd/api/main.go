@@ -30,6 +30,9 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
 
 	// Initialize database connections
 	db, err := database.NewPostgresDB(cfg.DatabaseURL)
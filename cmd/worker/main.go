@@ -10,7 +10,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/maylng/backend/internal/config"
 	"github.com/maylng/backend/internal/database"
@@ -27,8 +26,13 @@ type Worker struct {
 	emailService           *email.Service
 	emailSvc               *services.EmailService
 	customDomainService    *services.CustomDomainService
-	sesVerificationService *services.SESVerificationService
-	dnsValidationService   *services.DNSValidationService
+	verificationScheduler  *services.DomainVerificationScheduler
+	domainHealthService    *services.DomainHealthService
+	dkimService            *services.DKIMService
+	domainSettingsService  *services.DomainSettingsService
+	dnsProvisioningService *services.DNSProvisioningService
+	usageService           *services.UsageService
+	accountDeletionService *services.AccountDeletionService
 }
 
 func main() {
@@ -39,6 +43,9 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
 
 	// Initialize database connections
 	db, err := database.NewPostgresDB(cfg.DatabaseURL)
@@ -61,6 +68,7 @@ func main() {
 				log.Printf("Failed to initialize SES provider: %v", err)
 				emailService = email.NewService(nil, nil)
 			} else {
+				sesProvider.RequireTLSConfigurationSet = cfg.SESRequireTLSConfigurationSet
 				emailService = email.NewService(sesProvider, nil)
 				log.Println("Using SES email provider")
 			}
@@ -84,6 +92,7 @@ func main() {
 		// Try SES first
 		if cfg.AWSRegion != "" {
 			if sesProvider, err := providers.NewSESProvider(cfg.AWSRegion); err == nil {
+				sesProvider.RequireTLSConfigurationSet = cfg.SESRequireTLSConfigurationSet
 				primary = sesProvider
 				log.Println("Initialized SES as primary provider")
 			}
@@ -107,24 +116,48 @@ func main() {
 		}
 	}
 
-	emailSvc := services.NewEmailService(db, emailService)
-
 	// Initialize custom domain services
 	customDomainService := services.NewCustomDomainService(db)
-	dnsValidationService := services.NewDNSValidationService()
+	var dnsResolver services.Resolver
+	if cfg.DNSResolverAddress != "" {
+		dnsResolver = services.NewServerResolver(cfg.DNSResolverAddress, cfg.DNSResolverNetwork, 5*time.Second)
+	}
+	domainSettingsService := services.NewDomainSettingsService(db, services.NewEmailTrackingService(db, cfg.TrackingBaseURL, cfg.TrackingSecret))
+	dnsValidationService := services.NewDNSValidationService(dnsResolver, cfg.DNSPublicResolvers, cfg.InboundMXHosts, cfg.DMARCReportAddress)
 
-	// Initialize SES verification service if using SES
-	var sesVerificationService *services.SESVerificationService
-	if cfg.EmailProvider == "ses" && cfg.AWSRegion != "" {
-		var err error
-		sesVerificationService, err = services.NewSESVerificationService(cfg.AWSRegion, customDomainService)
+	// Initialize the verification providers the worker polls domains with
+	var verificationProviders []services.DomainVerificationProvider
+	if cfg.AWSRegion != "" {
+		sesVerificationService, err := services.NewSESVerificationService(cfg.AWSRegion, customDomainService)
 		if err != nil {
 			log.Printf("Warning: Failed to initialize SES verification service: %v", err)
 		} else {
-			log.Println("Initialized SES verification service for domain polling")
+			verificationProviders = append(verificationProviders, sesVerificationService)
+		}
+	}
+	if cfg.ResendAPIKey != "" {
+		resendVerificationService, err := services.NewResendVerificationService(cfg.ResendAPIKey, cfg.AWSRegion, customDomainService)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize Resend verification service: %v", err)
+		} else {
+			verificationProviders = append(verificationProviders, resendVerificationService)
 		}
 	}
 
+	auditService := services.NewAuditService(db)
+	domainVerificationService := services.NewDomainVerificationService(customDomainService, cfg.EmailProvider, verificationProviders...)
+	dkimService := services.NewDKIMService(db, customDomainService, domainVerificationService, dnsResolver, cfg.DKIMEncryptionKey, time.Duration(cfg.DKIMKeyRotationDays)*24*time.Hour)
+	emailSvc := services.NewEmailService(db, emailService, dkimService, domainSettingsService, cfg.BlockDegradedDomains)
+	verificationScheduler := services.NewDomainVerificationScheduler(
+		db, customDomainService, dnsValidationService, auditService, emailService,
+		cfg.AuthEmailFrom, time.Duration(cfg.DomainVerificationTimeoutDays)*24*time.Hour,
+		domainVerificationService,
+	)
+	domainHealthService := services.NewDomainHealthService(
+		db, customDomainService, dnsValidationService, auditService, emailService, cfg.AuthEmailFrom,
+		cfg.DomainBlocklists, cfg.DomainHealthMinScore, time.Duration(cfg.DomainHealthCheckIntervalHours)*time.Hour,
+	)
+
 	// Initialize worker
 	worker := &Worker{
 		db:                     db,
@@ -132,8 +165,13 @@ func main() {
 		emailService:           emailService,
 		emailSvc:               emailSvc,
 		customDomainService:    customDomainService,
-		sesVerificationService: sesVerificationService,
-		dnsValidationService:   dnsValidationService,
+		verificationScheduler:  verificationScheduler,
+		domainHealthService:    domainHealthService,
+		dkimService:            dkimService,
+		domainSettingsService:  domainSettingsService,
+		dnsProvisioningService: services.NewDNSProvisioningService(db, customDomainService, cfg.DNSProviderEncryptionKey),
+		usageService:           services.NewUsageService(db),
+		accountDeletionService: services.NewAccountDeletionService(db, customDomainService, domainVerificationService, time.Duration(cfg.AccountDeletionCoolingOffDays)*24*time.Hour),
 	}
 
 	// Setup graceful shutdown
@@ -157,6 +195,10 @@ func main() {
 	go worker.processQueuedEmails(ctx)
 	go worker.cleanupExpiredEmails(ctx)
 	go worker.processDomainVerification(ctx)
+	go worker.processDomainHealth(ctx)
+	go worker.processDKIMKeys(ctx)
+	go worker.processDNSProvisioning(ctx)
+	go worker.processAccountDeletions(ctx)
 
 	// Wait for shutdown
 	<-ctx.Done()
@@ -178,12 +220,13 @@ func (w *Worker) processScheduledEmails(ctx context.Context) {
 }
 
 func (w *Worker) processScheduledEmailsBatch() {
-	// Get scheduled emails that are ready to send
+	// Get scheduled emails that are ready to send; sends of suspended accounts wait until reinstatement
 	query := `
-		SELECT id, account_id, from_email_id, to_recipients, cc_recipients, bcc_recipients,
-			   subject, text_content, html_content, attachments, headers, thread_id, metadata
-		FROM sent_emails 
-		WHERE status = 'scheduled' AND scheduled_at <= $1
+		SELECT e.id, e.account_id, e.from_email_id, e.to_recipients, e.cc_recipients, e.bcc_recipients,
+			   e.subject, e.text_content, e.html_content, e.attachments, e.headers, e.thread_id, e.metadata
+		FROM sent_emails e
+		JOIN accounts a ON a.id = e.account_id
+		WHERE e.status = 'scheduled' AND e.scheduled_at <= $1 AND a.status = 'active'
 		LIMIT 100
 	`
 
@@ -267,8 +310,25 @@ func (w *Worker) sendScheduledEmail(sentEmail *models.SentEmail, fromEmailAddres
 		}
 	}
 
+	// Sign with the sender domain's own DKIM key when it has one
+	signer, err := w.dkimService.SignerForAddress(sentEmail.AccountID, fromEmailAddress)
+	if err != nil {
+		log.Printf("Failed to load DKIM key for %s: %v", fromEmailAddress, err)
+	}
+	emailToSend.Signer = signer
+
+	// The sender's domain may have been degraded since the email was accepted, and an
+	// email that can't honor the domain's settings isn't sent
+	var result *email.SendResult
+	err = w.emailSvc.CheckQueuedSender(sentEmail.AccountID, sentEmail.FromEmailID)
+	if err == nil {
+		err = w.domainSettingsService.ApplyToEmail(sentEmail.ID, emailToSend)
+	}
+
 	// Send email
-	result, err := w.emailService.SendEmail(emailToSend)
+	if err == nil {
+		result, err = w.emailService.SendEmail(emailToSend)
+	}
 
 	// Update email status
 	var status models.EmailStatus
@@ -297,6 +357,12 @@ func (w *Worker) sendScheduledEmail(sentEmail *models.SentEmail, fromEmailAddres
 	if updateErr != nil {
 		log.Printf("Failed to update email status for %s: %v", sentEmail.ID, updateErr)
 	}
+
+	if status == models.EmailStatusSent {
+		if err := w.usageService.RecordUsage(sentEmail.AccountID, models.UsageMetricEmailsSent, 1); err != nil {
+			log.Printf("Failed to record send usage for email %s: %v", sentEmail.ID, err)
+		}
+	}
 }
 
 func (w *Worker) processQueuedEmails(ctx context.Context) {
@@ -314,12 +380,13 @@ func (w *Worker) processQueuedEmails(ctx context.Context) {
 }
 
 func (w *Worker) processQueuedEmailsBatch() {
-	// Get queued emails that are ready to send
+	// Get queued emails that are ready to send; sends of suspended accounts wait until reinstatement
 	query := `
-		SELECT id, account_id, from_email_id, to_recipients, cc_recipients, bcc_recipients,
-			   subject, text_content, html_content, attachments, headers, thread_id, metadata
-		FROM sent_emails 
-		WHERE status = 'queued'
+		SELECT e.id, e.account_id, e.from_email_id, e.to_recipients, e.cc_recipients, e.bcc_recipients,
+			   e.subject, e.text_content, e.html_content, e.attachments, e.headers, e.thread_id, e.metadata
+		FROM sent_emails e
+		JOIN accounts a ON a.id = e.account_id
+		WHERE e.status = 'queued' AND a.status = 'active'
 		LIMIT 100
 	`
 
@@ -413,8 +480,8 @@ func (w *Worker) cleanupExpiredEmailsBatch() {
 }
 
 func (w *Worker) processDomainVerification(ctx context.Context) {
-	// Check domain verification every 15 minutes
-	ticker := time.NewTicker(15 * time.Minute)
+	// The scheduler backs off per domain, so a short tick only checks domains that are due
+	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
 	// Run once immediately on startup
@@ -431,99 +498,98 @@ func (w *Worker) processDomainVerification(ctx context.Context) {
 }
 
 func (w *Worker) processDomainVerificationBatch() {
-	// Only process if SES verification service is available
-	if w.sesVerificationService == nil {
-		return
-	}
-
-	log.Println("Starting domain verification batch process...")
-
-	// Get all pending domains that haven't been checked in the last 10 minutes
-	query := `
-		SELECT id, account_id, domain, status, verification_attempted_at, created_at
-		FROM custom_domains 
-		WHERE status IN ('pending', 'failed') 
-		AND (verification_attempted_at IS NULL OR verification_attempted_at <= $1)
-		ORDER BY created_at ASC
-		LIMIT 50
-	`
-
-	// Check domains that haven't been verified in the last 10 minutes
-	cutoffTime := time.Now().Add(-10 * time.Minute)
-	rows, err := w.db.Query(query, cutoffTime)
+	checked, err := w.verificationScheduler.RunBatch(50)
 	if err != nil {
-		log.Printf("Failed to query pending domains: %v", err)
+		log.Printf("Failed to process domain verification: %v", err)
 		return
 	}
-	defer rows.Close()
+	if checked > 0 {
+		log.Printf("Completed domain verification batch: checked %d domains", checked)
+	}
+}
 
-	processedCount := 0
-	for rows.Next() {
-		var domainID, accountID uuid.UUID
-		var domain, status string
-		var verificationAttemptedAt, createdAt time.Time
+// processDomainHealth re-checks verified domains whose health check is due
+func (w *Worker) processDomainHealth(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
 
-		err := rows.Scan(&domainID, &accountID, &domain, &status, &verificationAttemptedAt, &createdAt)
-		if err != nil {
-			log.Printf("Failed to scan domain row: %v", err)
-			continue
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checked, err := w.domainHealthService.RunBatch(50)
+			if err != nil {
+				log.Printf("Failed to check domain health: %v", err)
+			}
+			if checked > 0 {
+				log.Printf("Checked the health of %d custom domains", checked)
+			}
 		}
+	}
+}
 
-		// Get full domain details
-		customDomain, err := w.customDomainService.GetCustomDomainByID(domainID)
-		if err != nil {
-			log.Printf("Failed to get domain %s: %v", domain, err)
-			continue
-		}
+// processDKIMKeys activates published DKIM keys and rotates and retires old ones
+func (w *Worker) processDKIMKeys(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
 
-		// Check DNS first if DNS validation service is available
-		var dnsOK bool = true
-		var dnsMessage string
-		if w.dnsValidationService != nil {
-			dnsMessage, err = w.dnsValidationService.GetDNSPropagationStatus(customDomain)
-			if err != nil {
-				log.Printf("DNS check failed for domain %s: %v", domain, err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.dkimService.ProcessKeys(); err != nil {
+				log.Printf("Failed to process DKIM keys: %v", err)
 			}
-
-			dnsStatus, _ := w.dnsValidationService.ValidateDomainDNS(customDomain)
-			dnsOK = dnsStatus != nil && dnsStatus.AllRecordsPresent
 		}
+	}
+}
 
-		// Only check SES if DNS is properly configured
-		if dnsOK {
-			// Check verification status with SES
-			err = w.sesVerificationService.CheckVerificationStatus(customDomain)
+// processDNSProvisioning publishes changed records of domains connected to a DNS
+// provider, such as a rotated DKIM key, and retries failed syncs
+func (w *Worker) processDNSProvisioning(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			synced, err := w.dnsProvisioningService.SyncChanged()
 			if err != nil {
-				log.Printf("Failed to check SES verification for domain %s: %v", domain, err)
-				continue
+				log.Printf("Failed to sync DNS provider records: %v", err)
 			}
-
-			log.Printf("Checked domain %s: status=%s, ses_status=%s",
-				domain, customDomain.Status, safeString(customDomain.SESVerificationStatus))
-		} else {
-			// Update domain with DNS status message
-			if dnsMessage != "" {
-				customDomain.FailureReason = &dnsMessage
-				w.customDomainService.UpdateCustomDomain(customDomain)
+			if synced > 0 {
+				log.Printf("Synced DNS records of %d custom domains", synced)
 			}
-			log.Printf("Skipped SES check for domain %s: DNS not ready (%s)", domain, dnsMessage)
 		}
-
-		processedCount++
-
-		// Add small delay between checks to avoid overwhelming SES API
-		time.Sleep(1 * time.Second)
 	}
+}
+
+// processAccountDeletions erases accounts whose deletion cooling-off period has ended
+func (w *Worker) processAccountDeletions(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
 
-	if processedCount > 0 {
-		log.Printf("Completed domain verification batch: processed %d domains", processedCount)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.processAccountDeletionsBatch()
+		}
 	}
 }
 
-// Helper function to safely dereference string pointers
-func safeString(s *string) string {
-	if s == nil {
-		return "nil"
+func (w *Worker) processAccountDeletionsBatch() {
+	purged, err := w.accountDeletionService.PurgeDueAccounts(100)
+	if err != nil {
+		log.Printf("Failed to process account deletions: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Deleted %d accounts after their cooling-off period", purged)
 	}
-	return *s
 }
@@ -16,6 +16,7 @@ require (
 	github.com/playwright-community/playwright-go v0.5200.0
 	github.com/redis/go-redis/v9 v9.3.0
 	github.com/sendgrid/sendgrid-go v3.13.0+incompatible
+	golang.org/x/net v0.17.0
 )
 
 require (
@@ -67,7 +68,6 @@ require (
 	go.uber.org/atomic v1.7.0 // indirect
 	golang.org/x/arch v0.3.0 // indirect
 	golang.org/x/crypto v0.19.0 // indirect
-	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/maylng/backend/internal/api/middleware"
@@ -28,6 +29,10 @@ func (h *AccountHandler) CreateAccount(c *gin.Context) {
 
 	account, err := h.accountService.CreateAccount(&req)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid plan") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -66,41 +71,13 @@ func (h *AccountHandler) UpdateAccount(c *gin.Context) {
 
 	account, err := h.accountService.UpdateAccount(accountID, &req)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid plan") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, account)
 }
-
-func (h *AccountHandler) DeleteAccount(c *gin.Context) {
-	accountID, exists := middleware.GetAccountIDFromContext(c)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
-		return
-	}
-
-	err := h.accountService.DeleteAccount(accountID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusNoContent, nil)
-}
-
-func (h *AccountHandler) GenerateNewAPIKey(c *gin.Context) {
-	accountID, exists := middleware.GetAccountIDFromContext(c)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
-		return
-	}
-
-	newAPIKey, err := h.accountService.GenerateNewAPIKey(accountID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"api_key": newAPIKey})
-}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/services"
+)
+
+// AccountDataHandler serves the account's data export and self-service deletion
+type AccountDataHandler struct {
+	exportService   *services.AccountExportService
+	deletionService *services.AccountDeletionService
+}
+
+func NewAccountDataHandler(exportService *services.AccountExportService, deletionService *services.AccountDeletionService) *AccountDataHandler {
+	return &AccountDataHandler{
+		exportService:   exportService,
+		deletionService: deletionService,
+	}
+}
+
+// ExportAccount streams a zip archive of the account's data
+func (h *AccountDataHandler) ExportAccount(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	filename := fmt.Sprintf("maylng-export-%s-%s.zip", accountID, time.Now().UTC().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// The status is already sent once the archive starts streaming, so a failure can
+	// only be logged; the client sees a truncated archive
+	if err := h.exportService.WriteExport(accountID, c.Writer); err != nil {
+		log.Printf("Failed to export account %s: %v", accountID, err)
+		c.Error(err)
+	}
+}
+
+// RequestDeletion schedules the account for deletion after the cooling-off period
+func (h *AccountDataHandler) RequestDeletion(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	deletion, err := h.deletionService.RequestDeletion(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+// GetDeletion reports whether a deletion is pending and when it will run
+func (h *AccountDataHandler) GetDeletion(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	deletion, err := h.deletionService.GetDeletion(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
+
+// CancelDeletion stops a pending deletion
+func (h *AccountDataHandler) CancelDeletion(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	if err := h.deletionService.CancelDeletion(accountID); err != nil {
+		if err.Error() == "no account deletion is pending" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	deletion, err := h.deletionService.GetDeletion(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
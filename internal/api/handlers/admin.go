@@ -3,9 +3,12 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
 	"github.com/maylng/backend/internal/services"
 )
 
@@ -65,6 +68,10 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	if before, err := h.accountService.GetAccount(id); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
 	err = h.accountService.DeleteAccount(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
@@ -123,3 +130,50 @@ func (h *AdminHandler) Stats(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"stats": stats})
 }
+
+// POST /v1/admin/users/:id/suspend
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	h.setUserStatus(c, models.AccountStatusSuspended)
+}
+
+// POST /v1/admin/users/:id/reinstate
+func (h *AdminHandler) ReinstateUser(c *gin.Context) {
+	h.setUserStatus(c, models.AccountStatusActive)
+}
+
+// POST /v1/admin/users/:id/close
+func (h *AdminHandler) CloseUser(c *gin.Context) {
+	h.setUserStatus(c, models.AccountStatusClosed)
+}
+
+func (h *AdminHandler) setUserStatus(c *gin.Context, status models.AccountStatus) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req models.ChangeAccountStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if before, err := h.accountService.GetAccount(id); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	user, err := h.accountService.SetAccountStatus(id, status, req.Reason)
+	if err != nil {
+		switch {
+		case err.Error() == "account not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case strings.HasPrefix(err.Error(), "invalid status change"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change user status"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
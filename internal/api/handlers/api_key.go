@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKey issues a new scoped API key for the account
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keys created by a member belong to that member
+	var memberID *uuid.UUID
+	if id, ok := middleware.GetMemberIDFromContext(c); ok {
+		memberID = &id
+	}
+
+	callerScopes, _ := middleware.GetScopesFromContext(c)
+	key, err := h.apiKeyService.CreateAPIKey(accountID, memberID, callerScopes, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys lists the account's API keys without their secrets
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// UpdateAPIKeyRestrictions sets the IP and origin allowlists of an API key
+func (h *APIKeyHandler) UpdateAPIKeyRestrictions(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var req models.UpdateAPIKeyRestrictionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorMemberID, actorRole := apiKeyActor(c)
+	key, err := h.apiKeyService.UpdateAPIKeyRestrictions(accountID, keyID, actorMemberID, actorRole, &req)
+	if err != nil {
+		switch {
+		case err.Error() == "API key not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case err.Error() == "cannot manage API keys of a higher role":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "invalid") || strings.HasPrefix(err.Error(), "at most"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// RevokeAPIKey revokes a single API key
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	actorMemberID, actorRole := apiKeyActor(c)
+	if err := h.apiKeyService.RevokeAPIKey(accountID, keyID, actorMemberID, actorRole); err != nil {
+		switch err.Error() {
+		case "API key not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "cannot manage API keys of a higher role":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// RotateCurrentAPIKey rotates the key used to authenticate this request
+func (h *APIKeyHandler) RotateCurrentAPIKey(c *gin.Context) {
+	keyID, exists := middleware.GetAPIKeyIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key not found in context"})
+		return
+	}
+
+	h.rotate(c, keyID)
+}
+
+// RotateAPIKey rotates the key given in the URL
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	h.rotate(c, keyID)
+}
+
+func (h *APIKeyHandler) rotate(c *gin.Context, keyID uuid.UUID) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	// The body is optional; an empty body uses the default grace period
+	var req models.RotateAPIKeyRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorMemberID, actorRole := apiKeyActor(c)
+	callerScopes, _ := middleware.GetScopesFromContext(c)
+	rotation, err := h.apiKeyService.RotateAPIKey(accountID, keyID, actorMemberID, actorRole, callerScopes, req.GracePeriodSeconds)
+	if err != nil {
+		switch err.Error() {
+		case "API key not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "cannot manage API keys of a higher role":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case "API key has already been rotated", "API key is revoked or expired":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			if strings.HasPrefix(err.Error(), "cannot grant scope") {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			if strings.HasPrefix(err.Error(), "grace period") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, rotation)
+}
+
+// apiKeyActor returns the member making the request and their role; both are empty
+// when the key acts for the account holder
+func apiKeyActor(c *gin.Context) (*uuid.UUID, string) {
+	var memberID *uuid.UUID
+	if id, ok := middleware.GetMemberIDFromContext(c); ok {
+		memberID = &id
+	}
+	role, _ := middleware.GetMemberRoleFromContext(c)
+	return memberID, role
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// ListAuditEvents returns the account's audit events, newest first
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	filter, err := parseAuditEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.auditService.ListAuditEvents(accountID, filter)
+	if err != nil {
+		if err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// VerifyAuditChain recomputes the account's hash chain and reports the first broken event
+func (h *AuditHandler) VerifyAuditChain(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	result, err := h.auditService.VerifyAuditChain(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func parseAuditEventFilter(c *gin.Context) (*models.AuditEventFilter, error) {
+	filter := &models.AuditEventFilter{
+		Limit:        50,
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		RequestID:    c.Query("request_id"),
+		Cursor:       c.Query("cursor"),
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			filter.Limit = l
+		}
+	}
+
+	if keyStr := c.Query("actor_api_key_id"); keyStr != "" {
+		keyID, err := uuid.Parse(keyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actor_api_key_id")
+		}
+		filter.ActorAPIKeyID = &keyID
+	}
+
+	if memberStr := c.Query("actor_member_id"); memberStr != "" {
+		memberID, err := uuid.Parse(memberStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actor_member_id")
+		}
+		filter.ActorMemberID = &memberID
+	}
+
+	if clientStr := c.Query("actor_oauth_client_id"); clientStr != "" {
+		clientID, err := uuid.Parse(clientStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actor_oauth_client_id")
+		}
+		filter.ActorOAuthClientID = &clientID
+	}
+
+	if afterStr := c.Query("created_after"); afterStr != "" {
+		after, err := time.Parse(time.RFC3339, afterStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_after: must be RFC 3339")
+		}
+		filter.CreatedAfter = &after
+	}
+
+	if beforeStr := c.Query("created_before"); beforeStr != "" {
+		before, err := time.Parse(time.RFC3339, beforeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_before: must be RFC 3339")
+		}
+		filter.CreatedBefore = &before
+	}
+
+	return filter, nil
+}
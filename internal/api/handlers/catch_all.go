@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+// CatchAllHandler manages custom domains' catch-all rules and resolves the recipients
+// of inbound mail for the inbound mail pipeline
+type CatchAllHandler struct {
+	customDomainService *services.CustomDomainService
+	catchAllService     *services.CatchAllService
+}
+
+func NewCatchAllHandler(customDomainService *services.CustomDomainService, catchAllService *services.CatchAllService) *CatchAllHandler {
+	return &CatchAllHandler{
+		customDomainService: customDomainService,
+		catchAllService:     catchAllService,
+	}
+}
+
+// ListRules returns the domain's catch-all rules in the order they are matched
+func (h *CatchAllHandler) ListRules(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	rules, err := h.catchAllService.ListRules(domain.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// CreateRule adds a catch-all rule to a verified domain
+func (h *CatchAllHandler) CreateRule(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	var req models.CreateCatchAllRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	rule, err := h.catchAllService.CreateRule(domain, &req)
+	if err != nil {
+		switch {
+		case err.Error() == "custom domain is not verified", strings.HasPrefix(err.Error(), "catch-all rule already exists"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "invalid catch-all rule"), strings.HasPrefix(err.Error(), "catch-all rule limit reached"),
+			err.Error() == "target email address not found":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// DeleteRule removes a catch-all rule of the domain
+func (h *CatchAllHandler) DeleteRule(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := h.catchAllService.DeleteRule(domain.ID, ruleID); err != nil {
+		if err.Error() == "catch-all rule not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResolveRecipient tells the inbound mail pipeline which address receives mail sent
+// to a recipient, creating it if a catch-all rule says so. A 404 means the message
+// should be rejected.
+func (h *CatchAllHandler) ResolveRecipient(c *gin.Context) {
+	var req models.ResolveRecipientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	resolution, err := h.catchAllService.Resolve(req.Recipient)
+	if err != nil {
+		switch {
+		case err.Error() == "invalid recipient":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "no address accepts mail"):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "email address limit reached"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resolution)
+}
@@ -1,19 +1,23 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
 	"github.com/maylng/backend/internal/models"
 	"github.com/maylng/backend/internal/services"
 )
 
 type CreateCustomDomainRequest struct {
 	Domain               string `json:"domain" binding:"required" validate:"required,fqdn"`
-	VerificationProvider string `json:"verification_provider,omitempty" validate:"omitempty,oneof=ses resend"`
+	VerificationProvider string `json:"verification_provider,omitempty"`
+}
+
+type MigrateCustomDomainRequest struct {
+	VerificationProvider string `json:"verification_provider" binding:"required"`
 }
 
 type CustomDomainResponse struct {
@@ -26,6 +30,8 @@ type CustomDomainResponse struct {
 	SESVerificationStatus      *string                   `json:"ses_verification_status,omitempty"`
 	SESDKIMVerificationStatus  *string                   `json:"ses_dkim_verification_status,omitempty"`
 	FailureReason              *string                   `json:"failure_reason,omitempty"`
+	MailFromDomain             *string                   `json:"mail_from_domain,omitempty"`
+	MailFromStatus             *string                   `json:"mail_from_status,omitempty"`
 	VerificationAttemptedAt    *string                   `json:"verification_attempted_at,omitempty"`
 	VerifiedAt                 *string                   `json:"verified_at,omitempty"`
 	CreatedAt                  string                    `json:"created_at"`
@@ -34,33 +40,20 @@ type CustomDomainResponse struct {
 }
 
 type CustomDomainHandler struct {
-	customDomainService         *services.CustomDomainService
-	domainVerificationService   *services.DomainVerificationService
-	sesVerificationService      *services.SESVerificationService
-	resendVerificationService   *services.ResendVerificationService
-	dnsValidationService        *services.DNSValidationService
-	defaultVerificationProvider string
+	customDomainService       *services.CustomDomainService
+	domainVerificationService *services.DomainVerificationService
+	dnsValidationService      *services.DNSValidationService
 }
 
 func NewCustomDomainHandler(
 	customDomainService *services.CustomDomainService,
 	domainVerificationService *services.DomainVerificationService,
-	sesVerificationService *services.SESVerificationService,
-	resendVerificationService *services.ResendVerificationService,
 	dnsValidationService *services.DNSValidationService,
-	defaultVerificationProvider string,
 ) *CustomDomainHandler {
-	if defaultVerificationProvider == "" {
-		defaultVerificationProvider = "ses" // Default to SES for backward compatibility
-	}
-
 	return &CustomDomainHandler{
-		customDomainService:         customDomainService,
-		domainVerificationService:   domainVerificationService,
-		sesVerificationService:      sesVerificationService,
-		resendVerificationService:   resendVerificationService,
-		dnsValidationService:        dnsValidationService,
-		defaultVerificationProvider: defaultVerificationProvider,
+		customDomainService:       customDomainService,
+		domainVerificationService: domainVerificationService,
+		dnsValidationService:      dnsValidationService,
 	}
 }
 
@@ -93,12 +86,13 @@ func (h *CustomDomainHandler) CreateCustomDomain(c *gin.Context) {
 	// Determine verification provider
 	verificationProvider := req.VerificationProvider
 	if verificationProvider == "" {
-		verificationProvider = h.defaultVerificationProvider
+		verificationProvider = h.domainVerificationService.DefaultProvider()
 	}
 
 	// Validate verification provider
-	if verificationProvider != "ses" && verificationProvider != "resend" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification provider. Must be 'ses' or 'resend'"})
+	verificationService, err := h.domainVerificationService.Provider(verificationProvider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification provider: " + err.Error()})
 		return
 	}
 
@@ -109,42 +103,22 @@ func (h *CustomDomainHandler) CreateCustomDomain(c *gin.Context) {
 			c.JSON(http.StatusConflict, gin.H{"error": "Domain already exists"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom domain"})
-		return
-	}
-
-	// Get the appropriate verification service
-	var verificationService services.DomainVerificationProvider
-	switch verificationProvider {
-	case "resend":
-		if h.resendVerificationService != nil {
-			verificationService = h.resendVerificationService
-		} else {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Resend verification service not available"})
-			return
-		}
-	case "ses":
-		if h.sesVerificationService != nil {
-			verificationService = h.sesVerificationService
-		} else {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "SES verification service not available"})
+		if strings.HasPrefix(err.Error(), "custom domain limit reached") || err.Error() == "custom domains are not available on your plan" {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
 			return
 		}
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification provider"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom domain"})
 		return
 	}
 
 	// Initiate verification using the selected provider
-	if verificationService != nil {
-		err = verificationService.InitiateDomainVerification(customDomain)
-		if err != nil {
-			// Log error but don't fail the request
-			// The domain is created, verification can be retried later
-			errMsg := err.Error()
-			customDomain.FailureReason = &errMsg
-			h.customDomainService.UpdateCustomDomain(customDomain)
-		}
+	err = verificationService.InitiateDomainVerification(customDomain)
+	if err != nil {
+		// Log error but don't fail the request
+		// The domain is created, verification can be retried later
+		errMsg := err.Error()
+		customDomain.FailureReason = &errMsg
+		h.customDomainService.UpdateCustomDomain(customDomain)
 	}
 
 	// Return response
@@ -205,77 +179,6 @@ func (h *CustomDomainHandler) GetCustomDomain(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// UpdateCustomDomain updates domain settings (tracking, TLS, etc.)
-func (h *CustomDomainHandler) UpdateCustomDomain(c *gin.Context) {
-	accountID, exists := c.Get("account_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account ID not found"})
-		return
-	}
-
-	domainID := c.Param("id")
-	id, err := uuid.Parse(domainID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
-		return
-	}
-
-	// Get domain to verify ownership
-	domain, err := h.customDomainService.GetCustomDomainByID(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Custom domain not found"})
-		return
-	}
-
-	// Verify ownership
-	if domain.AccountID != accountID.(uuid.UUID) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
-	// Parse update request
-	var updateSettings map[string]interface{}
-	if err := c.ShouldBindJSON(&updateSettings); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
-		return
-	}
-
-	// Update domain settings using the appropriate provider
-	switch domain.VerificationProvider {
-	case "resend":
-		if h.resendVerificationService != nil {
-			if err := h.resendVerificationService.UpdateDomainSettings(domain, updateSettings); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update domain settings: " + err.Error()})
-				return
-			}
-		} else {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Resend verification service not available"})
-			return
-		}
-	case "ses":
-		// SES doesn't support the same update operations, but we can still store metadata
-		if domain.Metadata == nil {
-			domain.Metadata = make(map[string]interface{})
-		}
-		for key, value := range updateSettings {
-			domain.Metadata["ses_"+key] = value
-		}
-		if err := h.customDomainService.UpdateCustomDomain(domain); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update domain metadata"})
-			return
-		}
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported verification provider for domain updates"})
-		return
-	}
-
-	// Return success response
-	c.JSON(http.StatusOK, gin.H{
-		"object": "domain",
-		"id":     domain.ID.String(),
-	})
-}
-
 // DeleteCustomDomain deletes a custom domain
 func (h *CustomDomainHandler) DeleteCustomDomain(c *gin.Context) {
 	accountID, exists := c.Get("account_id")
@@ -304,19 +207,10 @@ func (h *CustomDomainHandler) DeleteCustomDomain(c *gin.Context) {
 		return
 	}
 
-	// Delete from verification provider if verification service is available
-	switch domain.VerificationProvider {
-	case "resend":
-		if h.resendVerificationService != nil {
-			// Use the improved deletion method that leverages stored provider domain ID
-			h.resendVerificationService.DeleteDomainByID(domain)
-		}
-	case "ses":
-		if h.sesVerificationService != nil {
-			// Best effort - don't fail if provider deletion fails
-			h.sesVerificationService.DeleteDomainIdentity(domain.Domain)
-		}
-	}
+	middleware.SetAuditBefore(c, h.toResponse(domain))
+
+	// Delete from verification provider - best effort, don't fail if provider deletion fails
+	h.domainVerificationService.DeleteDomain(domain)
 
 	// Delete from database
 	err = h.customDomainService.DeleteCustomDomain(id, accountID.(uuid.UUID))
@@ -355,25 +249,15 @@ func (h *CustomDomainHandler) VerifyCustomDomain(c *gin.Context) {
 		return
 	}
 
-	// Trigger verification using the appropriate provider
-	var verificationService services.DomainVerificationProvider
-	switch domain.VerificationProvider {
-	case "resend":
-		verificationService = h.resendVerificationService
-	case "ses":
-		verificationService = h.sesVerificationService
+	// Trigger verification using the domain's provider
+	if err := h.domainVerificationService.InitiateDomainVerification(domain); err != nil {
+		h.handleProviderError(c, "Failed to initiate verification", err)
+		return
 	}
 
-	if verificationService != nil {
-		err = verificationService.InitiateDomainVerification(domain)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initiate verification: " + err.Error()})
-			return
-		}
-	} else {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": fmt.Sprintf("Verification service for provider '%s' not available", domain.VerificationProvider),
-		})
+	// The worker picks the domain up on its next run with a fresh timeout
+	if err := h.customDomainService.RestartVerification(domain); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule verification"})
 		return
 	}
 
@@ -409,25 +293,9 @@ func (h *CustomDomainHandler) CheckVerificationStatus(c *gin.Context) {
 		return
 	}
 
-	// Check status using the appropriate provider
-	var verificationService services.DomainVerificationProvider
-	switch domain.VerificationProvider {
-	case "resend":
-		verificationService = h.resendVerificationService
-	case "ses":
-		verificationService = h.sesVerificationService
-	}
-
-	if verificationService != nil {
-		err = verificationService.CheckVerificationStatus(domain)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check verification status: " + err.Error()})
-			return
-		}
-	} else {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": fmt.Sprintf("Verification service for provider '%s' not available", domain.VerificationProvider),
-		})
+	// Check status using the domain's provider
+	if err := h.domainVerificationService.CheckVerificationStatus(domain); err != nil {
+		h.handleProviderError(c, "Failed to check verification status", err)
 		return
 	}
 
@@ -445,7 +313,53 @@ func (h *CustomDomainHandler) CheckVerificationStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// ValidateDomainDNS checks the DNS configuration for a custom domain
+// GetVerificationProviders lists the configured verification providers and what each supports
+func (h *CustomDomainHandler) GetVerificationProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.domainVerificationService.Providers()})
+}
+
+// MigrateCustomDomain moves a domain to another verification provider
+func (h *CustomDomainHandler) MigrateCustomDomain(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	var req MigrateCustomDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	middleware.SetAuditBefore(c, h.toResponse(domain))
+
+	if err := h.domainVerificationService.MigrateDomain(domain, req.VerificationProvider); err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "custom domain already uses"), strings.HasPrefix(err.Error(), "custom domain sends through"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "verification provider"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to migrate custom domain: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toResponse(domain))
+}
+
+// handleProviderError reports an unconfigured provider as unavailable and other
+// provider errors as failures of the action
+func (h *CustomDomainHandler) handleProviderError(c *gin.Context, action string, err error) {
+	if strings.HasPrefix(err.Error(), "verification provider") {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": action + ": " + err.Error()})
+}
+
+// ValidateDomainDNS checks the DNS configuration for a custom domain and explains how to fix it.
+// With mode=propagation it instead compares the records across nameservers and resolvers.
 func (h *CustomDomainHandler) ValidateDomainDNS(c *gin.Context) {
 	accountID, exists := c.Get("account_id")
 	if !exists {
@@ -478,16 +392,55 @@ func (h *CustomDomainHandler) ValidateDomainDNS(c *gin.Context) {
 		return
 	}
 
-	dnsStatus, err := h.dnsValidationService.ValidateDomainDNS(domain)
+	switch c.DefaultQuery("mode", "diagnose") {
+	case "diagnose":
+	case "propagation":
+		report, err := h.dnsValidationService.CheckPropagation(domain)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check DNS propagation: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Must be 'diagnose' or 'propagation'"})
+		return
+	}
+
+	dnsStatus, err := h.dnsValidationService.DiagnoseDomainDNS(domain)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate DNS: " + err.Error()})
 		return
 	}
 
-	// Return DNS validation results
+	// Return DNS validation results with SPF, DMARC and MX diagnostics
 	c.JSON(http.StatusOK, dnsStatus)
 }
 
+// ownedCustomDomain loads the :id domain and checks it belongs to the caller's account.
+// It writes the error response and returns false otherwise.
+func ownedCustomDomain(c *gin.Context, customDomainService *services.CustomDomainService) (*models.CustomDomain, bool) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return nil, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return nil, false
+	}
+
+	domain, err := customDomainService.GetCustomDomainByID(id)
+	if err != nil || domain.AccountID != accountID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Custom domain not found"})
+		return nil, false
+	}
+
+	return domain, true
+}
+
 // Helper function to convert domain to response
 func (h *CustomDomainHandler) toResponse(domain *models.CustomDomain) CustomDomainResponse {
 	response := CustomDomainResponse{
@@ -500,6 +453,8 @@ func (h *CustomDomainHandler) toResponse(domain *models.CustomDomain) CustomDoma
 		SESVerificationStatus:      domain.SESVerificationStatus,
 		SESDKIMVerificationStatus:  domain.SESDKIMVerificationStatus,
 		FailureReason:              domain.FailureReason,
+		MailFromDomain:             domain.MailFromDomain,
+		MailFromStatus:             domain.MailFromStatus,
 		CreatedAt:                  domain.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:                  domain.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+// DKIMHandler manages a custom domain's self-hosted DKIM keys
+type DKIMHandler struct {
+	customDomainService *services.CustomDomainService
+	dkimService         *services.DKIMService
+}
+
+func NewDKIMHandler(customDomainService *services.CustomDomainService, dkimService *services.DKIMService) *DKIMHandler {
+	return &DKIMHandler{
+		customDomainService: customDomainService,
+		dkimService:         dkimService,
+	}
+}
+
+// ListKeys lists the domain's DKIM keys with their selector records
+func (h *DKIMHandler) ListKeys(c *gin.Context) {
+	domain, ok := h.ownedDomain(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.dkimService.ListKeys(domain.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.DKIMKeyListResponse{Keys: keys})
+}
+
+// EnableDKIM generates the domain's first key; signing starts once its record is published
+func (h *DKIMHandler) EnableDKIM(c *gin.Context) {
+	domain, ok := h.ownedDomain(c)
+	if !ok {
+		return
+	}
+
+	var req models.EnableDKIMRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	key, err := h.dkimService.EnableDKIM(domain, req.Algorithm)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// RotateKey publishes a new key that takes over once its record is visible in DNS
+func (h *DKIMHandler) RotateKey(c *gin.Context) {
+	domain, ok := h.ownedDomain(c)
+	if !ok {
+		return
+	}
+
+	var req models.EnableDKIMRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	key, err := h.dkimService.RotateKey(domain, req.Algorithm)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// DisableDKIM stops self-hosted signing and unpublishes the domain's selectors
+func (h *DKIMHandler) DisableDKIM(c *gin.Context) {
+	domain, ok := h.ownedDomain(c)
+	if !ok {
+		return
+	}
+
+	if err := h.dkimService.DisableDKIM(domain); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "DKIM signing disabled"})
+}
+
+// ownedDomain loads the :id domain and checks it belongs to the caller's account
+func (h *DKIMHandler) ownedDomain(c *gin.Context) (*models.CustomDomain, bool) {
+	return ownedCustomDomain(c, h.customDomainService)
+}
+
+func (h *DKIMHandler) handleError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "DKIM signing is already enabled", "a DKIM key rotation is already in progress", "a DKIM key change is already in progress":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "DKIM signing is not enabled":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		if strings.HasPrefix(err.Error(), "verification provider") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// bindOptionalJSON binds a JSON body that may be left out. The body is read even without
+// a Content-Length, so chunked requests keep their options; an empty body leaves obj as is.
+func bindOptionalJSON(c *gin.Context, obj interface{}) error {
+	if err := c.ShouldBindJSON(obj); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
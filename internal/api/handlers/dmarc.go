@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/services"
+)
+
+// maxInboundReportSize caps a forwarded report email or file
+const maxInboundReportSize = 20 << 20
+
+// maxReportStatsDays is the longest period a DMARC or TLS report stats request can cover
+const maxReportStatsDays = 365
+
+// DMARCHandler receives DMARC aggregate reports and serves each domain's alignment stats
+type DMARCHandler struct {
+	customDomainService *services.CustomDomainService
+	dmarcReportService  *services.DMARCReportService
+}
+
+func NewDMARCHandler(customDomainService *services.CustomDomainService, dmarcReportService *services.DMARCReportService) *DMARCHandler {
+	return &DMARCHandler{
+		customDomainService: customDomainService,
+		dmarcReportService:  dmarcReportService,
+	}
+}
+
+// GetStats returns the domain's DMARC alignment over the last ?days= days (30 by default)
+func (h *DMARCHandler) GetStats(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	since, until, ok := reportStatsPeriod(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.dmarcReportService.GetStats(domain, since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// IngestReport stores a report forwarded by the inbound mail pipeline: either the whole
+// report email or the report file itself (XML, gzip or zip)
+func (h *DMARCHandler) IngestReport(c *gin.Context) {
+	ingestInboundReport(c, h.dmarcReportService.IngestMessage, h.dmarcReportService.IngestFile)
+}
+
+// reportStatsPeriod reads the ?days= period of a report stats request (30 days by
+// default). It writes the error response and returns false when days is invalid.
+func reportStatsPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	days := 30
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxReportStatsDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+			return time.Time{}, time.Time{}, false
+		}
+		days = parsed
+	}
+
+	until := time.Now().UTC()
+	return until.AddDate(0, 0, -days).Truncate(24 * time.Hour), until, true
+}
+
+// ingestInboundReport passes a forwarded report to ingestMessage when the body is the
+// whole email (message/rfc822 or no content type), or to ingestFile otherwise
+func ingestInboundReport(c *gin.Context, ingestMessage, ingestFile func([]byte) (*services.ReportIngestResult, error)) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxInboundReportSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Report is too large"})
+		return
+	}
+
+	var result *services.ReportIngestResult
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "", "message/rfc822":
+		result, err = ingestMessage(body)
+	default:
+		result, err = ingestFile(body)
+	}
+	if err != nil {
+		// A report that can't be parsed comes back without a result
+		if result == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+// DNSProviderHandler connects custom domains to the DNS provider hosting their zone,
+// so their records are published automatically
+type DNSProviderHandler struct {
+	customDomainService    *services.CustomDomainService
+	dnsProvisioningService *services.DNSProvisioningService
+}
+
+func NewDNSProviderHandler(customDomainService *services.CustomDomainService, dnsProvisioningService *services.DNSProvisioningService) *DNSProviderHandler {
+	return &DNSProviderHandler{
+		customDomainService:    customDomainService,
+		dnsProvisioningService: dnsProvisioningService,
+	}
+}
+
+// GetConnection returns the domain's DNS provider connection and what was published
+func (h *DNSProviderHandler) GetConnection(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	connection, err := h.dnsProvisioningService.GetConnection(domain)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// Connect stores delegated credentials and publishes the domain's records
+func (h *DNSProviderHandler) Connect(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	var req models.ConnectDNSProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	connection, err := h.dnsProvisioningService.Connect(domain, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// Sync publishes the domain's current records again
+func (h *DNSProviderHandler) Sync(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	connection, err := h.dnsProvisioningService.Sync(domain)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// Disconnect deletes the published records and the stored credentials
+func (h *DNSProviderHandler) Disconnect(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	if err := h.dnsProvisioningService.Disconnect(domain); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "DNS provider disconnected"})
+}
+
+func (h *DNSProviderHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case err.Error() == "no DNS provider is connected":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err.Error() == "custom domain is not in the zone", strings.HasPrefix(err.Error(), "invalid DNS provider credentials"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "failed to delete DNS records"):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/services"
+)
+
+// DomainHealthHandler reports the health of verified custom domains
+type DomainHealthHandler struct {
+	customDomainService *services.CustomDomainService
+	domainHealthService *services.DomainHealthService
+}
+
+func NewDomainHealthHandler(customDomainService *services.CustomDomainService, domainHealthService *services.DomainHealthService) *DomainHealthHandler {
+	return &DomainHealthHandler{
+		customDomainService: customDomainService,
+		domainHealthService: domainHealthService,
+	}
+}
+
+// GetHealth returns the domain's latest health check
+func (h *DomainHealthHandler) GetHealth(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	health, err := h.domainHealthService.GetHealth(domain)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// CheckHealth checks the domain now, for example after fixing its DNS
+func (h *DomainHealthHandler) CheckHealth(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	health, err := h.domainHealthService.CheckDomain(domain)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+func (h *DomainHealthHandler) handleError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "custom domain is not verified":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "domain health has not been checked yet":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+// DomainSettingsHandler manages custom domains' sending settings
+type DomainSettingsHandler struct {
+	customDomainService   *services.CustomDomainService
+	domainSettingsService *services.DomainSettingsService
+}
+
+func NewDomainSettingsHandler(customDomainService *services.CustomDomainService, domainSettingsService *services.DomainSettingsService) *DomainSettingsHandler {
+	return &DomainSettingsHandler{
+		customDomainService:   customDomainService,
+		domainSettingsService: domainSettingsService,
+	}
+}
+
+// GetSettings returns the domain's sending settings
+func (h *DomainSettingsHandler) GetSettings(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	settings, err := h.domainSettingsService.GetSettings(domain.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings changes the domain's sending settings (tracking, TLS, defaults)
+func (h *DomainSettingsHandler) UpdateSettings(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateDomainSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if before, err := h.domainSettingsService.GetSettings(domain.ID); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	settings, err := h.domainSettingsService.UpdateSettings(domain, &req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid domain settings") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -47,6 +50,18 @@ func (h *EmailHandler) SendEmail(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if strings.Contains(err.Error(), "email limit reached") {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "custom domain ") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Error() == "scheduled sends are not available on your plan" {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -61,35 +76,106 @@ func (h *EmailHandler) GetEmails(c *gin.Context) {
 		return
 	}
 
-	// Parse query parameters
-	limit := 50
-	offset := 0
+	filter, err := parseEmailFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.emailService.GetEmails(accountID, filter)
+	if err != nil {
+		if err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"emails": result.Emails,
+		"pagination": gin.H{
+			"limit":       filter.Limit,
+			"offset":      filter.Offset,
+			"next_cursor": result.NextCursor,
+			"has_more":    result.HasMore,
+		},
+	})
+}
+
+// parseEmailFilter reads the GET /v1/emails query parameters into an EmailFilter
+func parseEmailFilter(c *gin.Context) (*models.EmailFilter, error) {
+	filter := &models.EmailFilter{
+		Limit:     50,
+		Recipient: strings.TrimSpace(c.Query("recipient")),
+		Query:     strings.TrimSpace(c.Query("q")),
+		Cursor:    c.Query("cursor"),
+	}
 
 	if limitStr := c.Query("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
+			filter.Limit = l
 		}
 	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
 
 	if offsetStr := c.Query("offset"); offsetStr != "" {
 		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+			filter.Offset = o
 		}
 	}
 
-	emails, err := h.emailService.GetEmails(accountID, limit, offset)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if statusStr := c.Query("status"); statusStr != "" {
+		status := models.EmailStatus(statusStr)
+		switch status {
+		case models.EmailStatusQueued, models.EmailStatusSent, models.EmailStatusDelivered,
+			models.EmailStatusFailed, models.EmailStatusScheduled:
+			filter.Status = &status
+		default:
+			return nil, fmt.Errorf("invalid status: %s", statusStr)
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"emails": emails,
-		"pagination": gin.H{
-			"limit":  limit,
-			"offset": offset,
-		},
-	})
+	if fromStr := c.Query("from_email_id"); fromStr != "" {
+		fromID, err := uuid.Parse(fromStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from_email_id")
+		}
+		filter.FromEmailID = &fromID
+	}
+
+	if threadStr := c.Query("thread_id"); threadStr != "" {
+		threadID, err := uuid.Parse(threadStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread_id")
+		}
+		filter.ThreadID = &threadID
+	}
+
+	if afterStr := c.Query("created_after"); afterStr != "" {
+		after, err := time.Parse(time.RFC3339, afterStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_after: must be RFC 3339")
+		}
+		filter.CreatedAfter = &after
+	}
+
+	if beforeStr := c.Query("created_before"); beforeStr != "" {
+		before, err := time.Parse(time.RFC3339, beforeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_before: must be RFC 3339")
+		}
+		filter.CreatedBefore = &before
+	}
+
+	// Metadata filters are passed as metadata[key]=value
+	if metadata := c.QueryMap("metadata"); len(metadata) > 0 {
+		filter.Metadata = metadata
+	}
+
+	return filter, nil
 }
 
 func (h *EmailHandler) GetEmail(c *gin.Context) {
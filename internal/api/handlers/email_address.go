@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -35,7 +36,7 @@ func (h *EmailAddressHandler) CreateEmailAddress(c *gin.Context) {
 
 	emailAddress, err := h.emailAddressService.CreateEmailAddress(accountID, &req)
 	if err != nil {
-		if err.Error() == "email address limit reached" ||
+		if strings.HasPrefix(err.Error(), "email address limit reached") ||
 			err.Error() == "email address already exists" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -108,6 +109,10 @@ func (h *EmailAddressHandler) UpdateEmailAddress(c *gin.Context) {
 		return
 	}
 
+	if before, err := h.emailAddressService.GetEmailAddress(accountID, emailAddressID); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
 	emailAddress, err := h.emailAddressService.UpdateEmailAddress(accountID, emailAddressID, &req)
 	if err != nil {
 		if err.Error() == "email address not found" {
@@ -134,6 +139,10 @@ func (h *EmailAddressHandler) DeleteEmailAddress(c *gin.Context) {
 		return
 	}
 
+	if before, err := h.emailAddressService.GetEmailAddress(accountID, emailAddressID); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
 	err = h.emailAddressService.DeleteEmailAddress(accountID, emailAddressID)
 	if err != nil {
 		if err.Error() == "email address not found" {
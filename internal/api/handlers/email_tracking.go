@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/services"
+)
+
+// trackingPixel is a transparent 1x1 GIF
+var trackingPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// EmailTrackingHandler serves the open pixels and link redirects of tracked emails
+type EmailTrackingHandler struct {
+	trackingService *services.EmailTrackingService
+}
+
+func NewEmailTrackingHandler(trackingService *services.EmailTrackingService) *EmailTrackingHandler {
+	return &EmailTrackingHandler{trackingService: trackingService}
+}
+
+// TrackOpen records an open and serves the pixel. The pixel is served whatever the
+// token, so mail clients don't show a broken image.
+func (h *EmailTrackingHandler) TrackOpen(c *gin.Context) {
+	if err := h.trackingService.RecordOpen(c.Param("token"), c.Request.UserAgent()); err != nil {
+		log.Printf("Failed to track open: %v", err)
+	}
+
+	c.Header("Cache-Control", "no-store, max-age=0")
+	c.Data(http.StatusOK, "image/gif", trackingPixel)
+}
+
+// TrackClick records a click and redirects to the link's target
+func (h *EmailTrackingHandler) TrackClick(c *gin.Context) {
+	target, err := h.trackingService.RecordClick(c.Param("token"), c.Request.UserAgent())
+	if target == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to track click: %v", err)
+	}
+
+	c.Header("Cache-Control", "no-store, max-age=0")
+	c.Redirect(http.StatusFound, target)
+}
+
+// GetEmailTracking returns the opens and clicks of an email
+func (h *EmailTrackingHandler) GetEmailTracking(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	emailID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email ID"})
+		return
+	}
+
+	tracking, err := h.trackingService.GetTracking(accountID, emailID)
+	if err != nil {
+		if err.Error() == "email not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tracking)
+}
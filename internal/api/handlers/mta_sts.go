@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+// MTASTSHandler manages custom domains' MTA-STS policies, serves the policy files and
+// receives TLS-RPT reports about inbound TLS
+type MTASTSHandler struct {
+	customDomainService *services.CustomDomainService
+	mtaSTSService       *services.MTASTSService
+	tlsReportService    *services.TLSReportService
+}
+
+func NewMTASTSHandler(customDomainService *services.CustomDomainService, mtaSTSService *services.MTASTSService, tlsReportService *services.TLSReportService) *MTASTSHandler {
+	return &MTASTSHandler{
+		customDomainService: customDomainService,
+		mtaSTSService:       mtaSTSService,
+		tlsReportService:    tlsReportService,
+	}
+}
+
+// GetPolicy returns the domain's MTA-STS policy with the records that publish it
+func (h *MTASTSHandler) GetPolicy(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	policy, err := h.mtaSTSService.GetPolicy(domain)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// SetPolicy publishes the domain's policy or changes its mode
+func (h *MTASTSHandler) SetPolicy(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	var req models.SetMTASTSPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	policy, err := h.mtaSTSService.SetPolicy(domain, req.Mode)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DisablePolicy stops serving the domain's policy and removes its records
+func (h *MTASTSHandler) DisablePolicy(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	if err := h.mtaSTSService.DisablePolicy(domain); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MTA-STS policy removed"})
+}
+
+// ServePolicy serves /.well-known/mta-sts.txt for the domain named by the Host header
+func (h *MTASTSHandler) ServePolicy(c *gin.Context) {
+	policy, err := h.mtaSTSService.PolicyForHost(c.Request.Host)
+	if err != nil {
+		c.String(http.StatusNotFound, "Not found\n")
+		return
+	}
+
+	c.Header("Cache-Control", "max-age=300")
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(policy))
+}
+
+// GetTLSReportStats returns the domain's reported inbound TLS sessions over the last
+// ?days= days (30 by default)
+func (h *MTASTSHandler) GetTLSReportStats(c *gin.Context) {
+	domain, ok := ownedCustomDomain(c, h.customDomainService)
+	if !ok {
+		return
+	}
+
+	since, until, ok := reportStatsPeriod(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.tlsReportService.GetStats(domain, since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// IngestTLSReport stores a TLS-RPT report forwarded by the inbound mail pipeline:
+// either the whole report email or the report file itself (JSON or gzip)
+func (h *MTASTSHandler) IngestTLSReport(c *gin.Context) {
+	ingestInboundReport(c, h.tlsReportService.IngestMessage, h.tlsReportService.IngestFile)
+}
+
+func (h *MTASTSHandler) handleError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "custom domain is not verified":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "MTA-STS is not enabled":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "no inbound MX hosts are configured":
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
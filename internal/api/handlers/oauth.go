@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+type OAuthHandler struct {
+	oauthService *services.OAuthService
+}
+
+func NewOAuthHandler(oauthService *services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+	}
+}
+
+// Token implements POST /oauth/token for the client_credentials grant (RFC 6749 section 4.4).
+// Clients authenticate with HTTP Basic or with client_id/client_secret form parameters.
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if c.PostForm("grant_type") != "client_credentials" {
+		if c.PostForm("grant_type") == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+			return
+		}
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if ok {
+		// Basic credentials are form-urlencoded before being base64 encoded
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	if clientID == "" || clientSecret == "" {
+		c.Header("WWW-Authenticate", `Basic realm="maylng"`)
+		oauthError(c, http.StatusUnauthorized, services.OAuthErrInvalidClient, "client authentication is required")
+		return
+	}
+
+	token, err := h.oauthService.IssueToken(clientID, clientSecret, c.PostForm("scope"))
+	if err != nil {
+		switch err.Error() {
+		case services.OAuthErrInvalidClient:
+			c.Header("WWW-Authenticate", `Basic realm="maylng"`)
+			oauthError(c, http.StatusUnauthorized, services.OAuthErrInvalidClient, "client authentication failed")
+		case services.OAuthErrInvalidScope:
+			oauthError(c, http.StatusBadRequest, services.OAuthErrInvalidScope, "the requested scope is not granted to this client")
+		case services.OAuthErrUnauthorizedClient:
+			oauthError(c, http.StatusBadRequest, services.OAuthErrUnauthorizedClient, "the client's account is not active")
+		default:
+			oauthError(c, http.StatusInternalServerError, "server_error", "failed to issue token")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// CreateClient registers an OAuth client; the secret is only returned here
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	var req models.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var memberID *uuid.UUID
+	if id, ok := middleware.GetMemberIDFromContext(c); ok {
+		memberID = &id
+	}
+
+	callerScopes, _ := middleware.GetScopesFromContext(c)
+	client, err := h.oauthService.CreateClient(accountID, memberID, callerScopes, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, client)
+}
+
+// ListClients lists the account's OAuth clients
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	clients, err := h.oauthService.ListClients(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"oauth_clients": clients})
+}
+
+// RevokeClient revokes an OAuth client and every token it issued
+func (h *OAuthHandler) RevokeClient(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth client ID"})
+		return
+	}
+
+	if err := h.oauthService.RevokeClient(accountID, id); err != nil {
+		if err.Error() == "OAuth client not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{
+		"error":             code,
+		"error_description": description,
+	})
+}
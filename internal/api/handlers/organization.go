@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+	apiKeyService       *services.APIKeyService
+}
+
+func NewOrganizationHandler(organizationService *services.OrganizationService, apiKeyService *services.APIKeyService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+		apiKeyService:       apiKeyService,
+	}
+}
+
+// GetOrganization returns the organization that owns the account
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	org, err := h.organizationService.GetOrganization(accountID)
+	if err != nil {
+		if err.Error() == "organization not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// UpdateOrganization renames the organization
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if before, err := h.organizationService.GetOrganization(accountID); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	org, err := h.organizationService.UpdateOrganization(accountID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// ListMembers lists the organization's members
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	members, err := h.organizationService.ListMembers(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// AddMember adds a member with a role
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	var req models.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorRole, _ := middleware.GetMemberRoleFromContext(c)
+	member, err := h.organizationService.AddMember(accountID, actorRole, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// UpdateMember changes a member's role
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if before, err := h.organizationService.GetMember(accountID, memberID); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	actorRole, _ := middleware.GetMemberRoleFromContext(c)
+	member, err := h.organizationService.UpdateMemberRole(accountID, actorRole, memberID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a member and revokes their API keys
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	if before, err := h.organizationService.GetMember(accountID, memberID); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	actorRole, _ := middleware.GetMemberRoleFromContext(c)
+	if err := h.organizationService.RemoveMember(accountID, actorRole, memberID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// CreateMemberAPIKey issues an API key that acts for the given member. The key can hold
+// at most the scopes of both the calling key and the member's role.
+func (h *OrganizationHandler) CreateMemberAPIKey(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := h.organizationService.GetMember(accountID, memberID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	actorRole, _ := middleware.GetMemberRoleFromContext(c)
+	if member.Role == models.RoleOwner && actorRole != "" && actorRole != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only owners can manage owners"})
+		return
+	}
+
+	callerScopes, _ := middleware.GetScopesFromContext(c)
+	grantable := models.IntersectScopes(callerScopes, models.RolePermissions[member.Role])
+	key, err := h.apiKeyService.CreateAPIKey(accountID, &member.ID, grantable, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *OrganizationHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case err.Error() == "organization not found" || err.Error() == "member not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err.Error() == "only owners can manage owners":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err.Error() == "member already exists" || err.Error() == "organization must keep at least one owner":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid") || strings.HasPrefix(err.Error(), "name must"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+type PlanHandler struct {
+	planService *services.PlanService
+}
+
+func NewPlanHandler(planService *services.PlanService) *PlanHandler {
+	return &PlanHandler{
+		planService: planService,
+	}
+}
+
+// GetLimits returns the calling account's plan and effective limits
+func (h *PlanHandler) GetLimits(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	limits, err := h.planService.GetAccountLimits(accountID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+// GET /v1/admin/plans
+func (h *PlanHandler) ListPlans(c *gin.Context) {
+	plans, err := h.planService.ListPlans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// PUT /v1/admin/plans/:id
+func (h *PlanHandler) UpsertPlan(c *gin.Context) {
+	var req models.UpsertPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if before, err := h.planService.GetPlan(c.Param("id")); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	plan, err := h.planService.UpsertPlan(c.Param("id"), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// GET /v1/admin/users/:id/limits
+func (h *PlanHandler) GetAccountLimits(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	limits, err := h.planService.GetAccountLimits(id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+// PUT /v1/admin/users/:id/plan
+func (h *PlanHandler) ChangeAccountPlan(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req models.ChangeAccountPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if before, err := h.planService.GetAccountLimits(id); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	limits, err := h.planService.ChangeAccountPlan(id, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+// PUT /v1/admin/users/:id/limits
+func (h *PlanHandler) SetLimitOverrides(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var overrides models.LimitOverrides
+	if err := c.ShouldBindJSON(&overrides); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if before, err := h.planService.GetAccountLimits(id); err == nil {
+		middleware.SetAuditBefore(c, before)
+	}
+
+	limits, err := h.planService.SetLimitOverrides(id, &overrides)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+func (h *PlanHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case err.Error() == "account not found" || err.Error() == "plan not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid") || strings.HasPrefix(err.Error(), "name must"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// RequestMagicLink emails a sign-in link. It responds the same way whether or not the
+// address belongs to a member.
+func (h *SessionHandler) RequestMagicLink(c *gin.Context) {
+	var req models.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.sessionService.RequestMagicLink(req.Email, c.ClientIP()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process sign-in request"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If this address belongs to an organization member, a sign-in link has been sent"})
+}
+
+// VerifyMagicLink exchanges a sign-in link token for session tokens
+func (h *SessionHandler) VerifyMagicLink(c *gin.Context) {
+	var req models.VerifyMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.sessionService.VerifyMagicLink(req.Token, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if err.Error() == "invalid or expired login link" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RefreshSession rotates the refresh token and issues a new access token
+func (h *SessionHandler) RefreshSession(c *gin.Context) {
+	var req models.RefreshSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.sessionService.RefreshSession(req.RefreshToken)
+	if err != nil {
+		if err.Error() == "invalid refresh token" || err.Error() == "session has ended" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the current session, or all of the member's sessions
+func (h *SessionHandler) Logout(c *gin.Context) {
+	sessionID, exists := middleware.GetSessionIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Logout requires a session access token"})
+		return
+	}
+	memberID, _ := middleware.GetMemberIDFromContext(c)
+
+	var req models.LogoutRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var err error
+	if req.All {
+		err = h.sessionService.RevokeAllSessions(memberID)
+	} else {
+		err = h.sessionService.RevokeSession(memberID, sessionID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListSessions lists the calling member's active sessions
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	memberID, exists := middleware.GetMemberIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sessions belong to organization members; this credential has no member"})
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if currentID, ok := middleware.GetSessionIDFromContext(c); ok {
+		for _, session := range sessions {
+			session.Current = session.ID == currentID
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession ends one of the calling member's sessions
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	memberID, exists := middleware.GetMemberIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sessions belong to organization members; this credential has no member"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(memberID, sessionID); err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
 	"github.com/maylng/backend/internal/models"
 	"github.com/maylng/backend/internal/services"
 )
@@ -62,18 +64,11 @@ func (h *TPSHandler) CreateTPS(c *gin.Context) {
 		return
 	}
 
-	// Get account plan for limit enforcement
-	account, err := h.accountService.GetAccount(accountID.(uuid.UUID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get account information"})
-		return
-	}
-
-	// Create TPS
-	tps, err := h.tpsService.CreateTPS(account.Plan, &req)
+	// Create TPS; the account's plan limits are enforced by the service
+	tps, err := h.tpsService.CreateTPS(accountID.(uuid.UUID), &req)
 	if err != nil {
 		// Check if it's a plan limit error
-		if err.Error() == "TPS limit reached for this agent email address" {
+		if strings.HasPrefix(err.Error(), "TPS limit reached for this agent email address") {
 			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
 			return
 		}
@@ -189,6 +184,8 @@ func (h *TPSHandler) UpdateTPS(c *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditBefore(c, existingTPS)
+
 	// Update TPS
 	updatedTPS, err := h.tpsService.UpdateTPS(tpsID, &req)
 	if err != nil {
@@ -229,6 +226,8 @@ func (h *TPSHandler) DeleteTPS(c *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditBefore(c, existingTPS)
+
 	// Delete TPS
 	if err := h.tpsService.DeleteTPS(tpsID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -237,3 +236,53 @@ func (h *TPSHandler) DeleteTPS(c *gin.Context) {
 
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// GetTPSSecrets returns the decrypted API key and password of a TPS integration
+func (h *TPSHandler) GetTPSSecrets(c *gin.Context) {
+	// Extract account ID from authentication middleware
+	accountID, exists := c.Get("account_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account ID not found"})
+		return
+	}
+
+	// Parse TPS ID from URL
+	tpsIDStr := c.Param("tps_id")
+	tpsID, err := uuid.Parse(tpsIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid TPS ID"})
+		return
+	}
+
+	// Verify TPS belongs to the authenticated account
+	existingTPS, err := h.tpsService.GetTPS(tpsID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "TPS not found"})
+		return
+	}
+
+	_, err = h.emailAddressService.GetEmailAddress(accountID.(uuid.UUID), existingTPS.EmailAddressID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "TPS does not belong to your account"})
+		return
+	}
+
+	apiKey, err := h.tpsService.GetDecryptedAPIKey(tpsID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	password, err := h.tpsService.GetDecryptedPassword(tpsID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       tpsID,
+		"api_key":  apiKey,
+		"username": existingTPS.Username,
+		"password": password,
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+type UsageHandler struct {
+	usageService *services.UsageService
+}
+
+func NewUsageHandler(usageService *services.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+	}
+}
+
+// GetUsage returns the account's daily usage for a date range
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	accountID, exists := middleware.GetAccountIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found in context"})
+		return
+	}
+
+	filter, err := parseUsageFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	usage, err := h.usageService.GetUsage(accountID, filter)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// GET /v1/admin/usage/export
+func (h *UsageHandler) ExportUsage(c *gin.Context) {
+	filter, err := parseUsageFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if accountStr := c.Query("account_id"); accountStr != "" {
+		accountID, err := uuid.Parse(accountStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		filter.AccountID = &accountID
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "stripe" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format: must be csv or stripe"})
+		return
+	}
+
+	records, err := h.usageService.ListUsageRecords(filter)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if format == "stripe" {
+		usageRecords, err := services.ToStripeUsageRecords(records)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"usage_records": usageRecords})
+		return
+	}
+
+	filename := "usage-" + filter.From.Format(services.UsageDateLayout) + "-" + filter.To.Format(services.UsageDateLayout) + ".csv"
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+	if err := services.WriteUsageCSV(c.Writer, records); err != nil {
+		c.Error(err)
+	}
+}
+
+func parseUsageFilter(c *gin.Context) (*models.UsageFilter, error) {
+	from, to, err := services.ParseUsageRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return nil, err
+	}
+	return &models.UsageFilter{From: from, To: to, Metric: c.Query("metric")}, nil
+}
+
+func (h *UsageHandler) handleError(c *gin.Context, err error) {
+	if strings.HasPrefix(err.Error(), "invalid") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+	"github.com/maylng/backend/internal/services"
+)
+
+// auditRedactedFields are never written to the audit log
+var auditRedactedFields = map[string]bool{
+	"api_key":       true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"client_secret": true,
+}
+
+// bodyCaptureWriter keeps a copy of the response body
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Audit records an audit event for the route once it succeeds. The action is
+// "<resource_type>.<verb>". The JSON response becomes the event's "after" state;
+// handlers supply the "before" state with SetAuditBefore. Secret fields are redacted.
+func Audit(auditService *services.AuditService, action string) gin.HandlerFunc {
+	resourceType, _, _ := strings.Cut(action, ".")
+
+	return func(c *gin.Context) {
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+
+		c.Next()
+
+		if c.Writer.Status() >= 400 {
+			return
+		}
+
+		after := redactJSON(writer.body.Bytes())
+		if override, exists := c.Get("audit_after"); exists {
+			after = marshalRedacted(override)
+		}
+
+		event := &models.AuditEvent{
+			Action:       action,
+			ResourceType: resourceType,
+			ResourceID:   auditResourceID(c, after),
+			After:        after,
+		}
+
+		if before, exists := c.Get("audit_before"); exists {
+			event.Before = marshalRedacted(before)
+		}
+
+		// Unauthenticated routes (platform account creation) log to the created account
+		if accountID, ok := GetAccountIDFromContext(c); ok {
+			event.AccountID = accountID
+		} else if id := jsonField(after, "id"); id != "" {
+			if parsed, err := uuid.Parse(id); err == nil {
+				event.AccountID = parsed
+			}
+		}
+
+		if keyID, ok := GetAPIKeyIDFromContext(c); ok {
+			event.ActorAPIKeyID = &keyID
+		}
+		if memberID, ok := GetMemberIDFromContext(c); ok {
+			event.ActorMemberID = &memberID
+		}
+		if clientID, ok := GetOAuthClientIDFromContext(c); ok {
+			event.ActorOAuthClientID = &clientID
+		}
+		if ip := c.ClientIP(); ip != "" {
+			event.IPAddress = &ip
+		}
+		if userAgent := c.Request.UserAgent(); userAgent != "" {
+			event.UserAgent = &userAgent
+		}
+		if requestID := GetRequestIDFromContext(c); requestID != "" {
+			event.RequestID = &requestID
+		}
+
+		if err := auditService.Record(event); err != nil {
+			log.Printf("Failed to record audit event %s: %v", action, err)
+		}
+	}
+}
+
+// SetAuditBefore records the resource's state before a mutation
+func SetAuditBefore(c *gin.Context, before interface{}) {
+	c.Set("audit_before", before)
+}
+
+// SetAuditAfter overrides the response body as the resource's state after a mutation
+func SetAuditAfter(c *gin.Context, after interface{}) {
+	c.Set("audit_after", after)
+}
+
+func auditResourceID(c *gin.Context, after json.RawMessage) *string {
+	for _, param := range []string{"id", "tps_id"} {
+		if id := c.Param(param); id != "" {
+			return &id
+		}
+	}
+	if id := jsonField(after, "id"); id != "" {
+		return &id
+	}
+	return nil
+}
+
+func jsonField(raw json.RawMessage, field string) string {
+	var object map[string]interface{}
+	if json.Unmarshal(raw, &object) != nil {
+		return ""
+	}
+	value, _ := object[field].(string)
+	return value
+}
+
+func marshalRedacted(v interface{}) json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return redactJSON(raw)
+}
+
+// redactJSON replaces secret fields at any depth. Non-JSON bodies are dropped.
+func redactJSON(raw []byte) json.RawMessage {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil
+	}
+
+	redacted, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if auditRedactedFields[key] && field != nil {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
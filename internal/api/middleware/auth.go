@@ -3,14 +3,23 @@ package middleware
 import (
 	"database/sql"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/maylng/backend/internal/auth"
 	"github.com/maylng/backend/internal/models"
 )
 
-func AuthMiddleware(db *sql.DB, salt string) gin.HandlerFunc {
+// AuthMiddleware authenticates requests by API key or by dashboard session token.
+// Keys formatted maylng_<keyid>_<secret> are looked up by key ID and verified against an
+// HMAC of the secret. Keys issued before key IDs existed are looked up by their legacy
+// salted hash and migrated to an HMAC of the whole key on first use, after which the
+// legacy salt is no longer needed for them. Session tokens are JWTs signed with
+// jwtSecret that name a live session.
+func AuthMiddleware(db *sql.DB, hasher *auth.KeyHasher, jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -19,60 +28,390 @@ func AuthMiddleware(db *sql.DB, salt string) gin.HandlerFunc {
 			return
 		}
 
-		apiKey, err := auth.ExtractAPIKey(authHeader)
+		credential, err := auth.ExtractAPIKey(authHeader)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
 			return
 		}
 
-		// Hash the API key to look up in database
-		hashedKey := auth.HashAPIKey(apiKey, salt)
-
-		// Look up account by hashed API key
-		var account models.Account
-		query := `
-			SELECT id, api_key_hash, plan, email_limit_per_month, email_address_limit, created_at, updated_at
-			FROM accounts WHERE api_key_hash = $1
-		`
-		err = db.QueryRow(query, hashedKey).Scan(
-			&account.ID,
-			&account.APIKeyHash,
-			&account.Plan,
-			&account.EmailLimitPerMonth,
-			&account.EmailAddressLimit,
-			&account.CreatedAt,
-			&account.UpdatedAt,
-		)
+		if auth.LooksLikeJWT(credential) {
+			authenticateToken(c, db, jwtSecret, credential)
+			return
+		}
+
+		authenticateAPIKey(c, db, hasher, credential)
+	}
+}
 
-		if err == sql.ErrNoRows {
+func authenticateAPIKey(c *gin.Context, db *sql.DB, hasher *auth.KeyHasher, apiKey string) {
+	keyID, secret, hasKeyID := auth.ParseAPIKey(apiKey)
+
+	// Look up the key and its account
+	var account models.Account
+	var key models.APIKey
+	var memberRole *string
+	var organizationID *uuid.UUID
+	query := `
+		SELECT k.id, k.key_hash, k.pepper_version, k.scopes, k.allowed_cidrs, k.allowed_origins,
+		       k.expires_at, k.revoked_at, k.member_id, m.role, o.id,
+		       a.id, a.plan, a.email_limit_per_month, a.email_address_limit, a.status, a.created_at, a.updated_at
+		FROM api_keys k
+		JOIN accounts a ON a.id = k.account_id
+		LEFT JOIN organizations o ON o.account_id = a.id
+		LEFT JOIN organization_members m ON m.id = k.member_id
+	`
+	var lookup interface{}
+	if hasKeyID {
+		query += "WHERE k.key_id = $1"
+		lookup = keyID
+	} else {
+		query += "WHERE k.key_hash = ANY($1) AND k.key_id IS NULL"
+		lookup = pq.Array(hasher.LegacyLookupHashes(apiKey))
+	}
+
+	err := db.QueryRow(query, lookup).Scan(
+		&key.ID,
+		&key.KeyHash,
+		&key.PepperVersion,
+		pq.Array(&key.Scopes),
+		pq.Array(&key.AllowedCIDRs),
+		pq.Array(&key.AllowedOrigins),
+		&key.ExpiresAt,
+		&key.RevokedAt,
+		&key.MemberID,
+		&memberRole,
+		&organizationID,
+		&account.ID,
+		&account.Plan,
+		&account.EmailLimitPerMonth,
+		&account.EmailAddressLimit,
+		&account.Status,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		c.Abort()
+		return
+	}
+
+	if hasKeyID {
+		if key.PepperVersion == nil || !hasher.Verify(secret, key.KeyHash, *key.PepperVersion) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
 			c.Abort()
 			return
 		}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		// Re-hash under the current pepper so old peppers can eventually be retired
+		if hasher.NeedsRehash(*key.PepperVersion) {
+			newHash, version := hasher.Hash(secret)
+			db.Exec(
+				"UPDATE api_keys SET key_hash = $1, pepper_version = $2 WHERE id = $3 AND pepper_version = $4",
+				newHash, version, key.ID, *key.PepperVersion,
+			)
+		}
+	} else if key.PepperVersion == nil || hasher.NeedsRehash(*key.PepperVersion) {
+		// Move legacy keys off the salted hash, or onto the current pepper
+		newHash, version := hasher.Hash(apiKey)
+		db.Exec(
+			"UPDATE api_keys SET key_hash = $1, pepper_version = $2 WHERE id = $3 AND key_hash = $4",
+			newHash, version, key.ID, key.KeyHash,
+		)
+	}
+
+	if key.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key has been revoked"})
+		c.Abort()
+		return
+	}
+
+	if !key.IsActive(time.Now()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key has expired"})
+		c.Abort()
+		return
+	}
+
+	if !requireActiveAccount(c, &account) {
+		return
+	}
+
+	// Restricted keys only work from their allowlisted networks and browser origins.
+	// A key with allowed origins is meant for browsers, so requests without an Origin fail too.
+	if !auth.IPAllowed(key.AllowedCIDRs, c.ClientIP()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed from this IP address"})
+		c.Abort()
+		return
+	}
+	if len(key.AllowedOrigins) > 0 && !auth.OriginAllowed(key.AllowedOrigins, c.GetHeader("Origin")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed from this origin"})
+		c.Abort()
+		return
+	}
+
+	// A member's key never exceeds the member's current role
+	if key.MemberID != nil {
+		if memberRole == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key belongs to a removed organization member"})
 			c.Abort()
 			return
 		}
+		key.Scopes = models.IntersectScopes(key.Scopes, models.RolePermissions[*memberRole])
+	}
 
-		// Store account in context
-		c.Set("account", account)
-		c.Set("account_id", account.ID)
-		c.Next()
+	// Record usage, at most once a minute per key to keep writes cheap
+	db.Exec(
+		"UPDATE api_keys SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')",
+		key.ID,
+	)
+
+	// Store account and key in context
+	c.Set("account", account)
+	c.Set("account_id", account.ID)
+	c.Set("api_key_id", key.ID)
+	c.Set("api_key_scopes", key.Scopes)
+	if organizationID != nil {
+		c.Set("organization_id", *organizationID)
+	}
+	if key.MemberID != nil {
+		c.Set("member_id", *key.MemberID)
+		c.Set("member_role", *memberRole)
 	}
+	c.Next()
 }
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+// authenticateToken authenticates a JWT access token: a dashboard session token or an
+// OAuth client_credentials token. The session or client behind the token is checked on
+// every request so logout and revocation take effect immediately.
+func authenticateToken(c *gin.Context, db *sql.DB, jwtSecret string, token string) {
+	claims, err := auth.ParseToken(token, jwtSecret, time.Now())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+		c.Abort()
+		return
+	}
+
+	switch claims.TokenType {
+	case auth.TokenTypeSession:
+		authenticateSession(c, db, claims)
+	case auth.TokenTypeClient:
+		authenticateClient(c, db, claims)
+	default:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+		c.Abort()
+	}
+}
+
+// authenticateSession authenticates a dashboard session; the member's current role
+// decides the scopes, short of admin.
+func authenticateSession(c *gin.Context, db *sql.DB, claims *auth.TokenClaims) {
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+		c.Abort()
+		return
+	}
+
+	var account models.Account
+	var memberID, organizationID uuid.UUID
+	var memberRole string
+	var expiresAt time.Time
+	var revokedAt *time.Time
+	query := `
+		SELECT s.expires_at, s.revoked_at, m.id, m.role, o.id,
+		       a.id, a.plan, a.email_limit_per_month, a.email_address_limit, a.status, a.created_at, a.updated_at
+		FROM sessions s
+		JOIN organization_members m ON m.id = s.member_id
+		JOIN organizations o ON o.id = m.organization_id
+		JOIN accounts a ON a.id = o.account_id
+		WHERE s.id = $1
+	`
+	err = db.QueryRow(query, sessionID).Scan(
+		&expiresAt,
+		&revokedAt,
+		&memberID,
+		&memberRole,
+		&organizationID,
+		&account.ID,
+		&account.Plan,
+		&account.EmailLimitPerMonth,
+		&account.EmailAddressLimit,
+		&account.Status,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session not found"})
+		c.Abort()
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		c.Abort()
+		return
+	}
+
+	if memberID.String() != claims.Subject || account.ID.String() != claims.AccountID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+		c.Abort()
+		return
+	}
+
+	if revokedAt != nil || !expiresAt.After(time.Now().UTC()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+		c.Abort()
+		return
+	}
+
+	if !requireActiveAccount(c, &account) {
+		return
+	}
+
+	c.Set("account", account)
+	c.Set("account_id", account.ID)
+	// Record activity, at most once a minute per session
+	db.Exec(
+		"UPDATE sessions SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')",
+		sessionID,
+	)
+
+	// Sessions never carry the admin scope; admin operations need an admin API key
+	c.Set("api_key_scopes", models.IntersectScopes(models.RolePermissions[memberRole], models.DefaultAPIKeyScopes))
+	c.Set("organization_id", organizationID)
+	c.Set("member_id", memberID)
+	c.Set("member_role", memberRole)
+	c.Set("session_id", sessionID)
+	c.Next()
+}
+
+// authenticateClient authenticates an OAuth client token. The request gets the scopes
+// granted in the token that the client (and its member's role, if any) still holds.
+func authenticateClient(c *gin.Context, db *sql.DB, claims *auth.TokenClaims) {
+	clientID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+		c.Abort()
+		return
+	}
+
+	var account models.Account
+	var client models.OAuthClient
+	var memberRole *string
+	var organizationID *uuid.UUID
+	query := `
+		SELECT c.scopes, c.revoked_at, c.member_id, m.role, o.id,
+		       a.id, a.plan, a.email_limit_per_month, a.email_address_limit, a.status, a.created_at, a.updated_at
+		FROM oauth_clients c
+		JOIN accounts a ON a.id = c.account_id
+		LEFT JOIN organizations o ON o.account_id = a.id
+		LEFT JOIN organization_members m ON m.id = c.member_id
+		WHERE c.id = $1
+	`
+	err = db.QueryRow(query, clientID).Scan(
+		pq.Array(&client.Scopes),
+		&client.RevokedAt,
+		&client.MemberID,
+		&memberRole,
+		&organizationID,
+		&account.ID,
+		&account.Plan,
+		&account.EmailLimitPerMonth,
+		&account.EmailAddressLimit,
+		&account.Status,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+		c.Abort()
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		c.Abort()
+		return
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+	if account.ID.String() != claims.AccountID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+		c.Abort()
+		return
+	}
+
+	if client.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "OAuth client has been revoked"})
+		c.Abort()
+		return
+	}
+
+	if !requireActiveAccount(c, &account) {
+		return
+	}
+
+	scopes := models.IntersectScopes(strings.Fields(claims.Scope), client.Scopes)
+	if client.MemberID != nil {
+		if memberRole == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "OAuth client belongs to a removed organization member"})
+			c.Abort()
+			return
+		}
+		scopes = models.IntersectScopes(scopes, models.RolePermissions[*memberRole])
+	}
+
+	c.Set("account", account)
+	c.Set("account_id", account.ID)
+	c.Set("api_key_scopes", scopes)
+	c.Set("oauth_client_id", clientID)
+	if organizationID != nil {
+		c.Set("organization_id", *organizationID)
+	}
+	if client.MemberID != nil {
+		c.Set("member_id", *client.MemberID)
+		c.Set("member_role", *memberRole)
+	}
+	c.Next()
+}
+
+// requireActiveAccount rejects requests for suspended or closed accounts with an error
+// code clients can act on. Credentials are checked first so the status of an account is
+// only revealed to its own callers.
+func requireActiveAccount(c *gin.Context, account *models.Account) bool {
+	switch account.Status {
+	case models.AccountStatusActive:
+		return true
+	case models.AccountStatusSuspended:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is suspended", "code": "account_suspended"})
+	case models.AccountStatusClosed:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is closed", "code": "account_closed"})
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is not active", "code": "account_inactive"})
+	}
+	c.Abort()
+	return false
+}
+
+// RequireScope returns middleware that rejects requests whose API key lacks the given scope.
+// It must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, _ := GetScopesFromContext(c)
+		if !models.HasScope(scopes, scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":          "API key is missing the required scope",
+				"required_scope": scope,
+			})
+			c.Abort()
 			return
 		}
 
@@ -97,3 +436,71 @@ func GetAccountIDFromContext(c *gin.Context) (uuid.UUID, bool) {
 	id, ok := accountID.(uuid.UUID)
 	return id, ok
 }
+
+func GetAPIKeyIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	keyID, exists := c.Get("api_key_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := keyID.(uuid.UUID)
+	return id, ok
+}
+
+// GetOAuthClientIDFromContext returns the OAuth client of a client_credentials token
+func GetOAuthClientIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	clientID, exists := c.Get("oauth_client_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := clientID.(uuid.UUID)
+	return id, ok
+}
+
+func GetScopesFromContext(c *gin.Context) ([]string, bool) {
+	scopes, exists := c.Get("api_key_scopes")
+	if !exists {
+		return nil, false
+	}
+	s, ok := scopes.([]string)
+	return s, ok
+}
+
+func GetOrganizationIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	orgID, exists := c.Get("organization_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := orgID.(uuid.UUID)
+	return id, ok
+}
+
+// GetMemberIDFromContext returns the organization member the API key acts for, if any
+func GetMemberIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	memberID, exists := c.Get("member_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := memberID.(uuid.UUID)
+	return id, ok
+}
+
+// GetMemberRoleFromContext returns the role of the member the API key acts for.
+// Keys not tied to a member act for the account holder and report no role.
+func GetMemberRoleFromContext(c *gin.Context) (string, bool) {
+	role, exists := c.Get("member_role")
+	if !exists {
+		return "", false
+	}
+	r, ok := role.(string)
+	return r, ok
+}
+
+// GetSessionIDFromContext returns the dashboard session of the request, if it used one
+func GetSessionIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := sessionID.(uuid.UUID)
+	return id, ok
+}
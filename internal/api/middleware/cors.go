@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maylng/backend/internal/auth"
+)
+
+// CORSConfig is the cross-origin policy for browser clients
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://app.example.com". "*" allows every
+	// origin, "https://*.example.com" every subdomain, and an empty list none.
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and HTTP auth. It is never combined
+	// with "*": wildcard origins always get an anonymous response.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// CORSMiddleware answers preflight requests and adds CORS headers for allowed origins.
+// Disallowed origins get no CORS headers, so the browser blocks the response.
+func CORSMiddleware(cfg CORSConfig) gin.HandlerFunc {
+	allowAny := false
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		if origin != "" && len(cfg.AllowedOrigins) > 0 && auth.OriginAllowed(cfg.AllowedOrigins, origin) {
+			if allowAny {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+				if cfg.AllowCredentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+			header.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+			header.Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+			header.Set("Access-Control-Expose-Headers", "X-Request-ID")
+			if cfg.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InboundTokenMiddleware guards the endpoints that receive reports forwarded by the
+// inbound mail pipeline. Callers send the shared secret in the X-Inbound-Token header.
+func InboundTokenMiddleware(expectedToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if expectedToken == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "inbound token not configured"})
+			c.Abort()
+			return
+		}
+
+		token := c.GetHeader("X-Inbound-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expectedToken)) != 1 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid inbound token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware caps each account's API calls at its plan's api_calls_per_hour,
+// counted in fixed one-hour windows in the rate_limits table. It must run after
+// AuthMiddleware. If the count can't be read the request is let through rather than
+// failing the API.
+func RateLimitMiddleware(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, exists := GetAccountIDFromContext(c)
+		if !exists {
+			c.Next()
+			return
+		}
+
+		windowStart := time.Now().UTC().Truncate(time.Hour)
+		windowEnd := windowStart.Add(time.Hour)
+		key := fmt.Sprintf("api:%s:%d", accountID, windowStart.Unix())
+
+		var count, limit int
+		query := `
+			WITH hit AS (
+				INSERT INTO rate_limits (key, count, window_start, expires_at)
+				VALUES ($1, 1, $2, $3)
+				ON CONFLICT (key) DO UPDATE SET count = rate_limits.count + 1
+				RETURNING count
+			)
+			SELECT hit.count, COALESCE((a.limit_overrides->>'api_calls_per_hour')::int, p.api_calls_per_hour)
+			FROM hit, accounts a
+			JOIN plans p ON p.id = a.plan
+			WHERE a.id = $4
+		`
+		if err := db.QueryRow(query, key, windowStart, windowEnd, accountID).Scan(&count, &limit); err != nil {
+			log.Printf("Failed to check rate limit for account %s: %v", accountID, err)
+			c.Next()
+			return
+		}
+
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(windowEnd.Unix(), 10))
+
+		if count > limit {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(windowEnd).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "API rate limit exceeded"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
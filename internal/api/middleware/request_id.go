@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware tags every request with an ID, reusing a well-formed X-Request-ID
+// from the caller, and echoes it in the response so logs and audit events can be correlated.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set("request_id", requestID)
+		c.Writer.Header().Set(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func GetRequestIDFromContext(c *gin.Context) string {
+	return c.GetString("request_id")
+}
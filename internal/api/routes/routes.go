@@ -2,23 +2,52 @@ package routes
 
 import (
 	"database/sql"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/maylng/backend/internal/api/handlers"
 	"github.com/maylng/backend/internal/api/middleware"
+	"github.com/maylng/backend/internal/auth"
 	"github.com/maylng/backend/internal/config"
 	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/models"
 	"github.com/maylng/backend/internal/services"
 	"github.com/redis/go-redis/v9"
 )
 
 func SetupRoutes(router *gin.Engine, cfg *config.Config, db *sql.DB, redisClient *redis.Client, emailService *email.Service) {
+	keyHasher, err := auth.NewKeyHasher(cfg.APIKeyPeppers, cfg.APIKeyPepperVersion, cfg.APIKeyHashSalt)
+	if err != nil {
+		log.Fatalf("Invalid API key pepper configuration: %v", err)
+	}
+
 	// Initialize services
-	accountService := services.NewAccountService(db, cfg.APIKeyHashSalt)
+	accountService := services.NewAccountService(db, keyHasher)
+	apiKeyService := services.NewAPIKeyService(db, keyHasher, time.Duration(cfg.APIKeyRotationGraceHours)*time.Hour)
+	organizationService := services.NewOrganizationService(db)
+	planService := services.NewPlanService(db)
+	usageService := services.NewUsageService(db)
+	auditService := services.NewAuditService(db)
+	sessionService := services.NewSessionService(db, emailService, cfg)
+	oauthService := services.NewOAuthService(db, keyHasher, cfg.JWTSecret, time.Duration(cfg.OAuthTokenTTLMinutes)*time.Minute)
 	emailAddressService := services.NewEmailAddressService(db, cfg)
-	emailSvc := services.NewEmailService(db, emailService)
 	customDomainService := services.NewCustomDomainService(db)
+
+	// DNS checks use the system resolver unless a nameserver is configured
+	var dnsResolver services.Resolver
+	if cfg.DNSResolverAddress != "" {
+		dnsResolver = services.NewServerResolver(cfg.DNSResolverAddress, cfg.DNSResolverNetwork, 5*time.Second)
+	}
+	emailTrackingService := services.NewEmailTrackingService(db, cfg.TrackingBaseURL, cfg.TrackingSecret)
+	domainSettingsService := services.NewDomainSettingsService(db, emailTrackingService)
 	tpsService := services.NewTPSService(db, cfg.TPSEncryptionKey)
+	dmarcReportService := services.NewDMARCReportService(db, customDomainService)
+	mtaSTSService := services.NewMTASTSService(db, customDomainService, cfg.MTASTSPolicyHost, cfg.InboundMXHosts, cfg.TLSReportAddress)
+	catchAllService := services.NewCatchAllService(db, customDomainService)
+	tlsReportService := services.NewTLSReportService(db, customDomainService)
+	dnsProvisioningService := services.NewDNSProvisioningService(db, customDomainService, cfg.DNSProviderEncryptionKey)
+	accountExportService := services.NewAccountExportService(db, accountService, emailAddressService, customDomainService, tpsService)
 
 	// Initialize SES verification service
 	var sesVerificationService *services.SESVerificationService
@@ -42,90 +71,212 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, db *sql.DB, redisClient
 		}
 	}
 
-	// Determine default verification provider based on email provider configuration
+	// Register the configured verification providers. New domains default to the one
+	// matching the email provider, falling back to SES for backward compatibility.
+	var verificationProviders []services.DomainVerificationProvider
+	if sesVerificationService != nil {
+		verificationProviders = append(verificationProviders, sesVerificationService)
+	}
+	if resendVerificationService != nil {
+		verificationProviders = append(verificationProviders, resendVerificationService)
+	}
 	defaultVerificationProvider := "ses"
-	if cfg.EmailProvider == "resend" && resendVerificationService != nil {
+	if cfg.EmailProvider == "resend" {
 		defaultVerificationProvider = "resend"
 	}
+	domainVerificationService := services.NewDomainVerificationService(customDomainService, defaultVerificationProvider, verificationProviders...)
+	dkimService := services.NewDKIMService(db, customDomainService, domainVerificationService, dnsResolver, cfg.DKIMEncryptionKey, time.Duration(cfg.DKIMKeyRotationDays)*24*time.Hour)
+	emailSvc := services.NewEmailService(db, emailService, dkimService, domainSettingsService, cfg.BlockDegradedDomains)
+	accountDeletionService := services.NewAccountDeletionService(db, customDomainService, domainVerificationService, time.Duration(cfg.AccountDeletionCoolingOffDays)*24*time.Hour)
 
 	// Initialize DNS validation service
-	dnsValidationService := services.NewDNSValidationService()
+	dnsValidationService := services.NewDNSValidationService(dnsResolver, cfg.DNSPublicResolvers, cfg.InboundMXHosts, cfg.DMARCReportAddress)
+	domainHealthService := services.NewDomainHealthService(
+		db, customDomainService, dnsValidationService, auditService, emailService, cfg.AuthEmailFrom,
+		cfg.DomainBlocklists, cfg.DomainHealthMinScore, time.Duration(cfg.DomainHealthCheckIntervalHours)*time.Hour,
+	)
 
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler()
 	accountHandler := handlers.NewAccountHandler(accountService)
+	accountDataHandler := handlers.NewAccountDataHandler(accountExportService, accountDeletionService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	planHandler := handlers.NewPlanHandler(planService)
+	usageHandler := handlers.NewUsageHandler(usageService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, apiKeyService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
 	emailAddressHandler := handlers.NewEmailAddressHandler(emailAddressService)
 	emailHandler := handlers.NewEmailHandler(emailSvc)
-	customDomainHandler := handlers.NewCustomDomainHandler(
-		customDomainService,
-		nil, // domain verification service - we'll implement later
-		sesVerificationService,
-		resendVerificationService,
-		dnsValidationService,
-		defaultVerificationProvider,
-	)
+	customDomainHandler := handlers.NewCustomDomainHandler(customDomainService, domainVerificationService, dnsValidationService)
+	dkimHandler := handlers.NewDKIMHandler(customDomainService, dkimService)
+	dmarcHandler := handlers.NewDMARCHandler(customDomainService, dmarcReportService)
+	mtaSTSHandler := handlers.NewMTASTSHandler(customDomainService, mtaSTSService, tlsReportService)
+	catchAllHandler := handlers.NewCatchAllHandler(customDomainService, catchAllService)
+	dnsProviderHandler := handlers.NewDNSProviderHandler(customDomainService, dnsProvisioningService)
+	domainHealthHandler := handlers.NewDomainHealthHandler(customDomainService, domainHealthService)
+	domainSettingsHandler := handlers.NewDomainSettingsHandler(customDomainService, domainSettingsService)
+	emailTrackingHandler := handlers.NewEmailTrackingHandler(emailTrackingService)
 	tpsHandler := handlers.NewTPSHandler(tpsService, emailAddressService, accountService)
 
 	// Middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.CORSMiddleware(middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           time.Duration(cfg.CORSMaxAgeSeconds) * time.Second,
+	}))
+
+	// audit records the route's successful calls in the audit log
+	audit := func(action string) gin.HandlerFunc {
+		return middleware.Audit(auditService, action)
+	}
 
 	// Health check routes (no auth required)
 	router.GET("/health", healthHandler.Health)
 	router.GET("/v1/health", healthHandler.HealthV1)
 
+	// MTA-STS policy files, requested by sending mail servers at mta-sts.<custom domain>
+	router.GET("/.well-known/mta-sts.txt", mtaSTSHandler.ServePolicy)
+
+	// Open pixels and link redirects of tracked emails
+	router.GET("/t/o/:token", emailTrackingHandler.TrackOpen)
+	router.GET("/t/c/:token", emailTrackingHandler.TrackClick)
+
 	// Public routes: open signups are disabled. Use admin or platform routes below.
 
-	// Protected routes
+	// Dashboard sign-in for organization members
+	authRoutes := router.Group("/v1/auth")
+	{
+		authRoutes.POST("/magic-link", sessionHandler.RequestMagicLink)
+		authRoutes.POST("/magic-link/verify", sessionHandler.VerifyMagicLink)
+		authRoutes.POST("/refresh", sessionHandler.RefreshSession)
+	}
+
+	// OAuth2 client_credentials token endpoint
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	// Protected routes. Each route requires one API key scope.
 	protected := router.Group("/v1")
-	protected.Use(middleware.AuthMiddleware(db, cfg.APIKeyHashSalt))
+	protected.Use(middleware.AuthMiddleware(db, keyHasher, cfg.JWTSecret), middleware.RateLimitMiddleware(db))
 	{
 		// Account management
-		protected.GET("/account", accountHandler.GetAccount)
-		protected.POST("/account/api-key", accountHandler.GenerateNewAPIKey)
+		protected.GET("/account", middleware.RequireScope(models.ScopeAccountRead), accountHandler.GetAccount)
+		protected.GET("/account/limits", middleware.RequireScope(models.ScopeAccountRead), planHandler.GetLimits)
+		protected.GET("/usage", middleware.RequireScope(models.ScopeBillingRead), usageHandler.GetUsage)
+		protected.GET("/account/export", middleware.RequireScope(models.ScopeAccountWrite), accountDataHandler.ExportAccount)
+		protected.DELETE("/account", middleware.RequireScope(models.ScopeAccountWrite), audit("account.deletion_request"), accountDataHandler.RequestDeletion)
+		protected.GET("/account/deletion", middleware.RequireScope(models.ScopeAccountRead), accountDataHandler.GetDeletion)
+		protected.POST("/account/deletion/cancel", middleware.RequireScope(models.ScopeAccountWrite), audit("account.deletion_cancel"), accountDataHandler.CancelDeletion)
+		protected.POST("/account/api-key", middleware.RequireScope(models.ScopeAccountWrite), audit("api_key.rotate"), apiKeyHandler.RotateCurrentAPIKey)
+
+		// API key management
+		protected.GET("/api-keys", middleware.RequireScope(models.ScopeAccountRead), apiKeyHandler.ListAPIKeys)
+		protected.POST("/api-keys", middleware.RequireScope(models.ScopeAccountWrite), audit("api_key.create"), apiKeyHandler.CreateAPIKey)
+		protected.PATCH("/api-keys/:id", middleware.RequireScope(models.ScopeAccountWrite), audit("api_key.update"), apiKeyHandler.UpdateAPIKeyRestrictions)
+		protected.POST("/api-keys/:id/rotate", middleware.RequireScope(models.ScopeAccountWrite), audit("api_key.rotate"), apiKeyHandler.RotateAPIKey)
+		protected.DELETE("/api-keys/:id", middleware.RequireScope(models.ScopeAccountWrite), audit("api_key.revoke"), apiKeyHandler.RevokeAPIKey)
+
+		// OAuth client management
+		protected.GET("/oauth-clients", middleware.RequireScope(models.ScopeAccountRead), oauthHandler.ListClients)
+		protected.POST("/oauth-clients", middleware.RequireScope(models.ScopeAccountWrite), audit("oauth_client.create"), oauthHandler.CreateClient)
+		protected.DELETE("/oauth-clients/:id", middleware.RequireScope(models.ScopeAccountWrite), audit("oauth_client.revoke"), oauthHandler.RevokeClient)
+
+		// Dashboard sessions
+		protected.POST("/auth/logout", middleware.RequireScope(models.ScopeAccountRead), sessionHandler.Logout)
+		protected.GET("/auth/sessions", middleware.RequireScope(models.ScopeAccountRead), sessionHandler.ListSessions)
+		protected.DELETE("/auth/sessions/:id", middleware.RequireScope(models.ScopeAccountRead), sessionHandler.RevokeSession)
+
+		// Organization and member management
+		protected.GET("/organization", middleware.RequireScope(models.ScopeOrgRead), organizationHandler.GetOrganization)
+		protected.PATCH("/organization", middleware.RequireScope(models.ScopeOrgWrite), audit("organization.update"), organizationHandler.UpdateOrganization)
+		protected.GET("/organization/members", middleware.RequireScope(models.ScopeOrgRead), organizationHandler.ListMembers)
+		protected.POST("/organization/members", middleware.RequireScope(models.ScopeOrgWrite), audit("organization_member.add"), organizationHandler.AddMember)
+		protected.PATCH("/organization/members/:id", middleware.RequireScope(models.ScopeOrgWrite), audit("organization_member.update"), organizationHandler.UpdateMember)
+		protected.DELETE("/organization/members/:id", middleware.RequireScope(models.ScopeOrgWrite), audit("organization_member.remove"), organizationHandler.RemoveMember)
+		protected.POST("/organization/members/:id/api-keys", middleware.RequireScope(models.ScopeOrgWrite), audit("api_key.create"), organizationHandler.CreateMemberAPIKey)
+
+		// Audit log
+		protected.GET("/audit-events", middleware.RequireScope(models.ScopeAuditRead), auditHandler.ListAuditEvents)
+		protected.GET("/audit-events/verify", middleware.RequireScope(models.ScopeAuditRead), auditHandler.VerifyAuditChain)
 
 		// Email address management
-		protected.POST("/email-addresses", emailAddressHandler.CreateEmailAddress)
-		protected.GET("/email-addresses", emailAddressHandler.GetEmailAddresses)
-		protected.GET("/email-addresses/:id", emailAddressHandler.GetEmailAddress)
-		protected.PATCH("/email-addresses/:id", emailAddressHandler.UpdateEmailAddress)
-		protected.DELETE("/email-addresses/:id", emailAddressHandler.DeleteEmailAddress)
+		protected.POST("/email-addresses", middleware.RequireScope(models.ScopeAddressesWrite), audit("email_address.create"), emailAddressHandler.CreateEmailAddress)
+		protected.GET("/email-addresses", middleware.RequireScope(models.ScopeAddressesRead), emailAddressHandler.GetEmailAddresses)
+		protected.GET("/email-addresses/:id", middleware.RequireScope(models.ScopeAddressesRead), emailAddressHandler.GetEmailAddress)
+		protected.PATCH("/email-addresses/:id", middleware.RequireScope(models.ScopeAddressesWrite), audit("email_address.update"), emailAddressHandler.UpdateEmailAddress)
+		protected.DELETE("/email-addresses/:id", middleware.RequireScope(models.ScopeAddressesWrite), audit("email_address.delete"), emailAddressHandler.DeleteEmailAddress)
 
 		// TPS (3rd Party Software) management
-		protected.POST("/tps", tpsHandler.CreateTPS)
-		protected.GET("/tps", tpsHandler.ListTPSByEmail)
-		protected.GET("/tps/:tps_id", tpsHandler.GetTPS)
-		protected.PATCH("/tps/:tps_id", tpsHandler.UpdateTPS)
-		protected.DELETE("/tps/:tps_id", tpsHandler.DeleteTPS)
+		protected.POST("/tps", middleware.RequireScope(models.ScopeTPSWrite), audit("tps.create"), tpsHandler.CreateTPS)
+		protected.GET("/tps", middleware.RequireScope(models.ScopeTPSRead), tpsHandler.ListTPSByEmail)
+		protected.GET("/tps/:tps_id", middleware.RequireScope(models.ScopeTPSRead), tpsHandler.GetTPS)
+		protected.GET("/tps/:tps_id/secrets", middleware.RequireScope(models.ScopeTPSReadSecrets), audit("tps.secrets_decrypt"), tpsHandler.GetTPSSecrets)
+		protected.PATCH("/tps/:tps_id", middleware.RequireScope(models.ScopeTPSWrite), audit("tps.update"), tpsHandler.UpdateTPS)
+		protected.DELETE("/tps/:tps_id", middleware.RequireScope(models.ScopeTPSWrite), audit("tps.delete"), tpsHandler.DeleteTPS)
 
 		// Email operations
-		protected.POST("/emails/send", emailHandler.SendEmail)
-		protected.GET("/emails", emailHandler.GetEmails)
-		protected.GET("/emails/:id", emailHandler.GetEmail)
-		protected.GET("/emails/:id/status", emailHandler.GetEmailStatus)
+		protected.POST("/emails/send", middleware.RequireScope(models.ScopeEmailsSend), emailHandler.SendEmail)
+		protected.GET("/emails", middleware.RequireScope(models.ScopeEmailsRead), emailHandler.GetEmails)
+		protected.GET("/emails/:id", middleware.RequireScope(models.ScopeEmailsRead), emailHandler.GetEmail)
+		protected.GET("/emails/:id/status", middleware.RequireScope(models.ScopeEmailsRead), emailHandler.GetEmailStatus)
+		protected.GET("/emails/:id/tracking", middleware.RequireScope(models.ScopeEmailsRead), emailTrackingHandler.GetEmailTracking)
 
 		// Custom domain management
-		protected.POST("/custom-domains", customDomainHandler.CreateCustomDomain)
-		protected.GET("/custom-domains", customDomainHandler.GetCustomDomains)
-		protected.GET("/custom-domains/:id", customDomainHandler.GetCustomDomain)
-		protected.PATCH("/custom-domains/:id", customDomainHandler.UpdateCustomDomain)
-		protected.DELETE("/custom-domains/:id", customDomainHandler.DeleteCustomDomain)
-		protected.POST("/custom-domains/:id/verify", customDomainHandler.VerifyCustomDomain)
-		protected.GET("/custom-domains/:id/status", customDomainHandler.CheckVerificationStatus)
-		protected.GET("/custom-domains/:id/dns", customDomainHandler.ValidateDomainDNS)
+		protected.POST("/custom-domains", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.create"), customDomainHandler.CreateCustomDomain)
+		protected.GET("/custom-domains", middleware.RequireScope(models.ScopeDomainsRead), customDomainHandler.GetCustomDomains)
+		protected.GET("/custom-domains/verification-providers", middleware.RequireScope(models.ScopeDomainsRead), customDomainHandler.GetVerificationProviders)
+		protected.GET("/custom-domains/:id", middleware.RequireScope(models.ScopeDomainsRead), customDomainHandler.GetCustomDomain)
+		protected.PATCH("/custom-domains/:id", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.update"), domainSettingsHandler.UpdateSettings)
+		protected.DELETE("/custom-domains/:id", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.delete"), customDomainHandler.DeleteCustomDomain)
+		protected.POST("/custom-domains/:id/migrate", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.migrate"), customDomainHandler.MigrateCustomDomain)
+		protected.POST("/custom-domains/:id/verify", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.verify"), customDomainHandler.VerifyCustomDomain)
+		protected.GET("/custom-domains/:id/status", middleware.RequireScope(models.ScopeDomainsRead), customDomainHandler.CheckVerificationStatus)
+		protected.GET("/custom-domains/:id/dns", middleware.RequireScope(models.ScopeDomainsRead), customDomainHandler.ValidateDomainDNS)
+		protected.GET("/custom-domains/:id/settings", middleware.RequireScope(models.ScopeDomainsRead), domainSettingsHandler.GetSettings)
+		protected.PATCH("/custom-domains/:id/settings", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.settings_update"), domainSettingsHandler.UpdateSettings)
+		protected.GET("/custom-domains/:id/health", middleware.RequireScope(models.ScopeDomainsRead), domainHealthHandler.GetHealth)
+		protected.POST("/custom-domains/:id/health/check", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.health_check"), domainHealthHandler.CheckHealth)
+		protected.GET("/custom-domains/:id/dns-provider", middleware.RequireScope(models.ScopeDomainsRead), dnsProviderHandler.GetConnection)
+		protected.PUT("/custom-domains/:id/dns-provider", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.dns_provider_connect"), dnsProviderHandler.Connect)
+		protected.POST("/custom-domains/:id/dns-provider/sync", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.dns_provider_sync"), dnsProviderHandler.Sync)
+		protected.DELETE("/custom-domains/:id/dns-provider", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.dns_provider_disconnect"), dnsProviderHandler.Disconnect)
+		protected.GET("/custom-domains/:id/dkim", middleware.RequireScope(models.ScopeDomainsRead), dkimHandler.ListKeys)
+		protected.POST("/custom-domains/:id/dkim", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.dkim_enable"), dkimHandler.EnableDKIM)
+		protected.POST("/custom-domains/:id/dkim/rotate", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.dkim_rotate"), dkimHandler.RotateKey)
+		protected.DELETE("/custom-domains/:id/dkim", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.dkim_disable"), dkimHandler.DisableDKIM)
+		protected.GET("/custom-domains/:id/dmarc", middleware.RequireScope(models.ScopeDomainsRead), dmarcHandler.GetStats)
+		protected.GET("/custom-domains/:id/mta-sts", middleware.RequireScope(models.ScopeDomainsRead), mtaSTSHandler.GetPolicy)
+		protected.PUT("/custom-domains/:id/mta-sts", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.mta_sts_update"), mtaSTSHandler.SetPolicy)
+		protected.DELETE("/custom-domains/:id/mta-sts", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.mta_sts_disable"), mtaSTSHandler.DisablePolicy)
+		protected.GET("/custom-domains/:id/tls-reports", middleware.RequireScope(models.ScopeDomainsRead), mtaSTSHandler.GetTLSReportStats)
+		protected.GET("/custom-domains/:id/catch-all", middleware.RequireScope(models.ScopeDomainsRead), catchAllHandler.ListRules)
+		protected.POST("/custom-domains/:id/catch-all", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.catch_all_create"), catchAllHandler.CreateRule)
+		protected.DELETE("/custom-domains/:id/catch-all/:rule_id", middleware.RequireScope(models.ScopeDomainsWrite), audit("custom_domain.catch_all_delete"), catchAllHandler.DeleteRule)
 
 		// Admin-only routes (also allow admins to create accounts)
 		adminHandler := handlers.NewAdminHandler(accountService, emailAddressService)
 		admin := protected.Group("/admin")
-		admin.Use(middleware.AdminMiddlewareDB(db))
+		admin.Use(middleware.RequireScope(models.ScopeAdmin), middleware.AdminMiddlewareDB(db))
 		{
 			// Admins can create accounts via POST /v1/admin/users
-			admin.POST("/users", accountHandler.CreateAccount)
+			admin.POST("/users", audit("account.create"), accountHandler.CreateAccount)
 
 			admin.GET("/users", adminHandler.ListUsers)
 			admin.GET("/users/:id", adminHandler.GetUser)
-			admin.DELETE("/users/:id", adminHandler.DeleteUser)
-			admin.POST("/users/:id/revoke-key", adminHandler.RevokeKey)
+			admin.DELETE("/users/:id", audit("account.delete"), adminHandler.DeleteUser)
+			admin.POST("/users/:id/revoke-key", audit("account.revoke_keys"), adminHandler.RevokeKey)
 			admin.GET("/users/:id/email-addresses", adminHandler.ListEmailAddresses)
+			admin.POST("/users/:id/suspend", audit("account.suspend"), adminHandler.SuspendUser)
+			admin.POST("/users/:id/reinstate", audit("account.reinstate"), adminHandler.ReinstateUser)
+			admin.POST("/users/:id/close", audit("account.close"), adminHandler.CloseUser)
+			admin.GET("/users/:id/limits", planHandler.GetAccountLimits)
+			admin.PUT("/users/:id/plan", audit("account.plan_change"), planHandler.ChangeAccountPlan)
+			admin.PUT("/users/:id/limits", audit("account.limits_update"), planHandler.SetLimitOverrides)
+			admin.GET("/plans", planHandler.ListPlans)
+			admin.PUT("/plans/:id", audit("plan.upsert"), planHandler.UpsertPlan)
+			admin.GET("/usage/export", usageHandler.ExportUsage)
 			admin.GET("/stats", adminHandler.Stats)
 		}
 
@@ -134,11 +285,21 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, db *sql.DB, redisClient
 			platform := router.Group("/v1/platform")
 			platform.Use(middleware.PlatformTokenMiddleware(cfg.PlatformCreationToken))
 			{
-				platform.POST("/accounts", accountHandler.CreateAccount)
+				platform.POST("/accounts", audit("account.create"), accountHandler.CreateAccount)
 			}
 		}
 	}
 
+	// Reports sent to the report addresses, forwarded by the inbound mail pipeline, and
+	// the recipient lookup it accepts mail with (requires X-Inbound-Token header)
+	inbound := router.Group("/v1/inbound")
+	inbound.Use(middleware.InboundTokenMiddleware(cfg.InboundReportToken))
+	{
+		inbound.POST("/dmarc-reports", dmarcHandler.IngestReport)
+		inbound.POST("/tls-reports", mtaSTSHandler.IngestTLSReport)
+		inbound.POST("/resolve", catchAllHandler.ResolveRecipient)
+	}
+
 	// TODO: Webhook routes for email providers
 	// webhooks := router.Group("/webhooks")
 	// {
@@ -2,6 +2,7 @@ package api
 
 import (
 	"database/sql"
+	"log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/maylng/backend/internal/api/routes"
@@ -19,6 +20,11 @@ type Server struct {
 func NewServer(cfg *config.Config, db *sql.DB, redisClient *redis.Client) *Server {
 	router := gin.New()
 
+	// Only believe X-Forwarded-For from known proxies so API key IP allowlists can't be spoofed
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Add built-in middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
@@ -66,6 +72,7 @@ func NewServer(cfg *config.Config, db *sql.DB, redisClient *redis.Client) *Serve
 			} else if cfg.SendGridAPIKey != "" {
 				fallbackProvider = providers.NewSendGridProvider(cfg.SendGridAPIKey)
 			}
+			sesProvider.RequireTLSConfigurationSet = cfg.SESRequireTLSConfigurationSet
 			emailService = email.NewService(sesProvider, fallbackProvider)
 		}
 	case "sendgrid":
@@ -3,32 +3,160 @@ package auth
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"strings"
 )
 
-// GenerateAPIKey generates a new API key
-func GenerateAPIKey() (string, error) {
-	bytes := make([]byte, 32)
-	_, err := rand.Read(bytes)
-	if err != nil {
-		return "", err
+const (
+	apiKeyPrefix = "maylng_"
+	keyIDBytes   = 8
+	secretBytes  = 32
+)
+
+// GenerateAPIKey generates a new API key formatted as maylng_<keyid>_<secret>.
+// The key ID is stored in clear text for lookup; only an HMAC of the secret is stored.
+func GenerateAPIKey() (apiKey, keyID string, err error) {
+	idBytes := make([]byte, keyIDBytes)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	keyID = hex.EncodeToString(idBytes)
+	return apiKeyPrefix + keyID + "_" + hex.EncodeToString(secret), keyID, nil
+}
+
+// GenerateClientCredentials generates an OAuth client ID and secret. The client ID is
+// public; only a hash of the secret is stored.
+func GenerateClientCredentials() (clientID, clientSecret string, err error) {
+	idBytes := make([]byte, 12)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	return "mcl_" + hex.EncodeToString(idBytes), "mcs_" + hex.EncodeToString(secret), nil
+}
+
+// ParseAPIKey splits a maylng_<keyid>_<secret> key into its parts.
+// ok is false for malformed keys and for legacy keys that have no key ID.
+func ParseAPIKey(apiKey string) (keyID, secret string, ok bool) {
+	if !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(apiKey, apiKeyPrefix), "_")
+	if len(parts) != 2 || len(parts[0]) != keyIDBytes*2 || len(parts[1]) != secretBytes*2 {
+		return "", "", false
+	}
+
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// KeyHasher hashes API key secrets with HMAC-SHA256 under a versioned pepper.
+// Each stored hash records its pepper version, so adding a new pepper and making it
+// current does not invalidate keys hashed under older ones.
+type KeyHasher struct {
+	peppers        map[int][]byte
+	currentVersion int
+	legacySalt     string
+}
+
+// NewKeyHasher creates a KeyHasher. currentVersion must be one of the configured peppers.
+// legacySalt verifies keys issued before key IDs existed.
+func NewKeyHasher(peppers map[int]string, currentVersion int, legacySalt string) (*KeyHasher, error) {
+	if _, ok := peppers[currentVersion]; !ok {
+		return nil, fmt.Errorf("no API key pepper configured for current version %d", currentVersion)
+	}
+
+	h := &KeyHasher{
+		peppers:        make(map[int][]byte, len(peppers)),
+		currentVersion: currentVersion,
+		legacySalt:     legacySalt,
 	}
-	return "maylng_" + hex.EncodeToString(bytes), nil
+	for version, pepper := range peppers {
+		if pepper == "" {
+			return nil, fmt.Errorf("API key pepper version %d is empty", version)
+		}
+		h.peppers[version] = []byte(pepper)
+	}
+
+	return h, nil
+}
+
+// CurrentVersion returns the pepper version used for new hashes
+func (h *KeyHasher) CurrentVersion() int {
+	return h.currentVersion
+}
+
+// Hash returns the HMAC of secret under the current pepper, with the pepper version used
+func (h *KeyHasher) Hash(secret string) (hash string, version int) {
+	return h.hashWith(secret, h.currentVersion), h.currentVersion
+}
+
+// Verify checks secret against a stored hash in constant time
+func (h *KeyHasher) Verify(secret, hash string, version int) bool {
+	if _, ok := h.peppers[version]; !ok {
+		return false
+	}
+	return hmac.Equal([]byte(h.hashWith(secret, version)), []byte(hash))
+}
+
+// NeedsRehash reports whether a hash was made with an older pepper
+func (h *KeyHasher) NeedsRehash(version int) bool {
+	return version != h.currentVersion
+}
+
+// LegacyLookupHashes returns the hashes a pre-key-ID API key may be stored under: the
+// original salted hash, and the HMAC of the whole key under each pepper once it has been
+// migrated. Without a legacy salt only migrated keys are found.
+func (h *KeyHasher) LegacyLookupHashes(apiKey string) []string {
+	hashes := make([]string, 0, len(h.peppers)+1)
+	if h.legacySalt != "" {
+		hashes = append(hashes, HashAPIKey(apiKey, h.legacySalt))
+	}
+	for version := range h.peppers {
+		hashes = append(hashes, h.hashWith(apiKey, version))
+	}
+	return hashes
+}
+
+func (h *KeyHasher) hashWith(secret string, version int) string {
+	mac := hmac.New(sha256.New, h.peppers[version])
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// HashAPIKey hashes an API key for storage
+// HashAPIKey hashes a legacy API key with the global salt
 func HashAPIKey(apiKey, salt string) string {
 	hash := sha256.Sum256([]byte(apiKey + salt))
 	return hex.EncodeToString(hash[:])
 }
 
-// ValidateAPIKey checks if the provided API key matches the hash
+// ValidateAPIKey checks if the provided legacy API key matches the hash
 func ValidateAPIKey(apiKey, hash, salt string) bool {
-	return HashAPIKey(apiKey, salt) == hash
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(apiKey, salt)), []byte(hash)) == 1
 }
 
 // ExtractAPIKey extracts API key from Authorization header
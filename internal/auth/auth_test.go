@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAndParseAPIKey(t *testing.T) {
+	apiKey, keyID, err := GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(apiKey, "maylng_"+keyID+"_"))
+
+	parsedID, secret, ok := ParseAPIKey(apiKey)
+	assert.True(t, ok)
+	assert.Equal(t, keyID, parsedID)
+	assert.Len(t, secret, 64)
+}
+
+func TestParseAPIKeyRejectsLegacyAndMalformedKeys(t *testing.T) {
+	for _, apiKey := range []string{
+		"maylng_" + strings.Repeat("a", 64),
+		"maylng_" + strings.Repeat("a", 16) + "_short",
+		"maylng_" + strings.Repeat("z", 16) + "_" + strings.Repeat("a", 64),
+		"other_" + strings.Repeat("a", 16) + "_" + strings.Repeat("a", 64),
+	} {
+		_, _, ok := ParseAPIKey(apiKey)
+		assert.False(t, ok, apiKey)
+	}
+}
+
+func TestKeyHasherPepperRotation(t *testing.T) {
+	v1, err := NewKeyHasher(map[int]string{1: "pepper-one"}, 1, "salt")
+	assert.NoError(t, err)
+
+	hash, version := v1.Hash("secret")
+	assert.Equal(t, 1, version)
+	assert.True(t, v1.Verify("secret", hash, version))
+	assert.False(t, v1.Verify("wrong", hash, version))
+
+	// Adding a new current pepper keeps hashes made with the old one verifiable
+	v2, err := NewKeyHasher(map[int]string{1: "pepper-one", 2: "pepper-two"}, 2, "salt")
+	assert.NoError(t, err)
+	assert.True(t, v2.Verify("secret", hash, 1))
+	assert.True(t, v2.NeedsRehash(1))
+
+	newHash, newVersion := v2.Hash("secret")
+	assert.Equal(t, 2, newVersion)
+	assert.NotEqual(t, hash, newHash)
+	assert.False(t, v2.Verify("secret", newHash, 1))
+
+	// Retired peppers no longer verify
+	v3, err := NewKeyHasher(map[int]string{2: "pepper-two"}, 2, "salt")
+	assert.NoError(t, err)
+	assert.False(t, v3.Verify("secret", hash, 1))
+}
+
+func TestKeyHasherLegacyLookupHashes(t *testing.T) {
+	legacyKey := "maylng_" + strings.Repeat("a", 64)
+	hasher, err := NewKeyHasher(map[int]string{1: "pepper-one"}, 1, "salt")
+	assert.NoError(t, err)
+
+	// A key still stored under the salt, and one migrated to the pepper, are both found
+	migrated, _ := hasher.Hash(legacyKey)
+	hashes := hasher.LegacyLookupHashes(legacyKey)
+	assert.Contains(t, hashes, HashAPIKey(legacyKey, "salt"))
+	assert.Contains(t, hashes, migrated)
+
+	// Once every legacy key is migrated the salt can be dropped
+	withoutSalt, err := NewKeyHasher(map[int]string{1: "pepper-one"}, 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{migrated}, withoutSalt.LegacyLookupHashes(legacyKey))
+}
+
+func TestNewKeyHasherRequiresCurrentPepper(t *testing.T) {
+	_, err := NewKeyHasher(map[int]string{1: "pepper-one"}, 2, "salt")
+	assert.Error(t, err)
+}
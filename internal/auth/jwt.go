@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Token types carried in the typ claim
+const (
+	TokenTypeSession = "session"
+	TokenTypeClient  = "client"
+)
+
+// TokenClaims are the claims of the HS256 JWTs issued by this service
+type TokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	AccountID string `json:"acc"`
+	SessionID string `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	TokenType string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+const jwtIssuer = "maylng"
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// IssueToken signs claims as an HS256 JWT valid for ttl
+func IssueToken(claims TokenClaims, ttl time.Duration, secret string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("JWT secret is not configured")
+	}
+
+	now := time.Now()
+	claims.Issuer = jwtIssuer
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + signJWT(signingInput, secret), nil
+}
+
+// ParseToken verifies an HS256 JWT and returns its claims. Only tokens issued by
+// IssueToken are accepted: the header must match exactly, so "alg":"none" and
+// algorithm-confusion tokens are rejected.
+func ParseToken(token, secret string, now time.Time) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	expected := signJWT(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var claims TokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	if claims.Issuer != jwtIssuer {
+		return nil, fmt.Errorf("invalid token issuer")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("token has expired")
+	}
+
+	return &claims, nil
+}
+
+// LooksLikeJWT reports whether a bearer credential is a JWT rather than an API key
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && !strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// GenerateOpaqueToken returns a random URL-safe token, used for magic links and refresh tokens
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken hashes an opaque token for storage. Tokens are random and single-purpose,
+// so an unsalted hash is enough to keep a database leak from exposing live tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func signJWT(signingInput, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueAndParseToken(t *testing.T) {
+	token, err := IssueToken(TokenClaims{
+		Subject:   "member",
+		AccountID: "account",
+		SessionID: "session",
+		TokenType: TokenTypeSession,
+	}, 15*time.Minute, "secret")
+	assert.NoError(t, err)
+	assert.True(t, LooksLikeJWT(token))
+
+	claims, err := ParseToken(token, "secret", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "member", claims.Subject)
+	assert.Equal(t, "session", claims.SessionID)
+	assert.Equal(t, TokenTypeSession, claims.TokenType)
+
+	_, err = ParseToken(token, "other-secret", time.Now())
+	assert.EqualError(t, err, "invalid token signature")
+
+	_, err = ParseToken(token, "secret", time.Now().Add(time.Hour))
+	assert.EqualError(t, err, "token has expired")
+}
+
+func TestParseTokenRejectsForgedHeaders(t *testing.T) {
+	token, err := IssueToken(TokenClaims{Subject: "member", TokenType: TokenTypeSession}, time.Minute, "secret")
+	assert.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	_, err = ParseToken(none+"."+parts[1]+".", "secret", time.Now())
+	assert.EqualError(t, err, "malformed token")
+
+	// Swapping the payload invalidates the signature
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"maylng","sub":"admin","typ":"session","exp":9999999999}`))
+	_, err = ParseToken(parts[0]+"."+payload+"."+parts[2], "secret", time.Now())
+	assert.EqualError(t, err, "invalid token signature")
+}
+
+func TestLooksLikeJWT(t *testing.T) {
+	apiKey, _, err := GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.False(t, LooksLikeJWT(apiKey))
+	assert.False(t, LooksLikeJWT("maylng_a.b.c"))
+	assert.True(t, LooksLikeJWT("a.b.c"))
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// NormalizeCIDR parses a CIDR block or a single IP address and returns it in
+// canonical form. A bare address becomes a /32 or /128 block.
+func NormalizeCIDR(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR: %s", value)
+		}
+		return prefix.Masked().String(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR: %s", value)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+}
+
+// IPAllowed reports whether ip falls inside any of the CIDR blocks. An empty list allows every address.
+func IPAllowed(cidrs []string, ip string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			continue
+		}
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeOrigin validates a browser origin such as https://app.example.com and returns
+// it lowercased without a trailing slash. The host may start with "*." to match any
+// subdomain, and the single value "*" matches every origin.
+func NormalizeOrigin(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "*" {
+		return value, nil
+	}
+
+	u, err := url.Parse(strings.Replace(value, "://*.", "://wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("invalid origin: %s", value)
+	}
+
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+// OriginAllowed reports whether origin matches any of the allowed origins. An empty list
+// allows every origin.
+func OriginAllowed(allowed []string, origin string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+
+		// https://*.example.com matches https://app.example.com but not https://example.com
+		scheme, host, found := strings.Cut(pattern, "://*.")
+		if found && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			return true
+		}
+	}
+	return false
+}
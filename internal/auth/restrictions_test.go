@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCIDR(t *testing.T) {
+	cidr, err := NormalizeCIDR("203.0.113.7")
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7/32", cidr)
+
+	cidr, err = NormalizeCIDR("10.1.2.3/8")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", cidr)
+
+	cidr, err = NormalizeCIDR("2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1/128", cidr)
+
+	_, err = NormalizeCIDR("10.0.0.0/33")
+	assert.Error(t, err)
+}
+
+func TestIPAllowed(t *testing.T) {
+	cidrs := []string{"10.0.0.0/8", "2001:db8::/32"}
+
+	assert.True(t, IPAllowed(nil, "198.51.100.1"))
+	assert.True(t, IPAllowed(cidrs, "10.20.30.40"))
+	assert.True(t, IPAllowed(cidrs, "::ffff:10.0.0.1"))
+	assert.True(t, IPAllowed(cidrs, "2001:db8::5"))
+	assert.False(t, IPAllowed(cidrs, "198.51.100.1"))
+	assert.False(t, IPAllowed(cidrs, "not-an-ip"))
+}
+
+func TestOriginAllowed(t *testing.T) {
+	origin, err := NormalizeOrigin("https://App.Example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://app.example.com", origin)
+
+	_, err = NormalizeOrigin("https://example.com/path")
+	assert.Error(t, err)
+	_, err = NormalizeOrigin("ftp://example.com")
+	assert.Error(t, err)
+
+	allowed := []string{"https://app.example.com", "https://*.example.org"}
+	assert.True(t, OriginAllowed(nil, "https://evil.test"))
+	assert.True(t, OriginAllowed(allowed, "https://APP.example.com"))
+	assert.True(t, OriginAllowed(allowed, "https://a.b.example.org"))
+	assert.False(t, OriginAllowed(allowed, "https://example.org"))
+	assert.False(t, OriginAllowed(allowed, "http://app.example.com"))
+	assert.False(t, OriginAllowed(allowed, "https://evilexample.org"))
+	assert.False(t, OriginAllowed(allowed, ""))
+}
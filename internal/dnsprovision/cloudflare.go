@@ -0,0 +1,198 @@
+package dnsprovision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare manages records through the Cloudflare API with a token scoped to
+// Zone:DNS:Edit on the customer's zone
+type Cloudflare struct {
+	BaseURL string
+	token   string
+	zone    string
+	client  *http.Client
+
+	mu     sync.Mutex
+	zoneID string
+}
+
+func NewCloudflare(apiToken, zone string) *Cloudflare {
+	return &Cloudflare{
+		BaseURL: cloudflareAPIURL,
+		token:   apiToken,
+		zone:    normalizeName(zone),
+		client:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+type cloudflareRecord struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	TTL      int    `json:"ttl"`
+	Priority *int   `json:"priority,omitempty"`
+	Proxied  *bool  `json:"proxied,omitempty"`
+}
+
+type cloudflareResponse struct {
+	Success bool              `json:"success"`
+	Errors  []cloudflareError `json:"errors"`
+	Result  json.RawMessage   `json:"result"`
+}
+
+type cloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *Cloudflare) CreateRecord(ctx context.Context, record Record) error {
+	zoneID, err := p.lookupZone(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := p.listRecords(ctx, zoneID, record)
+	if err != nil {
+		return err
+	}
+	for _, r := range existing {
+		if sameValue(record.Type, cloudflareContent(r), record.Value) && (record.Type != "MX" || r.Priority == nil || *r.Priority == record.Priority) {
+			return nil
+		}
+		// A name holds one CNAME, so a stale one is replaced
+		if strings.EqualFold(record.Type, "CNAME") {
+			if err := p.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	proxied := false
+	body := cloudflareRecord{
+		Type:    strings.ToUpper(record.Type),
+		Name:    normalizeName(record.Name),
+		Content: record.Value,
+		TTL:     record.ttl(),
+		Proxied: &proxied,
+	}
+	if strings.EqualFold(record.Type, "MX") {
+		priority := record.Priority
+		body.Priority = &priority
+	}
+	if strings.EqualFold(record.Type, "TXT") {
+		body.Proxied = nil
+	}
+	return p.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", body, nil)
+}
+
+func (p *Cloudflare) DeleteRecord(ctx context.Context, record Record) error {
+	zoneID, err := p.lookupZone(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := p.listRecords(ctx, zoneID, record)
+	if err != nil {
+		return err
+	}
+	for _, r := range existing {
+		if !sameValue(record.Type, cloudflareContent(r), record.Value) {
+			continue
+		}
+		if err := p.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupZone finds the ID of the zone, once
+func (p *Cloudflare) lookupZone(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.zoneID != "" {
+		return p.zoneID, nil
+	}
+
+	var zones []struct {
+		ID string `json:"id"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(p.zone), nil, &zones); err != nil {
+		return "", err
+	}
+	if len(zones) == 0 {
+		return "", fmt.Errorf("cloudflare zone %s not found or not accessible with this token", p.zone)
+	}
+	p.zoneID = zones[0].ID
+	return p.zoneID, nil
+}
+
+func (p *Cloudflare) listRecords(ctx context.Context, zoneID string, record Record) ([]cloudflareRecord, error) {
+	query := url.Values{}
+	query.Set("type", strings.ToUpper(record.Type))
+	query.Set("name", normalizeName(record.Name))
+	query.Set("per_page", "100")
+
+	var records []cloudflareRecord
+	if err := p.do(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (p *Cloudflare) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.BaseURL+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("cloudflare returned status %d", resp.StatusCode)
+	}
+	if !envelope.Success {
+		if len(envelope.Errors) > 0 {
+			return fmt.Errorf("cloudflare error %d: %s", envelope.Errors[0].Code, envelope.Errors[0].Message)
+		}
+		return fmt.Errorf("cloudflare returned status %d", resp.StatusCode)
+	}
+	if result != nil {
+		if err := json.Unmarshal(envelope.Result, result); err != nil {
+			return fmt.Errorf("unexpected cloudflare response: %w", err)
+		}
+	}
+	return nil
+}
+
+// cloudflareContent returns a record's value; TXT content may come back quoted
+func cloudflareContent(record cloudflareRecord) string {
+	if strings.EqualFold(record.Type, "TXT") && len(record.Content) >= 2 && strings.HasPrefix(record.Content, `"`) && strings.HasSuffix(record.Content, `"`) {
+		return strings.ReplaceAll(record.Content[1:len(record.Content)-1], `" "`, "")
+	}
+	return record.Content
+}
@@ -0,0 +1,111 @@
+// Package dnsprovision publishes DNS records in a customer's zone with credentials
+// the customer delegated: a Cloudflare API token, Route53 access keys, or a TSIG key
+// for RFC 2136 dynamic updates to their own nameserver.
+package dnsprovision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Supported providers
+const (
+	ProviderCloudflare = "cloudflare"
+	ProviderRoute53    = "route53"
+	ProviderRFC2136    = "rfc2136"
+)
+
+// defaultTTL is used for records that don't set one
+const defaultTTL = 3600
+
+// requestTimeout bounds each call to a provider
+const requestTimeout = 30 * time.Second
+
+// Record is one resource record. Name is fully qualified, without a trailing dot.
+type Record struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	TTL      int    `json:"ttl,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// Provisioner adds and removes single records in one zone. Both operations are
+// idempotent: creating a record that exists or deleting one that doesn't succeeds.
+// Other records at the same name are left alone, except that a CNAME replaces any
+// CNAME already there.
+type Provisioner interface {
+	CreateRecord(ctx context.Context, record Record) error
+	DeleteRecord(ctx context.Context, record Record) error
+}
+
+// New creates the provisioner for provider from the customer's credentials:
+//
+//	cloudflare: api_token
+//	route53:    access_key_id, secret_access_key, hosted_zone_id, optional session_token
+//	rfc2136:    server (host:port), optional tsig_key_name, tsig_secret (base64) and
+//	            tsig_algorithm (hmac-sha256 by default)
+func New(provider, zone string, credentials map[string]string) (Provisioner, error) {
+	zone = normalizeName(zone)
+	if zone == "" {
+		return nil, fmt.Errorf("zone is required")
+	}
+
+	required := map[string][]string{
+		ProviderCloudflare: {"api_token"},
+		ProviderRoute53:    {"access_key_id", "secret_access_key", "hosted_zone_id"},
+		ProviderRFC2136:    {"server"},
+	}
+	fields, ok := required[provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported DNS provider: %s", provider)
+	}
+	for _, field := range fields {
+		if credentials[field] == "" {
+			return nil, fmt.Errorf("%s credentials require %s", provider, field)
+		}
+	}
+
+	switch provider {
+	case ProviderCloudflare:
+		return NewCloudflare(credentials["api_token"], zone), nil
+	case ProviderRoute53:
+		return NewRoute53(credentials["access_key_id"], credentials["secret_access_key"], credentials["session_token"], credentials["hosted_zone_id"]), nil
+	default:
+		return NewRFC2136(credentials["server"], zone, credentials["tsig_key_name"], credentials["tsig_secret"], credentials["tsig_algorithm"])
+	}
+}
+
+// normalizeName lowercases a domain name and drops its trailing dot
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// sameValue compares record values the way DNS does: host names case-insensitively
+// and without their trailing dot, TXT values exactly
+func sameValue(recordType, a, b string) bool {
+	if strings.EqualFold(recordType, "TXT") {
+		return a == b
+	}
+	return normalizeName(a) == normalizeName(b)
+}
+
+// ttl returns the record's TTL or the default
+func (r Record) ttl() int {
+	if r.TTL > 0 {
+		return r.TTL
+	}
+	return defaultTTL
+}
+
+// splitTXT splits a TXT value into the 255-byte strings a TXT record is made of
+func splitTXT(value string) []string {
+	var parts []string
+	for len(value) > 255 {
+		parts = append(parts, value[:255])
+		value = value[255:]
+	}
+	return append(parts, value)
+}
@@ -0,0 +1,368 @@
+package dnsprovision
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestNew_ValidatesCredentials(t *testing.T) {
+	_, err := New(ProviderCloudflare, "example.com", map[string]string{})
+	assert.EqualError(t, err, "cloudflare credentials require api_token")
+
+	_, err = New(ProviderRoute53, "example.com", map[string]string{"access_key_id": "AKID", "secret_access_key": "secret"})
+	assert.EqualError(t, err, "route53 credentials require hosted_zone_id")
+
+	_, err = New("godaddy", "example.com", map[string]string{})
+	assert.EqualError(t, err, "unsupported DNS provider: godaddy")
+
+	_, err = New(ProviderRFC2136, "example.com", map[string]string{"server": "ns1.example.com", "tsig_key_name": "maylng", "tsig_secret": "not base64!"})
+	assert.EqualError(t, err, "tsig_secret must be a base64 encoded key")
+
+	p, err := New(ProviderRFC2136, "Example.COM.", map[string]string{"server": "ns1.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "ns1.example.com:53", p.(*RFC2136).server)
+	assert.Equal(t, "example.com", p.(*RFC2136).zone)
+}
+
+// fakeCloudflare keeps one zone's records in memory
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	records map[string]cloudflareRecord
+	nextID  int
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer cf-token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": []cloudflareError{{Code: 10000, Message: "Authentication error"}}})
+		return
+	}
+
+	var result interface{}
+	switch {
+	case r.URL.Path == "/zones":
+		result = []map[string]string{{"id": "zone-1"}}
+	case r.Method == http.MethodGet && r.URL.Path == "/zones/zone-1/dns_records":
+		list := []cloudflareRecord{}
+		for _, record := range f.records {
+			if record.Type == r.URL.Query().Get("type") && record.Name == r.URL.Query().Get("name") {
+				list = append(list, record)
+			}
+		}
+		result = list
+	case r.Method == http.MethodPost && r.URL.Path == "/zones/zone-1/dns_records":
+		var record cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&record)
+		f.nextID++
+		record.ID = string(rune('a' + f.nextID))
+		f.records[record.ID] = record
+		result = record
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/zones/zone-1/dns_records/"):
+		delete(f.records, strings.TrimPrefix(r.URL.Path, "/zones/zone-1/dns_records/"))
+		result = map[string]string{}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": result})
+}
+
+func TestCloudflare_CreateAndDeleteRecord(t *testing.T) {
+	fake := &fakeCloudflare{records: map[string]cloudflareRecord{
+		"x": {ID: "x", Type: "TXT", Name: "example.com", Content: `"google-site-verification=abc"`},
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p := NewCloudflare("cf-token", "example.com")
+	p.BaseURL = server.URL
+	ctx := context.Background()
+
+	spf := Record{Type: "TXT", Name: "example.com", Value: "v=spf1 include:amazonses.com ~all"}
+	require.NoError(t, p.CreateRecord(ctx, spf))
+	require.NoError(t, p.CreateRecord(ctx, spf))
+	require.NoError(t, p.CreateRecord(ctx, Record{Type: "MX", Name: "bounce.example.com", Value: "feedback-smtp.us-east-1.amazonses.com", Priority: 10}))
+	assert.Len(t, fake.records, 3)
+
+	require.NoError(t, p.CreateRecord(ctx, Record{Type: "CNAME", Name: "mta-sts.example.com", Value: "old.mayl.ng"}))
+	require.NoError(t, p.CreateRecord(ctx, Record{Type: "CNAME", Name: "mta-sts.example.com", Value: "mta-sts.mayl.ng"}))
+	var cnames []string
+	for _, record := range fake.records {
+		if record.Type == "CNAME" {
+			cnames = append(cnames, record.Content)
+		}
+	}
+	assert.Equal(t, []string{"mta-sts.mayl.ng"}, cnames)
+
+	require.NoError(t, p.DeleteRecord(ctx, spf))
+	require.NoError(t, p.DeleteRecord(ctx, spf))
+	assert.Contains(t, fake.records, "x", "records we didn't create are left alone")
+	assert.Len(t, fake.records, 3)
+
+	p = NewCloudflare("wrong", "example.com")
+	p.BaseURL = server.URL
+	assert.EqualError(t, p.CreateRecord(ctx, spf), "cloudflare error 10000: Authentication error")
+}
+
+// fakeRoute53 keeps one hosted zone's record sets in memory
+type fakeRoute53 struct {
+	mu   sync.Mutex
+	sets map[string]route53RecordSet
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Code>InvalidSignatureException</Code><Message>bad signature</Message></Error></ErrorResponse>`))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/2013-04-01/hostedzone/Z123/rrset":
+		var response route53ListResponse
+		if set, ok := f.sets[r.URL.Query().Get("type")+" "+r.URL.Query().Get("name")]; ok {
+			response.RecordSets = append(response.RecordSets, set)
+		}
+		xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"ListResourceRecordSetsResponse"`
+			route53ListResponse
+		}{route53ListResponse: response})
+	case r.Method == http.MethodPost && r.URL.Path == "/2013-04-01/hostedzone/Z123/rrset/":
+		var request route53ChangeRequest
+		body, _ := io.ReadAll(r.Body)
+		if err := xml.Unmarshal(body, &request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, change := range request.Changes {
+			key := change.RecordSet.Type + " " + change.RecordSet.Name
+			if change.Action == "DELETE" {
+				delete(f.sets, key)
+			} else {
+				f.sets[key] = change.RecordSet
+			}
+		}
+		w.Write([]byte(`<ChangeResourceRecordSetsResponse><ChangeInfo><Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRoute53_CreateAndDeleteRecord(t *testing.T) {
+	fake := &fakeRoute53{sets: map[string]route53RecordSet{
+		"TXT example.com.": {Name: "example.com.", Type: "TXT", TTL: 300, ResourceRecords: []route53Record{{Value: `"google-site-verification=abc"`}}},
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p := NewRoute53("AKID", "secret", "", "/hostedzone/Z123")
+	p.BaseURL = server.URL
+	ctx := context.Background()
+
+	spf := Record{Type: "TXT", Name: "example.com", Value: "v=spf1 include:amazonses.com ~all"}
+	require.NoError(t, p.CreateRecord(ctx, spf))
+	require.NoError(t, p.CreateRecord(ctx, spf))
+	assert.Equal(t, []route53Record{{Value: `"google-site-verification=abc"`}, {Value: `"v=spf1 include:amazonses.com ~all"`}},
+		fake.sets["TXT example.com."].ResourceRecords)
+
+	key := Record{Type: "TXT", Name: "maylng._domainkey.example.com", Value: strings.Repeat("k", 300)}
+	require.NoError(t, p.CreateRecord(ctx, key))
+	assert.Equal(t, `"`+strings.Repeat("k", 255)+`" "`+strings.Repeat("k", 45)+`"`, fake.sets["TXT maylng._domainkey.example.com."].ResourceRecords[0].Value)
+
+	require.NoError(t, p.CreateRecord(ctx, Record{Type: "MX", Name: "bounce.example.com", Value: "feedback-smtp.us-east-1.amazonses.com", Priority: 10}))
+	assert.Equal(t, "10 feedback-smtp.us-east-1.amazonses.com.", fake.sets["MX bounce.example.com."].ResourceRecords[0].Value)
+
+	require.NoError(t, p.DeleteRecord(ctx, spf))
+	assert.Equal(t, []route53Record{{Value: `"google-site-verification=abc"`}}, fake.sets["TXT example.com."].ResourceRecords)
+
+	require.NoError(t, p.DeleteRecord(ctx, key))
+	require.NoError(t, p.DeleteRecord(ctx, key))
+	assert.NotContains(t, fake.sets, "TXT maylng._domainkey.example.com.")
+
+	p = NewRoute53("OTHER", "secret", "", "Z123")
+	p.BaseURL = server.URL
+	assert.EqualError(t, p.CreateRecord(ctx, spf), "route53 error InvalidSignatureException: bad signature")
+}
+
+// fakeNameserver accepts one update per connection, checks its TSIG signature and
+// answers with rcode
+type fakeNameserver struct {
+	listener net.Listener
+	secret   []byte
+	rcode    dnsmessage.RCode
+	updates  chan []dnsmessage.Resource
+}
+
+func newFakeNameserver(t *testing.T, secret []byte) *fakeNameserver {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeNameserver{listener: listener, secret: secret, updates: make(chan []dnsmessage.Resource, 10)}
+	go f.serve(t)
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeNameserver) serve(t *testing.T) {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		var length [2]byte
+		io.ReadFull(conn, length[:])
+		msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+		io.ReadFull(conn, msg)
+
+		rcode := f.rcode
+		updates, ok := f.verify(msg)
+		if !ok {
+			rcode = 9 // NOTAUTH
+		} else {
+			f.updates <- updates
+		}
+
+		response := append([]byte{}, msg[:12]...)
+		response[2] |= 0x80
+		response[3] = byte(rcode)
+		binary.BigEndian.PutUint16(response[4:], 0)
+		binary.BigEndian.PutUint16(response[6:], 0)
+		binary.BigEndian.PutUint16(response[8:], 0)
+		binary.BigEndian.PutUint16(response[10:], 0)
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(response))), response...))
+		conn.Close()
+	}
+}
+
+// verify parses an update and checks its hmac-sha256 TSIG record
+func (f *fakeNameserver) verify(msg []byte) ([]dnsmessage.Resource, bool) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(msg)
+	if err != nil || header.OpCode != 5 {
+		return nil, false
+	}
+	questions, _ := parser.AllQuestions()
+	if len(questions) != 1 || questions[0].Type != dnsmessage.TypeSOA || questions[0].Name.String() != "example.com." {
+		return nil, false
+	}
+	parser.SkipAllAnswers()
+	updates, err := parser.AllAuthorities()
+	if err != nil {
+		return nil, false
+	}
+	additionals, err := parser.AllAdditionals()
+	if err != nil || len(additionals) != 1 || additionals[0].Header.Type != 250 {
+		return nil, false
+	}
+
+	tsig := additionals[0]
+	rdata := tsig.Body.(*dnsmessage.UnknownResource).Data
+	keyName := []byte("\x06maylng\x00")
+	algorithm := []byte("\x0bhmac-sha256\x00")
+	if !strings.HasPrefix(string(rdata), string(algorithm)) {
+		return nil, false
+	}
+	timers := rdata[len(algorithm) : len(algorithm)+8]
+	macSize := int(binary.BigEndian.Uint16(rdata[len(algorithm)+8:]))
+	mac := rdata[len(algorithm)+10 : len(algorithm)+10+macSize]
+
+	unsigned := append([]byte{}, msg[:len(msg)-len(keyName)-10-len(rdata)]...)
+	binary.BigEndian.PutUint16(unsigned[10:], 0)
+
+	expected := hmac.New(sha256.New, f.secret)
+	expected.Write(unsigned)
+	expected.Write(keyName)
+	expected.Write([]byte{0, 255, 0, 0, 0, 0})
+	expected.Write(algorithm)
+	expected.Write(timers)
+	expected.Write([]byte{0, 0, 0, 0})
+	return updates, hmac.Equal(mac, expected.Sum(nil))
+}
+
+func TestRFC2136_SignedUpdates(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	server := newFakeNameserver(t, secret)
+	ctx := context.Background()
+
+	p, err := NewRFC2136(server.listener.Addr().String(), "example.com", "maylng", base64.StdEncoding.EncodeToString(secret), "")
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.CreateRecord(ctx, Record{Type: "TXT", Name: "example.com", Value: "x"}), errServerNotAllowed)
+	// The fake nameserver listens on loopback
+	p.dialer.Control = nil
+
+	require.NoError(t, p.CreateRecord(ctx, Record{Type: "TXT", Name: "_dmarc.example.com", Value: "v=DMARC1; p=none", TTL: 600}))
+	updates := <-server.updates
+	require.Len(t, updates, 1)
+	assert.Equal(t, "_dmarc.example.com.", updates[0].Header.Name.String())
+	assert.Equal(t, dnsmessage.ClassINET, updates[0].Header.Class)
+	assert.Equal(t, uint32(600), updates[0].Header.TTL)
+	assert.Equal(t, []string{"v=DMARC1; p=none"}, updates[0].Body.(*dnsmessage.TXTResource).TXT)
+
+	require.NoError(t, p.CreateRecord(ctx, Record{Type: "CNAME", Name: "mta-sts.example.com", Value: "mta-sts.mayl.ng"}))
+	updates = <-server.updates
+	require.Len(t, updates, 2)
+	assert.Equal(t, classAny, updates[0].Header.Class, "the existing CNAME is replaced")
+	assert.Equal(t, "mta-sts.mayl.ng.", updates[1].Body.(*dnsmessage.CNAMEResource).CNAME.String())
+
+	require.NoError(t, p.DeleteRecord(ctx, Record{Type: "MX", Name: "bounce.example.com", Value: "feedback-smtp.us-east-1.amazonses.com", Priority: 10}))
+	updates = <-server.updates
+	require.Len(t, updates, 1)
+	assert.Equal(t, classNone, updates[0].Header.Class)
+	assert.Equal(t, uint32(0), updates[0].Header.TTL)
+	assert.Equal(t, uint16(10), updates[0].Body.(*dnsmessage.MXResource).Pref)
+
+	assert.EqualError(t, p.CreateRecord(ctx, Record{Type: "TXT", Name: "example.org", Value: "x"}), "example.org is outside zone example.com")
+
+	wrongKey, err := NewRFC2136(server.listener.Addr().String(), "example.com", "maylng", base64.StdEncoding.EncodeToString([]byte("wrong")), "hmac-sha256")
+	require.NoError(t, err)
+	wrongKey.dialer.Control = nil
+	assert.ErrorContains(t, wrongKey.CreateRecord(ctx, Record{Type: "TXT", Name: "example.com", Value: "x"}), "NOTAUTH")
+
+	server.rcode = dnsmessage.RCodeRefused
+	assert.ErrorContains(t, p.CreateRecord(ctx, Record{Type: "TXT", Name: "example.com", Value: "x"}), "REFUSED")
+}
+
+func TestCheckServerAddress(t *testing.T) {
+	for address, allowed := range map[string]bool{
+		"198.51.100.7:53":       true,
+		"[2001:db8::53]:53":     true,
+		"198.51.100.7:8080":     false,
+		"127.0.0.1:53":          false,
+		"10.0.0.2:53":           false,
+		"192.168.1.1:53":        false,
+		"100.64.0.1:53":         false,
+		"169.254.169.254:53":    false,
+		"[::1]:53":              false,
+		"[fd00:ec2::254]:53":    false,
+		"[::ffff:127.0.0.1]:53": false,
+		"0.0.0.0:53":            false,
+	} {
+		err := checkServerAddress("tcp", address, nil)
+		if allowed {
+			assert.NoError(t, err, address)
+		} else {
+			assert.ErrorIs(t, err, errServerNotAllowed, address)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package dnsprovision
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS values used by dynamic updates that dnsmessage doesn't name
+const (
+	opcodeUpdate = dnsmessage.OpCode(5)
+	classNone    = dnsmessage.Class(254)
+	classAny     = dnsmessage.Class(255)
+	typeTSIG     = dnsmessage.Type(250)
+	tsigFudge    = 300
+)
+
+// tsigAlgorithms are the TSIG algorithms (RFC 8945) keys may use
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+	"hmac-sha1":   sha1.New,
+}
+
+// errServerNotAllowed is returned for servers that aren't a public address on port 53.
+// The customer picks the server and both the API and the worker dial it, so anything
+// else would let customers probe our internal network.
+var errServerNotAllowed = errors.New("server must be a public nameserver address on port 53")
+
+// RFC2136 sends dynamic updates (RFC 2136) over TCP to the primary nameserver of a
+// zone the customer runs, signed with a TSIG key when one is given. Updates add or
+// delete single records, which leaves the other records of a set alone.
+type RFC2136 struct {
+	server    string
+	zone      string
+	keyName   string
+	secret    []byte
+	algorithm string
+	dialer    net.Dialer
+}
+
+func NewRFC2136(server, zone, keyName, secret, algorithm string) (*RFC2136, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	p := &RFC2136{
+		server: server,
+		zone:   normalizeName(zone),
+		dialer: net.Dialer{Timeout: requestTimeout, Control: checkServerAddress},
+	}
+
+	if keyName == "" {
+		return p, nil
+	}
+	if algorithm == "" {
+		algorithm = "hmac-sha256"
+	}
+	algorithm = normalizeName(algorithm)
+	if _, ok := tsigAlgorithms[algorithm]; !ok {
+		return nil, fmt.Errorf("unsupported TSIG algorithm: %s", algorithm)
+	}
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("tsig_secret must be a base64 encoded key")
+	}
+	p.keyName = normalizeName(keyName)
+	p.secret = key
+	p.algorithm = algorithm
+	return p, nil
+}
+
+func (p *RFC2136) CreateRecord(ctx context.Context, record Record) error {
+	var deletes []Record
+	// A name holds one CNAME, so a stale one is replaced
+	if strings.EqualFold(record.Type, "CNAME") {
+		deletes = append(deletes, Record{Type: "CNAME", Name: record.Name})
+	}
+	return p.update(ctx, deletes, []Record{record})
+}
+
+func (p *RFC2136) DeleteRecord(ctx context.Context, record Record) error {
+	return p.update(ctx, []Record{record}, nil)
+}
+
+// update sends one update message that deletes and then adds records
+func (p *RFC2136) update(ctx context.Context, deletes, adds []Record) error {
+	msg, err := p.buildUpdate(deletes, adds)
+	if err != nil {
+		return err
+	}
+	if p.keyName != "" {
+		msg = p.sign(msg, time.Now())
+	}
+
+	response, err := p.exchange(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	// The response's TSIG isn't verified: the server acting on a forged update is
+	// what the key protects against, and a forged success only skips a record
+	// that verification will then find missing
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return fmt.Errorf("invalid response from %s: %w", p.server, err)
+	}
+	if header.RCode != dnsmessage.RCodeSuccess {
+		return fmt.Errorf("dns update rejected by %s: %s", p.server, rcodeName(header.RCode))
+	}
+	return nil
+}
+
+// buildUpdate packs an update message for the zone. Deleting a record with no value
+// deletes the whole set (class ANY); otherwise only that value (class NONE).
+func (p *RFC2136) buildUpdate(deletes, adds []Record) ([]byte, error) {
+	zone, err := dnsmessage.NewName(p.zone + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid zone %s: %w", p.zone, err)
+	}
+
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), OpCode: opcodeUpdate})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	// In an update the question section is the zone section
+	if err := b.Question(dnsmessage.Question{Name: zone, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	// and the authority section holds the updates
+	if err := b.StartAuthorities(); err != nil {
+		return nil, err
+	}
+	for _, record := range deletes {
+		class := classNone
+		if record.Value == "" {
+			class = classAny
+		}
+		if err := p.addResource(&b, record, class, 0); err != nil {
+			return nil, err
+		}
+	}
+	for _, record := range adds {
+		if err := p.addResource(&b, record, dnsmessage.ClassINET, uint32(record.ttl())); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+func (p *RFC2136) addResource(b *dnsmessage.Builder, record Record, class dnsmessage.Class, ttl uint32) error {
+	name := normalizeName(record.Name)
+	if name != p.zone && !strings.HasSuffix(name, "."+p.zone) {
+		return fmt.Errorf("%s is outside zone %s", name, p.zone)
+	}
+	owner, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return fmt.Errorf("invalid record name %s: %w", name, err)
+	}
+	header := dnsmessage.ResourceHeader{Name: owner, Class: class, TTL: ttl}
+
+	recordType := strings.ToUpper(record.Type)
+	if record.Value == "" {
+		types := map[string]dnsmessage.Type{"TXT": dnsmessage.TypeTXT, "CNAME": dnsmessage.TypeCNAME, "MX": dnsmessage.TypeMX}
+		rrType, ok := types[recordType]
+		if !ok {
+			return fmt.Errorf("unsupported record type: %s", record.Type)
+		}
+		return b.UnknownResource(header, dnsmessage.UnknownResource{Type: rrType})
+	}
+
+	switch recordType {
+	case "TXT":
+		return b.TXTResource(header, dnsmessage.TXTResource{TXT: splitTXT(record.Value)})
+	case "CNAME":
+		target, err := dnsmessage.NewName(normalizeName(record.Value) + ".")
+		if err != nil {
+			return fmt.Errorf("invalid CNAME target %s: %w", record.Value, err)
+		}
+		return b.CNAMEResource(header, dnsmessage.CNAMEResource{CNAME: target})
+	case "MX":
+		target, err := dnsmessage.NewName(normalizeName(record.Value) + ".")
+		if err != nil {
+			return fmt.Errorf("invalid MX host %s: %w", record.Value, err)
+		}
+		return b.MXResource(header, dnsmessage.MXResource{Pref: uint16(record.Priority), MX: target})
+	default:
+		return fmt.Errorf("unsupported record type: %s", record.Type)
+	}
+}
+
+// sign appends a TSIG record (RFC 8945) to a packed message
+func (p *RFC2136) sign(msg []byte, now time.Time) []byte {
+	keyName := wireName(p.keyName)
+	algorithm := wireName(p.algorithm)
+	signed := uint64(now.Unix())
+
+	var timers [8]byte
+	putUint48(timers[:6], signed)
+	binary.BigEndian.PutUint16(timers[6:], tsigFudge)
+
+	mac := hmac.New(tsigAlgorithms[p.algorithm], p.secret)
+	mac.Write(msg)
+	mac.Write(keyName)
+	mac.Write([]byte{byte(classAny >> 8), byte(classAny), 0, 0, 0, 0})
+	mac.Write(algorithm)
+	mac.Write(timers[:])
+	mac.Write([]byte{0, 0, 0, 0}) // error, other len
+	sum := mac.Sum(nil)
+
+	rdata := append([]byte{}, algorithm...)
+	rdata = append(rdata, timers[:]...)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = append(rdata, msg[0], msg[1]) // original ID
+	rdata = append(rdata, 0, 0, 0, 0)     // error, other len
+
+	out := append([]byte{}, msg...)
+	out = append(out, keyName...)
+	out = binary.BigEndian.AppendUint16(out, uint16(typeTSIG))
+	out = binary.BigEndian.AppendUint16(out, uint16(classAny))
+	out = binary.BigEndian.AppendUint32(out, 0)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(rdata)))
+	out = append(out, rdata...)
+
+	arcount := binary.BigEndian.Uint16(out[10:12])
+	binary.BigEndian.PutUint16(out[10:12], arcount+1)
+	return out
+}
+
+// exchange sends a message over TCP and reads the response
+func (p *RFC2136) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	// Network errors aren't passed on: they end up in API responses and would tell
+	// customers which hosts and ports are reachable from here
+	conn, err := p.dialer.DialContext(ctx, "tcp", p.server)
+	if errors.Is(err, errServerNotAllowed) {
+		return nil, errServerNotAllowed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s", p.server)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	frame := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err := conn.Write(append(frame, msg...)); err != nil {
+		return nil, fmt.Errorf("failed to send update to %s", p.server)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("no response from %s", p.server)
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, fmt.Errorf("no response from %s", p.server)
+	}
+	return response, nil
+}
+
+// checkServerAddress runs for each address the server name resolves to, so a name that
+// resolves to an internal address is refused as well
+func checkServerAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errServerNotAllowed
+	}
+	ip := addrPort.Addr().Unmap()
+	if addrPort.Port() != 53 || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() || sharedAddressSpace.Contains(ip) {
+		return errServerNotAllowed
+	}
+	return nil
+}
+
+// sharedAddressSpace is carrier-grade NAT space (RFC 6598), internal like RFC 1918
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// wireName encodes a name in uncompressed, lowercase wire format
+func wireName(name string) []byte {
+	var out []byte
+	for _, label := range strings.Split(normalizeName(name), ".") {
+		if label == "" {
+			continue
+		}
+		out = append(out, byte(len(label)))
+		out = append(out, label...)
+	}
+	return append(out, 0)
+}
+
+func putUint48(b []byte, v uint64) {
+	b[0] = byte(v >> 40)
+	b[1] = byte(v >> 32)
+	binary.BigEndian.PutUint32(b[2:], uint32(v))
+}
+
+func rcodeName(rcode dnsmessage.RCode) string {
+	names := map[dnsmessage.RCode]string{
+		dnsmessage.RCodeFormatError:    "FORMERR",
+		dnsmessage.RCodeServerFailure:  "SERVFAIL",
+		dnsmessage.RCodeNameError:      "NXDOMAIN",
+		dnsmessage.RCodeNotImplemented: "NOTIMP",
+		dnsmessage.RCodeRefused:        "REFUSED",
+		6:                              "YXDOMAIN",
+		7:                              "YXRRSET",
+		8:                              "NXRRSET",
+		9:                              "NOTAUTH",
+		10:                             "NOTZONE",
+	}
+	if name, ok := names[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("rcode %d", rcode)
+}
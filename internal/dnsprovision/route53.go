@@ -0,0 +1,222 @@
+package dnsprovision
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	route53APIURL    = "https://route53.amazonaws.com"
+	route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+	// Route53 is a global service signed for us-east-1
+	route53SigningRegion = "us-east-1"
+)
+
+// Route53 manages records in one hosted zone through the Route53 API with an IAM
+// key limited to route53:ListResourceRecordSets and route53:ChangeResourceRecordSets
+// on that zone. Route53 stores all values of a name and type as one record set, so
+// records are added to and removed from the set.
+type Route53 struct {
+	BaseURL      string
+	credentials  aws.Credentials
+	hostedZoneID string
+	signer       *v4.Signer
+	client       *http.Client
+}
+
+func NewRoute53(accessKeyID, secretAccessKey, sessionToken, hostedZoneID string) *Route53 {
+	return &Route53{
+		BaseURL: route53APIURL,
+		credentials: aws.Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		},
+		hostedZoneID: strings.TrimPrefix(hostedZoneID, "/hostedzone/"),
+		signer:       v4.NewSigner(),
+		client:       &http.Client{Timeout: requestTimeout},
+	}
+}
+
+type route53RecordSet struct {
+	Name            string          `xml:"Name"`
+	Type            string          `xml:"Type"`
+	TTL             int             `xml:"TTL"`
+	ResourceRecords []route53Record `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53Record struct {
+	Value string `xml:"Value"`
+}
+
+type route53ListResponse struct {
+	RecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53Change struct {
+	Action    string           `xml:"Action"`
+	RecordSet route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ErrorResponse struct {
+	Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+}
+
+func (p *Route53) CreateRecord(ctx context.Context, record Record) error {
+	set, err := p.getRecordSet(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	value := route53Value(record)
+	if set == nil || strings.EqualFold(record.Type, "CNAME") {
+		set = &route53RecordSet{Name: normalizeName(record.Name) + ".", Type: strings.ToUpper(record.Type)}
+	}
+	for _, r := range set.ResourceRecords {
+		if sameValue(record.Type, r.Value, value) {
+			return nil
+		}
+	}
+
+	set.TTL = record.ttl()
+	set.ResourceRecords = append(set.ResourceRecords, route53Record{Value: value})
+	return p.change(ctx, "UPSERT", *set)
+}
+
+func (p *Route53) DeleteRecord(ctx context.Context, record Record) error {
+	set, err := p.getRecordSet(ctx, record)
+	if err != nil || set == nil {
+		return err
+	}
+
+	value := route53Value(record)
+	remaining := make([]route53Record, 0, len(set.ResourceRecords))
+	for _, r := range set.ResourceRecords {
+		if !sameValue(record.Type, r.Value, value) {
+			remaining = append(remaining, r)
+		}
+	}
+	if len(remaining) == len(set.ResourceRecords) {
+		return nil
+	}
+
+	// A DELETE must name the record set exactly as it is
+	if len(remaining) == 0 {
+		return p.change(ctx, "DELETE", *set)
+	}
+	set.ResourceRecords = remaining
+	return p.change(ctx, "UPSERT", *set)
+}
+
+// getRecordSet returns the record set at the record's name and type, or nil
+func (p *Route53) getRecordSet(ctx context.Context, record Record) (*route53RecordSet, error) {
+	name := normalizeName(record.Name) + "."
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("type", strings.ToUpper(record.Type))
+	query.Set("maxitems", "1")
+
+	var response route53ListResponse
+	if err := p.do(ctx, http.MethodGet, "/2013-04-01/hostedzone/"+p.hostedZoneID+"/rrset?"+query.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	// The listing starts at the name, so the first set may belong to a later one
+	for _, set := range response.RecordSets {
+		if strings.EqualFold(set.Name, name) && strings.EqualFold(set.Type, record.Type) {
+			return &set, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Route53) change(ctx context.Context, action string, set route53RecordSet) error {
+	request := route53ChangeRequest{
+		Xmlns:   route53Namespace,
+		Changes: []route53Change{{Action: action, RecordSet: set}},
+	}
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return p.do(ctx, http.MethodPost, "/2013-04-01/hostedzone/"+p.hostedZoneID+"/rrset/", append([]byte(xml.Header), body...), nil)
+}
+
+func (p *Route53) do(ctx context.Context, method, path string, body []byte, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, p.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	hash := sha256.Sum256(body)
+	if err := p.signer.SignHTTP(ctx, p.credentials, req, hex.EncodeToString(hash[:]), "route53", route53SigningRegion, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign route53 request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("route53 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("route53 request failed: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		var errResp route53ErrorResponse
+		if xml.Unmarshal(data, &errResp) == nil && errResp.Error.Code != "" {
+			return fmt.Errorf("route53 error %s: %s", errResp.Error.Code, errResp.Error.Message)
+		}
+		return fmt.Errorf("route53 returned status %d", resp.StatusCode)
+	}
+	if result != nil {
+		if err := xml.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("unexpected route53 response: %w", err)
+		}
+	}
+	return nil
+}
+
+// route53Value formats a record's value the way Route53 stores it: TXT values as
+// quoted strings, MX values with their priority, and host names fully qualified
+func route53Value(record Record) string {
+	switch strings.ToUpper(record.Type) {
+	case "TXT":
+		parts := splitTXT(record.Value)
+		for i, part := range parts {
+			parts[i] = strconv.Quote(part)
+		}
+		return strings.Join(parts, " ")
+	case "MX":
+		return strconv.Itoa(record.Priority) + " " + normalizeName(record.Value) + "."
+	case "CNAME", "NS":
+		return normalizeName(record.Value) + "."
+	default:
+		return record.Value
+	}
+}
@@ -0,0 +1,159 @@
+// Package attachment extracts attachments from raw email messages, such as the
+// compressed report files that DMARC and TLS-RPT reporters send.
+package attachment
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// Part is a decoded message part
+type Part struct {
+	MediaType string
+	Filename  string
+	Data      []byte
+}
+
+// MatchFunc decides from a part's media type and file name whether to decode it
+type MatchFunc func(mediaType, filename string) bool
+
+// Limits bound the work one message can cause. A small message can hold many parts, and
+// each part can be an archive that expands far beyond its own size.
+type Limits struct {
+	// MaxSize caps one decoded part or decompressed file
+	MaxSize int64
+	// MaxTotalSize caps everything decoded and decompressed from the message
+	MaxTotalSize int64
+	// MaxEntries caps the MIME parts and archive files opened for the message
+	MaxEntries int
+}
+
+// Budget tracks how much of its Limits a message has used
+type Budget struct {
+	limits  Limits
+	size    int64
+	entries int
+}
+
+func NewBudget(limits Limits) *Budget {
+	return &Budget{limits: limits}
+}
+
+// Entry counts one more part or file
+func (b *Budget) Entry() error {
+	b.entries++
+	if b.entries > b.limits.MaxEntries {
+		return fmt.Errorf("message has more than %d parts and files", b.limits.MaxEntries)
+	}
+	return nil
+}
+
+// Reader reads one part or file, failing once it exceeds MaxSize or the message's
+// total exceeds MaxTotalSize
+func (b *Budget) Reader(r io.Reader) io.Reader {
+	return &budgetReader{r: r, budget: b, remaining: b.limits.MaxSize}
+}
+
+type budgetReader struct {
+	r         io.Reader
+	budget    *Budget
+	remaining int64
+}
+
+func (br *budgetReader) Read(p []byte) (int, error) {
+	limit := br.remaining
+	if total := br.budget.limits.MaxTotalSize - br.budget.size; total < limit {
+		limit = total
+	}
+	if limit <= 0 {
+		// Only data beyond the limit is an error
+		var probe [1]byte
+		if n, err := br.r.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		if br.remaining <= 0 {
+			return 0, fmt.Errorf("part or file is larger than %d bytes", br.budget.limits.MaxSize)
+		}
+		return 0, fmt.Errorf("message expands to more than %d bytes", br.budget.limits.MaxTotalSize)
+	}
+
+	if int64(len(p)) > limit {
+		p = p[:limit]
+	}
+	n, err := br.r.Read(p)
+	br.remaining -= int64(n)
+	br.budget.size += int64(n)
+	return n, err
+}
+
+// Walk calls fn with every non-multipart part of the message that match accepts.
+// Part bodies are decoded within budget, which fn can keep using for files inside them.
+func Walk(raw []byte, budget *Budget, match MatchFunc, fn func(Part) error) error {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("invalid email: %w", err)
+	}
+	return walk(message.Body, message.Header, budget, match, fn)
+}
+
+// header is the subset of MIME headers walk needs
+type header interface {
+	Get(key string) string
+}
+
+func walk(body io.Reader, h header, budget *Budget, match MatchFunc, fn func(Part) error) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid email: %w", err)
+			}
+			if err := budget.Entry(); err != nil {
+				part.Close()
+				return err
+			}
+			err = walk(part, part.Header, budget, match, fn)
+			part.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	filename := params["name"]
+	if _, dispositionParams, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && dispositionParams["filename"] != "" {
+		filename = dispositionParams["filename"]
+	}
+	if !match(mediaType, filename) {
+		return nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	data, err := io.ReadAll(budget.Reader(body))
+	if err != nil {
+		return fmt.Errorf("invalid attachment: %w", err)
+	}
+	return fn(Part{MediaType: mediaType, Filename: filename, Data: data})
+}
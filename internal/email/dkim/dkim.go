@@ -0,0 +1,294 @@
+// Package dkim signs outgoing messages with DomainKeys Identified Mail (RFC 6376),
+// using rsa-sha256 or ed25519-sha256 (RFC 8463) and relaxed/relaxed canonicalization.
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Signing algorithms
+const (
+	AlgorithmRSASHA256     = "rsa-sha256"
+	AlgorithmEd25519SHA256 = "ed25519-sha256"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// DefaultSignedHeaders are signed when present in the message
+var DefaultSignedHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"In-Reply-To", "References", "MIME-Version", "Content-Type", "List-Unsubscribe",
+}
+
+// Signer adds a DKIM-Signature header to messages for one domain and selector
+type Signer struct {
+	Domain    string
+	Selector  string
+	Algorithm string
+	key       crypto.Signer
+	headers   []string
+	now       func() time.Time
+}
+
+// NewSigner creates a signer for the domain and selector. The key must be an
+// *rsa.PrivateKey or an ed25519.PrivateKey.
+func NewSigner(domain, selector string, key crypto.Signer) (*Signer, error) {
+	algorithm, err := keyAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	if domain == "" || selector == "" {
+		return nil, fmt.Errorf("domain and selector are required")
+	}
+
+	return &Signer{
+		Domain:    domain,
+		Selector:  selector,
+		Algorithm: algorithm,
+		key:       key,
+		headers:   DefaultSignedHeaders,
+		now:       time.Now,
+	}, nil
+}
+
+// Sign returns the message with its line endings normalized to CRLF and a
+// DKIM-Signature header prepended
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	normalized := normalizeLineEndings(string(message))
+
+	headerBlock, body, found := strings.Cut(normalized, "\r\n\r\n")
+	if !found {
+		headerBlock, body = strings.TrimSuffix(normalized, "\r\n"), ""
+	}
+	headers := parseHeaders(headerBlock)
+
+	bodyHash := sha256.Sum256([]byte(canonicalizeBodyRelaxed(body)))
+
+	signedNames, signedHeaders := selectHeaders(headers, s.headers)
+	if !containsFold(signedNames, "From") {
+		return nil, fmt.Errorf("message has no From header")
+	}
+
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.Algorithm, s.Domain, s.Selector, s.now().Unix(),
+		strings.Join(signedNames, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	hash := sha256.New()
+	for _, header := range signedHeaders {
+		hash.Write([]byte(canonicalizeHeaderRelaxed(header)))
+	}
+	signatureHeader := canonicalizeHeaderRelaxed("DKIM-Signature: " + value)
+	hash.Write([]byte(strings.TrimSuffix(signatureHeader, "\r\n")))
+
+	signature, err := s.sign(hash.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	header := "DKIM-Signature: " + value + foldBase64(base64.StdEncoding.EncodeToString(signature)) + "\r\n"
+	return []byte(header + normalized), nil
+}
+
+func (s *Signer) sign(digest []byte) ([]byte, error) {
+	switch s.key.(type) {
+	case ed25519.PrivateKey:
+		// RFC 8463 signs the SHA-256 digest with pure Ed25519
+		return s.key.Sign(rand.Reader, digest, crypto.Hash(0))
+	default:
+		return s.key.Sign(rand.Reader, digest, crypto.SHA256)
+	}
+}
+
+// GenerateKey creates a private key for the algorithm: RSA-2048 or Ed25519
+func GenerateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRSASHA256:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEd25519SHA256:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported DKIM algorithm: %s", algorithm)
+	}
+}
+
+// MarshalPrivateKey encodes the key as a PKCS #8 PEM block
+func MarshalPrivateKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKey decodes a key encoded by MarshalPrivateKey
+func ParsePrivateKey(encoded string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	if _, err := keyAlgorithm(signer); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// PublicKeyRecord returns the TXT record value that publishes the key's public half
+func PublicKeyRecord(key crypto.Signer) (string, error) {
+	switch public := key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return "", err
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(public), nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", public)
+	}
+}
+
+// RecordName returns the DNS name of the selector's TXT record
+func RecordName(selector, domain string) string {
+	return selector + "._domainkey." + domain
+}
+
+func keyAlgorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 1024 {
+			return "", fmt.Errorf("RSA keys must be at least 1024 bits")
+		}
+		return AlgorithmRSASHA256, nil
+	case ed25519.PrivateKey:
+		return AlgorithmEd25519SHA256, nil
+	default:
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// selectHeaders picks the headers to sign. A name appearing more than once is signed
+// once per instance, from the bottom up, as RFC 6376 section 5.4.2 requires.
+func selectHeaders(headers []string, names []string) ([]string, []string) {
+	var signedNames, signedHeaders []string
+	for _, name := range names {
+		var instances []string
+		for _, header := range headers {
+			if headerName, _, _ := strings.Cut(header, ":"); strings.EqualFold(strings.TrimSpace(headerName), name) {
+				instances = append(instances, header)
+			}
+		}
+		for i := len(instances) - 1; i >= 0; i-- {
+			signedNames = append(signedNames, name)
+			signedHeaders = append(signedHeaders, instances[i])
+		}
+	}
+	return signedNames, signedHeaders
+}
+
+// parseHeaders splits a header block into headers, keeping folded lines together
+func parseHeaders(block string) []string {
+	var headers []string
+	for _, line := range strings.Split(block, "\r\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(headers) > 0 {
+			headers[len(headers)-1] += "\r\n" + line
+			continue
+		}
+		if line != "" {
+			headers = append(headers, line)
+		}
+	}
+	return headers
+}
+
+// canonicalizeHeaderRelaxed implements the relaxed header algorithm of RFC 6376 section 3.4.2
+func canonicalizeHeaderRelaxed(header string) string {
+	name, value, _ := strings.Cut(header, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = collapseWhitespace(value)
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.TrimSpace(value) + "\r\n"
+}
+
+// canonicalizeBodyRelaxed implements the relaxed body algorithm of RFC 6376 section 3.4.4
+func canonicalizeBodyRelaxed(body string) string {
+	lines := strings.Split(body, "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseWhitespace(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func collapseWhitespace(s string) string {
+	var b strings.Builder
+	inWhitespace := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			if !inWhitespace {
+				b.WriteByte(' ')
+			}
+			inWhitespace = true
+			continue
+		}
+		inWhitespace = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func normalizeLineEndings(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// foldBase64 wraps a long signature so the header stays under the line length limit
+func foldBase64(value string) string {
+	const width = 72
+	var b strings.Builder
+	for i := 0; i < len(value); i += width {
+		if i > 0 {
+			b.WriteString("\r\n\t")
+		}
+		end := i + width
+		if end > len(value) {
+			end = len(value)
+		}
+		b.WriteString(value[i:end])
+	}
+	return b.String()
+}
+
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelaxedCanonicalization(t *testing.T) {
+	// Example from RFC 6376 section 3.4.5
+	assert.Equal(t, "a:X\r\n", canonicalizeHeaderRelaxed("A: X"))
+	assert.Equal(t, "b:Y Z\r\n", canonicalizeHeaderRelaxed("B : Y\t\r\n\tZ  "))
+	assert.Equal(t, " C\r\nD E\r\n", canonicalizeBodyRelaxed(" C \r\nD \t E\r\n\r\n\r\n"))
+	assert.Equal(t, "", canonicalizeBodyRelaxed("\r\n\r\n"))
+}
+
+func TestSignVerifies(t *testing.T) {
+	message := "From: Team <team@example.com>\r\nTo: user@example.org\r\nSubject: Hello\r\n  world\r\n\r\nHi there  \nBye\n\n"
+
+	for _, algorithm := range []string{AlgorithmRSASHA256, AlgorithmEd25519SHA256} {
+		key, err := GenerateKey(algorithm)
+		require.NoError(t, err)
+
+		signer, err := NewSigner("example.com", "sel1", key)
+		require.NoError(t, err)
+
+		signed, err := signer.Sign([]byte(message))
+		require.NoError(t, err)
+
+		record, err := PublicKeyRecord(key)
+		require.NoError(t, err)
+		verifySignature(t, string(signed), record)
+	}
+}
+
+func TestSignRequiresFrom(t *testing.T) {
+	key, err := GenerateKey(AlgorithmEd25519SHA256)
+	require.NoError(t, err)
+	signer, err := NewSigner("example.com", "sel1", key)
+	require.NoError(t, err)
+
+	_, err = signer.Sign([]byte("Subject: Hello\r\n\r\nBody\r\n"))
+	assert.Error(t, err)
+}
+
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	key, err := GenerateKey(AlgorithmEd25519SHA256)
+	require.NoError(t, err)
+
+	encoded, err := MarshalPrivateKey(key)
+	require.NoError(t, err)
+	parsed, err := ParsePrivateKey(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+}
+
+// verifySignature checks the first DKIM-Signature header the way a receiver would
+func verifySignature(t *testing.T, message, record string) {
+	headerBlock, body, _ := strings.Cut(message, "\r\n\r\n")
+	headers := parseHeaders(headerBlock)
+	require.True(t, strings.HasPrefix(headers[0], "DKIM-Signature: "))
+
+	tags := map[string]string{}
+	for _, tag := range strings.Split(strings.TrimPrefix(headers[0], "DKIM-Signature: "), ";") {
+		name, value, _ := strings.Cut(tag, "=")
+		tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(value), "")
+	}
+
+	bodyHash := sha256.Sum256([]byte(canonicalizeBodyRelaxed(body)))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(bodyHash[:]), tags["bh"])
+
+	_, signedHeaders := selectHeaders(headers[1:], strings.Split(tags["h"], ":"))
+
+	// Rebuild the signed data with the b= value removed
+	withoutB := headers[0][:strings.Index(headers[0], "; b=")+len("; b=")]
+	hash := sha256.New()
+	for _, header := range signedHeaders {
+		hash.Write([]byte(canonicalizeHeaderRelaxed(header)))
+	}
+	hash.Write([]byte(strings.TrimSuffix(canonicalizeHeaderRelaxed(withoutB), "\r\n")))
+	digest := hash.Sum(nil)
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	require.NoError(t, err)
+	publicKey, err := base64.StdEncoding.DecodeString(record[strings.Index(record, "p=")+2:])
+	require.NoError(t, err)
+
+	switch tags["a"] {
+	case AlgorithmRSASHA256:
+		parsed, err := x509.ParsePKIXPublicKey(publicKey)
+		require.NoError(t, err)
+		assert.NoError(t, rsa.VerifyPKCS1v15(parsed.(*rsa.PublicKey), crypto.SHA256, digest, signature))
+	case AlgorithmEd25519SHA256:
+		assert.True(t, ed25519.Verify(ed25519.PublicKey(publicKey), digest, signature))
+	default:
+		t.Fatalf("unexpected algorithm %s", tags["a"])
+	}
+}
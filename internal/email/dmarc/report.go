@@ -0,0 +1,225 @@
+// Package dmarc parses DMARC aggregate feedback reports (RFC 7489 appendix C). Reports
+// arrive by email as XML files, usually gzip- or zip-compressed attachments.
+package dmarc
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/maylng/backend/internal/email/attachment"
+)
+
+// MaxReportSize caps the uncompressed size of a single report, so a small compressed
+// attachment can't expand without bound
+const MaxReportSize = 32 << 20
+
+// reportLimits bound everything read from one report email or file: reporters send one
+// report per message, so a few parts and archive entries are plenty
+var reportLimits = attachment.Limits{
+	MaxSize:      MaxReportSize,
+	MaxTotalSize: 2 * MaxReportSize,
+	MaxEntries:   50,
+}
+
+// Feedback is an aggregate report from one receiver about one domain and period
+type Feedback struct {
+	Metadata ReportMetadata  `xml:"report_metadata"`
+	Policy   PolicyPublished `xml:"policy_published"`
+	Records  []Record        `xml:"record"`
+}
+
+type ReportMetadata struct {
+	OrgName   string    `xml:"org_name"`
+	Email     string    `xml:"email"`
+	ReportID  string    `xml:"report_id"`
+	DateRange DateRange `xml:"date_range"`
+}
+
+// DateRange is the reporting period in Unix seconds
+type DateRange struct {
+	Begin int64 `xml:"begin"`
+	End   int64 `xml:"end"`
+}
+
+// PolicyPublished is the DMARC record the receiver found for the domain
+type PolicyPublished struct {
+	Domain string `xml:"domain"`
+	ADKIM  string `xml:"adkim"`
+	ASPF   string `xml:"aspf"`
+	P      string `xml:"p"`
+	SP     string `xml:"sp"`
+	Pct    *int   `xml:"pct"`
+}
+
+// Record counts messages from one source IP with the same evaluation results
+type Record struct {
+	Row         Row         `xml:"row"`
+	Identifiers Identifiers `xml:"identifiers"`
+	AuthResults AuthResults `xml:"auth_results"`
+}
+
+type Row struct {
+	SourceIP        string          `xml:"source_ip"`
+	Count           int             `xml:"count"`
+	PolicyEvaluated PolicyEvaluated `xml:"policy_evaluated"`
+}
+
+// PolicyEvaluated holds the DMARC outcome; DKIM and SPF are "pass" only when aligned
+type PolicyEvaluated struct {
+	Disposition string `xml:"disposition"`
+	DKIM        string `xml:"dkim"`
+	SPF         string `xml:"spf"`
+}
+
+type Identifiers struct {
+	EnvelopeFrom string `xml:"envelope_from"`
+	HeaderFrom   string `xml:"header_from"`
+}
+
+// AuthResults are the raw DKIM and SPF results, before alignment
+type AuthResults struct {
+	DKIM []AuthResult `xml:"dkim"`
+	SPF  []AuthResult `xml:"spf"`
+}
+
+type AuthResult struct {
+	Domain   string `xml:"domain"`
+	Selector string `xml:"selector"`
+	Result   string `xml:"result"`
+}
+
+// Begin returns the start of the reporting period
+func (f *Feedback) Begin() time.Time {
+	return time.Unix(f.Metadata.DateRange.Begin, 0).UTC()
+}
+
+// End returns the end of the reporting period
+func (f *Feedback) End() time.Time {
+	return time.Unix(f.Metadata.DateRange.End, 0).UTC()
+}
+
+// DKIMAligned reports whether the receiver found an aligned, passing DKIM signature
+func (r *Record) DKIMAligned() bool {
+	return strings.EqualFold(r.Row.PolicyEvaluated.DKIM, "pass")
+}
+
+// SPFAligned reports whether the receiver found an aligned, passing SPF result
+func (r *Record) SPFAligned() bool {
+	return strings.EqualFold(r.Row.PolicyEvaluated.SPF, "pass")
+}
+
+// Parse decodes a report's XML
+func Parse(r io.Reader) (*Feedback, error) {
+	decoder := xml.NewDecoder(io.LimitReader(r, MaxReportSize))
+	// Reports are ASCII in practice whatever encoding the declaration names
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var feedback Feedback
+	if err := decoder.Decode(&feedback); err != nil {
+		return nil, fmt.Errorf("invalid DMARC report XML: %w", err)
+	}
+	if feedback.Policy.Domain == "" {
+		return nil, fmt.Errorf("DMARC report has no policy domain")
+	}
+	if feedback.Metadata.ReportID == "" {
+		return nil, fmt.Errorf("DMARC report has no report ID")
+	}
+	return &feedback, nil
+}
+
+// ParseFile decodes a report file, which may be plain XML, gzip or a zip archive
+func ParseFile(data []byte) ([]*Feedback, error) {
+	return parseFile(data, attachment.NewBudget(reportLimits))
+}
+
+func parseFile(data []byte, budget *attachment.Budget) ([]*Feedback, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip report: %w", err)
+		}
+		defer reader.Close()
+		feedback, err := Parse(budget.Reader(reader))
+		if err != nil {
+			return nil, err
+		}
+		return []*Feedback{feedback}, nil
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zip report: %w", err)
+		}
+		var reports []*Feedback
+		for _, file := range archive.File {
+			if file.FileInfo().IsDir() || !strings.EqualFold(path.Ext(file.Name), ".xml") {
+				continue
+			}
+			if err := budget.Entry(); err != nil {
+				return nil, err
+			}
+			reader, err := file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("invalid zip report: %w", err)
+			}
+			feedback, err := Parse(budget.Reader(reader))
+			reader.Close()
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, feedback)
+		}
+		if len(reports) == 0 {
+			return nil, fmt.Errorf("zip report contains no XML file")
+		}
+		return reports, nil
+	default:
+		feedback, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []*Feedback{feedback}, nil
+	}
+}
+
+// ParseMessage finds the reports attached to a report email
+func ParseMessage(raw []byte) ([]*Feedback, error) {
+	var reports []*Feedback
+	budget := attachment.NewBudget(reportLimits)
+	err := attachment.Walk(raw, budget, isReportPart, func(part attachment.Part) error {
+		feedback, err := parseFile(part.Data, budget)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, feedback...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("report email has no DMARC report attached")
+	}
+	return reports, nil
+}
+
+// isReportPart reports whether a message part holds a report, by media type or file name
+func isReportPart(mediaType, filename string) bool {
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/zip", "application/x-zip-compressed",
+		"application/xml", "text/xml":
+		return true
+	}
+
+	filename = strings.ToLower(filename)
+	return strings.HasSuffix(filename, ".xml") || strings.HasSuffix(filename, ".gz") || strings.HasSuffix(filename, ".zip")
+}
@@ -0,0 +1,127 @@
+package dmarc
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleReport = `<?xml version="1.0" encoding="UTF-8" ?>
+<feedback>
+  <report_metadata>
+    <org_name>google.com</org_name>
+    <email>noreply-dmarc-support@google.com</email>
+    <report_id>1234567890</report_id>
+    <date_range><begin>1760745600</begin><end>1760831999</end></date_range>
+  </report_metadata>
+  <policy_published>
+    <domain>example.com</domain><adkim>r</adkim><aspf>r</aspf><p>none</p><sp>none</sp><pct>100</pct>
+  </policy_published>
+  <record>
+    <row>
+      <source_ip>54.240.8.1</source_ip><count>12</count>
+      <policy_evaluated><disposition>none</disposition><dkim>pass</dkim><spf>fail</spf></policy_evaluated>
+    </row>
+    <identifiers><header_from>example.com</header_from></identifiers>
+    <auth_results>
+      <dkim><domain>amazonses.com</domain><result>pass</result></dkim>
+      <dkim><domain>example.com</domain><result>pass</result></dkim>
+      <spf><domain>amazonses.com</domain><result>pass</result></spf>
+    </auth_results>
+  </record>
+  <record>
+    <row>
+      <source_ip>203.0.113.9</source_ip><count>3</count>
+      <policy_evaluated><disposition>none</disposition><dkim>fail</dkim><spf>fail</spf></policy_evaluated>
+    </row>
+    <identifiers><header_from>example.com</header_from></identifiers>
+    <auth_results><spf><domain>spoofer.example</domain><result>softfail</result></spf></auth_results>
+  </record>
+</feedback>`
+
+func TestParseFile(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(sampleReport))
+	writer.Close()
+
+	var zipped bytes.Buffer
+	archive := zip.NewWriter(&zipped)
+	file, err := archive.Create("google.com!example.com!1760745600!1760831999.xml")
+	require.NoError(t, err)
+	file.Write([]byte(sampleReport))
+	archive.Close()
+
+	for name, data := range map[string][]byte{"xml": []byte(sampleReport), "gzip": gzipped.Bytes(), "zip": zipped.Bytes()} {
+		reports, err := ParseFile(data)
+		require.NoError(t, err, name)
+		require.Len(t, reports, 1, name)
+
+		report := reports[0]
+		assert.Equal(t, "google.com", report.Metadata.OrgName)
+		assert.Equal(t, "example.com", report.Policy.Domain)
+		assert.Equal(t, int64(1760745600), report.Begin().Unix())
+		require.Len(t, report.Records, 2)
+		assert.Equal(t, 12, report.Records[0].Row.Count)
+		assert.True(t, report.Records[0].DKIMAligned())
+		assert.False(t, report.Records[0].SPFAligned())
+		assert.Len(t, report.Records[0].AuthResults.DKIM, 2)
+	}
+
+	_, err = ParseFile([]byte("<feedback></feedback>"))
+	assert.Error(t, err)
+}
+
+func TestParseFileLimitsEntries(t *testing.T) {
+	var zipped bytes.Buffer
+	archive := zip.NewWriter(&zipped)
+	for i := 0; i <= reportLimits.MaxEntries; i++ {
+		file, err := archive.Create(fmt.Sprintf("report-%d.xml", i))
+		require.NoError(t, err)
+		file.Write([]byte(sampleReport))
+	}
+	archive.Close()
+
+	_, err := ParseFile(zipped.Bytes())
+	assert.ErrorContains(t, err, "more than 50 parts and files")
+}
+
+func TestParseMessage(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(sampleReport))
+	writer.Close()
+
+	encoded := base64.StdEncoding.EncodeToString(gzipped.Bytes())
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded)
+
+	message := "From: noreply-dmarc-support@google.com\r\n" +
+		"To: dmarc-reports@mayl.ng\r\n" +
+		"Subject: Report domain: example.com Submitter: google.com Report-ID: 1234567890\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b1\"\r\n\r\n" +
+		"--b1\r\nContent-Type: text/plain\r\n\r\nThis is an aggregate report from google.com.\r\n" +
+		"--b1\r\nContent-Type: application/gzip; name=\"google.com!example.com!1760745600!1760831999.xml.gz\"\r\n" +
+		"Content-Disposition: attachment\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+		wrapped.String() + "\r\n--b1--\r\n"
+
+	reports, err := ParseMessage([]byte(message))
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "1234567890", reports[0].Metadata.ReportID)
+
+	_, err = ParseMessage([]byte("From: a@example.com\r\nSubject: hi\r\n\r\nno report here\r\n"))
+	assert.Error(t, err)
+}
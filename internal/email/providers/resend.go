@@ -54,6 +54,9 @@ func (p *ResendProvider) SendEmail(emailMsg *email.Email) (*email.SendResult, er
 	if emailMsg.TextContent != "" {
 		params.Text = emailMsg.TextContent
 	}
+	if emailMsg.ReplyTo != "" {
+		params.ReplyTo = emailMsg.ReplyTo
+	}
 	if len(emailMsg.CcRecipients) > 0 {
 		params.Cc = emailMsg.CcRecipients
 	}
@@ -64,6 +64,10 @@ func (p *SendGridProvider) SendEmail(emailMsg *email.Email) (*email.SendResult,
 		message.AddPersonalizations(personalization)
 	}
 
+	if emailMsg.ReplyTo != "" {
+		message.SetReplyTo(mail.NewEmail("", emailMsg.ReplyTo))
+	}
+
 	// Add custom headers
 	for key, value := range emailMsg.Headers {
 		message.SetHeader(key, value)
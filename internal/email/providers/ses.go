@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -16,6 +17,9 @@ import (
 type SESProvider struct {
 	client *sesv2.Client
 	region string
+	// RequireTLSConfigurationSet names a configuration set whose TLS policy is
+	// REQUIRE; emails that must go over TLS are sent with it
+	RequireTLSConfigurationSet string
 }
 
 func NewSESProvider(region string) (*SESProvider, error) {
@@ -85,8 +89,8 @@ func (p *SESProvider) SendEmail(emailMsg *email.Email) (*email.SendResult, error
 	}
 
 	// Handle attachments (if any)
-	if len(emailMsg.Attachments) > 0 {
-		// For attachments, we need to use Raw content instead of Simple
+	if len(emailMsg.Attachments) > 0 || emailMsg.Signer != nil {
+		// For attachments and signing, we need to use Raw content instead of Simple
 		rawContent, err := p.buildRawEmailContent(emailMsg)
 		if err != nil {
 			return &email.SendResult{
@@ -95,6 +99,16 @@ func (p *SESProvider) SendEmail(emailMsg *email.Email) (*email.SendResult, error
 			}, err
 		}
 
+		if emailMsg.Signer != nil {
+			rawContent, err = emailMsg.Signer.Sign(rawContent)
+			if err != nil {
+				return &email.SendResult{
+					Status:       "failed",
+					ErrorMessage: fmt.Sprintf("failed to sign email: %v", err),
+				}, err
+			}
+		}
+
 		content = &types.EmailContent{
 			Raw: &types.RawMessage{
 				Data: rawContent,
@@ -127,9 +141,22 @@ func (p *SESProvider) SendEmail(emailMsg *email.Email) (*email.SendResult, error
 		Destination:      destination,
 		Content:          content,
 	}
+	if emailMsg.ReplyTo != "" {
+		input.ReplyToAddresses = []string{emailMsg.ReplyTo}
+	}
+
+	if emailMsg.RequireTLS {
+		if p.RequireTLSConfigurationSet == "" {
+			return &email.SendResult{
+				Status:       "failed",
+				ErrorMessage: "no configuration set requires TLS",
+			}, fmt.Errorf("no configuration set requires TLS")
+		}
+		input.ConfigurationSetName = aws.String(p.RequireTLSConfigurationSet)
+	}
 
 	// Add custom headers if no attachments (only supported in Simple content)
-	if len(emailMsg.Attachments) == 0 && len(emailMsg.Headers) > 0 {
+	if content.Raw == nil && len(emailMsg.Headers) > 0 {
 		// Note: SES v2 doesn't directly support custom headers in Simple content
 		// Custom headers would need to be included in Raw content
 		// For now, we'll log a warning and continue
@@ -151,6 +178,11 @@ func (p *SESProvider) SendEmail(emailMsg *email.Email) (*email.SendResult, error
 	}, nil
 }
 
+// CanRequireTLS reports whether a configuration set that requires TLS is configured
+func (p *SESProvider) CanRequireTLS() bool {
+	return p.RequireTLSConfigurationSet != ""
+}
+
 func (p *SESProvider) GetDeliveryStatus(messageID string) (*email.DeliveryStatus, error) {
 	// SES doesn't provide a direct API to get delivery status by message ID
 	// This would typically be handled through SNS notifications or CloudWatch events
@@ -160,7 +192,7 @@ func (p *SESProvider) GetDeliveryStatus(messageID string) (*email.DeliveryStatus
 	}, nil
 }
 
-// buildRawEmailContent builds raw email content for emails with attachments
+// buildRawEmailContent builds raw email content for emails with attachments or a signer
 func (p *SESProvider) buildRawEmailContent(emailMsg *email.Email) ([]byte, error) {
 	boundary := "----=_NextPart_000_0000_01234567.89ABCDEF"
 
@@ -179,7 +211,12 @@ func (p *SESProvider) buildRawEmailContent(emailMsg *email.Email) ([]byte, error
 		rawEmail.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(emailMsg.CcRecipients, ", ")))
 	}
 
+	if emailMsg.ReplyTo != "" {
+		rawEmail.WriteString(fmt.Sprintf("Reply-To: %s\r\n", emailMsg.ReplyTo))
+	}
+
 	rawEmail.WriteString(fmt.Sprintf("Subject: %s\r\n", emailMsg.Subject))
+	rawEmail.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
 	rawEmail.WriteString("MIME-Version: 1.0\r\n")
 	rawEmail.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n", boundary))
 
@@ -11,17 +11,33 @@ type Provider interface {
 	GetDeliveryStatus(messageID string) (*DeliveryStatus, error)
 }
 
+// TLSEnforcer is implemented by providers that can refuse to deliver a message to a
+// receiver that doesn't offer TLS
+type TLSEnforcer interface {
+	CanRequireTLS() bool
+}
+
+// MessageSigner signs a raw RFC 5322 message, e.g. with DKIM
+type MessageSigner interface {
+	Sign(message []byte) ([]byte, error)
+}
+
 type Email struct {
 	FromEmail     string
 	FromName      string
 	ToRecipients  []string
 	CcRecipients  []string
 	BccRecipients []string
+	ReplyTo       string
 	Subject       string
 	TextContent   string
 	HTMLContent   string
 	Attachments   []Attachment
 	Headers       map[string]string
+	// Signer signs the message on providers that send raw messages
+	Signer MessageSigner
+	// RequireTLS sends the message only through a provider that enforces TLS
+	RequireTLS bool
 }
 
 type Attachment struct {
@@ -57,9 +73,17 @@ func NewService(primary Provider, fallback Provider) *Service {
 }
 
 func (s *Service) SendEmail(email *Email) (*SendResult, error) {
+	primary, fallback := s.primary, s.fallback
+	if email.RequireTLS {
+		primary, fallback = requireTLS(primary), requireTLS(fallback)
+		if primary == nil && fallback == nil {
+			return nil, fmt.Errorf("no email provider can require TLS")
+		}
+	}
+
 	// Try primary provider first
-	if s.primary != nil {
-		result, err := s.primary.SendEmail(email)
+	if primary != nil {
+		result, err := primary.SendEmail(email)
 		if err == nil {
 			return result, nil
 		}
@@ -67,13 +91,21 @@ func (s *Service) SendEmail(email *Email) (*SendResult, error) {
 	}
 
 	// Try fallback provider if primary fails
-	if s.fallback != nil {
-		return s.fallback.SendEmail(email)
+	if fallback != nil {
+		return fallback.SendEmail(email)
 	}
 
 	return nil, fmt.Errorf("no email providers available")
 }
 
+// requireTLS returns the provider if it can enforce TLS
+func requireTLS(provider Provider) Provider {
+	if enforcer, ok := provider.(TLSEnforcer); ok && enforcer.CanRequireTLS() {
+		return provider
+	}
+	return nil
+}
+
 func (s *Service) GetDeliveryStatus(messageID string) (*DeliveryStatus, error) {
 	// Try primary provider first
 	if s.primary != nil {
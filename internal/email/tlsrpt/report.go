@@ -0,0 +1,147 @@
+// Package tlsrpt parses SMTP TLS reports (RFC 8460), which sending mail servers send
+// about the TLS sessions they tried to establish with a domain's MX hosts.
+package tlsrpt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maylng/backend/internal/email/attachment"
+)
+
+// MaxReportSize caps the uncompressed size of a single report
+const MaxReportSize = 32 << 20
+
+// reportLimits bound everything read from one report email or file
+var reportLimits = attachment.Limits{
+	MaxSize:      MaxReportSize,
+	MaxTotalSize: 2 * MaxReportSize,
+	MaxEntries:   50,
+}
+
+// Report is one sender's account of its sessions with a recipient domain over a period
+type Report struct {
+	OrganizationName string         `json:"organization-name"`
+	DateRange        DateRange      `json:"date-range"`
+	ContactInfo      string         `json:"contact-info"`
+	ReportID         string         `json:"report-id"`
+	Policies         []PolicyResult `json:"policies"`
+}
+
+type DateRange struct {
+	Start time.Time `json:"start-datetime"`
+	End   time.Time `json:"end-datetime"`
+}
+
+// PolicyResult summarizes the sessions made under one policy
+type PolicyResult struct {
+	Policy         Policy          `json:"policy"`
+	Summary        Summary         `json:"summary"`
+	FailureDetails []FailureDetail `json:"failure-details"`
+}
+
+// Policy identifies the policy the sender applied: "sts", "tlsa" or "no-policy-found"
+type Policy struct {
+	Type   string     `json:"policy-type"`
+	Domain string     `json:"policy-domain"`
+	MXHost StringList `json:"mx-host"`
+}
+
+type Summary struct {
+	SuccessfulSessions int `json:"total-successful-session-count"`
+	FailedSessions     int `json:"total-failure-session-count"`
+}
+
+// FailureDetail counts failed sessions with the same cause, e.g. "certificate-expired"
+type FailureDetail struct {
+	ResultType          string `json:"result-type"`
+	SendingMTAIP        string `json:"sending-mta-ip"`
+	ReceivingMXHostname string `json:"receiving-mx-hostname"`
+	ReceivingIP         string `json:"receiving-ip"`
+	FailedSessions      int    `json:"failed-session-count"`
+	FailureReasonCode   string `json:"failure-reason-code"`
+}
+
+// StringList accepts a JSON string or array of strings; reporters disagree on which
+// one mx-host is
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Parse decodes a report, which may be gzip-compressed
+func Parse(data []byte) (*Report, error) {
+	return parse(data, attachment.NewBudget(reportLimits))
+}
+
+func parse(data []byte, budget *attachment.Budget) (*Report, error) {
+	var reader io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip TLS report: %w", err)
+		}
+		defer gz.Close()
+		reader = budget.Reader(gz)
+	}
+
+	var report Report
+	if err := json.NewDecoder(io.LimitReader(reader, MaxReportSize)).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid TLS report JSON: %w", err)
+	}
+	if report.ReportID == "" {
+		return nil, fmt.Errorf("TLS report has no report ID")
+	}
+	if len(report.Policies) == 0 {
+		return nil, fmt.Errorf("TLS report has no policies")
+	}
+	return &report, nil
+}
+
+// ParseMessage finds the reports attached to a report email
+func ParseMessage(raw []byte) ([]*Report, error) {
+	var reports []*Report
+	budget := attachment.NewBudget(reportLimits)
+	err := attachment.Walk(raw, budget, isReportPart, func(part attachment.Part) error {
+		report, err := parse(part.Data, budget)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("report email has no TLS report attached")
+	}
+	return reports, nil
+}
+
+// isReportPart reports whether a message part holds a report, by media type or file name
+func isReportPart(mediaType, filename string) bool {
+	switch mediaType {
+	case "application/tlsrpt+gzip", "application/tlsrpt+json", "application/gzip", "application/json":
+		return true
+	}
+
+	filename = strings.ToLower(filename)
+	return strings.HasSuffix(filename, ".json") || strings.HasSuffix(filename, ".json.gz")
+}
@@ -0,0 +1,91 @@
+package tlsrpt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleReport is the example from RFC 8460 section 4.4, with mx-host as a string
+const sampleReport = `{
+  "organization-name": "Company-X",
+  "date-range": {"start-datetime": "2016-04-01T00:00:00Z", "end-datetime": "2016-04-01T23:59:59Z"},
+  "contact-info": "sts-reporting@company-x.example",
+  "report-id": "5065427c-23d3-47ca-b6e0-946ea0e8c4be",
+  "policies": [{
+    "policy": {
+      "policy-type": "sts",
+      "policy-string": ["version: STSv1", "mode: testing", "mx: *.mail.company-y.example", "max_age: 86400"],
+      "policy-domain": "company-y.example",
+      "mx-host": "*.mail.company-y.example"
+    },
+    "summary": {"total-successful-session-count": 5326, "total-failure-session-count": 303},
+    "failure-details": [{
+      "result-type": "certificate-expired",
+      "sending-mta-ip": "2001:db8:abcd:0012::1",
+      "receiving-mx-hostname": "mx1.mail.company-y.example",
+      "failed-session-count": 100
+    }, {
+      "result-type": "starttls-not-supported",
+      "sending-mta-ip": "2001:db8:abcd:0013::1",
+      "receiving-mx-hostname": "mx2.mail.company-y.example",
+      "receiving-ip": "203.0.113.56",
+      "failed-session-count": 200,
+      "additional-information": "https://reports.company-x.example/report_info?id=5065427c-23d3#StarttlsNotSupported"
+    }]
+  }]
+}`
+
+func TestParse(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(sampleReport))
+	writer.Close()
+
+	for name, data := range map[string][]byte{"json": []byte(sampleReport), "gzip": gzipped.Bytes()} {
+		report, err := Parse(data)
+		require.NoError(t, err, name)
+		assert.Equal(t, "Company-X", report.OrganizationName)
+		require.Len(t, report.Policies, 1)
+
+		policy := report.Policies[0]
+		assert.Equal(t, "company-y.example", policy.Policy.Domain)
+		assert.Equal(t, StringList{"*.mail.company-y.example"}, policy.Policy.MXHost)
+		assert.Equal(t, 303, policy.Summary.FailedSessions)
+		require.Len(t, policy.FailureDetails, 2)
+		assert.Equal(t, "certificate-expired", policy.FailureDetails[0].ResultType)
+	}
+
+	report, err := Parse([]byte(`{"report-id": "r1", "policies": [{"policy": {"policy-type": "sts", "mx-host": ["a.example", "b.example"]}}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, StringList{"a.example", "b.example"}, report.Policies[0].Policy.MXHost)
+
+	_, err = Parse([]byte(`{"report-id": "r1"}`))
+	assert.Error(t, err)
+}
+
+func TestParseMessage(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(sampleReport))
+	writer.Close()
+
+	message := "From: sts-reporting@company-x.example\r\n" +
+		"Subject: Report Domain: company-y.example Submitter: company-x.example Report-ID: <5065427c>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=\"tlsrpt\"; boundary=\"b1\"\r\n\r\n" +
+		"--b1\r\nContent-Type: text/plain\r\n\r\nThis is an aggregate TLS report.\r\n" +
+		"--b1\r\nContent-Type: application/tlsrpt+gzip\r\n" +
+		"Content-Disposition: attachment; filename=\"company-x.example!company-y.example!1459468800!1459555199.json.gz\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		base64.StdEncoding.EncodeToString(gzipped.Bytes()) + "\r\n--b1--\r\n"
+
+	reports, err := ParseMessage([]byte(message))
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "5065427c-23d3-47ca-b6e0-946ea0e8c4be", reports[0].ReportID)
+}
@@ -6,38 +6,61 @@ import (
 	"github.com/google/uuid"
 )
 
+type AccountStatus string
+
+const (
+	AccountStatusActive    AccountStatus = "active"
+	AccountStatusSuspended AccountStatus = "suspended"
+	AccountStatusClosed    AccountStatus = "closed"
+)
+
 type Account struct {
-	ID                 uuid.UUID `json:"id" db:"id"`
-	APIKeyHash         string    `json:"-" db:"api_key_hash"`
-	Plan               string    `json:"plan" db:"plan"`
-	EmailLimitPerMonth int       `json:"email_limit_per_month" db:"email_limit_per_month"`
-	EmailAddressLimit  int       `json:"email_address_limit" db:"email_address_limit"`
-	IsAdmin            bool      `json:"is_admin" db:"is_admin"`
-	CreatedAt          time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+	ID                 uuid.UUID     `json:"id" db:"id"`
+	APIKeyHash         string        `json:"-" db:"api_key_hash"`
+	Plan               string        `json:"plan" db:"plan"`
+	EmailLimitPerMonth int           `json:"email_limit_per_month" db:"email_limit_per_month"`
+	EmailAddressLimit  int           `json:"email_address_limit" db:"email_address_limit"`
+	IsAdmin            bool          `json:"is_admin" db:"is_admin"`
+	Status             AccountStatus `json:"status" db:"status"`
+	StatusReason       *string       `json:"status_reason,omitempty" db:"status_reason"`
+	StatusChangedAt    *time.Time    `json:"status_changed_at,omitempty" db:"status_changed_at"`
+	CreatedAt          time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at" db:"updated_at"`
 }
 
 type CreateAccountRequest struct {
-	Plan string `json:"plan" validate:"omitempty,oneof=starter pro enterprise"`
+	Plan             string `json:"plan" validate:"omitempty,max=50"` // A plan ID from the plans table
+	OrganizationName string `json:"organization_name" validate:"omitempty,max=255"`
+	// OwnerEmail optionally registers the first organization owner; the returned key then acts for them
+	OwnerEmail string `json:"owner_email" validate:"omitempty,email"`
+	OwnerName  string `json:"owner_name" validate:"omitempty,max=255"`
 }
 
 type AccountResponse struct {
-	ID                   uuid.UUID `json:"id"`
-	Plan                 string    `json:"plan"`
-	EmailLimitPerMonth   int       `json:"email_limit_per_month"`
-	EmailAddressLimit    int       `json:"email_address_limit"`
-	IsAdmin              bool      `json:"is_admin,omitempty"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
-	APIKey               string    `json:"api_key,omitempty"` // Only returned on creation
-	EmailAddressesCount  int       `json:"email_addresses_count,omitempty"`
-	TPSCount             int       `json:"tps_count,omitempty"` // 3rd Party Software
-	CustomDomainsCount   int       `json:"custom_domains_count,omitempty"`
-	VerifiedDomainsCount int       `json:"verified_domains_count,omitempty"`
+	ID                   uuid.UUID     `json:"id"`
+	Plan                 string        `json:"plan"`
+	EmailLimitPerMonth   int           `json:"email_limit_per_month"`
+	EmailAddressLimit    int           `json:"email_address_limit"`
+	IsAdmin              bool          `json:"is_admin,omitempty"`
+	Status               AccountStatus `json:"status"`
+	StatusReason         *string       `json:"status_reason,omitempty"`
+	StatusChangedAt      *time.Time    `json:"status_changed_at,omitempty"`
+	CreatedAt            time.Time     `json:"created_at"`
+	UpdatedAt            time.Time     `json:"updated_at"`
+	APIKey               string        `json:"api_key,omitempty"` // Only returned on creation
+	EmailAddressesCount  int           `json:"email_addresses_count,omitempty"`
+	TPSCount             int           `json:"tps_count,omitempty"` // 3rd Party Software
+	CustomDomainsCount   int           `json:"custom_domains_count,omitempty"`
+	VerifiedDomainsCount int           `json:"verified_domains_count,omitempty"`
 }
 
 type UpdateAccountRequest struct {
-	Plan *string `json:"plan" validate:"omitempty,oneof=starter pro enterprise"`
+	Plan *string `json:"plan" validate:"omitempty,max=50"`
+}
+
+// ChangeAccountStatusRequest suspends, reinstates or closes an account
+type ChangeAccountStatusRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
 }
 
 // GlobalStats contains aggregated statistics for admin dashboards
@@ -47,3 +70,17 @@ type GlobalStats struct {
 	TotalCustomDomains   int64 `json:"total_custom_domains"`
 	TotalVerifiedDomains int64 `json:"total_verified_domains"`
 }
+
+// AccountDeletionResponse describes a pending self-service account deletion
+type AccountDeletionResponse struct {
+	Pending     bool       `json:"pending"`
+	RequestedAt *time.Time `json:"requested_at,omitempty"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+}
+
+// AccountExportManifest is the manifest.json at the root of an account export archive
+type AccountExportManifest struct {
+	AccountID   uuid.UUID `json:"account_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key scopes. Each route requires one scope; a key only reaches the routes its scopes cover.
+const (
+	ScopeAccountRead    = "account:read"
+	ScopeAccountWrite   = "account:write"
+	ScopeAddressesRead  = "addresses:read"
+	ScopeAddressesWrite = "addresses:write"
+	ScopeEmailsRead     = "emails:read"
+	ScopeEmailsSend     = "emails:send"
+	ScopeDomainsRead    = "domains:read"
+	ScopeDomainsWrite   = "domains:write"
+	ScopeTPSRead        = "tps:read"
+	ScopeTPSWrite       = "tps:write"
+	ScopeTPSReadSecrets = "tps:read-secrets"
+	ScopeOrgRead        = "organization:read"
+	ScopeOrgWrite       = "organization:write"
+	ScopeBillingRead    = "billing:read"
+	ScopeBillingWrite   = "billing:write"
+	ScopeAuditRead      = "audit:read"
+	ScopeAdmin          = "admin"
+)
+
+// AllAPIKeyScopes lists every scope
+var AllAPIKeyScopes = []string{
+	ScopeAccountRead,
+	ScopeAccountWrite,
+	ScopeAddressesRead,
+	ScopeAddressesWrite,
+	ScopeEmailsRead,
+	ScopeEmailsSend,
+	ScopeDomainsRead,
+	ScopeDomainsWrite,
+	ScopeTPSRead,
+	ScopeTPSWrite,
+	ScopeTPSReadSecrets,
+	ScopeOrgRead,
+	ScopeOrgWrite,
+	ScopeBillingRead,
+	ScopeBillingWrite,
+	ScopeAuditRead,
+	ScopeAdmin,
+}
+
+// DefaultAPIKeyScopes are granted to the key issued when an account is created: every
+// scope but admin, which operators grant explicitly
+var DefaultAPIKeyScopes = func() []string {
+	scopes := []string{}
+	for _, scope := range AllAPIKeyScopes {
+		if scope != ScopeAdmin {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}()
+
+// IsValidAPIKeyScope reports whether scope is a known API key scope
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range AllAPIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether scopes contains scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IntersectScopes returns the scopes present in both lists, keeping the order of a
+func IntersectScopes(a, b []string) []string {
+	result := []string{}
+	for _, scope := range a {
+		if HasScope(b, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+// APIKeyRestrictions limit where a key can be used from. Empty lists impose no restriction.
+type APIKeyRestrictions struct {
+	AllowedCIDRs   []string `json:"allowed_cidrs"`   // Client IPs the key is accepted from, e.g. "203.0.113.0/24"
+	AllowedOrigins []string `json:"allowed_origins"` // Browser origins the key is accepted from, e.g. "https://app.example.com"
+}
+
+type APIKey struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	AccountID     uuid.UUID  `json:"account_id" db:"account_id"`
+	MemberID      *uuid.UUID `json:"member_id" db:"member_id"`
+	Name          string     `json:"name" db:"name"`
+	KeyPrefix     string     `json:"key_prefix" db:"key_prefix"`
+	KeyID         *string    `json:"-" db:"key_id"` // nil for keys issued before key IDs
+	KeyHash       string     `json:"-" db:"key_hash"`
+	PepperVersion *int       `json:"-" db:"pepper_version"`
+	Scopes        []string   `json:"scopes" db:"scopes"`
+	ExpiresAt     *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt    *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt     *time.Time `json:"revoked_at" db:"revoked_at"`
+	ReplacedByID  *uuid.UUID `json:"replaced_by_id" db:"replaced_by_id"`
+	RotatedAt     *time.Time `json:"rotated_at" db:"rotated_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	APIKeyRestrictions
+}
+
+// IsActive returns true if the key is neither revoked nor expired at the given time
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || k.ExpiresAt.After(now)
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	APIKeyRestrictions
+}
+
+// UpdateAPIKeyRestrictionsRequest replaces a key's restrictions. Omitted lists are left
+// unchanged and empty lists remove the restriction.
+type UpdateAPIKeyRestrictionsRequest struct {
+	AllowedCIDRs   *[]string `json:"allowed_cidrs"`
+	AllowedOrigins *[]string `json:"allowed_origins"`
+}
+
+type APIKeyResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	MemberID     *uuid.UUID `json:"member_id,omitempty"` // Set when the key acts for an organization member
+	KeyPrefix    string     `json:"key_prefix"`
+	Scopes       []string   `json:"scopes"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uuid.UUID `json:"replaced_by_id,omitempty"`
+	RotatedAt    *time.Time `json:"rotated_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	APIKey       string     `json:"api_key,omitempty"` // Only returned on creation
+	APIKeyRestrictions
+}
+
+// RotateAPIKeyRequest controls how long the old key keeps working after rotation.
+// A nil grace period uses the server default; zero revokes the old key immediately.
+type RotateAPIKeyRequest struct {
+	GracePeriodSeconds *int `json:"grace_period_seconds" validate:"omitempty,min=0"`
+}
+
+type APIKeyRotationResponse struct {
+	APIKey      string          `json:"api_key"` // The new key, kept at the top level for older clients
+	NewKey      *APIKeyResponse `json:"new_key"`
+	PreviousKey *APIKeyResponse `json:"previous_key"`
+}
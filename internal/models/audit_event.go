@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent is an immutable record of a mutating call or secret access
+type AuditEvent struct {
+	ID                 uuid.UUID       `json:"id" db:"id"`
+	Seq                int64           `json:"-" db:"seq"`
+	AccountID          uuid.UUID       `json:"account_id" db:"account_id"`
+	ActorAPIKeyID      *uuid.UUID      `json:"actor_api_key_id" db:"actor_api_key_id"`
+	ActorMemberID      *uuid.UUID      `json:"actor_member_id" db:"actor_member_id"`
+	ActorOAuthClientID *uuid.UUID      `json:"actor_oauth_client_id" db:"actor_oauth_client_id"`
+	Action             string          `json:"action" db:"action"`
+	ResourceType       string          `json:"resource_type" db:"resource_type"`
+	ResourceID         *string         `json:"resource_id" db:"resource_id"`
+	IPAddress          *string         `json:"ip_address" db:"ip_address"`
+	UserAgent          *string         `json:"user_agent" db:"user_agent"`
+	RequestID          *string         `json:"request_id" db:"request_id"`
+	Before             json.RawMessage `json:"before" db:"before"`
+	After              json.RawMessage `json:"after" db:"after"`
+	PrevHash           string          `json:"prev_hash" db:"prev_hash"`
+	Hash               string          `json:"hash" db:"hash"`
+	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
+	// RedactedAt is set once the account was erased and the event's personal data cleared
+	RedactedAt *time.Time `json:"redacted_at,omitempty" db:"redacted_at"`
+}
+
+// AuditChange is a top-level field whose value differs between before and after
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+type AuditEventResponse struct {
+	*AuditEvent
+	Changes map[string]AuditChange `json:"changes,omitempty"`
+}
+
+type AuditEventFilter struct {
+	Action             string
+	ResourceType       string
+	ResourceID         string
+	ActorAPIKeyID      *uuid.UUID
+	ActorMemberID      *uuid.UUID
+	ActorOAuthClientID *uuid.UUID
+	RequestID          string
+	CreatedAfter       *time.Time
+	CreatedBefore      *time.Time
+	Cursor             string
+	Limit              int
+}
+
+type AuditEventListResponse struct {
+	Events     []*AuditEventResponse `json:"audit_events"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	HasMore    bool                  `json:"has_more"`
+}
+
+// AuditChainVerification reports whether an account's hash chain is intact
+type AuditChainVerification struct {
+	Valid         bool       `json:"valid"`
+	EventsChecked int        `json:"events_checked"`
+	FirstInvalid  *uuid.UUID `json:"first_invalid_event_id,omitempty"`
+}
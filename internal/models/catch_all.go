@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CatchAllAction string
+
+const (
+	CatchAllActionCreate CatchAllAction = "create" // Creates the address mail was sent to
+	CatchAllActionRoute  CatchAllAction = "route"  // Delivers to the rule's target address
+)
+
+// CatchAllRule accepts mail for the local parts of a custom domain that match Pattern,
+// where '*' matches any characters, e.g. signup-* or * for every local part
+type CatchAllRule struct {
+	ID             uuid.UUID      `json:"id" db:"id"`
+	CustomDomainID uuid.UUID      `json:"custom_domain_id" db:"custom_domain_id"`
+	Pattern        string         `json:"pattern" db:"pattern"`
+	Action         CatchAllAction `json:"action" db:"action"`
+	// TargetEmailAddressID receives the mail of a route rule, and of a create rule
+	// once the account's address limit is reached
+	TargetEmailAddressID *uuid.UUID `json:"target_email_address_id" db:"target_email_address_id"`
+	// AddressType is the type of the addresses a create rule creates
+	AddressType *EmailAddressType `json:"address_type,omitempty" db:"address_type"`
+	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+type CreateCatchAllRuleRequest struct {
+	Pattern              string           `json:"pattern" binding:"required"`
+	Action               CatchAllAction   `json:"action" binding:"required,oneof=create route"`
+	TargetEmailAddressID *uuid.UUID       `json:"target_email_address_id"`
+	AddressType          EmailAddressType `json:"address_type" binding:"omitempty,oneof=temporary persistent"`
+}
+
+// ResolveRecipientRequest asks which address receives mail sent to Recipient
+type ResolveRecipientRequest struct {
+	Recipient string `json:"recipient" binding:"required"`
+}
+
+// RecipientResolution is the address that receives mail sent to a recipient. RuleID
+// is set when a catch-all rule accepted it, and Created when the rule created it.
+type RecipientResolution struct {
+	Recipient    string                `json:"recipient"`
+	EmailAddress *EmailAddressResponse `json:"email_address"`
+	RuleID       *uuid.UUID            `json:"rule_id,omitempty"`
+	Created      bool                  `json:"created"`
+}
@@ -13,6 +13,7 @@ const (
 	CustomDomainStatusVerified CustomDomainStatus = "verified"
 	CustomDomainStatusFailed   CustomDomainStatus = "failed"
 	CustomDomainStatusDisabled CustomDomainStatus = "disabled"
+	CustomDomainStatusDegraded CustomDomainStatus = "degraded"
 )
 
 type SESVerificationStatus string
@@ -25,12 +26,53 @@ const (
 	SESVerificationStatusNotStarted       SESVerificationStatus = "NotStarted"
 )
 
+// DNS record purposes. Records without a purpose and MAIL FROM records come from the
+// verification provider and are replaced whenever verification is initiated again.
+const (
+	DNSRecordPurposeDKIM     = "dkim"      // Self-hosted DKIM selector
+	DNSRecordPurposeMailFrom = "mail_from" // MX and SPF records of the custom MAIL FROM domain
+	DNSRecordPurposeMTASTS   = "mta_sts"   // MTA-STS policy host and TXT records, and the TLS-RPT record
+)
+
+// MailFromSubdomain is the label prepended to a custom domain to form its MAIL FROM domain
+const MailFromSubdomain = "bounce"
+
+// Custom MAIL FROM verification statuses
+const (
+	MailFromStatusPending  = "pending"
+	MailFromStatusVerified = "verified"
+	MailFromStatusFailed   = "failed"
+)
+
 type DNSRecord struct {
 	Type     string `json:"type"`
 	Name     string `json:"name"`
 	Value    string `json:"value"`
 	TTL      int    `json:"ttl,omitempty"`
 	Priority int    `json:"priority,omitempty"`
+	Purpose  string `json:"purpose,omitempty"`
+}
+
+// ReplaceProviderDNSRecords swaps the verification provider's records for new ones and
+// keeps records published for other purposes
+func (cd *CustomDomain) ReplaceProviderDNSRecords(records []DNSRecord) {
+	for _, record := range cd.DNSRecords {
+		if record.Purpose != "" && record.Purpose != DNSRecordPurposeMailFrom {
+			records = append(records, record)
+		}
+	}
+	cd.DNSRecords = records
+}
+
+// ReplaceDNSRecords swaps the records published for purpose for new ones
+func (cd *CustomDomain) ReplaceDNSRecords(purpose string, records []DNSRecord) {
+	kept := make([]DNSRecord, 0, len(cd.DNSRecords)+len(records))
+	for _, record := range cd.DNSRecords {
+		if record.Purpose != purpose {
+			kept = append(kept, record)
+		}
+	}
+	cd.DNSRecords = append(kept, records...)
 }
 
 type CustomDomain struct {
@@ -49,14 +91,17 @@ type CustomDomain struct {
 	VerificationAttemptedAt    *time.Time             `json:"verification_attempted_at,omitempty" db:"verification_attempted_at"`
 	VerifiedAt                 *time.Time             `json:"verified_at,omitempty" db:"verified_at"`
 	FailureReason              *string                `json:"failure_reason,omitempty" db:"failure_reason"`
+	MailFromDomain             *string                `json:"mail_from_domain,omitempty" db:"mail_from_domain"`
+	MailFromStatus             *string                `json:"mail_from_status,omitempty" db:"mail_from_status"`
 	Metadata                   map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
 	CreatedAt                  time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt                  time.Time              `json:"updated_at" db:"updated_at"`
 }
 
-// IsVerified returns true if the domain is verified
+// IsVerified returns true if the domain is verified, including a verified domain a
+// health check found problems with
 func (cd *CustomDomain) IsVerified() bool {
-	return cd.Status == CustomDomainStatusVerified
+	return cd.Status == CustomDomainStatusVerified || cd.Status == CustomDomainStatusDegraded
 }
 
 // CanSendEmails returns true if the domain can be used for sending emails
@@ -64,6 +109,11 @@ func (cd *CustomDomain) CanSendEmails() bool {
 	return cd.Status == CustomDomainStatusVerified
 }
 
+// DefaultMailFromDomain returns the MAIL FROM domain providers are set up with for this domain, e.g. bounce.example.com
+func (cd *CustomDomain) DefaultMailFromDomain() string {
+	return MailFromSubdomain + "." + cd.Domain
+}
+
 // GetDefaultFromAddress returns a default from address for this domain
 func (cd *CustomDomain) GetDefaultFromAddress() string {
 	return "noreply@" + cd.Domain
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DKIMKeyStatus string
+
+const (
+	DKIMKeyStatusPending DKIMKeyStatus = "pending" // Published, waiting for DNS before signing
+	DKIMKeyStatusActive  DKIMKeyStatus = "active"  // Signs outbound mail
+	DKIMKeyStatusRetired DKIMKeyStatus = "retired" // Replaced, still published until removed
+)
+
+// DKIMKey is a self-hosted DKIM key for a custom domain
+type DKIMKey struct {
+	ID                  uuid.UUID     `json:"id" db:"id"`
+	CustomDomainID      uuid.UUID     `json:"custom_domain_id" db:"custom_domain_id"`
+	Selector            string        `json:"selector" db:"selector"`
+	Algorithm           string        `json:"algorithm" db:"algorithm"`
+	PublicKeyRecord     string        `json:"public_key_record" db:"public_key_record"`
+	EncryptedPrivateKey string        `json:"-" db:"encrypted_private_key"`
+	Status              DKIMKeyStatus `json:"status" db:"status"`
+	ActivatedAt         *time.Time    `json:"activated_at,omitempty" db:"activated_at"`
+	RetiredAt           *time.Time    `json:"retired_at,omitempty" db:"retired_at"`
+	CreatedAt           time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// EnableDKIMRequest turns on self-hosted DKIM signing for a custom domain
+type EnableDKIMRequest struct {
+	Algorithm string `json:"algorithm" binding:"omitempty,oneof=rsa-sha256 ed25519-sha256"`
+}
+
+// DKIMKeyListResponse lists a custom domain's DKIM keys, newest first
+type DKIMKeyListResponse struct {
+	Keys []*DKIMKey `json:"keys"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// DMARCAlignmentStats counts messages by DMARC outcome. A message passes DMARC when
+// DKIM or SPF passed aligned with the From domain.
+type DMARCAlignmentStats struct {
+	Messages    int `json:"messages"`
+	DMARCPass   int `json:"dmarc_pass"`
+	DMARCFail   int `json:"dmarc_fail"`
+	DKIMAligned int `json:"dkim_aligned"`
+	SPFAligned  int `json:"spf_aligned"`
+	Quarantined int `json:"quarantined"`
+	Rejected    int `json:"rejected"`
+	PassRatePct int `json:"pass_rate_pct"`
+}
+
+// DMARCDailyStats are the alignment counts of reports starting on one day (UTC)
+type DMARCDailyStats struct {
+	Date string `json:"date"`
+	DMARCAlignmentStats
+}
+
+// DMARCSourceStats are the alignment counts of mail from one source IP. Sources that
+// mostly fail are either unauthorized senders or services missing from SPF and DKIM.
+type DMARCSourceStats struct {
+	SourceIP    string   `json:"source_ip"`
+	HeaderFrom  []string `json:"header_from"`
+	DKIMDomains []string `json:"dkim_domains,omitempty"`
+	SPFDomains  []string `json:"spf_domains,omitempty"`
+	DMARCAlignmentStats
+}
+
+// DMARCReporterStats summarizes the reports one receiver sent
+type DMARCReporterStats struct {
+	OrgName  string `json:"org_name"`
+	Reports  int    `json:"reports"`
+	Messages int    `json:"messages"`
+}
+
+// DMARCStatsResponse is the alignment overview of a custom domain over a period
+type DMARCStatsResponse struct {
+	Domain    string               `json:"domain"`
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Reports   int                  `json:"reports"`
+	Totals    DMARCAlignmentStats  `json:"totals"`
+	Daily     []DMARCDailyStats    `json:"daily"`
+	Sources   []DMARCSourceStats   `json:"sources"`
+	Reporters []DMARCReporterStats `json:"reporters"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DNSProviderConnection links a custom domain to the DNS provider hosting its zone,
+// through which Maylng publishes the domain's records. Credentials are stored
+// encrypted and never returned.
+type DNSProviderConnection struct {
+	CustomDomainID     uuid.UUID   `json:"custom_domain_id" db:"custom_domain_id"`
+	Provider           string      `json:"provider" db:"provider"`
+	Zone               string      `json:"zone" db:"zone"`
+	ProvisionedRecords []DNSRecord `json:"provisioned_records" db:"provisioned_records"`
+	LastSyncedAt       *time.Time  `json:"last_synced_at,omitempty" db:"last_synced_at"`
+	LastError          *string     `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt          time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// ConnectDNSProviderRequest delegates access to the zone a custom domain is in. Zone
+// defaults to the domain itself.
+type ConnectDNSProviderRequest struct {
+	Provider    string            `json:"provider" binding:"required,oneof=cloudflare route53 rfc2136"`
+	Zone        string            `json:"zone,omitempty"`
+	Credentials map[string]string `json:"credentials" binding:"required"`
+}
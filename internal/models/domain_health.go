@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Severities of a domain health reason. A critical problem degrades the domain
+// whatever its score.
+const (
+	DomainHealthSeverityCritical = "critical"
+	DomainHealthSeverityError    = "error"
+	DomainHealthSeverityWarning  = "warning"
+)
+
+// DomainHealth is the latest health check of a verified custom domain. Score starts
+// at 100 and every problem found takes its penalty off.
+type DomainHealth struct {
+	CustomDomainID uuid.UUID            `json:"custom_domain_id" db:"custom_domain_id"`
+	Status         CustomDomainStatus   `json:"status"`
+	Score          int                  `json:"score" db:"score"`
+	Reasons        []DomainHealthReason `json:"reasons" db:"reasons"`
+	BlocklistedOn  []string             `json:"blocklisted_on" db:"blocklisted_on"`
+	CheckedAt      time.Time            `json:"checked_at" db:"checked_at"`
+	NextCheckAt    time.Time            `json:"next_check_at" db:"next_check_at"`
+	DegradedSince  *time.Time           `json:"degraded_since,omitempty" db:"degraded_since"`
+}
+
+// DomainHealthReason is one problem a health check found
+type DomainHealthReason struct {
+	Check    string `json:"check"` // records, spf, dmarc, mx or blocklist
+	Severity string `json:"severity"`
+	Problem  string `json:"problem"`
+	Fix      string `json:"fix,omitempty"`
+	Penalty  int    `json:"penalty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DomainSettings are the sending settings of a custom domain. The send pipeline
+// applies them to every email sent from the domain, whatever the email provider.
+type DomainSettings struct {
+	CustomDomainID uuid.UUID `json:"custom_domain_id" db:"custom_domain_id"`
+	OpenTracking   bool      `json:"open_tracking" db:"open_tracking"`
+	ClickTracking  bool      `json:"click_tracking" db:"click_tracking"`
+	// RequireTLS only sends through providers that refuse unencrypted delivery
+	RequireTLS bool `json:"require_tls" db:"require_tls"`
+	// DefaultFromName and DefaultReplyTo apply when an email doesn't set its own
+	DefaultFromName *string `json:"default_from_name" db:"default_from_name"`
+	DefaultReplyTo  *string `json:"default_reply_to" db:"default_reply_to"`
+	// AllowedSenderPrefixes restricts the local parts emails can be sent from; empty
+	// allows any
+	AllowedSenderPrefixes []string  `json:"allowed_sender_prefixes" db:"allowed_sender_prefixes"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UpdateDomainSettingsRequest changes the settings that are set. An empty
+// default_from_name or default_reply_to clears it.
+type UpdateDomainSettingsRequest struct {
+	OpenTracking          *bool     `json:"open_tracking"`
+	ClickTracking         *bool     `json:"click_tracking"`
+	RequireTLS            *bool     `json:"require_tls"`
+	DefaultFromName       *string   `json:"default_from_name"`
+	DefaultReplyTo        *string   `json:"default_reply_to"`
+	AllowedSenderPrefixes *[]string `json:"allowed_sender_prefixes"`
+}
+
+// EmailTracking summarizes the opens and clicks of a tracked email
+type EmailTracking struct {
+	EmailID       uuid.UUID           `json:"email_id"`
+	Opens         int                 `json:"opens"`
+	FirstOpenedAt *time.Time          `json:"first_opened_at,omitempty"`
+	LastOpenedAt  *time.Time          `json:"last_opened_at,omitempty"`
+	Clicks        int                 `json:"clicks"`
+	Links         []EmailTrackingLink `json:"links"`
+}
+
+// EmailTrackingLink counts the clicks of one link in a tracked email
+type EmailTrackingLink struct {
+	URL    string `json:"url"`
+	Clicks int    `json:"clicks"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MTASTSMode string
+
+const (
+	MTASTSModeTesting MTASTSMode = "testing" // Senders report TLS failures but still deliver
+	MTASTSModeEnforce MTASTSMode = "enforce" // Senders refuse to deliver without valid TLS
+	MTASTSModeNone    MTASTSMode = "none"    // Withdraws an earlier policy from senders' caches
+)
+
+// MTASTSPolicy is the MTA-STS policy served for a custom domain
+type MTASTSPolicy struct {
+	CustomDomainID uuid.UUID   `json:"custom_domain_id" db:"custom_domain_id"`
+	Mode           MTASTSMode  `json:"mode" db:"mode"`
+	PolicyID       string      `json:"policy_id" db:"policy_id"`
+	MaxAge         int         `json:"max_age" db:"max_age"`
+	MXHosts        []string    `json:"mx"`
+	Policy         string      `json:"policy"`
+	DNSRecords     []DNSRecord `json:"dns_records"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// SetMTASTSPolicyRequest publishes or changes a custom domain's MTA-STS policy
+type SetMTASTSPolicyRequest struct {
+	Mode MTASTSMode `json:"mode" binding:"required,oneof=testing enforce none"`
+}
+
+// TLSSessionStats counts TLS sessions senders reported
+type TLSSessionStats struct {
+	Successful     int `json:"successful_sessions"`
+	Failed         int `json:"failed_sessions"`
+	FailureRatePct int `json:"failure_rate_pct"`
+}
+
+// TLSDailyStats are the session counts of reports starting on one day (UTC)
+type TLSDailyStats struct {
+	Date string `json:"date"`
+	TLSSessionStats
+}
+
+// TLSFailureStats groups failed sessions by cause and receiving MX host
+type TLSFailureStats struct {
+	ResultType          string   `json:"result_type"`
+	ReceivingMXHostname string   `json:"receiving_mx_hostname,omitempty"`
+	FailedSessions      int      `json:"failed_sessions"`
+	Reporters           []string `json:"reporters"`
+}
+
+// TLSReportStatsResponse is the inbound TLS overview of a custom domain over a period
+type TLSReportStatsResponse struct {
+	Domain   string            `json:"domain"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Reports  int               `json:"reports"`
+	Totals   TLSSessionStats   `json:"totals"`
+	Daily    []TLSDailyStats   `json:"daily"`
+	Failures []TLSFailureStats `json:"failures"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OAuthClient struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	AccountID        uuid.UUID  `json:"account_id" db:"account_id"`
+	MemberID         *uuid.UUID `json:"member_id" db:"member_id"`
+	Name             string     `json:"name" db:"name"`
+	ClientID         string     `json:"client_id" db:"client_id"`
+	ClientSecretHash string     `json:"-" db:"client_secret_hash"`
+	PepperVersion    int        `json:"-" db:"pepper_version"`
+	Scopes           []string   `json:"scopes" db:"scopes"`
+	LastUsedAt       *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt        *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateOAuthClientRequest struct {
+	Name   string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1"`
+}
+
+type OAuthClientResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	MemberID     *uuid.UUID `json:"member_id,omitempty"`
+	ClientID     string     `json:"client_id"`
+	Scopes       []string   `json:"scopes"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ClientSecret string     `json:"client_secret,omitempty"` // Only returned on creation
+}
+
+// OAuthTokenResponse is the RFC 6749 access token response
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization member roles
+const (
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleDeveloper = "developer"
+	RoleViewer    = "viewer"
+)
+
+var viewerScopes = []string{
+	ScopeAccountRead,
+	ScopeAddressesRead,
+	ScopeEmailsRead,
+	ScopeDomainsRead,
+	ScopeTPSRead,
+	ScopeOrgRead,
+	ScopeBillingRead,
+}
+
+var developerScopes = append(append([]string{}, viewerScopes...),
+	ScopeAddressesWrite,
+	ScopeEmailsSend,
+	ScopeDomainsWrite,
+	ScopeTPSWrite,
+	ScopeTPSReadSecrets,
+)
+
+var adminScopes = append(append([]string{}, developerScopes...),
+	ScopeAccountWrite,
+	ScopeOrgWrite,
+	ScopeAuditRead,
+)
+
+// RolePermissions lists the scopes each role may exercise. A key acting for a member is
+// limited to the intersection of its own scopes and its member's role permissions.
+var RolePermissions = map[string][]string{
+	RoleOwner:     AllAPIKeyScopes,
+	RoleAdmin:     adminScopes,
+	RoleDeveloper: developerScopes,
+	RoleViewer:    viewerScopes,
+}
+
+// IsValidRole reports whether role is a known organization role
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+type Organization struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	AccountID uuid.UUID `json:"account_id" db:"account_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type OrganizationMember struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	OrganizationID uuid.UUID `json:"organization_id" db:"organization_id"`
+	Email          string    `json:"email" db:"email"`
+	Name           *string   `json:"name" db:"name"`
+	Role           string    `json:"role" db:"role"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type OrganizationResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	MembersCount int       `json:"members_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=255"`
+}
+
+type AddMemberRequest struct {
+	Email string  `json:"email" validate:"required,email"`
+	Name  *string `json:"name" validate:"omitempty,max=255"`
+	Role  string  `json:"role" validate:"required,oneof=owner admin developer viewer"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin developer viewer"`
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+)
+
+// Plan feature flags
+const (
+	FeatureCustomDomains  = "custom_domains"
+	FeatureScheduledSends = "scheduled_sends"
+)
+
+// AllPlanFeatures lists every feature flag a plan can carry
+var AllPlanFeatures = []string{
+	FeatureCustomDomains,
+	FeatureScheduledSends,
+}
+
+// IsValidPlanFeature reports whether feature is a known plan feature flag
+func IsValidPlanFeature(feature string) bool {
+	for _, f := range AllPlanFeatures {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanLimits are the quotas and features of a plan. An account's effective limits are
+// its plan's limits with the account's overrides applied.
+type PlanLimits struct {
+	EmailLimitPerMonth int             `json:"email_limit_per_month"`
+	EmailLimitPerDay   int             `json:"email_limit_per_day"`
+	EmailLimitPerHour  int             `json:"email_limit_per_hour"`
+	EmailAddressLimit  int             `json:"email_address_limit"`
+	TPSPerAddressLimit int             `json:"tps_per_address_limit"`
+	CustomDomainLimit  int             `json:"custom_domain_limit"`
+	APICallsPerHour    int             `json:"api_calls_per_hour"`
+	Features           map[string]bool `json:"features"`
+}
+
+// HasFeature reports whether the feature flag is enabled
+func (l *PlanLimits) HasFeature(feature string) bool {
+	return l.Features[feature]
+}
+
+// WithOverrides returns the limits with every set override applied
+func (l PlanLimits) WithOverrides(o *LimitOverrides) PlanLimits {
+	if o == nil {
+		return l
+	}
+
+	apply := func(limit *int, override *int) {
+		if override != nil {
+			*limit = *override
+		}
+	}
+	apply(&l.EmailLimitPerMonth, o.EmailLimitPerMonth)
+	apply(&l.EmailLimitPerDay, o.EmailLimitPerDay)
+	apply(&l.EmailLimitPerHour, o.EmailLimitPerHour)
+	apply(&l.EmailAddressLimit, o.EmailAddressLimit)
+	apply(&l.TPSPerAddressLimit, o.TPSPerAddressLimit)
+	apply(&l.CustomDomainLimit, o.CustomDomainLimit)
+	apply(&l.APICallsPerHour, o.APICallsPerHour)
+
+	features := make(map[string]bool, len(l.Features)+len(o.Features))
+	for feature, enabled := range l.Features {
+		features[feature] = enabled
+	}
+	for feature, enabled := range o.Features {
+		features[feature] = enabled
+	}
+	l.Features = features
+
+	return l
+}
+
+type Plan struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	IsPublic  bool      `json:"is_public" db:"is_public"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	PlanLimits
+}
+
+// LimitOverrides replace individual plan limits for one account. Unset fields and
+// features fall back to the plan.
+type LimitOverrides struct {
+	EmailLimitPerMonth *int            `json:"email_limit_per_month,omitempty"`
+	EmailLimitPerDay   *int            `json:"email_limit_per_day,omitempty"`
+	EmailLimitPerHour  *int            `json:"email_limit_per_hour,omitempty"`
+	EmailAddressLimit  *int            `json:"email_address_limit,omitempty"`
+	TPSPerAddressLimit *int            `json:"tps_per_address_limit,omitempty"`
+	CustomDomainLimit  *int            `json:"custom_domain_limit,omitempty"`
+	APICallsPerHour    *int            `json:"api_calls_per_hour,omitempty"`
+	Features           map[string]bool `json:"features,omitempty"`
+}
+
+// UpsertPlanRequest creates or replaces a plan. Every limit must be given.
+type UpsertPlanRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	IsPublic bool   `json:"is_public"`
+	PlanLimits
+}
+
+// ChangeAccountPlanRequest moves an account to another plan. When LimitOverrides is set
+// it replaces the account's overrides; otherwise they are kept.
+type ChangeAccountPlanRequest struct {
+	Plan           string          `json:"plan" validate:"required"`
+	LimitOverrides *LimitOverrides `json:"limit_overrides"`
+}
+
+// AccountLimitsResponse shows an account's plan, its overrides and the resulting limits
+type AccountLimitsResponse struct {
+	Plan      string         `json:"plan"`
+	Limits    PlanLimits     `json:"limits"`
+	Overrides LimitOverrides `json:"overrides"`
+}
@@ -89,6 +89,30 @@ type EmailResponse struct {
 	Status            EmailStatus `json:"status"`
 	ProviderMessageID *string     `json:"provider_message_id"`
 	FailureReason     *string     `json:"failure_reason"`
+	Warnings          []string    `json:"warnings,omitempty"`
 	CreatedAt         time.Time   `json:"created_at"`
 	UpdatedAt         time.Time   `json:"updated_at"`
 }
+
+// EmailFilter narrows the sent emails returned by GET /v1/emails.
+// Cursor takes precedence over Offset when both are set.
+type EmailFilter struct {
+	Status        *EmailStatus
+	FromEmailID   *uuid.UUID
+	Recipient     string
+	ThreadID      *uuid.UUID
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Metadata      map[string]string
+	Query         string
+	Cursor        string
+	Limit         int
+	Offset        int
+}
+
+// EmailListResponse is a page of sent emails
+type EmailListResponse struct {
+	Emails     []*EmailResponse `json:"emails"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	HasMore    bool             `json:"has_more"`
+}
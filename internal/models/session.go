@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	MemberID   uuid.UUID  `json:"member_id" db:"member_id"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	IPAddress  *string    `json:"ip_address" db:"ip_address"`
+	UserAgent  *string    `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	Current    bool       `json:"current,omitempty"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required" validate:"required,email"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" binding:"required" validate:"required"`
+}
+
+type RefreshSessionRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required" validate:"required"`
+}
+
+type LogoutRequest struct {
+	// All ends every session of the member, not just the current one
+	All bool `json:"all"`
+}
+
+// SessionTokens is returned on login and refresh. The refresh token is only shown once.
+type SessionTokens struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`
+	RefreshToken string    `json:"refresh_token"`
+	SessionID    uuid.UUID `json:"session_id"`
+	MemberID     uuid.UUID `json:"member_id"`
+	AccountID    uuid.UUID `json:"account_id"`
+	Role         string    `json:"role"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Usage metrics
+const (
+	UsageMetricEmailsSent            = "emails_sent"
+	UsageMetricAddressesCreated      = "addresses_created"
+	UsageMetricInboundMessages       = "inbound_messages"
+	UsageMetricStorageBytes          = "storage_bytes"
+	UsageMetricBrowserSessionMinutes = "browser_session_minutes"
+)
+
+// AllUsageMetrics lists every metered metric
+var AllUsageMetrics = []string{
+	UsageMetricEmailsSent,
+	UsageMetricAddressesCreated,
+	UsageMetricInboundMessages,
+	UsageMetricStorageBytes,
+	UsageMetricBrowserSessionMinutes,
+}
+
+// IsValidUsageMetric reports whether metric is a known usage metric
+func IsValidUsageMetric(metric string) bool {
+	for _, m := range AllUsageMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// UsageRecord is one account's total for one metric on one UTC day
+type UsageRecord struct {
+	AccountID uuid.UUID `json:"account_id" db:"account_id"`
+	Date      string    `json:"date" db:"usage_date"`
+	Metric    string    `json:"metric" db:"metric"`
+	Quantity  int64     `json:"quantity" db:"quantity"`
+}
+
+// UsageFilter selects usage records in an inclusive range of UTC days
+type UsageFilter struct {
+	From      time.Time
+	To        time.Time
+	Metric    string
+	AccountID *uuid.UUID
+}
+
+type UsageDay struct {
+	Date     string `json:"date"`
+	Metric   string `json:"metric"`
+	Quantity int64  `json:"quantity"`
+}
+
+type UsageResponse struct {
+	From   string           `json:"from"`
+	To     string           `json:"to"`
+	Totals map[string]int64 `json:"totals"`
+	Usage  []UsageDay       `json:"usage"`
+}
+
+// StripeUsageRecord follows Stripe's usage record shape. Each record sets the day's
+// total, so re-exporting a range is idempotent.
+type StripeUsageRecord struct {
+	Customer       string `json:"customer"`
+	Metric         string `json:"metric"`
+	Quantity       int64  `json:"quantity"`
+	Timestamp      int64  `json:"timestamp"`
+	Action         string `json:"action"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/maylng/backend/internal/auth"
@@ -10,47 +11,45 @@ import (
 )
 
 type AccountService struct {
-	db   *sql.DB
-	salt string
+	db     *sql.DB
+	hasher *auth.KeyHasher
 }
 
-func NewAccountService(db *sql.DB, salt string) *AccountService {
+func NewAccountService(db *sql.DB, hasher *auth.KeyHasher) *AccountService {
 	return &AccountService{
-		db:   db,
-		salt: salt,
+		db:     db,
+		hasher: hasher,
 	}
 }
 
 func (s *AccountService) CreateAccount(req *models.CreateAccountRequest) (*models.AccountResponse, error) {
-	// Generate API key
-	apiKey, err := auth.GenerateAPIKey()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate API key: %w", err)
-	}
-
-	// Hash API key
-	hashedKey := auth.HashAPIKey(apiKey, s.salt)
-
 	// Set default plan if not provided
 	plan := "starter"
 	if req.Plan != "" {
 		plan = req.Plan
 	}
 
-	emailLimitPerMonth, emailAddressLimit, err := getPlanLimits(plan)
+	planLimits, err := getPlan(s.db, plan)
 	if err != nil {
 		return nil, err
 	}
+	emailLimitPerMonth, emailAddressLimit := planLimits.EmailLimitPerMonth, planLimits.EmailAddressLimit
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
 	// Insert account into database
 	query := `
-		INSERT INTO accounts (api_key_hash, plan, email_limit_per_month, email_address_limit)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO accounts (plan, email_limit_per_month, email_address_limit)
+		VALUES ($1, $2, $3)
 		RETURNING id, created_at, updated_at
 	`
 
 	var account models.Account
-	err = s.db.QueryRow(query, hashedKey, plan, emailLimitPerMonth, emailAddressLimit).Scan(
+	err = tx.QueryRow(query, plan, emailLimitPerMonth, emailAddressLimit).Scan(
 		&account.ID,
 		&account.CreatedAt,
 		&account.UpdatedAt,
@@ -60,37 +59,63 @@ func (s *AccountService) CreateAccount(req *models.CreateAccountRequest) (*model
 		return nil, fmt.Errorf("failed to create account: %w", err)
 	}
 
+	// Every account is owned by an organization
+	orgName := req.OrganizationName
+	if orgName == "" {
+		orgName = "Default"
+	}
+	var organizationID uuid.UUID
+	err = tx.QueryRow(
+		"INSERT INTO organizations (account_id, name) VALUES ($1, $2) RETURNING id",
+		account.ID, orgName,
+	).Scan(&organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	// When an owner is named, the first key acts for them
+	var ownerID *uuid.UUID
+	if req.OwnerEmail != "" {
+		var ownerName *string
+		if req.OwnerName != "" {
+			ownerName = &req.OwnerName
+		}
+		var id uuid.UUID
+		err = tx.QueryRow(
+			"INSERT INTO organization_members (organization_id, email, name, role) VALUES ($1, $2, $3, $4) RETURNING id",
+			organizationID, strings.ToLower(req.OwnerEmail), ownerName, models.RoleOwner,
+		).Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create organization owner: %w", err)
+		}
+		ownerID = &id
+	}
+
+	// Issue the account's first key with every scope but admin
+	apiKey, err := insertAPIKey(tx, s.hasher, account.ID, ownerID, "default", models.DefaultAPIKeyScopes, nil, models.APIKeyRestrictions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit account: %w", err)
+	}
+
 	return &models.AccountResponse{
 		ID:                 account.ID,
 		Plan:               plan,
 		EmailLimitPerMonth: emailLimitPerMonth,
 		EmailAddressLimit:  emailAddressLimit,
+		Status:             models.AccountStatusActive,
 		CreatedAt:          account.CreatedAt,
 		UpdatedAt:          account.UpdatedAt,
-		APIKey:             apiKey, // Only returned on creation
+		APIKey:             apiKey.APIKey, // Only returned on creation
 	}, nil
 }
 
-func getPlanLimits(plan string) (emailLimitPerMonth int, emailAddressLimit int, err error) {
-	switch plan {
-	case "starter":
-		emailLimitPerMonth = 5000
-		emailAddressLimit = 5
-	case "pro":
-		emailLimitPerMonth = 50000
-		emailAddressLimit = 50
-	case "enterprise":
-		emailLimitPerMonth = 175000
-		emailAddressLimit = 500
-	default:
-		return 0, 0, fmt.Errorf("invalid plan: %s", plan)
-	}
-	return emailLimitPerMonth, emailAddressLimit, nil
-}
-
 func (s *AccountService) GetAccount(accountID uuid.UUID) (*models.AccountResponse, error) {
 	query := `
-		SELECT id, plan, email_limit_per_month, email_address_limit, created_at, updated_at
+		SELECT id, plan, email_limit_per_month, email_address_limit, status, status_reason, status_changed_at, created_at, updated_at
 		FROM accounts WHERE id = $1
 	`
 
@@ -100,6 +125,9 @@ func (s *AccountService) GetAccount(accountID uuid.UUID) (*models.AccountRespons
 		&account.Plan,
 		&account.EmailLimitPerMonth,
 		&account.EmailAddressLimit,
+		&account.Status,
+		&account.StatusReason,
+		&account.StatusChangedAt,
 		&account.CreatedAt,
 		&account.UpdatedAt,
 	)
@@ -131,6 +159,9 @@ func (s *AccountService) GetAccount(accountID uuid.UUID) (*models.AccountRespons
 		EmailAddressLimit:   account.EmailAddressLimit,
 		EmailAddressesCount: emailAddressesCount,
 		TPSCount:            tpsCount,
+		Status:              account.Status,
+		StatusReason:        account.StatusReason,
+		StatusChangedAt:     account.StatusChangedAt,
 		CreatedAt:           account.CreatedAt,
 		UpdatedAt:           account.UpdatedAt,
 	}, nil
@@ -148,53 +179,35 @@ func (s *AccountService) UpdateAccount(accountID uuid.UUID, req *models.UpdateAc
 		plan = *req.Plan
 	}
 
-	emailLimitPerMonth, emailAddressLimit, err := getPlanLimits(plan)
+	// Customers can only pick public plans; admins move accounts to any plan
+	newPlan, err := getPlan(s.db, plan)
 	if err != nil {
 		return nil, err
 	}
+	if !newPlan.IsPublic && plan != currentAccount.Plan {
+		return nil, fmt.Errorf("invalid plan: %s", plan)
+	}
 
-	query := `
-		UPDATE accounts
-		SET plan = $1, email_limit_per_month = $2, email_address_limit = $3, updated_at = NOW()
-		WHERE id = $4
-		RETURNING id, plan, email_limit_per_month, email_address_limit, created_at, updated_at
-	`
-	var account models.Account
-	err = s.db.QueryRow(query, plan, emailLimitPerMonth, emailAddressLimit, accountID).Scan(
-		&account.ID,
-		&account.Plan,
-		&account.EmailLimitPerMonth,
-		&account.EmailAddressLimit,
-		&account.CreatedAt,
-		&account.UpdatedAt,
-	)
-
+	tx, err := s.db.Begin()
 	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE accounts SET plan = $1, updated_at = NOW() WHERE id = $2", plan, accountID); err != nil {
 		return nil, fmt.Errorf("failed to update account: %w", err)
 	}
 
-	// Get email addresses count
-	emailAddressesCount, err := s.getEmailAddressesCount(accountID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get email addresses count: %w", err)
+	// Overrides still apply on top of the new plan
+	if _, err := tx.Exec(recomputeAccountLimitsQuery+"a.id = $1", accountID); err != nil {
+		return nil, fmt.Errorf("failed to recompute account limits: %w", err)
 	}
 
-	// Get TPS count
-	tpsCount, err := s.getTPSCount(accountID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get TPS count: %w", err)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit account update: %w", err)
 	}
 
-	return &models.AccountResponse{
-		ID:                  account.ID,
-		Plan:                account.Plan,
-		EmailLimitPerMonth:  account.EmailLimitPerMonth,
-		EmailAddressLimit:   account.EmailAddressLimit,
-		EmailAddressesCount: emailAddressesCount,
-		TPSCount:            tpsCount,
-		CreatedAt:           account.CreatedAt,
-		UpdatedAt:           account.UpdatedAt,
-	}, nil
+	return s.GetAccount(accountID)
 }
 
 func (s *AccountService) DeleteAccount(accountID uuid.UUID) error {
@@ -228,7 +241,7 @@ func (s *AccountService) ListAccounts(limit, offset int) ([]*models.AccountRespo
 	}
 
 	query := `
-		SELECT id, plan, email_limit_per_month, email_address_limit, is_admin, created_at, updated_at
+		SELECT id, plan, email_limit_per_month, email_address_limit, is_admin, status, status_reason, status_changed_at, created_at, updated_at
 		FROM accounts
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -243,7 +256,7 @@ func (s *AccountService) ListAccounts(limit, offset int) ([]*models.AccountRespo
 	var results []*models.AccountResponse
 	for rows.Next() {
 		var acc models.Account
-		if err := rows.Scan(&acc.ID, &acc.Plan, &acc.EmailLimitPerMonth, &acc.EmailAddressLimit, &acc.IsAdmin, &acc.CreatedAt, &acc.UpdatedAt); err != nil {
+		if err := rows.Scan(&acc.ID, &acc.Plan, &acc.EmailLimitPerMonth, &acc.EmailAddressLimit, &acc.IsAdmin, &acc.Status, &acc.StatusReason, &acc.StatusChangedAt, &acc.CreatedAt, &acc.UpdatedAt); err != nil {
 			return nil, err
 		}
 		results = append(results, &models.AccountResponse{
@@ -252,6 +265,9 @@ func (s *AccountService) ListAccounts(limit, offset int) ([]*models.AccountRespo
 			EmailLimitPerMonth: acc.EmailLimitPerMonth,
 			EmailAddressLimit:  acc.EmailAddressLimit,
 			IsAdmin:            acc.IsAdmin,
+			Status:             acc.Status,
+			StatusReason:       acc.StatusReason,
+			StatusChangedAt:    acc.StatusChangedAt,
 			CreatedAt:          acc.CreatedAt,
 			UpdatedAt:          acc.UpdatedAt,
 		})
@@ -260,20 +276,83 @@ func (s *AccountService) ListAccounts(limit, offset int) ([]*models.AccountRespo
 	return results, nil
 }
 
-// RevokeAPIKey clears the stored api_key_hash for an account (forces generate new key)
-func (s *AccountService) RevokeAPIKey(accountID uuid.UUID) error {
-	query := `UPDATE accounts SET api_key_hash = NULL, updated_at = NOW() WHERE id = $1`
-	res, err := s.db.Exec(query, accountID)
+// SetAccountStatus suspends, reinstates or closes an account. No data is removed:
+// suspension pauses queued and scheduled sends until the account is reinstated, and
+// closing fails them so they never go out.
+func (s *AccountService) SetAccountStatus(accountID uuid.UUID, status models.AccountStatus, reason string) (*models.AccountResponse, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current models.AccountStatus
+	err = tx.QueryRow(`SELECT status FROM accounts WHERE id = $1 FOR UPDATE`, accountID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("account not found")
 	}
-	n, err := res.RowsAffected()
 	if err != nil {
+		return nil, fmt.Errorf("failed to get account status: %w", err)
+	}
+
+	if !canChangeAccountStatus(current, status) {
+		return nil, fmt.Errorf("invalid status change: account is %s", current)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE accounts SET status = $1, status_reason = $2, status_changed_at = NOW() WHERE id = $3`,
+		status, reason, accountID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account status: %w", err)
+	}
+
+	if status == models.AccountStatusClosed {
+		_, err = tx.Exec(
+			`UPDATE sent_emails SET status = 'failed', failure_reason = 'account closed' WHERE account_id = $1 AND status IN ('queued', 'scheduled')`,
+			accountID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cancel pending emails: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit account status: %w", err)
+	}
+
+	return s.GetAccount(accountID)
+}
+
+// canChangeAccountStatus reports whether an account may move between the statuses.
+// Active accounts can be suspended or closed, suspended accounts reinstated or closed,
+// and closed accounts only reinstated.
+func canChangeAccountStatus(from, to models.AccountStatus) bool {
+	switch to {
+	case models.AccountStatusSuspended:
+		return from == models.AccountStatusActive
+	case models.AccountStatusClosed:
+		return from == models.AccountStatusActive || from == models.AccountStatusSuspended
+	case models.AccountStatusActive:
+		return from == models.AccountStatusSuspended || from == models.AccountStatusClosed
+	}
+	return false
+}
+
+// RevokeAPIKey revokes every active API key for an account (forces generate new key)
+func (s *AccountService) RevokeAPIKey(accountID uuid.UUID) error {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM accounts WHERE id = $1)`, accountID).Scan(&exists); err != nil {
 		return err
 	}
-	if n == 0 {
+	if !exists {
 		return fmt.Errorf("account not found")
 	}
+
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE account_id = $1 AND revoked_at IS NULL`
+	if _, err := s.db.Exec(query, accountID); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -300,39 +379,6 @@ func (s *AccountService) GetGlobalStats() (*models.GlobalStats, error) {
 	return stats, nil
 }
 
-func (s *AccountService) GenerateNewAPIKey(accountID uuid.UUID) (string, error) {
-	// Generate a new API key
-	newAPIKey, err := auth.GenerateAPIKey()
-	if err != nil {
-		return "", fmt.Errorf("failed to generate new API key: %w", err)
-	}
-
-	// Hash the new API key
-	hashedKey := auth.HashAPIKey(newAPIKey, s.salt)
-
-	// Update the account with the new hashed API key
-	query := `
-		UPDATE accounts
-		SET api_key_hash = $1, updated_at = NOW()
-		WHERE id = $2
-	`
-	result, err := s.db.Exec(query, hashedKey, accountID)
-	if err != nil {
-		return "", fmt.Errorf("failed to update API key: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return "", fmt.Errorf("failed to check rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return "", fmt.Errorf("account not found")
-	}
-
-	return newAPIKey, nil
-}
-
 // getEmailAddressesCount returns the count of email addresses for a given account
 func (s *AccountService) getEmailAddressesCount(accountID uuid.UUID) (int, error) {
 	query := `
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+// AccountDeletionService runs the two-step self-service deletion: the account requests
+// deletion, and once the cooling-off period has passed without a cancellation the
+// worker erases the account and everything it owns.
+type AccountDeletionService struct {
+	db                        *sql.DB
+	customDomainService       *CustomDomainService
+	domainVerificationService *DomainVerificationService
+	coolingOff                time.Duration
+}
+
+func NewAccountDeletionService(db *sql.DB, customDomainService *CustomDomainService, domainVerificationService *DomainVerificationService, coolingOff time.Duration) *AccountDeletionService {
+	return &AccountDeletionService{
+		db:                        db,
+		customDomainService:       customDomainService,
+		domainVerificationService: domainVerificationService,
+		coolingOff:                coolingOff,
+	}
+}
+
+// RequestDeletion schedules the account for deletion after the cooling-off period.
+// Requesting again keeps the original schedule.
+func (s *AccountDeletionService) RequestDeletion(accountID uuid.UUID) (*models.AccountDeletionResponse, error) {
+	now := time.Now().UTC()
+	_, err := s.db.Exec(
+		`UPDATE accounts SET deletion_requested_at = $1, deletion_scheduled_at = $2 WHERE id = $3 AND deletion_requested_at IS NULL`,
+		now, now.Add(s.coolingOff), accountID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request account deletion: %w", err)
+	}
+
+	return s.GetDeletion(accountID)
+}
+
+// GetDeletion returns the account's pending deletion, if any
+func (s *AccountDeletionService) GetDeletion(accountID uuid.UUID) (*models.AccountDeletionResponse, error) {
+	var deletion models.AccountDeletionResponse
+	err := s.db.QueryRow(
+		`SELECT deletion_requested_at, deletion_scheduled_at FROM accounts WHERE id = $1`,
+		accountID,
+	).Scan(&deletion.RequestedAt, &deletion.ScheduledAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("account not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account deletion: %w", err)
+	}
+
+	deletion.Pending = deletion.ScheduledAt != nil
+	return &deletion, nil
+}
+
+// CancelDeletion stops a pending deletion during the cooling-off period
+func (s *AccountDeletionService) CancelDeletion(accountID uuid.UUID) error {
+	result, err := s.db.Exec(
+		`UPDATE accounts SET deletion_requested_at = NULL, deletion_scheduled_at = NULL WHERE id = $1 AND deletion_scheduled_at IS NOT NULL`,
+		accountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no account deletion is pending")
+	}
+
+	return nil
+}
+
+// PurgeDueAccounts erases up to limit accounts whose cooling-off period has ended and
+// returns how many were erased. Deleting the account cascades to its organization,
+// members, sessions, keys, OAuth clients, email addresses, sent emails, TPS integrations
+// and custom domains. Custom domains are removed from their verification provider first
+// so no identities are left behind. The audit log and usage records are kept for accounting.
+func (s *AccountDeletionService) PurgeDueAccounts(limit int) (int, error) {
+	rows, err := s.db.Query(
+		`SELECT id FROM accounts WHERE deletion_scheduled_at <= $1 ORDER BY deletion_scheduled_at LIMIT $2`,
+		time.Now().UTC(), limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due account deletions: %w", err)
+	}
+
+	var due []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan due account deletion: %w", err)
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+
+	purged := 0
+	for _, id := range due {
+		deleted, err := s.purgeAccount(id)
+		if err != nil {
+			log.Printf("Failed to delete account %s: %v", id, err)
+			continue
+		}
+		if deleted {
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeAccount erases one account. The account row stays locked while its domains are
+// removed from their providers, so a cancellation cannot slip in halfway through.
+// Audit events outlive the account as a record of who did what and when, but their
+// before/after payloads (member emails, addresses, names) and client IP addresses and
+// user agents are cleared. Usage records hold only counts and are kept for billing.
+func (s *AccountDeletionService) purgeAccount(id uuid.UUID) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Re-check the schedule so a cancellation that raced the listing wins
+	var locked uuid.UUID
+	err = tx.QueryRow(
+		`SELECT id FROM accounts WHERE id = $1 AND deletion_scheduled_at <= $2 FOR UPDATE`,
+		id, time.Now().UTC(),
+	).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock account: %w", err)
+	}
+
+	domains, err := s.customDomainService.GetCustomDomainsByAccountID(id)
+	if err != nil {
+		return false, err
+	}
+	for _, domain := range domains {
+		// Best effort, as when a customer deletes a domain
+		if err := s.domainVerificationService.DeleteDomain(domain); err != nil {
+			log.Printf("Failed to remove custom domain %s from its provider: %v", domain.Domain, err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE audit_events
+		SET before = NULL, after = NULL, ip_address = NULL, user_agent = NULL, redacted_at = NOW()
+		WHERE account_id = $1 AND redacted_at IS NULL
+	`, id); err != nil {
+		return false, fmt.Errorf("failed to redact audit events: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM accounts WHERE id = $1`, id); err != nil {
+		return false, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit account deletion: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+// AccountExportService builds a zip archive of everything an account has stored.
+// Secrets are never exported: API keys, TPS API keys and passwords appear only as
+// has_api_key/has_password flags.
+type AccountExportService struct {
+	db                  *sql.DB
+	accountService      *AccountService
+	emailAddressService *EmailAddressService
+	customDomainService *CustomDomainService
+	tpsService          *TPSService
+}
+
+func NewAccountExportService(db *sql.DB, accountService *AccountService, emailAddressService *EmailAddressService, customDomainService *CustomDomainService, tpsService *TPSService) *AccountExportService {
+	return &AccountExportService{
+		db:                  db,
+		accountService:      accountService,
+		emailAddressService: emailAddressService,
+		customDomainService: customDomainService,
+		tpsService:          tpsService,
+	}
+}
+
+// WriteExport writes the account's archive to w. Sent emails are streamed one JSON
+// object per line so large mailboxes don't have to fit in memory.
+func (s *AccountExportService) WriteExport(accountID uuid.UUID, w io.Writer) error {
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		return err
+	}
+
+	addresses, err := s.emailAddressService.GetEmailAddresses(accountID)
+	if err != nil {
+		return err
+	}
+
+	var tps []*models.TPSResponse
+	for _, addr := range addresses {
+		list, err := s.tpsService.ListTPSByEmailAddress(addr.ID)
+		if err != nil {
+			return err
+		}
+		tps = append(tps, list...)
+	}
+
+	domains, err := s.customDomainService.GetCustomDomainsByAccountID(accountID)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	manifest := models.AccountExportManifest{
+		AccountID:   accountID,
+		GeneratedAt: time.Now().UTC(),
+		Files:       []string{"account.json", "email_addresses.json", "sent_emails.jsonl", "custom_domains.json", "tps.json"},
+	}
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"manifest.json", manifest},
+		{"account.json", account},
+		{"email_addresses.json", nonNilSlice(addresses)},
+		{"custom_domains.json", nonNilSlice(domains)},
+		{"tps.json", nonNilSlice(tps)},
+	}
+	for _, f := range files {
+		if err := writeArchiveJSON(archive, f.name, f.value); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeSentEmails(archive, accountID); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	return nil
+}
+
+func (s *AccountExportService) writeSentEmails(archive *zip.Writer, accountID uuid.UUID) error {
+	f, err := archive.Create("sent_emails.jsonl")
+	if err != nil {
+		return fmt.Errorf("failed to add sent_emails.jsonl: %w", err)
+	}
+
+	query := `
+		SELECT id, account_id, from_email_id, to_recipients, cc_recipients, bcc_recipients,
+			   subject, text_content, html_content, attachments, headers, thread_id,
+			   scheduled_at, sent_at, status, provider_message_id, failure_reason, metadata,
+			   created_at, updated_at
+		FROM sent_emails
+		WHERE account_id = $1
+		ORDER BY created_at, id
+	`
+	rows, err := s.db.Query(query, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to export sent emails: %w", err)
+	}
+	defer rows.Close()
+
+	enc := json.NewEncoder(f)
+	for rows.Next() {
+		var e models.SentEmail
+		err := rows.Scan(
+			&e.ID, &e.AccountID, &e.FromEmailID, &e.ToRecipients, &e.CcRecipients, &e.BccRecipients,
+			&e.Subject, &e.TextContent, &e.HTMLContent, &e.Attachments, &e.Headers, &e.ThreadID,
+			&e.ScheduledAt, &e.SentAt, &e.Status, &e.ProviderMessageID, &e.FailureReason, &e.Metadata,
+			&e.CreatedAt, &e.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan sent email: %w", err)
+		}
+		if err := enc.Encode(&e); err != nil {
+			return fmt.Errorf("failed to write sent email: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to export sent emails: %w", err)
+	}
+	return nil
+}
+
+func writeArchiveJSON(archive *zip.Writer, name string, value interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// nonNilSlice makes empty lists export as [] rather than null
+func nonNilSlice[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
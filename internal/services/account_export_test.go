@@ -0,0 +1,29 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteArchiveJSON(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	assert.NoError(t, writeArchiveJSON(archive, "tps.json", nonNilSlice([]*models.TPSResponse(nil))))
+	assert.NoError(t, archive.Close())
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, reader.File, 1)
+	assert.Equal(t, "tps.json", reader.File[0].Name)
+
+	f, err := reader.File[0].Open()
+	assert.NoError(t, err)
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", string(content))
+}
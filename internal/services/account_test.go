@@ -0,0 +1,24 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanChangeAccountStatus(t *testing.T) {
+	active, suspended, closed := models.AccountStatusActive, models.AccountStatusSuspended, models.AccountStatusClosed
+
+	assert.True(t, canChangeAccountStatus(active, suspended))
+	assert.True(t, canChangeAccountStatus(active, closed))
+	assert.True(t, canChangeAccountStatus(suspended, active))
+	assert.True(t, canChangeAccountStatus(suspended, closed))
+	assert.True(t, canChangeAccountStatus(closed, active))
+
+	assert.False(t, canChangeAccountStatus(active, active))
+	assert.False(t, canChangeAccountStatus(suspended, suspended))
+	assert.False(t, canChangeAccountStatus(closed, suspended))
+	assert.False(t, canChangeAccountStatus(closed, closed))
+	assert.False(t, canChangeAccountStatus(active, "deleted"))
+}
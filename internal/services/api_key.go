@@ -0,0 +1,474 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/auth"
+	"github.com/maylng/backend/internal/models"
+)
+
+// maxRotationGracePeriod caps how long a rotated key may stay valid
+const maxRotationGracePeriod = 30 * 24 * time.Hour
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type APIKeyService struct {
+	db                 *sql.DB
+	hasher             *auth.KeyHasher
+	defaultGracePeriod time.Duration
+}
+
+func NewAPIKeyService(db *sql.DB, hasher *auth.KeyHasher, defaultGracePeriod time.Duration) *APIKeyService {
+	return &APIKeyService{
+		db:                 db,
+		hasher:             hasher,
+		defaultGracePeriod: defaultGracePeriod,
+	}
+}
+
+// CreateAPIKey issues a new named key for the account. A key can only grant
+// scopes that the calling key already holds. A non-nil memberID ties the key to an
+// organization member, whose role then bounds the key's permissions.
+func (s *APIKeyService) CreateAPIKey(accountID uuid.UUID, memberID *uuid.UUID, callerScopes []string, req *models.CreateAPIKeyRequest) (*models.APIKeyResponse, error) {
+	if req.Name == "" || len(req.Name) > 100 {
+		return nil, fmt.Errorf("name must be between 1 and 100 characters")
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkGrantableScopes(callerScopes, scopes); err != nil {
+		return nil, err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expires_at must be in the future")
+	}
+
+	restrictions, err := normalizeRestrictions(req.AllowedCIDRs, req.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+
+	return insertAPIKey(s.db, s.hasher, accountID, memberID, req.Name, scopes, req.ExpiresAt, restrictions)
+}
+
+// ListAPIKeys returns all keys for an account, including revoked ones
+func (s *APIKeyService) ListAPIKeys(accountID uuid.UUID) ([]*models.APIKeyResponse, error) {
+	query := `
+		SELECT ` + apiKeyResponseColumns + `
+		FROM api_keys
+		WHERE account_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKeyResponse{}
+	for rows.Next() {
+		key, err := scanAPIKeyResponse(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// UpdateAPIKeyRestrictions changes where a key can be used from. Restrictions apply
+// from the key's next request. actorMemberID and actorRole identify the member making
+// the change; both are empty for the account holder.
+func (s *APIKeyService) UpdateAPIKeyRestrictions(accountID, keyID uuid.UUID, actorMemberID *uuid.UUID, actorRole string, req *models.UpdateAPIKeyRestrictionsRequest) (*models.APIKeyResponse, error) {
+	var cidrs, origins []string
+	if req.AllowedCIDRs != nil {
+		cidrs = *req.AllowedCIDRs
+	}
+	if req.AllowedOrigins != nil {
+		origins = *req.AllowedOrigins
+	}
+	restrictions, err := normalizeRestrictions(cidrs, origins)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeAPIKeyActor(tx, accountID, keyID, actorMemberID, actorRole); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE api_keys
+		SET allowed_cidrs = CASE WHEN $3 THEN $4::text[] ELSE allowed_cidrs END,
+			allowed_origins = CASE WHEN $5 THEN $6::text[] ELSE allowed_origins END
+		WHERE id = $1 AND account_id = $2 AND revoked_at IS NULL
+		RETURNING ` + apiKeyResponseColumns
+	key, err := scanAPIKeyResponse(tx.QueryRow(query,
+		keyID,
+		accountID,
+		req.AllowedCIDRs != nil,
+		pq.Array(restrictions.AllowedCIDRs),
+		req.AllowedOrigins != nil,
+		pq.Array(restrictions.AllowedOrigins),
+	))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit API key restrictions: %w", err)
+	}
+
+	return key, nil
+}
+
+// RevokeAPIKey revokes a single key belonging to the account on behalf of the given
+// member, or of the account holder when actorRole is empty
+func (s *APIKeyService) RevokeAPIKey(accountID, keyID uuid.UUID, actorMemberID *uuid.UUID, actorRole string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeAPIKeyActor(tx, accountID, keyID, actorMemberID, actorRole); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		"UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND account_id = $2 AND revoked_at IS NULL",
+		keyID, accountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API key not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit API key revocation: %w", err)
+	}
+
+	return nil
+}
+
+// RotateAPIKey issues a replacement for the given key with the same name, scopes and
+// expiry. The old key keeps working for the grace period so running agents can cut over;
+// a nil grace period uses the server default and zero revokes the old key immediately.
+// Since the caller receives the new secret, it must hold every scope of the rotated key,
+// and a member may only rotate keys they are allowed to manage.
+func (s *APIKeyService) RotateAPIKey(accountID, keyID uuid.UUID, actorMemberID *uuid.UUID, actorRole string, callerScopes []string, gracePeriodSeconds *int) (*models.APIKeyRotationResponse, error) {
+	grace := s.defaultGracePeriod
+	if gracePeriodSeconds != nil {
+		// Clamp the seconds before converting so huge values can't overflow into range
+		seconds := int64(*gracePeriodSeconds)
+		if maxSeconds := int64(maxRotationGracePeriod / time.Second); seconds > maxSeconds {
+			seconds = maxSeconds + 1
+		} else if seconds < 0 {
+			seconds = -1
+		}
+		grace = time.Duration(seconds) * time.Second
+	}
+	if grace < 0 {
+		return nil, fmt.Errorf("grace period must not be negative")
+	}
+	if grace > maxRotationGracePeriod {
+		return nil, fmt.Errorf("grace period must not exceed %d days", int(maxRotationGracePeriod.Hours()/24))
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeAPIKeyActor(tx, accountID, keyID, actorMemberID, actorRole); err != nil {
+		return nil, err
+	}
+
+	var old models.APIKey
+	query := `
+		SELECT id, member_id, name, key_prefix, scopes, allowed_cidrs, allowed_origins, expires_at, last_used_at, revoked_at, replaced_by_id, created_at
+		FROM api_keys
+		WHERE id = $1 AND account_id = $2
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, keyID, accountID).Scan(
+		&old.ID,
+		&old.MemberID,
+		&old.Name,
+		&old.KeyPrefix,
+		pq.Array(&old.Scopes),
+		pq.Array(&old.AllowedCIDRs),
+		pq.Array(&old.AllowedOrigins),
+		&old.ExpiresAt,
+		&old.LastUsedAt,
+		&old.RevokedAt,
+		&old.ReplacedByID,
+		&old.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if old.ReplacedByID != nil {
+		return nil, fmt.Errorf("API key has already been rotated")
+	}
+	if !old.IsActive(time.Now()) {
+		return nil, fmt.Errorf("API key is revoked or expired")
+	}
+	if err := checkGrantableScopes(callerScopes, old.Scopes); err != nil {
+		return nil, err
+	}
+
+	newKey, err := insertAPIKey(tx, s.hasher, accountID, old.MemberID, old.Name, old.Scopes, old.ExpiresAt, old.APIKeyRestrictions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate new API key: %w", err)
+	}
+
+	// The old key expires at the end of the grace period, or at its original expiry if that comes first
+	previous := &models.APIKeyResponse{
+		ID:                 old.ID,
+		MemberID:           old.MemberID,
+		Name:               old.Name,
+		KeyPrefix:          old.KeyPrefix,
+		Scopes:             old.Scopes,
+		LastUsedAt:         old.LastUsedAt,
+		CreatedAt:          old.CreatedAt,
+		APIKeyRestrictions: old.APIKeyRestrictions,
+	}
+	graceSeconds := int64(grace / time.Second)
+	updateQuery := `
+		UPDATE api_keys
+		SET replaced_by_id = $1,
+			rotated_at = NOW(),
+			expires_at = LEAST(COALESCE(expires_at, 'infinity'::timestamp), NOW() + make_interval(secs => $2::double precision)),
+			revoked_at = CASE WHEN $2::double precision = 0 THEN NOW() ELSE NULL END
+		WHERE id = $3
+		RETURNING expires_at, revoked_at, replaced_by_id, rotated_at
+	`
+	err = tx.QueryRow(updateQuery, newKey.ID, graceSeconds, old.ID).Scan(
+		&previous.ExpiresAt,
+		&previous.RevokedAt,
+		&previous.ReplacedByID,
+		&previous.RotatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule old API key expiry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit API key rotation: %w", err)
+	}
+
+	return &models.APIKeyRotationResponse{
+		APIKey:      newKey.APIKey,
+		NewKey:      newKey,
+		PreviousKey: previous,
+	}, nil
+}
+
+// authorizeAPIKeyActor locks the key and fails unless the actor may manage it
+func authorizeAPIKeyActor(q queryRower, accountID, keyID uuid.UUID, actorMemberID *uuid.UUID, actorRole string) error {
+	var keyMemberID *uuid.UUID
+	var keyRole sql.NullString
+	query := `
+		SELECT k.member_id, m.role
+		FROM api_keys k
+		LEFT JOIN organization_members m ON m.id = k.member_id
+		WHERE k.id = $1 AND k.account_id = $2
+		FOR UPDATE OF k
+	`
+	err := q.QueryRow(query, keyID, accountID).Scan(&keyMemberID, &keyRole)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("API key not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if !canManageAPIKey(actorMemberID, actorRole, keyMemberID, keyRole.String) {
+		return fmt.Errorf("cannot manage API keys of a higher role")
+	}
+	return nil
+}
+
+// checkGrantableScopes ensures the calling key holds every scope it hands out
+func checkGrantableScopes(callerScopes, scopes []string) error {
+	for _, scope := range scopes {
+		if !models.HasScope(callerScopes, scope) {
+			return fmt.Errorf("cannot grant scope %s: not held by the calling key", scope)
+		}
+	}
+	return nil
+}
+
+// insertAPIKey generates, hashes and stores a key, returning the clear-text key once.
+// The key ID doubles as the displayed prefix since it carries no secret material.
+func insertAPIKey(q queryRower, hasher *auth.KeyHasher, accountID uuid.UUID, memberID *uuid.UUID, name string, scopes []string, expiresAt *time.Time, restrictions models.APIKeyRestrictions) (*models.APIKeyResponse, error) {
+	apiKey, keyID, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	_, secret, _ := auth.ParseAPIKey(apiKey)
+	keyHash, pepperVersion := hasher.Hash(secret)
+
+	if restrictions.AllowedCIDRs == nil {
+		restrictions.AllowedCIDRs = []string{}
+	}
+	if restrictions.AllowedOrigins == nil {
+		restrictions.AllowedOrigins = []string{}
+	}
+
+	key := &models.APIKeyResponse{
+		Name:               name,
+		MemberID:           memberID,
+		KeyPrefix:          strings.TrimSuffix(apiKey, "_"+secret),
+		Scopes:             scopes,
+		ExpiresAt:          expiresAt,
+		APIKey:             apiKey,
+		APIKeyRestrictions: restrictions,
+	}
+
+	query := `
+		INSERT INTO api_keys (account_id, member_id, name, key_id, key_prefix, key_hash, pepper_version, scopes, expires_at, allowed_cidrs, allowed_origins)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`
+	err = q.QueryRow(query,
+		accountID, memberID, name, keyID, key.KeyPrefix, keyHash, pepperVersion, pq.Array(scopes), expiresAt,
+		pq.Array(restrictions.AllowedCIDRs), pq.Array(restrictions.AllowedOrigins),
+	).Scan(
+		&key.ID,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	return key, nil
+}
+
+const apiKeyResponseColumns = `id, member_id, name, key_prefix, scopes, allowed_cidrs, allowed_origins,
+	expires_at, last_used_at, revoked_at, replaced_by_id, rotated_at, created_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKeyResponse(row rowScanner) (*models.APIKeyResponse, error) {
+	var key models.APIKeyResponse
+	err := row.Scan(
+		&key.ID,
+		&key.MemberID,
+		&key.Name,
+		&key.KeyPrefix,
+		pq.Array(&key.Scopes),
+		pq.Array(&key.AllowedCIDRs),
+		pq.Array(&key.AllowedOrigins),
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.ReplacedByID,
+		&key.RotatedAt,
+		&key.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+	return &key, nil
+}
+
+// maxRestrictionEntries caps each allowlist so the auth check stays cheap
+const maxRestrictionEntries = 50
+
+// normalizeRestrictions validates CIDR blocks and origins, returning them in canonical form
+func normalizeRestrictions(cidrs, origins []string) (models.APIKeyRestrictions, error) {
+	restrictions := models.APIKeyRestrictions{AllowedCIDRs: []string{}, AllowedOrigins: []string{}}
+	if len(cidrs) > maxRestrictionEntries || len(origins) > maxRestrictionEntries {
+		return restrictions, fmt.Errorf("at most %d CIDRs and %d origins are allowed", maxRestrictionEntries, maxRestrictionEntries)
+	}
+
+	seen := make(map[string]bool)
+	for _, value := range cidrs {
+		cidr, err := auth.NormalizeCIDR(value)
+		if err != nil {
+			return restrictions, err
+		}
+		if !seen[cidr] {
+			seen[cidr] = true
+			restrictions.AllowedCIDRs = append(restrictions.AllowedCIDRs, cidr)
+		}
+	}
+
+	for _, value := range origins {
+		origin, err := auth.NormalizeOrigin(value)
+		if err != nil {
+			return restrictions, err
+		}
+		if !seen[origin] {
+			seen[origin] = true
+			restrictions.AllowedOrigins = append(restrictions.AllowedOrigins, origin)
+		}
+	}
+
+	return restrictions, nil
+}
+
+// normalizeScopes validates scopes and removes duplicates, keeping request order
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, scope := range scopes {
+		if !models.IsValidAPIKeyScope(scope) {
+			return nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	scopes, err := normalizeScopes([]string{models.ScopeEmailsSend, models.ScopeEmailsRead, models.ScopeEmailsSend})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.ScopeEmailsSend, models.ScopeEmailsRead}, scopes)
+
+	_, err = normalizeScopes(nil)
+	assert.EqualError(t, err, "at least one scope is required")
+
+	_, err = normalizeScopes([]string{"emails:delete"})
+	assert.EqualError(t, err, "invalid scope: emails:delete")
+}
+
+func TestCreateAPIKeyRejectsScopeEscalation(t *testing.T) {
+	// Validation happens before any database access
+	service := NewAPIKeyService(nil, nil, 24*time.Hour)
+
+	_, err := service.CreateAPIKey(uuid.New(), nil, []string{models.ScopeEmailsSend}, &models.CreateAPIKeyRequest{
+		Name:   "agent",
+		Scopes: []string{models.ScopeEmailsSend, models.ScopeAdmin},
+	})
+	assert.EqualError(t, err, "cannot grant scope admin: not held by the calling key")
+}
+
+func TestRotateAPIKeyRejectsGracePeriodOutOfRange(t *testing.T) {
+	service := NewAPIKeyService(nil, nil, 24*time.Hour)
+
+	negative := -60
+	_, err := service.RotateAPIKey(uuid.New(), uuid.New(), nil, "", nil, &negative)
+	assert.EqualError(t, err, "grace period must not be negative")
+
+	tooLong := 31 * 24 * 60 * 60
+	_, err = service.RotateAPIKey(uuid.New(), uuid.New(), nil, "", nil, &tooLong)
+	assert.EqualError(t, err, "grace period must not exceed 30 days")
+
+	// Large enough to overflow a Duration once converted
+	huge := int(^uint(0) >> 1)
+	_, err = service.RotateAPIKey(uuid.New(), uuid.New(), nil, "", nil, &huge)
+	assert.EqualError(t, err, "grace period must not exceed 30 days")
+
+	huge = -huge - 1
+	_, err = service.RotateAPIKey(uuid.New(), uuid.New(), nil, "", nil, &huge)
+	assert.EqualError(t, err, "grace period must not be negative")
+}
+
+func TestCheckGrantableScopesRejectsRotatingHigherScopedKey(t *testing.T) {
+	// A key managing API keys must not obtain the secret of an admin key by rotating it
+	caller := []string{models.ScopeAccountWrite}
+	err := checkGrantableScopes(caller, []string{models.ScopeAdmin})
+	assert.EqualError(t, err, "cannot grant scope admin: not held by the calling key")
+
+	assert.NoError(t, checkGrantableScopes([]string{models.ScopeAccountWrite, models.ScopeEmailsSend}, []string{models.ScopeEmailsSend}))
+}
+
+func TestNormalizeRestrictions(t *testing.T) {
+	restrictions, err := normalizeRestrictions(
+		[]string{"203.0.113.7", "10.1.2.3/8", "203.0.113.7/32"},
+		[]string{"https://App.example.com/", "https://app.example.com"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"203.0.113.7/32", "10.0.0.0/8"}, restrictions.AllowedCIDRs)
+	assert.Equal(t, []string{"https://app.example.com"}, restrictions.AllowedOrigins)
+
+	restrictions, err = normalizeRestrictions(nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, restrictions.AllowedCIDRs)
+	assert.NotNil(t, restrictions.AllowedOrigins)
+
+	_, err = normalizeRestrictions([]string{"10.0.0.0/40"}, nil)
+	assert.EqualError(t, err, "invalid CIDR: 10.0.0.0/40")
+
+	_, err = normalizeRestrictions(nil, []string{"https://example.com/app"})
+	assert.EqualError(t, err, "invalid origin: https://example.com/app")
+}
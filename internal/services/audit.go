@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+// genesisAuditHash is the prev_hash of the first event in each account's chain
+var genesisAuditHash = strings.Repeat("0", 64)
+
+type AuditService struct {
+	db *sql.DB
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{
+		db: db,
+	}
+}
+
+// Record appends an event to the account's hash chain. Appends for one account are
+// serialized with an advisory lock so the chain never forks.
+func (s *AuditService) Record(event *models.AuditEvent) error {
+	if event.Action == "" || event.ResourceType == "" {
+		return fmt.Errorf("action and resource type are required")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "audit:"+event.AccountID.String()); err != nil {
+		return fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+
+	// Hash the JSON exactly as Postgres will store and return it
+	var before, after sql.NullString
+	if err := tx.QueryRow(
+		"SELECT $1::jsonb::text, $2::jsonb::text",
+		nullableJSON(event.Before), nullableJSON(event.After),
+	).Scan(&before, &after); err != nil {
+		return fmt.Errorf("invalid audit payload: %w", err)
+	}
+	event.Before = rawJSONOrNil(before)
+	event.After = rawJSONOrNil(after)
+
+	event.PrevHash = genesisAuditHash
+	err = tx.QueryRow(
+		"SELECT hash FROM audit_events WHERE account_id = $1 ORDER BY seq DESC LIMIT 1",
+		event.AccountID,
+	).Scan(&event.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read audit chain: %w", err)
+	}
+
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	event.Hash = computeAuditHash(event)
+
+	query := `
+		INSERT INTO audit_events (
+			id, account_id, actor_api_key_id, actor_member_id, actor_oauth_client_id, action, resource_type, resource_id,
+			ip_address, user_agent, request_id, before, after, prev_hash, hash, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING seq
+	`
+	err = tx.QueryRow(query,
+		event.ID,
+		event.AccountID,
+		event.ActorAPIKeyID,
+		event.ActorMemberID,
+		event.ActorOAuthClientID,
+		event.Action,
+		event.ResourceType,
+		event.ResourceID,
+		event.IPAddress,
+		event.UserAgent,
+		event.RequestID,
+		nullableJSON(event.Before),
+		nullableJSON(event.After),
+		event.PrevHash,
+		event.Hash,
+		event.CreatedAt,
+	).Scan(&event.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit audit event: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditEvents returns the account's events, newest first
+func (s *AuditService) ListAuditEvents(accountID uuid.UUID, filter *models.AuditEventFilter) (*models.AuditEventListResponse, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query, args, err := buildAuditEventQuery(accountID, filter, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.AuditEventResponse{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &models.AuditEventResponse{
+			AuditEvent: event,
+			Changes:    computeAuditChanges(event.Before, event.After),
+		})
+	}
+
+	response := &models.AuditEventListResponse{Events: events}
+	if len(events) > limit {
+		response.Events = events[:limit]
+		response.HasMore = true
+		response.NextCursor = strconv.FormatInt(events[limit-1].Seq, 10)
+	}
+
+	return response, nil
+}
+
+// VerifyAuditChain recomputes every hash in the account's chain, oldest first. Redacted
+// events no longer hold what was hashed, so only their links in the chain are checked.
+func (s *AuditService) VerifyAuditChain(accountID uuid.UUID) (*models.AuditChainVerification, error) {
+	rows, err := s.db.Query(
+		"SELECT "+auditEventColumns+" FROM audit_events WHERE account_id = $1 ORDER BY seq ASC",
+		accountID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit chain: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.AuditChainVerification{Valid: true}
+	prevHash := genesisAuditHash
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.EventsChecked++
+
+		if event.PrevHash != prevHash || (event.RedactedAt == nil && computeAuditHash(event) != event.Hash) {
+			result.Valid = false
+			result.FirstInvalid = &event.ID
+			break
+		}
+		prevHash = event.Hash
+	}
+
+	return result, nil
+}
+
+const auditEventColumns = `id, seq, account_id, actor_api_key_id, actor_member_id, actor_oauth_client_id, action, resource_type, resource_id,
+	ip_address, user_agent, request_id, before::text, after::text, prev_hash, hash, created_at, redacted_at`
+
+func scanAuditEvent(rows *sql.Rows) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	var before, after sql.NullString
+	if err := rows.Scan(
+		&event.ID,
+		&event.Seq,
+		&event.AccountID,
+		&event.ActorAPIKeyID,
+		&event.ActorMemberID,
+		&event.ActorOAuthClientID,
+		&event.Action,
+		&event.ResourceType,
+		&event.ResourceID,
+		&event.IPAddress,
+		&event.UserAgent,
+		&event.RequestID,
+		&before,
+		&after,
+		&event.PrevHash,
+		&event.Hash,
+		&event.CreatedAt,
+		&event.RedactedAt,
+	); err != nil {
+		return nil, fmt.Errorf("failed to scan audit event: %w", err)
+	}
+	event.Before = rawJSONOrNil(before)
+	event.After = rawJSONOrNil(after)
+	return &event, nil
+}
+
+// buildAuditEventQuery builds the filtered list query. The cursor is the seq of the last
+// event on the previous page.
+func buildAuditEventQuery(accountID uuid.UUID, filter *models.AuditEventFilter, fetchLimit int) (string, []interface{}, error) {
+	conditions := []string{"account_id = $1"}
+	args := []interface{}{accountID}
+	argIndex := 2
+
+	addCondition := func(format string, value interface{}) {
+		conditions = append(conditions, fmt.Sprintf(format, argIndex))
+		args = append(args, value)
+		argIndex++
+	}
+
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		addCondition("resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		addCondition("resource_id = $%d", filter.ResourceID)
+	}
+	if filter.ActorAPIKeyID != nil {
+		addCondition("actor_api_key_id = $%d", *filter.ActorAPIKeyID)
+	}
+	if filter.ActorMemberID != nil {
+		addCondition("actor_member_id = $%d", *filter.ActorMemberID)
+	}
+	if filter.ActorOAuthClientID != nil {
+		addCondition("actor_oauth_client_id = $%d", *filter.ActorOAuthClientID)
+	}
+	if filter.RequestID != "" {
+		addCondition("request_id = $%d", filter.RequestID)
+	}
+	if filter.CreatedAfter != nil {
+		addCondition("created_at >= $%d", filter.CreatedAfter.UTC())
+	}
+	if filter.CreatedBefore != nil {
+		addCondition("created_at < $%d", filter.CreatedBefore.UTC())
+	}
+	if filter.Cursor != "" {
+		seq, err := strconv.ParseInt(filter.Cursor, 10, 64)
+		if err != nil || seq <= 0 {
+			return "", nil, fmt.Errorf("invalid cursor")
+		}
+		addCondition("seq < $%d", seq)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM audit_events WHERE %s ORDER BY seq DESC LIMIT $%d",
+		auditEventColumns, strings.Join(conditions, " AND "), argIndex,
+	)
+	args = append(args, fetchLimit)
+
+	return query, args, nil
+}
+
+// computeAuditHash hashes the previous hash and every recorded field. Fields are
+// length-prefixed so no two different events share an encoding.
+func computeAuditHash(event *models.AuditEvent) string {
+	optionalUUID := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	optionalString := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	fields := []string{
+		event.PrevHash,
+		event.ID.String(),
+		event.AccountID.String(),
+		optionalUUID(event.ActorAPIKeyID),
+		optionalUUID(event.ActorMemberID),
+		event.Action,
+		event.ResourceType,
+		optionalString(event.ResourceID),
+		optionalString(event.IPAddress),
+		optionalString(event.UserAgent),
+		optionalString(event.RequestID),
+		string(event.Before),
+		string(event.After),
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	// Appended only when set so events recorded before the column existed keep their hash
+	if event.ActorOAuthClientID != nil {
+		fields = append(fields, event.ActorOAuthClientID.String())
+	}
+
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// computeAuditChanges lists the top-level fields that differ between two JSON objects
+func computeAuditChanges(before, after json.RawMessage) map[string]models.AuditChange {
+	beforeFields := map[string]json.RawMessage{}
+	afterFields := map[string]json.RawMessage{}
+	if len(before) > 0 && json.Unmarshal(before, &beforeFields) != nil {
+		return nil
+	}
+	if len(after) > 0 && json.Unmarshal(after, &afterFields) != nil {
+		return nil
+	}
+
+	changes := map[string]models.AuditChange{}
+	for key, oldValue := range beforeFields {
+		newValue := afterFields[key]
+		if !jsonEqual(oldValue, newValue) {
+			changes[key] = models.AuditChange{Before: oldValue, After: newValue}
+		}
+	}
+	for key, newValue := range afterFields {
+		if _, seen := beforeFields[key]; !seen {
+			changes[key] = models.AuditChange{After: newValue}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
+
+func nullableJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+func rawJSONOrNil(s sql.NullString) json.RawMessage {
+	if !s.Valid {
+		return nil
+	}
+	return json.RawMessage(s.String)
+}
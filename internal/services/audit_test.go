@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeAuditHashCoversEveryField(t *testing.T) {
+	resourceID := "abc"
+	event := &models.AuditEvent{
+		ID:           uuid.New(),
+		AccountID:    uuid.New(),
+		Action:       "email_address.update",
+		ResourceType: "email_address",
+		ResourceID:   &resourceID,
+		Before:       json.RawMessage(`{"display_name": "Old"}`),
+		After:        json.RawMessage(`{"display_name": "New"}`),
+		PrevHash:     genesisAuditHash,
+		CreatedAt:    time.Date(2025, 7, 6, 10, 0, 0, 0, time.UTC),
+	}
+
+	hash := computeAuditHash(event)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, computeAuditHash(event))
+
+	tampered := *event
+	tampered.After = json.RawMessage(`{"display_name": "Evil"}`)
+	assert.NotEqual(t, hash, computeAuditHash(&tampered))
+
+	relinked := *event
+	relinked.PrevHash = hash
+	assert.NotEqual(t, hash, computeAuditHash(&relinked))
+
+	clientID := uuid.New()
+	attributed := *event
+	attributed.ActorOAuthClientID = &clientID
+	assert.NotEqual(t, hash, computeAuditHash(&attributed))
+}
+
+func TestComputeAuditChanges(t *testing.T) {
+	changes := computeAuditChanges(
+		json.RawMessage(`{"display_name": "Old", "is_active": true, "removed": 1}`),
+		json.RawMessage(`{"display_name": "New", "is_active": true, "added": "x"}`),
+	)
+
+	assert.Len(t, changes, 3)
+	assert.JSONEq(t, `"Old"`, string(changes["display_name"].Before))
+	assert.JSONEq(t, `"New"`, string(changes["display_name"].After))
+	assert.Nil(t, changes["removed"].After)
+	assert.Nil(t, changes["added"].Before)
+	assert.NotContains(t, changes, "is_active")
+
+	assert.Nil(t, computeAuditChanges(nil, nil))
+}
+
+func TestBuildAuditEventQuery(t *testing.T) {
+	keyID := uuid.New()
+	query, args, err := buildAuditEventQuery(uuid.New(), &models.AuditEventFilter{
+		Action:        "tps.secrets_decrypt",
+		ActorAPIKeyID: &keyID,
+		Cursor:        "42",
+	}, 51)
+	assert.NoError(t, err)
+
+	assert.Contains(t, query, "action = $2")
+	assert.Contains(t, query, "actor_api_key_id = $3")
+	assert.Contains(t, query, "seq < $4")
+	assert.Contains(t, query, "ORDER BY seq DESC LIMIT $5")
+	assert.Equal(t, []interface{}{args[0], "tps.secrets_decrypt", keyID, int64(42), 51}, args)
+
+	_, _, err = buildAuditEventQuery(uuid.New(), &models.AuditEventFilter{Cursor: "nope"}, 51)
+	assert.EqualError(t, err, "invalid cursor")
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+// maxCatchAllRules is the number of catch-all rules a domain can have
+const maxCatchAllRules = 50
+
+// catchAllPatternPattern is the form of a catch-all pattern: a local part that may
+// contain '*' wildcards
+var catchAllPatternPattern = regexp.MustCompile(`^[a-z0-9._+*-]{1,64}$`)
+
+// CatchAllService accepts mail for local parts of verified custom domains that no
+// address exists for, as the domain's catch-all rules allow. The inbound mail
+// pipeline resolves each recipient before accepting a message.
+type CatchAllService struct {
+	db                  *sql.DB
+	customDomainService *CustomDomainService
+}
+
+func NewCatchAllService(db *sql.DB, customDomainService *CustomDomainService) *CatchAllService {
+	return &CatchAllService{
+		db:                  db,
+		customDomainService: customDomainService,
+	}
+}
+
+// ListRules returns the domain's rules in the order they are matched
+func (s *CatchAllService) ListRules(customDomainID uuid.UUID) ([]*models.CatchAllRule, error) {
+	rows, err := s.db.Query(`
+		SELECT id, custom_domain_id, pattern, action, target_email_address_id, address_type, created_at, updated_at
+		FROM catch_all_rules WHERE custom_domain_id = $1
+		ORDER BY created_at
+	`, customDomainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get catch-all rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*models.CatchAllRule{}
+	for rows.Next() {
+		var rule models.CatchAllRule
+		if err := rows.Scan(&rule.ID, &rule.CustomDomainID, &rule.Pattern, &rule.Action, &rule.TargetEmailAddressID,
+			&rule.AddressType, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan catch-all rule: %w", err)
+		}
+		rules = append(rules, &rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get catch-all rules: %w", err)
+	}
+
+	sortCatchAllRules(rules)
+	return rules, nil
+}
+
+// CreateRule adds a rule to a verified domain
+func (s *CatchAllService) CreateRule(customDomain *models.CustomDomain, req *models.CreateCatchAllRuleRequest) (*models.CatchAllRule, error) {
+	if !customDomain.IsVerified() {
+		return nil, fmt.Errorf("custom domain is not verified")
+	}
+
+	pattern, err := normalizeCatchAllPattern(req.Pattern, customDomain.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &models.CatchAllRule{
+		CustomDomainID:       customDomain.ID,
+		Pattern:              pattern,
+		Action:               req.Action,
+		TargetEmailAddressID: req.TargetEmailAddressID,
+	}
+	switch req.Action {
+	case models.CatchAllActionRoute:
+		if req.TargetEmailAddressID == nil {
+			return nil, fmt.Errorf("invalid catch-all rule: route rules need a target_email_address_id")
+		}
+		if req.AddressType != "" {
+			return nil, fmt.Errorf("invalid catch-all rule: address_type only applies to create rules")
+		}
+	case models.CatchAllActionCreate:
+		// Anyone can send to a catch-all, so created addresses expire unless asked
+		// otherwise rather than filling the address limit for good
+		addressType := req.AddressType
+		if addressType == "" {
+			addressType = models.EmailAddressTypeTemporary
+		}
+		rule.AddressType = &addressType
+	default:
+		return nil, fmt.Errorf("invalid catch-all rule: action must be create or route")
+	}
+
+	if rule.TargetEmailAddressID != nil {
+		var exists bool
+		err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM email_addresses WHERE id = $1 AND account_id = $2)`,
+			*rule.TargetEmailAddressID, customDomain.AccountID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get target email address: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("target email address not found")
+		}
+	}
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM catch_all_rules WHERE custom_domain_id = $1`, customDomain.ID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count catch-all rules: %w", err)
+	}
+	if count >= maxCatchAllRules {
+		return nil, fmt.Errorf("catch-all rule limit reached (%d/%d)", count, maxCatchAllRules)
+	}
+
+	err = s.db.QueryRow(`
+		INSERT INTO catch_all_rules (custom_domain_id, pattern, action, target_email_address_id, address_type)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, rule.CustomDomainID, rule.Pattern, rule.Action, rule.TargetEmailAddressID, rule.AddressType).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			return nil, fmt.Errorf("catch-all rule already exists for pattern %s", rule.Pattern)
+		}
+		return nil, fmt.Errorf("failed to create catch-all rule: %w", err)
+	}
+	return rule, nil
+}
+
+// DeleteRule removes a rule of the domain. Addresses it created are kept.
+func (s *CatchAllService) DeleteRule(customDomainID, ruleID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM catch_all_rules WHERE id = $1 AND custom_domain_id = $2`, ruleID, customDomainID)
+	if err != nil {
+		return fmt.Errorf("failed to delete catch-all rule: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("catch-all rule not found")
+	}
+	return nil
+}
+
+// Resolve returns the address that receives mail sent to recipient. An existing
+// address always receives its own mail; otherwise the most specific matching rule of
+// the recipient's verified domain decides. A create rule creates the address unless
+// the account's address limit is reached, in which case the mail goes to the rule's
+// target address if it has one. Suspended and closed accounts accept no mail. Every accepted recipient counts as an inbound message
+// of the account that owns the address.
+func (s *CatchAllService) Resolve(recipient string) (*models.RecipientResolution, error) {
+	recipient = strings.ToLower(strings.TrimSpace(recipient))
+	at := strings.LastIndex(recipient, "@")
+	if at <= 0 || at == len(recipient)-1 {
+		return nil, fmt.Errorf("invalid recipient")
+	}
+	localPart, domain := recipient[:at], recipient[at+1:]
+	notAccepted := fmt.Errorf("no address accepts mail for %s", recipient)
+
+	resolution := &models.RecipientResolution{Recipient: recipient}
+	accept := func(address *models.EmailAddressResponse, accountID uuid.UUID) (*models.RecipientResolution, error) {
+		resolution.EmailAddress = address
+		if err := recordUsage(s.db, accountID, models.UsageMetricInboundMessages, 1, time.Now()); err != nil {
+			log.Printf("Failed to record inbound message usage for %s: %v", address.ID, err)
+		}
+		return resolution, nil
+	}
+
+	address, err := s.addressByEmail(recipient)
+	if err != nil {
+		return nil, err
+	}
+	if address != nil {
+		if !address.acceptsMail() {
+			return nil, notAccepted
+		}
+		return accept(&address.EmailAddressResponse, address.AccountID)
+	}
+
+	// Other accounts may hold unverified rows for the same name
+	customDomain, err := s.customDomainService.GetVerifiedCustomDomainByDomain(domain)
+	if err != nil {
+		if err.Error() == "custom domain not found" || strings.HasSuffix(err.Error(), "is verified by more than one account") {
+			return nil, notAccepted
+		}
+		return nil, err
+	}
+
+	rules, err := s.ListRules(customDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	rule := matchCatchAllRule(rules, localPart)
+	if rule == nil {
+		return nil, notAccepted
+	}
+	resolution.RuleID = &rule.ID
+
+	if rule.Action == models.CatchAllActionCreate {
+		address, err := s.createAddress(customDomain, rule, localPart)
+		if err == nil {
+			resolution.Created = true
+			return accept(address, customDomain.AccountID)
+		}
+		if err.Error() == "email address already exists" {
+			// Another message for the same recipient created it first
+			address, err := s.addressByEmail(recipient)
+			if err != nil || address == nil || !address.acceptsMail() {
+				return nil, notAccepted
+			}
+			return accept(&address.EmailAddressResponse, address.AccountID)
+		}
+		if err.Error() == "account is not active" {
+			return nil, notAccepted
+		}
+		if !strings.HasPrefix(err.Error(), "email address limit reached") || rule.TargetEmailAddressID == nil {
+			return nil, err
+		}
+	}
+
+	if rule.TargetEmailAddressID == nil {
+		return nil, notAccepted
+	}
+	target, err := s.addressByID(*rule.TargetEmailAddressID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil || !target.acceptsMail() {
+		return nil, notAccepted
+	}
+	return accept(&target.EmailAddressResponse, target.AccountID)
+}
+
+// createAddress creates the address a create rule accepted mail for, within the
+// account's address limit. The account row is locked so concurrent messages can't
+// exceed the limit together, and so a suspension or closure takes effect at once.
+func (s *CatchAllService) createAddress(customDomain *models.CustomDomain, rule *models.CatchAllRule, localPart string) (*models.EmailAddressResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var limit, count int
+	var status models.AccountStatus
+	if err := tx.QueryRow(`SELECT email_address_limit, status FROM accounts WHERE id = $1 FOR UPDATE`, customDomain.AccountID).Scan(&limit, &status); err != nil {
+		return nil, fmt.Errorf("failed to get account limits: %w", err)
+	}
+	if status != models.AccountStatusActive {
+		return nil, fmt.Errorf("account is not active")
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM email_addresses WHERE account_id = $1 AND status != 'disabled'`, customDomain.AccountID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to check email address count: %w", err)
+	}
+	if count >= limit {
+		return nil, fmt.Errorf("email address limit reached (%d/%d)", count, limit)
+	}
+
+	addressType := models.EmailAddressTypeTemporary
+	if rule.AddressType != nil {
+		addressType = *rule.AddressType
+	}
+	var expiresAt *time.Time
+	if addressType == models.EmailAddressTypeTemporary {
+		expiry := time.Now().Add(24 * time.Hour)
+		expiresAt = &expiry
+	}
+
+	address := &models.EmailAddressResponse{
+		Email:          localPart + "@" + customDomain.Domain,
+		Type:           addressType,
+		AccessType:     models.EmailAddressAccessTypeAgent,
+		Prefix:         localPart,
+		Domain:         customDomain.Domain,
+		CustomDomainID: &customDomain.ID,
+		ExpiresAt:      expiresAt,
+		Metadata:       models.Metadata{"catch_all_rule_id": rule.ID.String(), "catch_all_pattern": rule.Pattern},
+	}
+	err = tx.QueryRow(`
+		INSERT INTO email_addresses (account_id, email, type, access_type, prefix, domain, custom_domain_id, expires_at, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, status, created_at, updated_at
+	`, customDomain.AccountID, address.Email, address.Type, address.AccessType, address.Prefix, address.Domain,
+		address.CustomDomainID, address.ExpiresAt, address.Metadata).Scan(&address.ID, &address.Status, &address.CreatedAt, &address.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			return nil, fmt.Errorf("email address already exists")
+		}
+		return nil, fmt.Errorf("failed to create email address: %w", err)
+	}
+
+	if err := recordUsage(tx, customDomain.AccountID, models.UsageMetricAddressesCreated, 1, time.Now()); err != nil {
+		log.Printf("Failed to record address usage for %s: %v", address.ID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit email address: %w", err)
+	}
+	return address, nil
+}
+
+// catchAllAddress is an address that may receive mail, with the account that owns it
+type catchAllAddress struct {
+	models.EmailAddressResponse
+	AccountID     uuid.UUID
+	AccountStatus models.AccountStatus
+}
+
+// acceptsMail reports whether both the address and its account are active
+func (a *catchAllAddress) acceptsMail() bool {
+	return a.Status == models.EmailAddressStatusActive && a.AccountStatus == models.AccountStatusActive
+}
+
+const catchAllAddressQuery = `
+	SELECT e.id, e.account_id, a.status, e.email, e.type, e.access_type, e.prefix, e.domain, e.status,
+		e.custom_domain_id, e.expires_at, e.metadata, e.created_at, e.updated_at
+	FROM email_addresses e
+	JOIN accounts a ON a.id = e.account_id
+`
+
+func (s *CatchAllService) addressByEmail(email string) (*catchAllAddress, error) {
+	return s.scanAddress(s.db.QueryRow(catchAllAddressQuery+`WHERE LOWER(e.email) = $1`, email))
+}
+
+func (s *CatchAllService) addressByID(id uuid.UUID) (*catchAllAddress, error) {
+	return s.scanAddress(s.db.QueryRow(catchAllAddressQuery+`WHERE e.id = $1`, id))
+}
+
+func (s *CatchAllService) scanAddress(row *sql.Row) (*catchAllAddress, error) {
+	var addr catchAllAddress
+	err := row.Scan(&addr.ID, &addr.AccountID, &addr.AccountStatus, &addr.Email, &addr.Type, &addr.AccessType, &addr.Prefix, &addr.Domain, &addr.Status,
+		&addr.CustomDomainID, &addr.ExpiresAt, &addr.Metadata, &addr.CreatedAt, &addr.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email address: %w", err)
+	}
+	return &addr, nil
+}
+
+// normalizeCatchAllPattern lowercases a pattern, drops an "@domain" suffix and
+// checks its form
+func normalizeCatchAllPattern(pattern, domain string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	pattern = strings.TrimSuffix(pattern, "@"+domain)
+	if !catchAllPatternPattern.MatchString(pattern) {
+		return "", fmt.Errorf("invalid catch-all rule: pattern %q must be a local part of letters, digits, '.', '_', '+', '-' and '*' wildcards", pattern)
+	}
+	return pattern, nil
+}
+
+// sortCatchAllRules orders rules from the most specific pattern to the least: more
+// literal characters first, then fewer wildcards, then the oldest
+func sortCatchAllRules(rules []*models.CatchAllRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		wildcardsI, wildcardsJ := strings.Count(rules[i].Pattern, "*"), strings.Count(rules[j].Pattern, "*")
+		literalI, literalJ := len(rules[i].Pattern)-wildcardsI, len(rules[j].Pattern)-wildcardsJ
+		if literalI != literalJ {
+			return literalI > literalJ
+		}
+		return wildcardsI < wildcardsJ
+	})
+}
+
+// matchCatchAllRule returns the first of the sorted rules whose pattern matches the
+// local part
+func matchCatchAllRule(rules []*models.CatchAllRule, localPart string) *models.CatchAllRule {
+	for _, rule := range rules {
+		if catchAllPatternMatches(rule.Pattern, localPart) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// catchAllPatternMatches reports whether the local part matches the pattern, where
+// '*' matches any run of characters, including none
+func catchAllPatternMatches(pattern, localPart string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == localPart
+	}
+	if !strings.HasPrefix(localPart, parts[0]) {
+		return false
+	}
+	rest := localPart[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return len(rest) >= len(last) && strings.HasSuffix(rest, last)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatchAllPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		localPart string
+		want      bool
+	}{
+		{"*", "anything", true},
+		{"signup-*", "signup-github", true},
+		{"signup-*", "signup-", true},
+		{"signup-*", "login-github", false},
+		{"*-bot", "support-bot", true},
+		{"*-bot", "bot", false},
+		{"a*b*c", "a1b2c", true},
+		{"a*b*c", "acb", false},
+		{"ab*ba", "aba", false},
+		{"billing", "billing", true},
+		{"billing", "billing2", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, catchAllPatternMatches(tt.pattern, tt.localPart), "%s ~ %s", tt.pattern, tt.localPart)
+	}
+}
+
+func TestMatchCatchAllRule_MostSpecificFirst(t *testing.T) {
+	rules := []*models.CatchAllRule{
+		{Pattern: "*"},
+		{Pattern: "signup-*"},
+		{Pattern: "signup-*-eu"},
+		{Pattern: "signup-github"},
+	}
+	sortCatchAllRules(rules)
+
+	assert.Equal(t, "signup-github", matchCatchAllRule(rules, "signup-github").Pattern)
+	assert.Equal(t, "signup-*-eu", matchCatchAllRule(rules, "signup-gitlab-eu").Pattern)
+	assert.Equal(t, "signup-*", matchCatchAllRule(rules, "signup-gitlab").Pattern)
+	assert.Equal(t, "*", matchCatchAllRule(rules, "hello").Pattern)
+	assert.Nil(t, matchCatchAllRule(rules[:len(rules)-1], "hello"))
+}
+
+func TestNormalizeCatchAllPattern(t *testing.T) {
+	pattern, err := normalizeCatchAllPattern(" Signup-*@acme.com ", "acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "signup-*", pattern)
+
+	for _, invalid := range []string{"", "sign up-*", "signup-*@other.com", "a?b"} {
+		_, err := normalizeCatchAllPattern(invalid, "acme.com")
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCatchAllAddressAcceptsMailOnlyForActiveAccounts(t *testing.T) {
+	address := &catchAllAddress{AccountStatus: models.AccountStatusActive}
+	address.Status = models.EmailAddressStatusActive
+	assert.True(t, address.acceptsMail())
+
+	address.AccountStatus = models.AccountStatusSuspended
+	assert.False(t, address.acceptsMail())
+
+	address.AccountStatus = models.AccountStatusActive
+	address.Status = models.EmailAddressStatusDisabled
+	assert.False(t, address.acceptsMail())
+}
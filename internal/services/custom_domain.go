@@ -26,6 +26,21 @@ func (s *CustomDomainService) CreateCustomDomain(accountID uuid.UUID, domain, ve
 		verificationProvider = "ses" // Default to SES for backward compatibility
 	}
 
+	accountLimits, err := getAccountLimits(s.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !accountLimits.Limits.HasFeature(models.FeatureCustomDomains) {
+		return nil, fmt.Errorf("custom domains are not available on your plan")
+	}
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM custom_domains WHERE account_id = $1", accountID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count custom domains: %w", err)
+	}
+	if count >= accountLimits.Limits.CustomDomainLimit {
+		return nil, fmt.Errorf("custom domain limit reached (%d/%d)", count, accountLimits.Limits.CustomDomainLimit)
+	}
+
 	customDomain := &models.CustomDomain{
 		ID:                   uuid.New(),
 		AccountID:            accountID,
@@ -46,7 +61,7 @@ func (s *CustomDomainService) CreateCustomDomain(accountID uuid.UUID, domain, ve
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 
-	_, err := s.db.Exec(query,
+	_, err = s.db.Exec(query,
 		customDomain.ID,
 		customDomain.AccountID,
 		customDomain.Domain,
@@ -75,7 +90,7 @@ func (s *CustomDomainService) GetCustomDomainByID(id uuid.UUID) (*models.CustomD
 		SELECT id, account_id, domain, status, verification_provider, provider_verification_status, provider_domain_id,
 			   verification_token, dkim_tokens, dns_records,
 			   ses_verification_status, ses_dkim_verification_status, verification_attempted_at,
-			   verified_at, failure_reason, metadata, created_at, updated_at
+			   verified_at, failure_reason, mail_from_domain, mail_from_status, metadata, created_at, updated_at
 		FROM custom_domains WHERE id = $1
 	`
 
@@ -95,6 +110,8 @@ func (s *CustomDomainService) GetCustomDomainByID(id uuid.UUID) (*models.CustomD
 		&customDomain.VerificationAttemptedAt,
 		&customDomain.VerifiedAt,
 		&customDomain.FailureReason,
+		&customDomain.MailFromDomain,
+		&customDomain.MailFromStatus,
 		&metadataJSON,
 		&customDomain.CreatedAt,
 		&customDomain.UpdatedAt,
@@ -127,7 +144,7 @@ func (s *CustomDomainService) GetCustomDomainsByAccountID(accountID uuid.UUID) (
 		SELECT id, account_id, domain, status, verification_provider, provider_verification_status, provider_domain_id,
 			   verification_token, dkim_tokens, dns_records,
 			   ses_verification_status, ses_dkim_verification_status, verification_attempted_at,
-			   verified_at, failure_reason, metadata, created_at, updated_at
+			   verified_at, failure_reason, mail_from_domain, mail_from_status, metadata, created_at, updated_at
 		FROM custom_domains WHERE account_id = $1 ORDER BY created_at DESC
 	`
 
@@ -158,6 +175,8 @@ func (s *CustomDomainService) GetCustomDomainsByAccountID(accountID uuid.UUID) (
 			&customDomain.VerificationAttemptedAt,
 			&customDomain.VerifiedAt,
 			&customDomain.FailureReason,
+			&customDomain.MailFromDomain,
+			&customDomain.MailFromStatus,
 			&metadataJSON,
 			&customDomain.CreatedAt,
 			&customDomain.UpdatedAt,
@@ -204,9 +223,11 @@ func (s *CustomDomainService) UpdateCustomDomain(customDomain *models.CustomDoma
 			verification_attempted_at = $10,
 			verified_at = $11,
 			failure_reason = $12,
-			metadata = $13,
+			mail_from_domain = $13,
+			mail_from_status = $14,
+			metadata = $15,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $14
+		WHERE id = $16
 	`
 
 	_, err := s.db.Exec(query,
@@ -222,6 +243,8 @@ func (s *CustomDomainService) UpdateCustomDomain(customDomain *models.CustomDoma
 		customDomain.VerificationAttemptedAt,
 		customDomain.VerifiedAt,
 		customDomain.FailureReason,
+		customDomain.MailFromDomain,
+		customDomain.MailFromStatus,
 		metadataJSON,
 		customDomain.ID,
 	)
@@ -262,7 +285,7 @@ func (s *CustomDomainService) GetCustomDomainByDomain(domain string) (*models.Cu
 		SELECT id, account_id, domain, status, verification_provider, provider_verification_status, provider_domain_id,
 			   verification_token, dkim_tokens, dns_records,
 			   ses_verification_status, ses_dkim_verification_status, verification_attempted_at,
-			   verified_at, failure_reason, metadata, created_at, updated_at
+			   verified_at, failure_reason, mail_from_domain, mail_from_status, metadata, created_at, updated_at
 		FROM custom_domains WHERE domain = $1
 	`
 
@@ -282,6 +305,8 @@ func (s *CustomDomainService) GetCustomDomainByDomain(domain string) (*models.Cu
 		&customDomain.VerificationAttemptedAt,
 		&customDomain.VerifiedAt,
 		&customDomain.FailureReason,
+		&customDomain.MailFromDomain,
+		&customDomain.MailFromStatus,
 		&metadataJSON,
 		&customDomain.CreatedAt,
 		&customDomain.UpdatedAt,
@@ -307,3 +332,57 @@ func (s *CustomDomainService) GetCustomDomainByDomain(domain string) (*models.Cu
 
 	return &customDomain, nil
 }
+
+// GetVerifiedCustomDomainByDomain finds the account that proved it owns the domain name.
+// Any account can add any name, so unverified rows are ignored, and a name verified by
+// more than one account matches neither rather than an arbitrary one.
+func (s *CustomDomainService) GetVerifiedCustomDomainByDomain(domain string) (*models.CustomDomain, error) {
+	rows, err := s.db.Query(
+		`SELECT id FROM custom_domains WHERE LOWER(domain) = LOWER($1) AND status IN ('verified', 'degraded') LIMIT 2`,
+		domain,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom domain: %w", err)
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan custom domain: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	switch len(ids) {
+	case 0:
+		return nil, fmt.Errorf("custom domain not found")
+	case 1:
+		return s.GetCustomDomainByID(ids[0])
+	default:
+		return nil, fmt.Errorf("custom domain %s is verified by more than one account", domain)
+	}
+}
+
+// RestartVerification resets the domain's verification schedule after verification is
+// triggered again: the worker checks it on its next run and the timeout starts over.
+// A failed domain goes back to pending.
+func (s *CustomDomainService) RestartVerification(customDomain *models.CustomDomain) error {
+	if customDomain.Status == models.CustomDomainStatusFailed {
+		customDomain.Status = models.CustomDomainStatusPending
+	}
+
+	query := `
+		UPDATE custom_domains SET
+			status = $1,
+			verification_started_at = CURRENT_TIMESTAMP,
+			verification_attempts = 0,
+			next_verification_at = NULL
+		WHERE id = $2
+	`
+	if _, err := s.db.Exec(query, customDomain.Status, customDomain.ID); err != nil {
+		return fmt.Errorf("failed to restart domain verification: %w", err)
+	}
+	return nil
+}
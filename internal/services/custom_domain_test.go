@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
 	"github.com/google/uuid"
 	"github.com/maylng/backend/internal/models"
+	"github.com/resend/resend-go/v2"
 )
 
 func TestCustomDomainService_CreateCustomDomain(t *testing.T) {
@@ -90,3 +92,50 @@ func TestDNSRecord(t *testing.T) {
 		t.Errorf("Expected record type 'TXT', got '%s'", record.Type)
 	}
 }
+
+func TestReplaceProviderDNSRecordsReplacesMailFromRecords(t *testing.T) {
+	customDomain := &models.CustomDomain{Domain: "example.com", DNSRecords: []models.DNSRecord{
+		{Type: "MX", Name: "bounce.example.com", Value: "feedback-smtp.us-east-1.amazonses.com", Priority: 10, Purpose: models.DNSRecordPurposeMailFrom},
+		{Type: "TXT", Name: "mayl1._domainkey.example.com", Value: "v=DKIM1; p=abc", Purpose: models.DNSRecordPurposeDKIM},
+	}}
+
+	customDomain.ReplaceProviderDNSRecords(nil)
+
+	if len(customDomain.DNSRecords) != 1 || customDomain.DNSRecords[0].Purpose != models.DNSRecordPurposeDKIM {
+		t.Errorf("Expected only the DKIM selector to be kept, got %+v", customDomain.DNSRecords)
+	}
+	if customDomain.DefaultMailFromDomain() != "bounce.example.com" {
+		t.Errorf("Expected MAIL FROM domain 'bounce.example.com', got '%s'", customDomain.DefaultMailFromDomain())
+	}
+}
+
+func TestResendMailFromStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []resend.Record
+		want    string
+	}{
+		{"no SPF records", []resend.Record{{Record: "DKIM", Status: "verified"}}, models.MailFromStatusPending},
+		{"partly verified", []resend.Record{{Record: "SPF", Status: "verified"}, {Record: "SPF", Status: "pending"}}, models.MailFromStatusPending},
+		{"all verified", []resend.Record{{Record: "SPF", Status: "verified"}, {Record: "SPF", Status: "verified"}, {Record: "DKIM", Status: "pending"}}, models.MailFromStatusVerified},
+		{"one failed", []resend.Record{{Record: "SPF", Status: "verified"}, {Record: "SPF", Status: "failed"}}, models.MailFromStatusFailed},
+	}
+
+	for _, tt := range tests {
+		if got := resendMailFromStatus(tt.records); got != tt.want {
+			t.Errorf("%s: expected '%s', got '%s'", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSESMailFromStatus(t *testing.T) {
+	if got := sesMailFromStatus(types.MailFromDomainStatusSuccess); got != models.MailFromStatusVerified {
+		t.Errorf("Expected 'verified', got '%s'", got)
+	}
+	if got := sesMailFromStatus(types.MailFromDomainStatusTemporaryFailure); got != models.MailFromStatusPending {
+		t.Errorf("Expected 'pending', got '%s'", got)
+	}
+	if got := sesMailFromStatus(types.MailFromDomainStatusFailed); got != models.MailFromStatusFailed {
+		t.Errorf("Expected 'failed', got '%s'", got)
+	}
+}
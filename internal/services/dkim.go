@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/auth"
+	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/email/dkim"
+	"github.com/maylng/backend/internal/models"
+)
+
+// retiredDKIMKeyRetention is how long a replaced key stays published so mail signed
+// with it just before the rotation still verifies
+const retiredDKIMKeyRetention = 7 * 24 * time.Hour
+
+const dkimKeyColumns = `id, custom_domain_id, selector, algorithm, public_key_record, encrypted_private_key,
+	status, activated_at, retired_at, created_at, updated_at`
+
+// DKIMService manages self-hosted DKIM keys. Keys are published through the custom
+// domain's DNS records. A new key signs only after its selector record is visible in
+// DNS, and rotation keeps the old selector published alongside the new one until mail
+// signed with it has been delivered.
+type DKIMService struct {
+	db                        *sql.DB
+	customDomainService       *CustomDomainService
+	domainVerificationService *DomainVerificationService
+	resolver                  Resolver
+	encryptionKey             string
+	rotationInterval          time.Duration
+}
+
+// NewDKIMService creates the service. A nil resolver uses the system resolver; a zero
+// rotation interval turns off scheduled rotation.
+func NewDKIMService(db *sql.DB, customDomainService *CustomDomainService, domainVerificationService *DomainVerificationService, resolver Resolver, encryptionKey string, rotationInterval time.Duration) *DKIMService {
+	if resolver == nil {
+		resolver = NewSystemResolver()
+	}
+	return &DKIMService{
+		db:                        db,
+		customDomainService:       customDomainService,
+		domainVerificationService: domainVerificationService,
+		resolver:                  resolver,
+		encryptionKey:             encryptionKey,
+		rotationInterval:          rotationInterval,
+	}
+}
+
+// EnableDKIM generates the domain's first key and publishes its selector record
+func (s *DKIMService) EnableDKIM(customDomain *models.CustomDomain, algorithm string) (*models.DKIMKey, error) {
+	if err := s.checkSigningProvider(customDomain); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.ListKeys(customDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.Status != models.DKIMKeyStatusRetired {
+			return nil, fmt.Errorf("DKIM signing is already enabled")
+		}
+	}
+
+	if algorithm == "" {
+		algorithm = dkim.AlgorithmRSASHA256
+	}
+	return s.createKey(customDomain, algorithm)
+}
+
+// RotateKey publishes a new key that takes over from the active one once its selector
+// record is visible. An empty algorithm keeps the active key's algorithm.
+func (s *DKIMService) RotateKey(customDomain *models.CustomDomain, algorithm string) (*models.DKIMKey, error) {
+	if err := s.checkSigningProvider(customDomain); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.ListKeys(customDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var active *models.DKIMKey
+	for _, key := range keys {
+		switch key.Status {
+		case models.DKIMKeyStatusPending:
+			return nil, fmt.Errorf("a DKIM key rotation is already in progress")
+		case models.DKIMKeyStatusActive:
+			active = key
+		}
+	}
+	if active == nil {
+		return nil, fmt.Errorf("DKIM signing is not enabled")
+	}
+
+	if algorithm == "" {
+		algorithm = active.Algorithm
+	}
+	return s.createKey(customDomain, algorithm)
+}
+
+// checkSigningProvider fails unless the domain's provider sends mail that self-hosted
+// keys can sign; other providers would send it unsigned
+func (s *DKIMService) checkSigningProvider(customDomain *models.CustomDomain) error {
+	provider, err := s.domainVerificationService.Provider(customDomain.VerificationProvider)
+	if err != nil {
+		return err
+	}
+	if !provider.Capabilities().DKIMSigning {
+		return fmt.Errorf("verification provider '%s' does not support self-hosted DKIM signing", customDomain.VerificationProvider)
+	}
+	return nil
+}
+
+// DisableDKIM deletes the domain's keys and unpublishes their selector records
+func (s *DKIMService) DisableDKIM(customDomain *models.CustomDomain) error {
+	result, err := s.db.Exec(`DELETE FROM dkim_keys WHERE custom_domain_id = $1`, customDomain.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete DKIM keys: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("DKIM signing is not enabled")
+	}
+
+	customDomain.ReplaceDNSRecords(models.DNSRecordPurposeDKIM, nil)
+	return s.customDomainService.UpdateCustomDomain(customDomain)
+}
+
+// ListKeys returns the domain's keys, newest first
+func (s *DKIMService) ListKeys(customDomainID uuid.UUID) ([]*models.DKIMKey, error) {
+	rows, err := s.db.Query(
+		`SELECT `+dkimKeyColumns+` FROM dkim_keys WHERE custom_domain_id = $1 ORDER BY created_at DESC`,
+		customDomainID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DKIM keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*models.DKIMKey{}
+	for rows.Next() {
+		key, err := scanDKIMKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DKIM key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// SignerForAddress returns a DKIM signer for the sender's domain in the sending account,
+// or nil if the domain has no active self-hosted key there
+func (s *DKIMService) SignerForAddress(accountID uuid.UUID, address string) (email.MessageSigner, error) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return nil, nil
+	}
+
+	var domain, selector, encryptedKey string
+	err := s.db.QueryRow(`
+		SELECT cd.domain, k.selector, k.encrypted_private_key
+		FROM dkim_keys k
+		JOIN custom_domains cd ON cd.id = k.custom_domain_id
+		WHERE cd.account_id = $1 AND LOWER(cd.domain) = LOWER($2) AND k.status = 'active'
+	`, accountID, address[at+1:]).Scan(&domain, &selector, &encryptedKey)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DKIM key: %w", err)
+	}
+
+	pemKey, err := auth.DecryptString(encryptedKey, s.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DKIM key: %w", err)
+	}
+	privateKey, err := dkim.ParsePrivateKey(pemKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM key: %w", err)
+	}
+
+	signer, err := dkim.NewSigner(domain, selector, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// ProcessKeys activates pending keys whose selector record is published, starts
+// scheduled rotations and removes retired keys past their retention
+func (s *DKIMService) ProcessKeys() error {
+	if err := s.activatePublishedKeys(); err != nil {
+		return err
+	}
+	if err := s.rotateDueKeys(); err != nil {
+		return err
+	}
+	return s.removeRetiredKeys()
+}
+
+func (s *DKIMService) activatePublishedKeys() error {
+	keys, err := s.keysWhere(`status = 'pending'`)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		customDomain, err := s.customDomainService.GetCustomDomainByID(key.CustomDomainID)
+		if err != nil {
+			log.Printf("Failed to get domain for DKIM key %s: %v", key.ID, err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dnsCheckTimeout)
+		result := checkDNSRecord(ctx, s.resolver, dkimDNSRecord(customDomain.Domain, key))
+		cancel()
+		if !result.IsPresent {
+			continue
+		}
+
+		if err := s.activateKey(key); err != nil {
+			log.Printf("Failed to activate DKIM key %s for %s: %v", key.Selector, customDomain.Domain, err)
+			continue
+		}
+		log.Printf("Activated DKIM selector %s for %s", key.Selector, customDomain.Domain)
+	}
+	return nil
+}
+
+// activateKey makes the key the domain's signing key and retires the previous one
+func (s *DKIMService) activateKey(key *models.DKIMKey) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE dkim_keys SET status = 'retired', retired_at = CURRENT_TIMESTAMP WHERE custom_domain_id = $1 AND status = 'active'`,
+		key.CustomDomainID,
+	); err != nil {
+		return fmt.Errorf("failed to retire DKIM key: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE dkim_keys SET status = 'active', activated_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'pending'`,
+		key.ID,
+	); err != nil {
+		return fmt.Errorf("failed to activate DKIM key: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *DKIMService) rotateDueKeys() error {
+	if s.rotationInterval <= 0 {
+		return nil
+	}
+
+	keys, err := s.keysWhere(`status = 'active' AND activated_at <= $1
+		AND NOT EXISTS (SELECT 1 FROM dkim_keys p WHERE p.custom_domain_id = dkim_keys.custom_domain_id AND p.status = 'pending')`,
+		time.Now().Add(-s.rotationInterval))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		customDomain, err := s.customDomainService.GetCustomDomainByID(key.CustomDomainID)
+		if err != nil {
+			log.Printf("Failed to get domain for DKIM key %s: %v", key.ID, err)
+			continue
+		}
+		if _, err := s.createKey(customDomain, key.Algorithm); err != nil {
+			log.Printf("Failed to rotate DKIM key for %s: %v", customDomain.Domain, err)
+		}
+	}
+	return nil
+}
+
+func (s *DKIMService) removeRetiredKeys() error {
+	keys, err := s.keysWhere(`status = 'retired' AND retired_at <= $1`, time.Now().Add(-retiredDKIMKeyRetention))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		customDomain, err := s.customDomainService.GetCustomDomainByID(key.CustomDomainID)
+		if err != nil {
+			log.Printf("Failed to get domain for DKIM key %s: %v", key.ID, err)
+			continue
+		}
+
+		name := dkim.RecordName(key.Selector, customDomain.Domain)
+		var records []models.DNSRecord
+		for _, record := range customDomain.DNSRecords {
+			if record.Purpose != models.DNSRecordPurposeDKIM || record.Name != name {
+				records = append(records, record)
+			}
+		}
+		customDomain.DNSRecords = records
+		if err := s.customDomainService.UpdateCustomDomain(customDomain); err != nil {
+			log.Printf("Failed to unpublish DKIM selector %s for %s: %v", key.Selector, customDomain.Domain, err)
+			continue
+		}
+
+		if _, err := s.db.Exec(`DELETE FROM dkim_keys WHERE id = $1`, key.ID); err != nil {
+			log.Printf("Failed to delete DKIM key %s: %v", key.ID, err)
+		}
+	}
+	return nil
+}
+
+// createKey generates a pending key and publishes its selector record
+func (s *DKIMService) createKey(customDomain *models.CustomDomain, algorithm string) (*models.DKIMKey, error) {
+	privateKey, err := dkim.GenerateKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	pemKey, err := dkim.MarshalPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode DKIM key: %w", err)
+	}
+	encryptedKey, err := auth.EncryptString(pemKey, s.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt DKIM key: %w", err)
+	}
+	publicKeyRecord, err := dkim.PublicKeyRecord(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode DKIM public key: %w", err)
+	}
+
+	key, err := scanDKIMKey(s.db.QueryRow(
+		`INSERT INTO dkim_keys (custom_domain_id, selector, algorithm, public_key_record, encrypted_private_key)
+		VALUES ($1, $2, $3, $4, $5) RETURNING `+dkimKeyColumns,
+		customDomain.ID, newDKIMSelector(time.Now()), algorithm, publicKeyRecord, encryptedKey,
+	))
+	// A concurrent enable or rotation got there first
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, fmt.Errorf("a DKIM key change is already in progress")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DKIM key: %w", err)
+	}
+
+	customDomain.DNSRecords = append(customDomain.DNSRecords, dkimDNSRecord(customDomain.Domain, key))
+	if err := s.customDomainService.UpdateCustomDomain(customDomain); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *DKIMService) keysWhere(condition string, args ...interface{}) ([]*models.DKIMKey, error) {
+	rows, err := s.db.Query(`SELECT `+dkimKeyColumns+` FROM dkim_keys WHERE `+condition, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DKIM keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.DKIMKey
+	for rows.Next() {
+		key, err := scanDKIMKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DKIM key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func scanDKIMKey(row rowScanner) (*models.DKIMKey, error) {
+	var key models.DKIMKey
+	err := row.Scan(
+		&key.ID, &key.CustomDomainID, &key.Selector, &key.Algorithm, &key.PublicKeyRecord, &key.EncryptedPrivateKey,
+		&key.Status, &key.ActivatedAt, &key.RetiredAt, &key.CreatedAt, &key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func dkimDNSRecord(domain string, key *models.DKIMKey) models.DNSRecord {
+	return models.DNSRecord{
+		Type:    "TXT",
+		Name:    dkim.RecordName(key.Selector, domain),
+		Value:   key.PublicKeyRecord,
+		Purpose: models.DNSRecordPurposeDKIM,
+	}
+}
+
+// newDKIMSelector names a key after its creation time so successive selectors never collide
+func newDKIMSelector(now time.Time) string {
+	return "mayl" + strconv.FormatInt(now.Unix(), 36)
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDKIMSelector(t *testing.T) {
+	first := newDKIMSelector(time.Unix(1760000000, 0))
+	second := newDKIMSelector(time.Unix(1760000001, 0))
+
+	assert.Regexp(t, `^mayl[0-9a-z]+$`, first)
+	assert.NotEqual(t, first, second)
+}
+
+func TestReplaceProviderDNSRecordsKeepsDKIMSelectors(t *testing.T) {
+	selector := dkimDNSRecord("example.com", &models.DKIMKey{Selector: "mayl1", PublicKeyRecord: "v=DKIM1; k=ed25519; p=abc"})
+	customDomain := &models.CustomDomain{DNSRecords: []models.DNSRecord{
+		{Type: "TXT", Name: "_amazonses.example.com", Value: "old"},
+		selector,
+	}}
+
+	customDomain.ReplaceProviderDNSRecords([]models.DNSRecord{{Type: "TXT", Name: "_amazonses.example.com", Value: "new"}})
+
+	assert.Equal(t, []models.DNSRecord{{Type: "TXT", Name: "_amazonses.example.com", Value: "new"}, selector}, customDomain.DNSRecords)
+	assert.Equal(t, "mayl1._domainkey.example.com", selector.Name)
+}
+
+func TestEnableDKIMRequiresSigningProvider(t *testing.T) {
+	// The provider check happens before any database access
+	registry := NewDomainVerificationService(nil, "ses", &fakeVerificationProvider{name: "ses", dkimSigning: true}, &fakeVerificationProvider{name: "resend"})
+	service := NewDKIMService(nil, nil, registry, &FakeResolver{}, "", 0)
+
+	_, err := service.EnableDKIM(&models.CustomDomain{Domain: "example.com", VerificationProvider: "resend"}, "")
+	assert.EqualError(t, err, "verification provider 'resend' does not support self-hosted DKIM signing")
+
+	_, err = service.RotateKey(&models.CustomDomain{Domain: "example.com", VerificationProvider: "resend"}, "")
+	assert.EqualError(t, err, "verification provider 'resend' does not support self-hosted DKIM signing")
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/email/dmarc"
+	"github.com/maylng/backend/internal/models"
+)
+
+// maxDMARCSources caps the source IPs returned in a stats response
+const maxDMARCSources = 100
+
+// dmarcAlignmentColumns sums a set of report records into DMARCAlignmentStats order
+const dmarcAlignmentColumns = `
+	COALESCE(SUM(rec.message_count), 0),
+	COALESCE(SUM(CASE WHEN rec.dkim_aligned OR rec.spf_aligned THEN rec.message_count ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN rec.dkim_aligned THEN rec.message_count ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN rec.spf_aligned THEN rec.message_count ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN rec.disposition = 'quarantine' THEN rec.message_count ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN rec.disposition = 'reject' THEN rec.message_count ELSE 0 END), 0)`
+
+// ReportIngestResult counts what happened to the DMARC or TLS reports in one delivery
+type ReportIngestResult struct {
+	Stored        int      `json:"stored"`
+	Duplicates    int      `json:"duplicates"`
+	UnknownDomain []string `json:"unknown_domains,omitempty"`
+}
+
+// DMARCReportService stores DMARC aggregate reports sent to the report address and
+// summarizes them per custom domain
+type DMARCReportService struct {
+	db                  *sql.DB
+	customDomainService *CustomDomainService
+}
+
+func NewDMARCReportService(db *sql.DB, customDomainService *CustomDomainService) *DMARCReportService {
+	return &DMARCReportService{
+		db:                  db,
+		customDomainService: customDomainService,
+	}
+}
+
+// IngestMessage stores the reports attached to a report email
+func (s *DMARCReportService) IngestMessage(raw []byte) (*ReportIngestResult, error) {
+	reports, err := dmarc.ParseMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.Ingest(reports)
+}
+
+// IngestFile stores the reports in an XML, gzip or zip report file
+func (s *DMARCReportService) IngestFile(data []byte) (*ReportIngestResult, error) {
+	reports, err := dmarc.ParseFile(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.Ingest(reports)
+}
+
+// Ingest stores reports for domains that are registered as custom domains. Reports
+// already stored are skipped, since receivers resend them after delivery failures.
+func (s *DMARCReportService) Ingest(reports []*dmarc.Feedback) (*ReportIngestResult, error) {
+	result := &ReportIngestResult{}
+	for _, report := range reports {
+		customDomain := findReportDomain(s.customDomainService, report.Policy.Domain)
+		if customDomain == nil {
+			result.UnknownDomain = append(result.UnknownDomain, report.Policy.Domain)
+			continue
+		}
+
+		stored, err := s.storeReport(customDomain.ID, report)
+		if err != nil {
+			return result, err
+		}
+		if stored {
+			result.Stored++
+		} else {
+			result.Duplicates++
+		}
+	}
+	return result, nil
+}
+
+// findReportDomain finds the verified custom domain a DMARC or TLS report is about.
+// Reports about a subdomain belong to the closest verified parent domain.
+func findReportDomain(customDomainService *CustomDomainService, domain string) *models.CustomDomain {
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), "."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		if customDomain, err := customDomainService.GetVerifiedCustomDomainByDomain(strings.Join(labels[i:], ".")); err == nil {
+			return customDomain
+		}
+	}
+	return nil
+}
+
+func (s *DMARCReportService) storeReport(customDomainID uuid.UUID, report *dmarc.Feedback) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var reportID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO dmarc_reports (custom_domain_id, org_name, reporter_email, report_id, date_begin, date_end, policy)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (custom_domain_id, org_name, report_id) DO NOTHING
+		RETURNING id
+	`, customDomainID, report.Metadata.OrgName, report.Metadata.Email, report.Metadata.ReportID,
+		report.Begin(), report.End(), report.Policy.P).Scan(&reportID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to store DMARC report: %w", err)
+	}
+
+	for i := range report.Records {
+		row := newDMARCRecordRow(&report.Records[i])
+		_, err := tx.Exec(`
+			INSERT INTO dmarc_report_records (report_id, source_ip, message_count, disposition, dkim_aligned, spf_aligned,
+				header_from, envelope_from, dkim_domain, dkim_result, spf_domain, spf_result)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, reportID, row.sourceIP, row.count, row.disposition, row.dkimAligned, row.spfAligned,
+			row.headerFrom, row.envelopeFrom, row.dkimDomain, row.dkimResult, row.spfDomain, row.spfResult)
+		if err != nil {
+			return false, fmt.Errorf("failed to store DMARC report record: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit DMARC report: %w", err)
+	}
+	return true, nil
+}
+
+// dmarcRecordRow is a report record flattened for storage
+type dmarcRecordRow struct {
+	sourceIP     string
+	count        int
+	disposition  string
+	dkimAligned  bool
+	spfAligned   bool
+	headerFrom   string
+	envelopeFrom string
+	dkimDomain   string
+	dkimResult   string
+	spfDomain    string
+	spfResult    string
+}
+
+// newDMARCRecordRow flattens a record. Of several DKIM signatures it keeps a passing
+// one, preferring the one from the From domain, since that's what made DMARC pass.
+func newDMARCRecordRow(record *dmarc.Record) dmarcRecordRow {
+	row := dmarcRecordRow{
+		sourceIP:     strings.TrimSpace(record.Row.SourceIP),
+		count:        record.Row.Count,
+		disposition:  strings.ToLower(record.Row.PolicyEvaluated.Disposition),
+		dkimAligned:  record.DKIMAligned(),
+		spfAligned:   record.SPFAligned(),
+		headerFrom:   strings.ToLower(record.Identifiers.HeaderFrom),
+		envelopeFrom: strings.ToLower(record.Identifiers.EnvelopeFrom),
+	}
+
+	best := -1
+	for _, result := range record.AuthResults.DKIM {
+		score := 0
+		if strings.EqualFold(result.Result, "pass") {
+			score += 2
+		}
+		if strings.EqualFold(result.Domain, row.headerFrom) {
+			score++
+		}
+		if best == -1 || score > best {
+			best = score
+			row.dkimDomain = strings.ToLower(result.Domain)
+			row.dkimResult = strings.ToLower(result.Result)
+		}
+	}
+	if len(record.AuthResults.SPF) > 0 {
+		row.spfDomain = strings.ToLower(record.AuthResults.SPF[0].Domain)
+		row.spfResult = strings.ToLower(record.AuthResults.SPF[0].Result)
+	}
+	return row
+}
+
+// GetStats summarizes the reports covering the period from since to until
+func (s *DMARCReportService) GetStats(customDomain *models.CustomDomain, since, until time.Time) (*models.DMARCStatsResponse, error) {
+	response := &models.DMARCStatsResponse{
+		Domain:    customDomain.Domain,
+		From:      since,
+		To:        until,
+		Daily:     []models.DMARCDailyStats{},
+		Sources:   []models.DMARCSourceStats{},
+		Reporters: []models.DMARCReporterStats{},
+	}
+
+	const period = `r.custom_domain_id = $1 AND r.date_begin >= $2 AND r.date_begin < $3`
+
+	err := s.db.QueryRow(`
+		SELECT COUNT(DISTINCT r.id),`+dmarcAlignmentColumns+`
+		FROM dmarc_reports r
+		LEFT JOIN dmarc_report_records rec ON rec.report_id = r.id
+		WHERE `+period, customDomain.ID, since, until).Scan(append([]interface{}{&response.Reports}, alignmentScanArgs(&response.Totals)...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DMARC totals: %w", err)
+	}
+	finishAlignmentStats(&response.Totals)
+
+	rows, err := s.db.Query(`
+		SELECT TO_CHAR(DATE_TRUNC('day', r.date_begin), 'YYYY-MM-DD') AS day,`+dmarcAlignmentColumns+`
+		FROM dmarc_reports r
+		JOIN dmarc_report_records rec ON rec.report_id = r.id
+		WHERE `+period+`
+		GROUP BY day ORDER BY day
+	`, customDomain.ID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DMARC daily stats: %w", err)
+	}
+	for rows.Next() {
+		var day models.DMARCDailyStats
+		if err := rows.Scan(append([]interface{}{&day.Date}, alignmentScanArgs(&day.DMARCAlignmentStats)...)...); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan DMARC daily stats: %w", err)
+		}
+		finishAlignmentStats(&day.DMARCAlignmentStats)
+		response.Daily = append(response.Daily, day)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get DMARC daily stats: %w", err)
+	}
+
+	rows, err = s.db.Query(`
+		SELECT rec.source_ip,
+			ARRAY_AGG(DISTINCT rec.header_from) FILTER (WHERE rec.header_from <> ''),
+			ARRAY_AGG(DISTINCT rec.dkim_domain) FILTER (WHERE rec.dkim_domain <> ''),
+			ARRAY_AGG(DISTINCT rec.spf_domain) FILTER (WHERE rec.spf_domain <> ''),`+dmarcAlignmentColumns+`
+		FROM dmarc_reports r
+		JOIN dmarc_report_records rec ON rec.report_id = r.id
+		WHERE `+period+`
+		GROUP BY rec.source_ip
+		ORDER BY SUM(rec.message_count) DESC, rec.source_ip
+		LIMIT $4
+	`, customDomain.ID, since, until, maxDMARCSources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DMARC sources: %w", err)
+	}
+	for rows.Next() {
+		var source models.DMARCSourceStats
+		args := []interface{}{&source.SourceIP, pq.Array(&source.HeaderFrom), pq.Array(&source.DKIMDomains), pq.Array(&source.SPFDomains)}
+		if err := rows.Scan(append(args, alignmentScanArgs(&source.DMARCAlignmentStats)...)...); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan DMARC source: %w", err)
+		}
+		if source.HeaderFrom == nil {
+			source.HeaderFrom = []string{}
+		}
+		finishAlignmentStats(&source.DMARCAlignmentStats)
+		response.Sources = append(response.Sources, source)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get DMARC sources: %w", err)
+	}
+
+	rows, err = s.db.Query(`
+		SELECT r.org_name, COUNT(DISTINCT r.id), COALESCE(SUM(rec.message_count), 0)
+		FROM dmarc_reports r
+		LEFT JOIN dmarc_report_records rec ON rec.report_id = r.id
+		WHERE `+period+`
+		GROUP BY r.org_name
+		ORDER BY COUNT(DISTINCT r.id) DESC, r.org_name
+	`, customDomain.ID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DMARC reporters: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var reporter models.DMARCReporterStats
+		if err := rows.Scan(&reporter.OrgName, &reporter.Reports, &reporter.Messages); err != nil {
+			return nil, fmt.Errorf("failed to scan DMARC reporter: %w", err)
+		}
+		response.Reporters = append(response.Reporters, reporter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get DMARC reporters: %w", err)
+	}
+
+	return response, nil
+}
+
+// alignmentScanArgs returns scan destinations matching dmarcAlignmentColumns
+func alignmentScanArgs(stats *models.DMARCAlignmentStats) []interface{} {
+	return []interface{}{&stats.Messages, &stats.DMARCPass, &stats.DKIMAligned, &stats.SPFAligned, &stats.Quarantined, &stats.Rejected}
+}
+
+// finishAlignmentStats fills in the values derived from the summed counts
+func finishAlignmentStats(stats *models.DMARCAlignmentStats) {
+	stats.DMARCFail = stats.Messages - stats.DMARCPass
+	if stats.Messages > 0 {
+		stats.PassRatePct = stats.DMARCPass * 100 / stats.Messages
+	}
+}
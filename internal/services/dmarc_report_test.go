@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/maylng/backend/internal/email/dmarc"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDMARCRecordRowPrefersAlignedDKIM(t *testing.T) {
+	record := &dmarc.Record{
+		Row:         dmarc.Row{SourceIP: " 54.240.8.1 ", Count: 4, PolicyEvaluated: dmarc.PolicyEvaluated{Disposition: "None", DKIM: "pass", SPF: "fail"}},
+		Identifiers: dmarc.Identifiers{HeaderFrom: "Example.com"},
+		AuthResults: dmarc.AuthResults{
+			DKIM: []dmarc.AuthResult{{Domain: "amazonses.com", Result: "pass"}, {Domain: "example.com", Result: "pass"}},
+			SPF:  []dmarc.AuthResult{{Domain: "amazonses.com", Result: "pass"}},
+		},
+	}
+
+	row := newDMARCRecordRow(record)
+	assert.Equal(t, "54.240.8.1", row.sourceIP)
+	assert.Equal(t, "none", row.disposition)
+	assert.True(t, row.dkimAligned)
+	assert.False(t, row.spfAligned)
+	assert.Equal(t, "example.com", row.dkimDomain)
+	assert.Equal(t, "amazonses.com", row.spfDomain)
+}
+
+func TestFinishAlignmentStats(t *testing.T) {
+	stats := models.DMARCAlignmentStats{Messages: 15, DMARCPass: 12}
+	finishAlignmentStats(&stats)
+	assert.Equal(t, 3, stats.DMARCFail)
+	assert.Equal(t, 80, stats.PassRatePct)
+}
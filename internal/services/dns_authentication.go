@@ -0,0 +1,529 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/maylng/backend/internal/models"
+)
+
+// spfMaxLookups is the RFC 7208 limit on DNS-querying SPF terms; receivers return a
+// permanent error for records that need more
+const spfMaxLookups = 10
+
+// Severities of a DNS fix instruction
+const (
+	DNSFixSeverityError   = "error"
+	DNSFixSeverityWarning = "warning"
+	DNSFixSeverityInfo    = "info"
+)
+
+// spfProviderIncludes is the SPF include that authorizes each verification provider's
+// sending servers
+var spfProviderIncludes = map[string]string{
+	"ses":    "amazonses.com",
+	"resend": "amazonses.com",
+}
+
+// SPFCheck describes the SPF record at the domain root
+type SPFCheck struct {
+	Record             string   `json:"record,omitempty"`
+	AllQualifier       string   `json:"all_qualifier,omitempty"` // "-all", "~all", "?all" or "+all"
+	Includes           []string `json:"includes,omitempty"`      // Every include reached, nested ones too
+	LookupCount        int      `json:"lookup_count"`
+	AuthorizesProvider bool     `json:"authorizes_provider"`
+}
+
+// DMARCCheck describes the domain's DMARC policy
+type DMARCCheck struct {
+	Record          string   `json:"record,omitempty"`
+	Policy          string   `json:"policy,omitempty"`
+	SubdomainPolicy string   `json:"subdomain_policy,omitempty"`
+	Percent         int      `json:"percent"`
+	ReportURIs      []string `json:"rua,omitempty"`
+}
+
+// MXCheck describes where mail for the domain is delivered
+type MXCheck struct {
+	Hosts           []string `json:"hosts"`
+	ExpectedHosts   []string `json:"expected_hosts"`
+	PointsToInbound bool     `json:"points_to_inbound"`
+}
+
+// DNSFixInstruction tells the customer what to change in their DNS and why
+type DNSFixInstruction struct {
+	Check    string            `json:"check"` // records, spf, dmarc or mx
+	Severity string            `json:"severity"`
+	Problem  string            `json:"problem"`
+	Fix      string            `json:"fix"`
+	Record   *models.DNSRecord `json:"record,omitempty"` // The record to add or replace, when there is one
+}
+
+// DiagnoseDomainDNS checks the provider's records like ValidateDomainDNS and also the
+// domain's SPF, DMARC and MX setup, with fix instructions for every problem found
+func (s *DNSValidationService) DiagnoseDomainDNS(customDomain *models.CustomDomain) (*DomainDNSStatus, error) {
+	status, err := s.ValidateDomainDNS(customDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range status.ValidationResults {
+		if result.IsPresent {
+			continue
+		}
+		status.FixInstructions = append(status.FixInstructions, DNSFixInstruction{
+			Check:    "records",
+			Severity: DNSFixSeverityError,
+			Problem:  result.Error,
+			Fix:      fmt.Sprintf("Add a %s record named %s with the value %s", result.RecordType, result.RecordName, result.ExpectedValue),
+			Record:   &models.DNSRecord{Type: result.RecordType, Name: result.RecordName, Value: result.ExpectedValue},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsCheckTimeout)
+	defer cancel()
+
+	var fixes []DNSFixInstruction
+	status.SPF, fixes = checkSPF(ctx, s.resolver, customDomain.Domain, spfProviderIncludes[customDomain.VerificationProvider])
+	status.FixInstructions = append(status.FixInstructions, fixes...)
+
+	status.DMARC, fixes = checkDMARC(ctx, s.resolver, customDomain.Domain, s.dmarcReportAddress)
+	status.FixInstructions = append(status.FixInstructions, fixes...)
+
+	if len(s.inboundMXHosts) > 0 {
+		status.MX, fixes = checkMX(ctx, s.resolver, customDomain.Domain, s.inboundMXHosts)
+		status.FixInstructions = append(status.FixInstructions, fixes...)
+	}
+
+	return status, nil
+}
+
+// checkSPF evaluates the SPF record at the domain root, following include and redirect
+// terms to count DNS lookups
+func checkSPF(ctx context.Context, resolver Resolver, domain, providerInclude string) (*SPFCheck, []DNSFixInstruction) {
+	check := &SPFCheck{}
+	suggested := "v=spf1 ~all"
+	if providerInclude != "" {
+		suggested = fmt.Sprintf("v=spf1 include:%s ~all", providerInclude)
+	}
+
+	txtRecords, err := lookupTXTRecords(ctx, resolver, domain)
+	if err != nil {
+		return check, []DNSFixInstruction{{
+			Check:    "spf",
+			Severity: DNSFixSeverityWarning,
+			Problem:  fmt.Sprintf("Could not look up the SPF record: %v", err),
+			Fix:      "Check that the domain's nameservers are answering, then check again",
+		}}
+	}
+
+	records := spfRecords(txtRecords)
+	switch {
+	case len(records) == 0:
+		return check, []DNSFixInstruction{{
+			Check:    "spf",
+			Severity: DNSFixSeverityWarning,
+			Problem:  "No SPF record found, so receivers can't tell which servers may send for this domain",
+			Fix:      fmt.Sprintf("Add a TXT record at %s with the value %s", domain, suggested),
+			Record:   &models.DNSRecord{Type: "TXT", Name: domain, Value: suggested},
+		}}
+	case len(records) > 1:
+		return check, []DNSFixInstruction{{
+			Check:    "spf",
+			Severity: DNSFixSeverityError,
+			Problem:  fmt.Sprintf("Found %d SPF records; receivers treat more than one as a permanent error", len(records)),
+			Fix:      "Merge them into a single TXT record starting with v=spf1",
+		}}
+	}
+
+	check.Record = records[0]
+	walker := &spfWalker{ctx: ctx, resolver: resolver, seen: map[string]bool{strings.ToLower(domain): true}}
+	walker.walk(check.Record)
+	check.Includes = walker.includes
+	check.LookupCount = walker.lookups
+	check.AllQualifier = spfAllQualifier(check.Record)
+
+	var fixes []DNSFixInstruction
+	for _, problem := range walker.problems {
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "spf",
+			Severity: DNSFixSeverityError,
+			Problem:  problem,
+			Fix:      "Remove the include or correct the domain it points at",
+		})
+	}
+
+	if check.LookupCount > spfMaxLookups {
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "spf",
+			Severity: DNSFixSeverityError,
+			Problem:  fmt.Sprintf("The SPF record needs %d DNS lookups; receivers fail SPF above %d", check.LookupCount, spfMaxLookups),
+			Fix:      "Remove includes for services you no longer use, or replace them with ip4: and ip6: ranges",
+		})
+	}
+
+	switch check.AllQualifier {
+	case "":
+		if !strings.Contains(strings.ToLower(check.Record), "redirect=") {
+			fixes = append(fixes, DNSFixInstruction{
+				Check:    "spf",
+				Severity: DNSFixSeverityWarning,
+				Problem:  "The SPF record has no all mechanism, so mail from unlisted servers gets a neutral result",
+				Fix:      "End the record with ~all, or -all once every server that sends for the domain is listed",
+			})
+		}
+	case "+all":
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "spf",
+			Severity: DNSFixSeverityError,
+			Problem:  "+all allows any server on the internet to send as this domain",
+			Fix:      "Replace +all with ~all, or -all once every server that sends for the domain is listed",
+		})
+	case "?all":
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "spf",
+			Severity: DNSFixSeverityWarning,
+			Problem:  "?all gives mail from unlisted servers a neutral result, which protects nothing",
+			Fix:      "Replace ?all with ~all, or -all once every server that sends for the domain is listed",
+		})
+	case "~all":
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "spf",
+			Severity: DNSFixSeverityInfo,
+			Problem:  "~all soft-fails mail from unlisted servers, so spoofed mail usually lands in spam instead of being rejected",
+			Fix:      "Switch to -all once your DMARC reports show every legitimate sender passing",
+		})
+	}
+
+	if providerInclude != "" {
+		for _, include := range check.Includes {
+			if strings.EqualFold(include, providerInclude) {
+				check.AuthorizesProvider = true
+				break
+			}
+		}
+		if !check.AuthorizesProvider {
+			value := insertSPFInclude(check.Record, providerInclude)
+			fixes = append(fixes, DNSFixInstruction{
+				Check:    "spf",
+				Severity: DNSFixSeverityWarning,
+				Problem:  fmt.Sprintf("The SPF record doesn't include %s, so mail sent through Maylng can fail SPF", providerInclude),
+				Fix:      fmt.Sprintf("Change the TXT record at %s to %s", domain, value),
+				Record:   &models.DNSRecord{Type: "TXT", Name: domain, Value: value},
+			})
+		}
+	}
+
+	return check, fixes
+}
+
+// spfWalker counts the DNS lookups an SPF record needs, following includes and redirects
+type spfWalker struct {
+	ctx      context.Context
+	resolver Resolver
+	seen     map[string]bool
+	includes []string
+	lookups  int
+	problems []string
+}
+
+func (w *spfWalker) walk(record string) {
+	for _, term := range strings.Fields(record)[1:] {
+		mechanism := strings.TrimLeft(strings.ToLower(term), "+-~?")
+
+		switch {
+		case strings.HasPrefix(mechanism, "include:"):
+			target := strings.TrimPrefix(mechanism, "include:")
+			w.lookups++
+			w.includes = append(w.includes, target)
+			w.follow("include:", target)
+		case strings.HasPrefix(mechanism, "redirect="):
+			w.lookups++
+			w.follow("redirect=", strings.TrimPrefix(mechanism, "redirect="))
+		case mechanism == "a", mechanism == "mx", mechanism == "ptr",
+			strings.HasPrefix(mechanism, "a:"), strings.HasPrefix(mechanism, "a/"),
+			strings.HasPrefix(mechanism, "mx:"), strings.HasPrefix(mechanism, "mx/"),
+			strings.HasPrefix(mechanism, "ptr:"), strings.HasPrefix(mechanism, "exists:"):
+			w.lookups++
+		}
+	}
+}
+
+func (w *spfWalker) follow(term, target string) {
+	// Past the limit the result is already a permanent error; macros can't be expanded here
+	if w.lookups > spfMaxLookups || w.seen[target] || strings.Contains(target, "%") {
+		return
+	}
+	w.seen[target] = true
+
+	txtRecords, err := lookupTXTRecords(w.ctx, w.resolver, target)
+	if err != nil {
+		w.problems = append(w.problems, fmt.Sprintf("%s%s could not be looked up: %v", term, target, err))
+		return
+	}
+
+	records := spfRecords(txtRecords)
+	if len(records) == 0 {
+		w.problems = append(w.problems, fmt.Sprintf("%s%s has no SPF record", term, target))
+		return
+	}
+	w.walk(records[0])
+}
+
+// spfAllQualifier returns the record's all mechanism with its qualifier, or "" if it has none
+func spfAllQualifier(record string) string {
+	for _, term := range strings.Fields(strings.ToLower(record)) {
+		if term == "all" {
+			return "+all"
+		}
+		if len(term) == 4 && strings.HasSuffix(term, "all") && strings.ContainsAny(term[:1], "+-~?") {
+			return term
+		}
+	}
+	return ""
+}
+
+// insertSPFInclude adds an include before the record's all mechanism
+func insertSPFInclude(record, include string) string {
+	terms := strings.Fields(record)
+	for i, term := range terms {
+		if strings.TrimLeft(strings.ToLower(term), "+-~?") == "all" {
+			terms = append(terms[:i], append([]string{"include:" + include}, terms[i:]...)...)
+			return strings.Join(terms, " ")
+		}
+	}
+	return strings.Join(append(terms, "include:"+include), " ")
+}
+
+func spfRecords(txtRecords []string) []string {
+	var records []string
+	for _, txt := range txtRecords {
+		lower := strings.ToLower(strings.TrimSpace(txt))
+		if lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			records = append(records, strings.TrimSpace(txt))
+		}
+	}
+	return records
+}
+
+// checkDMARC evaluates the DMARC policy at _dmarc.<domain>. Aggregate reports are
+// suggested to go to reportAddress when it is set.
+func checkDMARC(ctx context.Context, resolver Resolver, domain, reportAddress string) (*DMARCCheck, []DNSFixInstruction) {
+	check := &DMARCCheck{}
+	name := "_dmarc." + domain
+	rua := "mailto:" + reportAddress
+	if reportAddress == "" {
+		rua = "mailto:dmarc-reports@" + domain
+	}
+	suggested := fmt.Sprintf("v=DMARC1; p=none; rua=%s", rua)
+
+	txtRecords, err := lookupTXTRecords(ctx, resolver, name)
+	if err != nil {
+		return check, []DNSFixInstruction{{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityWarning,
+			Problem:  fmt.Sprintf("Could not look up the DMARC record: %v", err),
+			Fix:      "Check that the domain's nameservers are answering, then check again",
+		}}
+	}
+
+	var records []string
+	for _, txt := range txtRecords {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(txt)), "v=dmarc1") {
+			records = append(records, strings.TrimSpace(txt))
+		}
+	}
+
+	switch {
+	case len(records) == 0:
+		return check, []DNSFixInstruction{{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityWarning,
+			Problem:  "No DMARC record found; Gmail and Yahoo expect one from bulk senders and may send your mail to spam",
+			Fix:      fmt.Sprintf("Add a TXT record at %s with the value %s", name, suggested),
+			Record:   &models.DNSRecord{Type: "TXT", Name: name, Value: suggested},
+		}}
+	case len(records) > 1:
+		return check, []DNSFixInstruction{{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityError,
+			Problem:  fmt.Sprintf("Found %d DMARC records at %s; receivers ignore DMARC when there is more than one", len(records), name),
+			Fix:      "Delete all but one of them",
+		}}
+	}
+
+	check.Record = records[0]
+	tags := parseDMARCTags(check.Record)
+	check.Policy = tags["p"]
+	check.SubdomainPolicy = tags["sp"]
+	check.Percent = 100
+	for _, uri := range strings.Split(tags["rua"], ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			check.ReportURIs = append(check.ReportURIs, uri)
+		}
+	}
+
+	var fixes []DNSFixInstruction
+	if pct, ok := tags["pct"]; ok {
+		percent, err := strconv.Atoi(pct)
+		if err != nil || percent < 0 || percent > 100 {
+			fixes = append(fixes, DNSFixInstruction{
+				Check:    "dmarc",
+				Severity: DNSFixSeverityError,
+				Problem:  fmt.Sprintf("pct=%s is not a number from 0 to 100", pct),
+				Fix:      "Set pct=100 or remove the tag",
+			})
+		} else {
+			check.Percent = percent
+		}
+	}
+
+	switch check.Policy {
+	case "quarantine", "reject":
+	case "none":
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityInfo,
+			Problem:  "p=none only monitors; mail that fails DMARC is still delivered",
+			Fix:      "Move to p=quarantine once your reports show your mail passing, then to p=reject",
+		})
+	default:
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityError,
+			Problem:  "The DMARC record has no valid p= policy, so receivers ignore it",
+			Fix:      "Set p=none, p=quarantine or p=reject",
+		})
+	}
+
+	if check.SubdomainPolicy == "none" && (check.Policy == "quarantine" || check.Policy == "reject") {
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityWarning,
+			Problem:  "sp=none leaves subdomains unprotected even though the domain itself is",
+			Fix:      "Remove the sp= tag so subdomains inherit p=, or set it to quarantine or reject",
+		})
+	}
+
+	if check.Percent < 100 && check.Policy != "none" {
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityInfo,
+			Problem:  fmt.Sprintf("The policy applies to only %d%% of failing mail", check.Percent),
+			Fix:      "Raise pct to 100 once your reports show your mail passing",
+		})
+	}
+
+	if len(check.ReportURIs) == 0 {
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityWarning,
+			Problem:  "The DMARC record has no rua= address, so you won't receive aggregate reports",
+			Fix:      fmt.Sprintf("Add rua=%s to the record", rua),
+		})
+	} else if reportAddress != "" && !hasReportURI(check.ReportURIs, rua) {
+		fixes = append(fixes, DNSFixInstruction{
+			Check:    "dmarc",
+			Severity: DNSFixSeverityInfo,
+			Problem:  "Aggregate reports aren't sent to Maylng, so the domain's DMARC stats stay empty",
+			Fix:      fmt.Sprintf("Add %s to the rua= tag, separated from the other addresses by a comma", rua),
+		})
+	}
+
+	return check, fixes
+}
+
+// parseDMARCTags splits a DMARC record into lowercased tag names and their values
+func parseDMARCTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(record, ";") {
+		name, value, found := strings.Cut(part, "=")
+		if !found {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if name == "p" || name == "sp" {
+			value = strings.ToLower(value)
+		}
+		tags[name] = value
+	}
+	return tags
+}
+
+// hasReportURI reports whether uris includes uri, ignoring a "!size" limit suffix
+func hasReportURI(uris []string, uri string) bool {
+	for _, candidate := range uris {
+		candidate, _, _ = strings.Cut(candidate, "!")
+		if strings.EqualFold(candidate, uri) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMX verifies that the domain's MX records point at the inbound servers
+func checkMX(ctx context.Context, resolver Resolver, domain string, inboundHosts []string) (*MXCheck, []DNSFixInstruction) {
+	check := &MXCheck{Hosts: []string{}, ExpectedHosts: inboundHosts}
+	suggested := &models.DNSRecord{Type: "MX", Name: domain, Value: inboundHosts[0], Priority: 10}
+
+	mxRecords, err := resolver.LookupMX(ctx, domain)
+	if err != nil && !isDNSNotFound(err) {
+		return check, []DNSFixInstruction{{
+			Check:    "mx",
+			Severity: DNSFixSeverityWarning,
+			Problem:  fmt.Sprintf("Could not look up MX records: %v", err),
+			Fix:      "Check that the domain's nameservers are answering, then check again",
+		}}
+	}
+
+	for _, mx := range mxRecords {
+		host := strings.TrimSuffix(mx.Host, ".")
+		check.Hosts = append(check.Hosts, host)
+		for _, inbound := range inboundHosts {
+			if strings.EqualFold(host, strings.TrimSuffix(inbound, ".")) {
+				check.PointsToInbound = true
+			}
+		}
+	}
+
+	if len(check.Hosts) == 0 {
+		return check, []DNSFixInstruction{{
+			Check:    "mx",
+			Severity: DNSFixSeverityWarning,
+			Problem:  fmt.Sprintf("%s has no MX records, so it can't receive mail", domain),
+			Fix:      fmt.Sprintf("Add an MX record at %s pointing to %s with priority 10", domain, inboundHosts[0]),
+			Record:   suggested,
+		}}
+	}
+
+	if !check.PointsToInbound {
+		return check, []DNSFixInstruction{{
+			Check:    "mx",
+			Severity: DNSFixSeverityWarning,
+			Problem:  fmt.Sprintf("MX records point to %s, so mail to this domain doesn't reach Maylng", strings.Join(check.Hosts, ", ")),
+			Fix:      fmt.Sprintf("Replace the MX records at %s with one pointing to %s with priority 10", domain, inboundHosts[0]),
+			Record:   suggested,
+		}}
+	}
+
+	return check, nil
+}
+
+// lookupTXTRecords treats a name without TXT records as empty rather than an error
+func lookupTXTRecords(ctx context.Context, resolver Resolver, name string) ([]string, error) {
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil && !isDNSNotFound(err) {
+		return nil, err
+	}
+	return records, nil
+}
+
+func isDNSNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSPF(t *testing.T) {
+	resolver := &FakeResolver{TXT: map[string][]string{
+		"example.com":       {"google-site-verification=abc", "v=spf1 include:_spf.example.net include:amazonses.com mx ~all"},
+		"_spf.example.net":  {"v=spf1 include:_spf2.example.net a -all"},
+		"_spf2.example.net": {"v=spf1 ip4:192.0.2.0/24 -all"},
+		"amazonses.com":     {"v=spf1 ip4:199.255.192.0/22 -all"},
+	}}
+
+	check, fixes := checkSPF(context.Background(), resolver, "example.com", "amazonses.com")
+	assert.Equal(t, "~all", check.AllQualifier)
+	assert.Equal(t, []string{"_spf.example.net", "_spf2.example.net", "amazonses.com"}, check.Includes)
+	assert.Equal(t, 5, check.LookupCount)
+	assert.True(t, check.AuthorizesProvider)
+	assert.Len(t, fixes, 1)
+	assert.Equal(t, DNSFixSeverityInfo, fixes[0].Severity)
+
+	// Too many lookups and a missing provider include
+	resolver = &FakeResolver{TXT: map[string][]string{
+		"example.com":   {"v=spf1 a mx ptr exists:x.example.com include:a.example.com -all"},
+		"a.example.com": {"v=spf1 a mx a:1.example.com a:2.example.com a:3.example.com a:4.example.com -all"},
+	}}
+	check, fixes = checkSPF(context.Background(), resolver, "example.com", "amazonses.com")
+	assert.Equal(t, 11, check.LookupCount)
+	assert.False(t, check.AuthorizesProvider)
+	assert.Len(t, fixes, 2)
+	assert.Equal(t, DNSFixSeverityError, fixes[0].Severity)
+	assert.Equal(t, "v=spf1 a mx ptr exists:x.example.com include:a.example.com include:amazonses.com -all", fixes[1].Record.Value)
+
+	// No record at all suggests one
+	_, fixes = checkSPF(context.Background(), &FakeResolver{}, "example.com", "amazonses.com")
+	assert.Len(t, fixes, 1)
+	assert.Equal(t, "v=spf1 include:amazonses.com ~all", fixes[0].Record.Value)
+}
+
+func TestSPFAllQualifier(t *testing.T) {
+	assert.Equal(t, "-all", spfAllQualifier("v=spf1 mx -all"))
+	assert.Equal(t, "+all", spfAllQualifier("v=spf1 all"))
+	assert.Equal(t, "?all", spfAllQualifier("v=spf1 ?ALL"))
+	assert.Equal(t, "", spfAllQualifier("v=spf1 redirect=_spf.example.com"))
+}
+
+func TestCheckDMARC(t *testing.T) {
+	resolver := &FakeResolver{TXT: map[string][]string{
+		"_dmarc.example.com": {"v=DMARC1; p=Reject; sp=none; pct=50; rua=mailto:a@example.com, mailto:b@example.net"},
+	}}
+
+	check, fixes := checkDMARC(context.Background(), resolver, "example.com", "")
+	assert.Equal(t, "reject", check.Policy)
+	assert.Equal(t, "none", check.SubdomainPolicy)
+	assert.Equal(t, 50, check.Percent)
+	assert.Equal(t, []string{"mailto:a@example.com", "mailto:b@example.net"}, check.ReportURIs)
+	assert.Len(t, fixes, 2)
+
+	_, fixes = checkDMARC(context.Background(), resolver, "example.com", "dmarc-reports@mayl.ng")
+	assert.Len(t, fixes, 3)
+	assert.Contains(t, fixes[2].Fix, "mailto:dmarc-reports@mayl.ng")
+	_, fixes = checkDMARC(context.Background(), resolver, "example.com", "B@example.net")
+	assert.Len(t, fixes, 2)
+
+	check, fixes = checkDMARC(context.Background(), &FakeResolver{TXT: map[string][]string{"_dmarc.example.com": {"v=DMARC1"}}}, "example.com", "")
+	assert.Equal(t, 100, check.Percent)
+	assert.Equal(t, DNSFixSeverityError, fixes[0].Severity)
+	assert.Len(t, fixes, 2)
+}
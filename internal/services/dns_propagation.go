@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maylng/backend/internal/models"
+)
+
+// propagationQueryTimeout bounds each query of a propagation check so one unreachable
+// resolver can't hold up the report
+const propagationQueryTimeout = 5 * time.Second
+
+// Kinds of resolver in a propagation report
+const (
+	ResolverKindAuthoritative = "authoritative"
+	ResolverKindPublic        = "public"
+)
+
+// ResolverPropagationResult is what one resolver returns for the domain's records
+type ResolverPropagationResult struct {
+	Resolver          string                `json:"resolver"`
+	Kind              string                `json:"kind"`
+	Address           string                `json:"address"`
+	AllRecordsPresent bool                  `json:"all_records_present"`
+	ValidationResults []DNSValidationResult `json:"validation_results"`
+}
+
+// DNSPropagationReport compares the domain's records across its authoritative nameservers
+// and public resolvers. Records missing from public resolvers but present on the
+// authoritative nameservers are still propagating.
+type DNSPropagationReport struct {
+	Domain          string                      `json:"domain"`
+	Zone            string                      `json:"zone,omitempty"`
+	Nameservers     []string                    `json:"nameservers"`
+	FullyPropagated bool                        `json:"fully_propagated"`
+	Resolvers       []ResolverPropagationResult `json:"resolvers"`
+	Error           string                      `json:"error,omitempty"`
+	CheckedAt       time.Time                   `json:"checked_at"`
+}
+
+// CheckPropagation queries the domain's authoritative nameservers and each public resolver
+// directly for the domain's records
+func (s *DNSValidationService) CheckPropagation(customDomain *models.CustomDomain) (*DNSPropagationReport, error) {
+	report := &DNSPropagationReport{
+		Domain:      customDomain.Domain,
+		Nameservers: []string{},
+		Resolvers:   []ResolverPropagationResult{},
+		CheckedAt:   time.Now(),
+	}
+
+	type target struct {
+		name, kind, address string
+		resolver            Resolver
+	}
+	var targets []target
+
+	zone, nameservers, err := s.findAuthoritativeNameservers(customDomain.Domain)
+	if err != nil {
+		report.Error = fmt.Sprintf("Could not find the domain's nameservers: %v", err)
+	}
+	report.Zone = zone
+	for _, ns := range nameservers {
+		host := strings.TrimSuffix(ns.Host, ".")
+		address := net.JoinHostPort(host, "53")
+		report.Nameservers = append(report.Nameservers, host)
+		targets = append(targets, target{host, ResolverKindAuthoritative, address, s.serverResolver(address)})
+	}
+	for _, address := range s.publicResolvers {
+		targets = append(targets, target{address, ResolverKindPublic, address, s.serverResolver(address)})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no resolvers to check")
+	}
+
+	report.Resolvers = make([]ResolverPropagationResult, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t target) {
+			defer wg.Done()
+			report.Resolvers[i] = checkRecordsWithResolver(t.resolver, customDomain.DNSRecords)
+			report.Resolvers[i].Resolver = t.name
+			report.Resolvers[i].Kind = t.kind
+			report.Resolvers[i].Address = t.address
+		}(i, t)
+	}
+	wg.Wait()
+
+	report.FullyPropagated = len(nameservers) > 0
+	for _, result := range report.Resolvers {
+		if !result.AllRecordsPresent {
+			report.FullyPropagated = false
+		}
+	}
+
+	return report, nil
+}
+
+func checkRecordsWithResolver(resolver Resolver, records []models.DNSRecord) ResolverPropagationResult {
+	result := ResolverPropagationResult{
+		AllRecordsPresent: true,
+		ValidationResults: []DNSValidationResult{},
+	}
+	for _, record := range records {
+		ctx, cancel := context.WithTimeout(context.Background(), propagationQueryTimeout)
+		check := checkDNSRecord(ctx, resolver, record)
+		cancel()
+
+		result.ValidationResults = append(result.ValidationResults, check)
+		if !check.IsPresent {
+			result.AllRecordsPresent = false
+		}
+	}
+	return result
+}
+
+// findAuthoritativeNameservers walks up from the domain to the zone that has NS records.
+// Records for a subdomain usually live in the parent domain's zone.
+func (s *DNSValidationService) findAuthoritativeNameservers(domain string) (string, []*net.NS, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), propagationQueryTimeout)
+	defer cancel()
+
+	zone := strings.TrimSuffix(domain, ".")
+	for strings.Count(zone, ".") >= 1 {
+		nameservers, err := s.resolver.LookupNS(ctx, zone)
+		if err == nil && len(nameservers) > 0 {
+			return zone, nameservers, nil
+		}
+		if err != nil && !isDNSNotFound(err) {
+			return "", nil, err
+		}
+		_, zone, _ = strings.Cut(zone, ".")
+	}
+	return "", nil, fmt.Errorf("no NS records found for %s or its parent domains", domain)
+}
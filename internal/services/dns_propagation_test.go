@@ -0,0 +1,40 @@
+package services
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPropagation(t *testing.T) {
+	record := models.DNSRecord{Type: "TXT", Name: "_amazonses.mail.example.com", Value: "token"}
+	propagated := &FakeResolver{TXT: map[string][]string{"_amazonses.mail.example.com": {"token"}}}
+	stale := &FakeResolver{}
+
+	service := NewDNSValidationService(&FakeResolver{
+		NS: map[string][]*net.NS{"example.com": {{Host: "ns1.example.net."}}},
+	}, []string{"8.8.8.8:53", "1.1.1.1:53"}, nil, "")
+	service.serverResolver = func(address string) Resolver {
+		if address == "1.1.1.1:53" {
+			return stale
+		}
+		return propagated
+	}
+
+	report, err := service.CheckPropagation(&models.CustomDomain{Domain: "mail.example.com", DNSRecords: []models.DNSRecord{record}})
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", report.Zone)
+	assert.Equal(t, []string{"ns1.example.net"}, report.Nameservers)
+	assert.False(t, report.FullyPropagated)
+	require.Len(t, report.Resolvers, 3)
+
+	assert.Equal(t, ResolverKindAuthoritative, report.Resolvers[0].Kind)
+	assert.Equal(t, "ns1.example.net:53", report.Resolvers[0].Address)
+	assert.True(t, report.Resolvers[0].AllRecordsPresent)
+	assert.True(t, report.Resolvers[1].AllRecordsPresent)
+	assert.Equal(t, "1.1.1.1:53", report.Resolvers[2].Resolver)
+	assert.False(t, report.Resolvers[2].AllRecordsPresent)
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/auth"
+	"github.com/maylng/backend/internal/dnsprovision"
+	"github.com/maylng/backend/internal/models"
+)
+
+// dnsProvisioningTimeout bounds one sync of a domain's records
+const dnsProvisioningTimeout = 2 * time.Minute
+
+// DNSProvisioningService publishes custom domains' DNS records through a DNS provider
+// the customer delegated access to, instead of the customer copying them by hand. It
+// remembers what it published, so records a domain stops needing (a rotated DKIM key,
+// a disabled MTA-STS policy) are removed on the next sync.
+type DNSProvisioningService struct {
+	db                  *sql.DB
+	customDomainService *CustomDomainService
+	encryptionKey       string
+}
+
+func NewDNSProvisioningService(db *sql.DB, customDomainService *CustomDomainService, encryptionKey string) *DNSProvisioningService {
+	return &DNSProvisioningService{
+		db:                  db,
+		customDomainService: customDomainService,
+		encryptionKey:       encryptionKey,
+	}
+}
+
+// Connect stores the credentials for the zone the domain is in and publishes the
+// domain's records. Reconnecting to the same zone keeps track of the records already
+// published there.
+func (s *DNSProvisioningService) Connect(customDomain *models.CustomDomain, req *models.ConnectDNSProviderRequest) (*models.DNSProviderConnection, error) {
+	zone := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(req.Zone)), ".")
+	if zone == "" {
+		zone = customDomain.Domain
+	}
+	if !inZone(customDomain.Domain, zone) {
+		return nil, fmt.Errorf("custom domain is not in the zone")
+	}
+	if _, err := dnsprovision.New(req.Provider, zone, req.Credentials); err != nil {
+		return nil, fmt.Errorf("invalid DNS provider credentials: %w", err)
+	}
+
+	credentials, err := json.Marshal(req.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := auth.EncryptString(string(credentials), s.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt DNS provider credentials: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO dns_provider_connections (custom_domain_id, provider, zone, encrypted_credentials)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (custom_domain_id) DO UPDATE SET
+			provisioned_records = CASE
+				WHEN dns_provider_connections.provider = EXCLUDED.provider AND dns_provider_connections.zone = EXCLUDED.zone
+				THEN dns_provider_connections.provisioned_records ELSE '[]' END,
+			provider = EXCLUDED.provider,
+			zone = EXCLUDED.zone,
+			encrypted_credentials = EXCLUDED.encrypted_credentials,
+			last_error = NULL
+	`, customDomain.ID, req.Provider, zone, encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save DNS provider connection: %w", err)
+	}
+
+	return s.Sync(customDomain)
+}
+
+// GetConnection returns the domain's DNS provider connection
+func (s *DNSProvisioningService) GetConnection(customDomain *models.CustomDomain) (*models.DNSProviderConnection, error) {
+	connection, _, err := s.getConnection(customDomain.ID)
+	return connection, err
+}
+
+// Sync brings the zone in line with the domain's records: records published earlier
+// that the domain no longer needs are deleted and missing ones created. Records that
+// fail are reported in last_error and retried on the next sync. Once everything is
+// published, verification of an unverified domain whose records changed starts over so
+// the worker checks it on its next run.
+func (s *DNSProvisioningService) Sync(customDomain *models.CustomDomain) (*models.DNSProviderConnection, error) {
+	connection, credentials, err := s.getConnection(customDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioner, err := dnsprovision.New(connection.Provider, connection.Zone, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS provider credentials: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsProvisioningTimeout)
+	defer cancel()
+	published, failures := provisionRecords(ctx, provisioner, customDomain.Domain, connection.ProvisionedRecords, customDomain.DNSRecords)
+
+	changed := !sameDNSRecords(connection.ProvisionedRecords, published)
+	now := time.Now()
+	connection.ProvisionedRecords = published
+	connection.LastSyncedAt = &now
+	connection.LastError = nil
+	if len(failures) > 0 {
+		lastError := strings.Join(failures, "; ")
+		connection.LastError = &lastError
+	}
+	if err := s.saveSync(connection); err != nil {
+		return nil, err
+	}
+
+	// Restarting on every sync would keep pushing back the verification timeout
+	if changed && len(failures) == 0 && !customDomain.IsVerified() {
+		if err := s.customDomainService.RestartVerification(customDomain); err != nil {
+			return nil, err
+		}
+	}
+	return connection, nil
+}
+
+// Disconnect deletes the records published through the provider and forgets the
+// credentials. If a record can't be deleted the connection is kept so it can be
+// retried.
+func (s *DNSProvisioningService) Disconnect(customDomain *models.CustomDomain) error {
+	connection, credentials, err := s.getConnection(customDomain.ID)
+	if err != nil {
+		return err
+	}
+
+	provisioner, err := dnsprovision.New(connection.Provider, connection.Zone, credentials)
+	if err != nil {
+		return fmt.Errorf("invalid DNS provider credentials: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsProvisioningTimeout)
+	defer cancel()
+	remaining, failures := provisionRecords(ctx, provisioner, customDomain.Domain, connection.ProvisionedRecords, nil)
+	if len(failures) > 0 {
+		connection.ProvisionedRecords = remaining
+		lastError := strings.Join(failures, "; ")
+		connection.LastError = &lastError
+		if err := s.saveSync(connection); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to delete DNS records: %s", lastError)
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM dns_provider_connections WHERE custom_domain_id = $1`, customDomain.ID); err != nil {
+		return fmt.Errorf("failed to delete DNS provider connection: %w", err)
+	}
+	return nil
+}
+
+// SyncChanged syncs the connections whose domain's records changed since they were
+// published, or whose last sync failed. It returns the number of domains synced and
+// the last error.
+func (s *DNSProvisioningService) SyncChanged() (int, error) {
+	rows, err := s.db.Query(`
+		SELECT c.custom_domain_id
+		FROM dns_provider_connections c
+		JOIN custom_domains d ON d.id = c.custom_domain_id
+		WHERE c.last_error IS NOT NULL OR c.provisioned_records IS DISTINCT FROM COALESCE(d.dns_records, '[]'::jsonb)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find DNS provider connections to sync: %w", err)
+	}
+	var domainIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan DNS provider connection: %w", err)
+		}
+		domainIDs = append(domainIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find DNS provider connections to sync: %w", err)
+	}
+
+	// One domain's failure doesn't hold up the others
+	synced := 0
+	var syncErr error
+	for _, id := range domainIDs {
+		customDomain, err := s.customDomainService.GetCustomDomainByID(id)
+		if err != nil {
+			continue
+		}
+		if _, err := s.Sync(customDomain); err != nil {
+			syncErr = fmt.Errorf("failed to sync DNS records for %s: %w", customDomain.Domain, err)
+			continue
+		}
+		synced++
+	}
+	return synced, syncErr
+}
+
+func (s *DNSProvisioningService) getConnection(customDomainID uuid.UUID) (*models.DNSProviderConnection, map[string]string, error) {
+	connection := &models.DNSProviderConnection{CustomDomainID: customDomainID}
+	var encrypted string
+	var recordsJSON []byte
+	err := s.db.QueryRow(`
+		SELECT provider, zone, encrypted_credentials, provisioned_records, last_synced_at, last_error, created_at, updated_at
+		FROM dns_provider_connections WHERE custom_domain_id = $1
+	`, customDomainID).Scan(&connection.Provider, &connection.Zone, &encrypted, &recordsJSON,
+		&connection.LastSyncedAt, &connection.LastError, &connection.CreatedAt, &connection.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("no DNS provider is connected")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get DNS provider connection: %w", err)
+	}
+
+	if err := json.Unmarshal(recordsJSON, &connection.ProvisionedRecords); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode provisioned records: %w", err)
+	}
+
+	decrypted, err := auth.DecryptString(encrypted, s.encryptionKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt DNS provider credentials: %w", err)
+	}
+	var credentials map[string]string
+	if err := json.Unmarshal([]byte(decrypted), &credentials); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode DNS provider credentials: %w", err)
+	}
+	return connection, credentials, nil
+}
+
+func (s *DNSProvisioningService) saveSync(connection *models.DNSProviderConnection) error {
+	records, err := json.Marshal(connection.ProvisionedRecords)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		UPDATE dns_provider_connections SET provisioned_records = $1, last_synced_at = $2, last_error = $3
+		WHERE custom_domain_id = $4
+	`, records, connection.LastSyncedAt, connection.LastError, connection.CustomDomainID)
+	if err != nil {
+		return fmt.Errorf("failed to save DNS provider sync: %w", err)
+	}
+	return nil
+}
+
+// provisionRecords deletes the previously published records that aren't desired and
+// creates the desired ones. It returns the records now published, which includes old
+// records that failed to delete, and a message for each record that failed.
+func provisionRecords(ctx context.Context, provisioner dnsprovision.Provisioner, domain string, previous, desired []models.DNSRecord) ([]models.DNSRecord, []string) {
+	published := []models.DNSRecord{}
+	var failures []string
+
+	for _, record := range previous {
+		if containsDNSRecord(desired, record) {
+			continue
+		}
+		if err := provisioner.DeleteRecord(ctx, provisionedRecord(domain, record)); err != nil {
+			published = append(published, record)
+			failures = append(failures, fmt.Sprintf("delete %s %s: %v", record.Type, record.Name, err))
+		}
+	}
+
+	for _, record := range desired {
+		if err := provisioner.CreateRecord(ctx, provisionedRecord(domain, record)); err != nil {
+			failures = append(failures, fmt.Sprintf("create %s %s: %v", record.Type, record.Name, err))
+			continue
+		}
+		published = append(published, record)
+	}
+	return published, failures
+}
+
+// sameDNSRecords reports whether a and b hold the same records, in any order
+func sameDNSRecords(a, b []models.DNSRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, record := range a {
+		if !containsDNSRecord(b, record) {
+			return false
+		}
+	}
+	return true
+}
+
+// provisionedRecord converts a domain's record for a provider. Some verification
+// providers return names relative to the domain, which are qualified here.
+func provisionedRecord(domain string, record models.DNSRecord) dnsprovision.Record {
+	name := strings.TrimSuffix(strings.ToLower(record.Name), ".")
+	if name == "" || name == "@" {
+		name = domain
+	} else if !inZone(name, domain) {
+		name += "." + domain
+	}
+	return dnsprovision.Record{
+		Type:     record.Type,
+		Name:     name,
+		Value:    record.Value,
+		TTL:      record.TTL,
+		Priority: record.Priority,
+	}
+}
+
+func containsDNSRecord(records []models.DNSRecord, record models.DNSRecord) bool {
+	for _, r := range records {
+		if strings.EqualFold(r.Type, record.Type) && strings.EqualFold(r.Name, record.Name) && r.Value == record.Value && r.Priority == record.Priority {
+			return true
+		}
+	}
+	return false
+}
+
+// inZone reports whether name is the zone's apex or below it
+func inZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
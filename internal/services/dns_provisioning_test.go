@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/maylng/backend/internal/dnsprovision"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvisioner records the changes made and fails for names in failNames
+type fakeProvisioner struct {
+	created   []string
+	deleted   []string
+	failNames map[string]bool
+}
+
+func (p *fakeProvisioner) CreateRecord(ctx context.Context, record dnsprovision.Record) error {
+	if p.failNames[record.Name] {
+		return fmt.Errorf("rejected")
+	}
+	p.created = append(p.created, record.Type+" "+record.Name+" "+record.Value)
+	return nil
+}
+
+func (p *fakeProvisioner) DeleteRecord(ctx context.Context, record dnsprovision.Record) error {
+	if p.failNames[record.Name] {
+		return fmt.Errorf("rejected")
+	}
+	p.deleted = append(p.deleted, record.Type+" "+record.Name+" "+record.Value)
+	return nil
+}
+
+func TestProvisionRecords(t *testing.T) {
+	oldKey := models.DNSRecord{Type: "TXT", Name: "old._domainkey.example.com", Value: "v=DKIM1; p=old", Purpose: models.DNSRecordPurposeDKIM}
+	newKey := models.DNSRecord{Type: "TXT", Name: "new._domainkey.example.com", Value: "v=DKIM1; p=new", Purpose: models.DNSRecordPurposeDKIM}
+	spf := models.DNSRecord{Type: "MX", Name: "send", Value: "feedback-smtp.us-east-1.amazonses.com", Priority: 10}
+
+	provisioner := &fakeProvisioner{}
+	published, failures := provisionRecords(context.Background(), provisioner, "example.com",
+		[]models.DNSRecord{oldKey, spf}, []models.DNSRecord{spf, newKey})
+
+	assert.Equal(t, []string{"TXT old._domainkey.example.com v=DKIM1; p=old"}, provisioner.deleted)
+	assert.Equal(t, []string{
+		"MX send.example.com feedback-smtp.us-east-1.amazonses.com",
+		"TXT new._domainkey.example.com v=DKIM1; p=new",
+	}, provisioner.created)
+	assert.Equal(t, []models.DNSRecord{spf, newKey}, published)
+	assert.Empty(t, failures)
+
+	// Records that fail to delete stay published so the next sync retries them
+	provisioner = &fakeProvisioner{failNames: map[string]bool{"old._domainkey.example.com": true}}
+	published, failures = provisionRecords(context.Background(), provisioner, "example.com",
+		[]models.DNSRecord{oldKey}, nil)
+	assert.Equal(t, []models.DNSRecord{oldKey}, published)
+	assert.Equal(t, []string{"delete TXT old._domainkey.example.com: rejected"}, failures)
+}
+
+func TestProvisionedRecordName(t *testing.T) {
+	assert.Equal(t, "example.com", provisionedRecord("example.com", models.DNSRecord{Name: "@"}).Name)
+	assert.Equal(t, "resend._domainkey.example.com", provisionedRecord("example.com", models.DNSRecord{Name: "resend._domainkey"}).Name)
+	assert.Equal(t, "_dmarc.example.com", provisionedRecord("example.com", models.DNSRecord{Name: "_dmarc.Example.com."}).Name)
+	assert.True(t, inZone("mail.example.com", "example.com"))
+	assert.False(t, inZone("badexample.com", "example.com"))
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// Resolver answers the DNS queries DNSValidationService makes. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NewSystemResolver returns the operating system's resolver
+func NewSystemResolver() Resolver {
+	return net.DefaultResolver
+}
+
+// NewServerResolver returns a resolver that sends every query straight to one DNS server,
+// bypassing the system resolver and any cache in front of it. The address is host:port.
+// With network "tcp" every query uses TCP; otherwise queries use UDP and fall back to
+// TCP for truncated answers.
+func NewServerResolver(address, network string, timeout time.Duration) Resolver {
+	dialer := &net.Dialer{Timeout: timeout}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, requested, _ string) (net.Conn, error) {
+			if network == "tcp" {
+				requested = "tcp"
+			}
+			return dialer.DialContext(ctx, requested, address)
+		},
+	}
+}
+
+// FakeResolver answers from in-memory records, for tests and offline development.
+// Names are matched case-insensitively, with or without the trailing dot.
+type FakeResolver struct {
+	CNAME map[string]string
+	TXT   map[string][]string
+	MX    map[string][]*net.MX
+	NS    map[string][]*net.NS
+	Host  map[string][]string
+}
+
+func (r *FakeResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	for name, value := range r.CNAME {
+		if fakeResolverNameEqual(name, host) {
+			return value, nil
+		}
+	}
+	return "", fakeResolverNotFound(host)
+}
+
+func (r *FakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	for key, value := range r.TXT {
+		if fakeResolverNameEqual(key, name) {
+			return value, nil
+		}
+	}
+	return nil, fakeResolverNotFound(name)
+}
+
+func (r *FakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	for key, value := range r.MX {
+		if fakeResolverNameEqual(key, name) {
+			return value, nil
+		}
+	}
+	return nil, fakeResolverNotFound(name)
+}
+
+func (r *FakeResolver) LookupNS(_ context.Context, name string) ([]*net.NS, error) {
+	for key, value := range r.NS {
+		if fakeResolverNameEqual(key, name) {
+			return value, nil
+		}
+	}
+	return nil, fakeResolverNotFound(name)
+}
+
+func (r *FakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	for name, addrs := range r.Host {
+		if fakeResolverNameEqual(name, host) {
+			return addrs, nil
+		}
+	}
+	return nil, fakeResolverNotFound(host)
+}
+
+func fakeResolverNameEqual(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+func fakeResolverNotFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
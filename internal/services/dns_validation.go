@@ -1,18 +1,41 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"net"
 	"strings"
 	"time"
 
 	"github.com/maylng/backend/internal/models"
 )
 
-type DNSValidationService struct{}
+type DNSValidationService struct {
+	resolver Resolver
+	// serverResolver returns a resolver that queries one nameserver directly
+	serverResolver func(address string) Resolver
+	// publicResolvers are host:port addresses of the resolvers a propagation check queries
+	// alongside the domain's authoritative nameservers
+	publicResolvers []string
+	// inboundMXHosts are the mail servers a domain's MX records should point at
+	inboundMXHosts []string
+	// dmarcReportAddress is where DMARC records should send aggregate reports
+	dmarcReportAddress string
+}
 
-func NewDNSValidationService() *DNSValidationService {
-	return &DNSValidationService{}
+// NewDNSValidationService creates the service. A nil resolver uses the system resolver.
+func NewDNSValidationService(resolver Resolver, publicResolvers, inboundMXHosts []string, dmarcReportAddress string) *DNSValidationService {
+	if resolver == nil {
+		resolver = NewSystemResolver()
+	}
+	return &DNSValidationService{
+		resolver: resolver,
+		serverResolver: func(address string) Resolver {
+			return NewServerResolver(address, "udp", propagationQueryTimeout)
+		},
+		publicResolvers:    publicResolvers,
+		inboundMXHosts:     inboundMXHosts,
+		dmarcReportAddress: dmarcReportAddress,
+	}
 }
 
 type DNSValidationResult struct {
@@ -28,11 +51,22 @@ type DomainDNSStatus struct {
 	Domain            string                `json:"domain"`
 	AllRecordsPresent bool                  `json:"all_records_present"`
 	ValidationResults []DNSValidationResult `json:"validation_results"`
+	SPF               *SPFCheck             `json:"spf,omitempty"`
+	DMARC             *DMARCCheck           `json:"dmarc,omitempty"`
+	MX                *MXCheck              `json:"mx,omitempty"`
+	FixInstructions   []DNSFixInstruction   `json:"fix_instructions,omitempty"`
 	CheckedAt         time.Time             `json:"checked_at"`
 }
 
+// dnsCheckTimeout bounds all the lookups of one check so a slow resolver can't hang a
+// request or the worker
+const dnsCheckTimeout = 15 * time.Second
+
 // ValidateDomainDNS checks if the required DNS records are present for a custom domain
 func (s *DNSValidationService) ValidateDomainDNS(customDomain *models.CustomDomain) (*DomainDNSStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsCheckTimeout)
+	defer cancel()
+
 	status := &DomainDNSStatus{
 		Domain:            customDomain.Domain,
 		AllRecordsPresent: true,
@@ -42,7 +76,7 @@ func (s *DNSValidationService) ValidateDomainDNS(customDomain *models.CustomDoma
 
 	// Check each DNS record
 	for _, record := range customDomain.DNSRecords {
-		result := s.checkDNSRecord(record)
+		result := checkDNSRecord(ctx, s.resolver, record)
 		status.ValidationResults = append(status.ValidationResults, result)
 
 		if !result.IsPresent {
@@ -53,8 +87,8 @@ func (s *DNSValidationService) ValidateDomainDNS(customDomain *models.CustomDoma
 	return status, nil
 }
 
-// checkDNSRecord validates a single DNS record
-func (s *DNSValidationService) checkDNSRecord(record models.DNSRecord) DNSValidationResult {
+// checkDNSRecord validates a single DNS record against the given resolver
+func checkDNSRecord(ctx context.Context, resolver Resolver, record models.DNSRecord) DNSValidationResult {
 	result := DNSValidationResult{
 		RecordType:    record.Type,
 		RecordName:    record.Name,
@@ -64,11 +98,11 @@ func (s *DNSValidationService) checkDNSRecord(record models.DNSRecord) DNSValida
 
 	switch strings.ToUpper(record.Type) {
 	case "CNAME":
-		result = s.checkCNAME(record)
+		result = checkCNAMERecord(ctx, resolver, record)
 	case "TXT":
-		result = s.checkTXT(record)
+		result = checkTXTRecord(ctx, resolver, record)
 	case "MX":
-		result = s.checkMX(record)
+		result = checkMXRecord(ctx, resolver, record)
 	default:
 		result.Error = fmt.Sprintf("Unsupported record type: %s", record.Type)
 	}
@@ -76,8 +110,8 @@ func (s *DNSValidationService) checkDNSRecord(record models.DNSRecord) DNSValida
 	return result
 }
 
-// checkCNAME validates a CNAME record
-func (s *DNSValidationService) checkCNAME(record models.DNSRecord) DNSValidationResult {
+// checkCNAMERecord validates a CNAME record
+func checkCNAMERecord(ctx context.Context, resolver Resolver, record models.DNSRecord) DNSValidationResult {
 	result := DNSValidationResult{
 		RecordType:    record.Type,
 		RecordName:    record.Name,
@@ -86,7 +120,7 @@ func (s *DNSValidationService) checkCNAME(record models.DNSRecord) DNSValidation
 	}
 
 	// Look up CNAME record
-	cname, err := net.LookupCNAME(record.Name)
+	cname, err := resolver.LookupCNAME(ctx, record.Name)
 	if err != nil {
 		result.Error = fmt.Sprintf("DNS lookup failed: %v", err)
 		return result
@@ -106,8 +140,8 @@ func (s *DNSValidationService) checkCNAME(record models.DNSRecord) DNSValidation
 	return result
 }
 
-// checkTXT validates a TXT record
-func (s *DNSValidationService) checkTXT(record models.DNSRecord) DNSValidationResult {
+// checkTXTRecord validates a TXT record
+func checkTXTRecord(ctx context.Context, resolver Resolver, record models.DNSRecord) DNSValidationResult {
 	result := DNSValidationResult{
 		RecordType:    record.Type,
 		RecordName:    record.Name,
@@ -116,7 +150,7 @@ func (s *DNSValidationService) checkTXT(record models.DNSRecord) DNSValidationRe
 	}
 
 	// Look up TXT records
-	txtRecords, err := net.LookupTXT(record.Name)
+	txtRecords, err := resolver.LookupTXT(ctx, record.Name)
 	if err != nil {
 		result.Error = fmt.Sprintf("DNS lookup failed: %v", err)
 		return result
@@ -139,8 +173,8 @@ func (s *DNSValidationService) checkTXT(record models.DNSRecord) DNSValidationRe
 	return result
 }
 
-// checkMX validates an MX record
-func (s *DNSValidationService) checkMX(record models.DNSRecord) DNSValidationResult {
+// checkMXRecord validates an MX record
+func checkMXRecord(ctx context.Context, resolver Resolver, record models.DNSRecord) DNSValidationResult {
 	result := DNSValidationResult{
 		RecordType:    record.Type,
 		RecordName:    record.Name,
@@ -149,7 +183,7 @@ func (s *DNSValidationService) checkMX(record models.DNSRecord) DNSValidationRes
 	}
 
 	// Look up MX records
-	mxRecords, err := net.LookupMX(record.Name)
+	mxRecords, err := resolver.LookupMX(ctx, record.Name)
 	if err != nil {
 		result.Error = fmt.Sprintf("DNS lookup failed: %v", err)
 		return result
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/models"
+)
+
+// Penalties taken off a domain's health score for each problem found
+const (
+	domainHealthPenaltyCritical = 40
+	domainHealthPenaltyError    = 15
+	domainHealthPenaltyWarning  = 5
+)
+
+// blocklistQueryTimeout bounds the blocklist lookups of one domain
+const blocklistQueryTimeout = 10 * time.Second
+
+// DomainHealthService re-checks verified custom domains on a schedule: it validates
+// their DNS records again, diagnoses SPF, DMARC and MX, and looks the domain up on
+// DNS blocklists. Problems lower the domain's health score. A domain with a critical
+// problem (a missing DKIM or provider record, a blocklist listing) or a score under
+// minScore is demoted to degraded, and promoted back once a check finds it healthy.
+// Both changes are recorded as domain.degraded and domain.recovered events.
+type DomainHealthService struct {
+	db                   *sql.DB
+	customDomainService  *CustomDomainService
+	dnsValidationService *DNSValidationService
+	notifier             *domainNotifier
+	blocklists           []string
+	minScore             int
+	interval             time.Duration
+}
+
+func NewDomainHealthService(db *sql.DB, customDomainService *CustomDomainService, dnsValidationService *DNSValidationService, auditService *AuditService, emailService *email.Service, fromEmail string, blocklists []string, minScore int, interval time.Duration) *DomainHealthService {
+	return &DomainHealthService{
+		db:                   db,
+		customDomainService:  customDomainService,
+		dnsValidationService: dnsValidationService,
+		notifier:             newDomainNotifier(db, auditService, emailService, fromEmail),
+		blocklists:           blocklists,
+		minScore:             minScore,
+		interval:             interval,
+	}
+}
+
+// RunBatch checks up to limit verified or degraded domains that are due and returns
+// the number checked
+func (s *DomainHealthService) RunBatch(limit int) (int, error) {
+	rows, err := s.db.Query(`
+		SELECT d.id FROM custom_domains d
+		LEFT JOIN custom_domain_health h ON h.custom_domain_id = d.id
+		WHERE d.status IN ('verified', 'degraded')
+		AND (h.next_check_at IS NULL OR h.next_check_at <= $1)
+		ORDER BY h.next_check_at ASC NULLS FIRST
+		LIMIT $2
+	`, time.Now(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query domains due for a health check: %w", err)
+	}
+
+	var due []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan domain: %w", err)
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query domains due for a health check: %w", err)
+	}
+
+	checked := 0
+	for _, id := range due {
+		customDomain, err := s.customDomainService.GetCustomDomainByID(id)
+		if err != nil {
+			log.Printf("Failed to get domain %s: %v", id, err)
+			continue
+		}
+		if _, err := s.CheckDomain(customDomain); err != nil {
+			log.Printf("Failed to check health of domain %s: %v", customDomain.Domain, err)
+		}
+		checked++
+	}
+	return checked, nil
+}
+
+// CheckDomain checks the domain now, stores the result and demotes or promotes the
+// domain when its health changed
+func (s *DomainHealthService) CheckDomain(customDomain *models.CustomDomain) (*models.DomainHealth, error) {
+	if !customDomain.IsVerified() {
+		return nil, fmt.Errorf("custom domain is not verified")
+	}
+
+	dnsStatus, err := s.dnsValidationService.DiagnoseDomainDNS(customDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blocklistQueryTimeout)
+	defer cancel()
+	listedOn := checkBlocklists(ctx, s.dnsValidationService.resolver, customDomain.Domain, s.blocklists)
+
+	now := time.Now()
+	health := &models.DomainHealth{
+		CustomDomainID: customDomain.ID,
+		BlocklistedOn:  listedOn,
+		CheckedAt:      now,
+		NextCheckAt:    now.Add(s.interval),
+	}
+	health.Score, health.Reasons = scoreDomainHealth(customDomain, dnsStatus, listedOn)
+	degraded := isDegraded(health, s.minScore)
+
+	if err := s.updateStatus(customDomain, health, degraded); err != nil {
+		return nil, err
+	}
+	health.Status = customDomain.Status
+
+	reasons, err := json.Marshal(health.Reasons)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO custom_domain_health (custom_domain_id, score, reasons, blocklisted_on, checked_at, next_check_at, degraded_since)
+		VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $7 THEN $5::timestamp END)
+		ON CONFLICT (custom_domain_id) DO UPDATE SET
+			score = EXCLUDED.score,
+			reasons = EXCLUDED.reasons,
+			blocklisted_on = EXCLUDED.blocklisted_on,
+			checked_at = EXCLUDED.checked_at,
+			next_check_at = EXCLUDED.next_check_at,
+			degraded_since = CASE WHEN $7 THEN COALESCE(custom_domain_health.degraded_since, EXCLUDED.checked_at) END
+		RETURNING degraded_since
+	`, customDomain.ID, health.Score, reasons, pq.Array(health.BlocklistedOn), now, health.NextCheckAt, degraded).Scan(&health.DegradedSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save domain health: %w", err)
+	}
+
+	return health, nil
+}
+
+// GetHealth returns the domain's latest health check
+func (s *DomainHealthService) GetHealth(customDomain *models.CustomDomain) (*models.DomainHealth, error) {
+	health := &models.DomainHealth{CustomDomainID: customDomain.ID, Status: customDomain.Status}
+	var reasons []byte
+	err := s.db.QueryRow(`
+		SELECT score, reasons, blocklisted_on, checked_at, next_check_at, degraded_since
+		FROM custom_domain_health WHERE custom_domain_id = $1
+	`, customDomain.ID).Scan(&health.Score, &reasons, pq.Array(&health.BlocklistedOn), &health.CheckedAt, &health.NextCheckAt, &health.DegradedSince)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("domain health has not been checked yet")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain health: %w", err)
+	}
+	if err := json.Unmarshal(reasons, &health.Reasons); err != nil {
+		return nil, fmt.Errorf("failed to decode domain health reasons: %w", err)
+	}
+	return health, nil
+}
+
+// updateStatus demotes a verified domain that became unhealthy and promotes a degraded
+// one that recovered
+func (s *DomainHealthService) updateStatus(customDomain *models.CustomDomain, health *models.DomainHealth, degraded bool) error {
+	var action string
+	switch {
+	case degraded && customDomain.Status == models.CustomDomainStatusVerified:
+		reason := degradedReason(health)
+		customDomain.Status = models.CustomDomainStatusDegraded
+		customDomain.FailureReason = &reason
+		action = "domain.degraded"
+	case !degraded && customDomain.Status == models.CustomDomainStatusDegraded:
+		customDomain.Status = models.CustomDomainStatusVerified
+		customDomain.FailureReason = nil
+		action = "domain.recovered"
+	default:
+		return nil
+	}
+
+	if err := s.customDomainService.UpdateCustomDomain(customDomain); err != nil {
+		return err
+	}
+	s.notifier.notify(customDomain, action)
+	return nil
+}
+
+// scoreDomainHealth scores a domain from its DNS diagnosis and blocklist listings. The
+// validation results are in the order of the domain's records, whose purpose sets how
+// much a missing one matters: DKIM and provider records are needed to send at all.
+func scoreDomainHealth(customDomain *models.CustomDomain, dnsStatus *DomainDNSStatus, listedOn []string) (int, []models.DomainHealthReason) {
+	reasons := []models.DomainHealthReason{}
+
+	for i, result := range dnsStatus.ValidationResults {
+		if result.IsPresent || i >= len(customDomain.DNSRecords) {
+			continue
+		}
+		severity := models.DomainHealthSeverityCritical
+		switch customDomain.DNSRecords[i].Purpose {
+		case models.DNSRecordPurposeMailFrom:
+			severity = models.DomainHealthSeverityError
+		case models.DNSRecordPurposeMTASTS:
+			severity = models.DomainHealthSeverityWarning
+		}
+		problem := result.Error
+		if problem == "" {
+			problem = fmt.Sprintf("The %s record %s is missing", result.RecordType, result.RecordName)
+		}
+		reasons = append(reasons, models.DomainHealthReason{
+			Check:    "records",
+			Severity: severity,
+			Problem:  problem,
+			Fix:      fmt.Sprintf("Add a %s record named %s with the value %s", result.RecordType, result.RecordName, result.ExpectedValue),
+		})
+	}
+
+	for _, fix := range dnsStatus.FixInstructions {
+		if fix.Check == "records" || fix.Severity == DNSFixSeverityInfo {
+			continue
+		}
+		severity := models.DomainHealthSeverityWarning
+		if fix.Severity == DNSFixSeverityError {
+			severity = models.DomainHealthSeverityError
+		}
+		reasons = append(reasons, models.DomainHealthReason{Check: fix.Check, Severity: severity, Problem: fix.Problem, Fix: fix.Fix})
+	}
+
+	for _, zone := range listedOn {
+		reasons = append(reasons, models.DomainHealthReason{
+			Check:    "blocklist",
+			Severity: models.DomainHealthSeverityCritical,
+			Problem:  fmt.Sprintf("%s is listed on the %s blocklist, so many receivers reject or filter its mail", customDomain.Domain, zone),
+			Fix:      "Find out why on the blocklist operator's website, fix the cause and request delisting",
+		})
+	}
+
+	score := 100
+	for i := range reasons {
+		switch reasons[i].Severity {
+		case models.DomainHealthSeverityCritical:
+			reasons[i].Penalty = domainHealthPenaltyCritical
+		case models.DomainHealthSeverityError:
+			reasons[i].Penalty = domainHealthPenaltyError
+		default:
+			reasons[i].Penalty = domainHealthPenaltyWarning
+		}
+		score -= reasons[i].Penalty
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score, reasons
+}
+
+// isDegraded reports whether the health check should demote the domain
+func isDegraded(health *models.DomainHealth, minScore int) bool {
+	if health.Score < minScore {
+		return true
+	}
+	for _, reason := range health.Reasons {
+		if reason.Severity == models.DomainHealthSeverityCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// degradedReason summarizes the worst problems found for the domain's failure reason
+func degradedReason(health *models.DomainHealth) string {
+	var problems []string
+	for _, severity := range []string{models.DomainHealthSeverityCritical, models.DomainHealthSeverityError} {
+		for _, reason := range health.Reasons {
+			if reason.Severity == severity {
+				problems = append(problems, reason.Problem)
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return fmt.Sprintf("Health score %d is below the minimum", health.Score)
+	}
+	if len(problems) > 3 {
+		problems = append(problems[:3], fmt.Sprintf("and %d more problems", len(problems)-3))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// checkBlocklists looks the domain up on domain-based DNS blocklists (such as
+// dbl.spamhaus.org) and returns the ones it is listed on. A listing is an answer in
+// 127.0.0.0/8; 127.255.255.0/24 answers are the operator refusing the query (Spamhaus
+// refuses queries through public resolvers), not listings. Lookups that fail are
+// skipped rather than counted against the domain.
+func checkBlocklists(ctx context.Context, resolver Resolver, domain string, zones []string) []string {
+	listedOn := []string{}
+	for _, zone := range zones {
+		zone = strings.Trim(zone, ".")
+		addrs, err := resolver.LookupHost(ctx, domain+"."+zone)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr).To4()
+			if ip != nil && ip[0] == 127 && !(ip[1] == 255 && ip[2] == 255) {
+				listedOn = append(listedOn, zone)
+				break
+			}
+		}
+	}
+	return listedOn
+}
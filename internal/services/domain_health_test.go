@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreDomainHealth(t *testing.T) {
+	customDomain := &models.CustomDomain{Domain: "example.com", DNSRecords: []models.DNSRecord{
+		{Type: "CNAME", Name: "abc._domainkey.example.com", Value: "abc.dkim.amazonses.com"},
+		{Type: "MX", Name: "bounce.example.com", Value: "feedback-smtp.us-east-1.amazonses.com", Purpose: models.DNSRecordPurposeMailFrom},
+	}}
+	dnsStatus := &DomainDNSStatus{
+		ValidationResults: []DNSValidationResult{
+			{RecordType: "CNAME", RecordName: "abc._domainkey.example.com", ExpectedValue: "abc.dkim.amazonses.com", IsPresent: true},
+			{RecordType: "MX", RecordName: "bounce.example.com", ExpectedValue: "feedback-smtp.us-east-1.amazonses.com", Error: "MX record not found"},
+		},
+		FixInstructions: []DNSFixInstruction{
+			{Check: "records", Severity: DNSFixSeverityError, Problem: "MX record not found"},
+			{Check: "dmarc", Severity: DNSFixSeverityWarning, Problem: "No DMARC record found"},
+			{Check: "spf", Severity: DNSFixSeverityInfo, Problem: "~all soft-fails mail from unlisted servers"},
+		},
+	}
+
+	score, reasons := scoreDomainHealth(customDomain, dnsStatus, nil)
+	assert.Equal(t, 80, score)
+	assert.Equal(t, []models.DomainHealthReason{
+		{Check: "records", Severity: models.DomainHealthSeverityError, Problem: "MX record not found",
+			Fix: "Add a MX record named bounce.example.com with the value feedback-smtp.us-east-1.amazonses.com", Penalty: 15},
+		{Check: "dmarc", Severity: models.DomainHealthSeverityWarning, Problem: "No DMARC record found", Penalty: 5},
+	}, reasons)
+	assert.False(t, isDegraded(&models.DomainHealth{Score: score, Reasons: reasons}, 60))
+
+	// A missing DKIM record or a blocklist listing degrades the domain whatever the score
+	dnsStatus.ValidationResults[0].IsPresent = false
+	score, reasons = scoreDomainHealth(customDomain, dnsStatus, []string{"dbl.spamhaus.org"})
+	assert.Equal(t, 0, score)
+	assert.Equal(t, models.DomainHealthSeverityCritical, reasons[0].Severity)
+	assert.Equal(t, "The CNAME record abc._domainkey.example.com is missing", reasons[0].Problem)
+	assert.Equal(t, "blocklist", reasons[3].Check)
+
+	health := &models.DomainHealth{Score: 100, Reasons: reasons[3:]}
+	assert.True(t, isDegraded(health, 60))
+	assert.Equal(t, "example.com is listed on the dbl.spamhaus.org blocklist, so many receivers reject or filter its mail", degradedReason(health))
+}
+
+func TestCheckBlocklists(t *testing.T) {
+	resolver := &FakeResolver{Host: map[string][]string{
+		"example.com.dbl.spamhaus.org": {"127.0.1.2"},
+		"example.com.multi.surbl.org":  {"127.255.255.254"},
+	}}
+
+	listedOn := checkBlocklists(context.Background(), resolver, "example.com", []string{"dbl.spamhaus.org", "multi.surbl.org", "uribl.example"})
+	assert.Equal(t, []string{"dbl.spamhaus.org"}, listedOn)
+}
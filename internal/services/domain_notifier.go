@@ -0,0 +1,128 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/models"
+)
+
+// domainNotifier records custom domain lifecycle events (domain.verified, domain.failed,
+// domain.degraded, domain.recovered) in the audit log and emails them to the
+// account's owners and admins
+type domainNotifier struct {
+	db           *sql.DB
+	auditService *AuditService
+	emailService *email.Service
+	fromEmail    string
+}
+
+func newDomainNotifier(db *sql.DB, auditService *AuditService, emailService *email.Service, fromEmail string) *domainNotifier {
+	return &domainNotifier{
+		db:           db,
+		auditService: auditService,
+		emailService: emailService,
+		fromEmail:    fromEmail,
+	}
+}
+
+// notify records the domain event and emails the account's owners and admins
+func (n *domainNotifier) notify(customDomain *models.CustomDomain, action string) {
+	domainID := customDomain.ID.String()
+	after, _ := json.Marshal(map[string]interface{}{
+		"id":             customDomain.ID,
+		"domain":         customDomain.Domain,
+		"status":         customDomain.Status,
+		"failure_reason": customDomain.FailureReason,
+	})
+
+	if n.auditService != nil {
+		err := n.auditService.Record(&models.AuditEvent{
+			AccountID:    customDomain.AccountID,
+			Action:       action,
+			ResourceType: "custom_domain",
+			ResourceID:   &domainID,
+			After:        after,
+		})
+		if err != nil {
+			log.Printf("Failed to record %s event for domain %s: %v", action, customDomain.Domain, err)
+		}
+	}
+
+	if n.emailService == nil {
+		return
+	}
+
+	recipients, err := n.notificationRecipients(customDomain.AccountID)
+	if err != nil {
+		log.Printf("Failed to look up recipients for domain %s: %v", customDomain.Domain, err)
+		return
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	subject, text := domainEventEmail(customDomain, action)
+	_, err = n.emailService.SendEmail(&email.Email{
+		FromEmail:    n.fromEmail,
+		FromName:     "Maylng",
+		ToRecipients: recipients,
+		Subject:      subject,
+		TextContent:  text,
+	})
+	if err != nil {
+		log.Printf("Failed to send %s email for domain %s: %v", action, customDomain.Domain, err)
+	}
+}
+
+func (n *domainNotifier) notificationRecipients(accountID uuid.UUID) ([]string, error) {
+	rows, err := n.db.Query(`
+		SELECT m.email FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE o.account_id = $1 AND m.role IN ('owner', 'admin')
+		ORDER BY m.created_at ASC
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, address)
+	}
+	return recipients, rows.Err()
+}
+
+func domainEventEmail(customDomain *models.CustomDomain, action string) (string, string) {
+	reason := ""
+	if customDomain.FailureReason != nil {
+		reason = *customDomain.FailureReason
+	}
+
+	switch action {
+	case "domain.verified":
+		return fmt.Sprintf("%s is verified", customDomain.Domain),
+			fmt.Sprintf("Your domain %s is verified and ready to send email.\n", customDomain.Domain)
+	case "domain.degraded":
+		return fmt.Sprintf("%s needs attention", customDomain.Domain),
+			fmt.Sprintf("A routine check of your domain %s found problems that can hurt delivery:\n\n%s\n\nSee the domain's health in the dashboard or at GET /v1/custom-domains/%s/health for how to fix them.\n", customDomain.Domain, reason, customDomain.ID)
+	case "domain.recovered":
+		return fmt.Sprintf("%s is healthy again", customDomain.Domain),
+			fmt.Sprintf("The problems found with your domain %s are fixed.\n", customDomain.Domain)
+	}
+
+	if reason == "" {
+		reason = "The verification provider rejected the domain."
+	}
+	return fmt.Sprintf("%s could not be verified", customDomain.Domain),
+		fmt.Sprintf("Verification of your domain %s failed.\n\n%s\n\nFix the DNS records and trigger verification again to retry.\n", customDomain.Domain, reason)
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/models"
+)
+
+// Limits of a domain's settings
+const (
+	maxDefaultFromNameLength = 100
+	maxSenderPrefixes        = 20
+)
+
+// senderPrefixPattern is the form of an allowed sender prefix: the start of a local part
+var senderPrefixPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._+-]{0,63}$`)
+
+// DomainSettingsService stores custom domains' sending settings and applies them to
+// the emails sent from them
+type DomainSettingsService struct {
+	db              *sql.DB
+	trackingService *EmailTrackingService
+}
+
+func NewDomainSettingsService(db *sql.DB, trackingService *EmailTrackingService) *DomainSettingsService {
+	return &DomainSettingsService{
+		db:              db,
+		trackingService: trackingService,
+	}
+}
+
+// GetSettings returns the domain's settings, or the defaults if none were saved
+func (s *DomainSettingsService) GetSettings(customDomainID uuid.UUID) (*models.DomainSettings, error) {
+	settings := &models.DomainSettings{CustomDomainID: customDomainID, AllowedSenderPrefixes: []string{}}
+	err := s.db.QueryRow(`
+		SELECT open_tracking, click_tracking, require_tls, default_from_name, default_reply_to, allowed_sender_prefixes, updated_at
+		FROM custom_domain_settings WHERE custom_domain_id = $1
+	`, customDomainID).Scan(&settings.OpenTracking, &settings.ClickTracking, &settings.RequireTLS,
+		&settings.DefaultFromName, &settings.DefaultReplyTo, pq.Array(&settings.AllowedSenderPrefixes), &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateSettings validates and saves the settings the request changes
+func (s *DomainSettingsService) UpdateSettings(customDomain *models.CustomDomain, req *models.UpdateDomainSettingsRequest) (*models.DomainSettings, error) {
+	settings, err := s.GetSettings(customDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyDomainSettings(settings, customDomain.Domain, req); err != nil {
+		return nil, fmt.Errorf("invalid domain settings: %w", err)
+	}
+
+	err = s.db.QueryRow(`
+		INSERT INTO custom_domain_settings (custom_domain_id, open_tracking, click_tracking, require_tls, default_from_name, default_reply_to, allowed_sender_prefixes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (custom_domain_id) DO UPDATE SET
+			open_tracking = EXCLUDED.open_tracking,
+			click_tracking = EXCLUDED.click_tracking,
+			require_tls = EXCLUDED.require_tls,
+			default_from_name = EXCLUDED.default_from_name,
+			default_reply_to = EXCLUDED.default_reply_to,
+			allowed_sender_prefixes = EXCLUDED.allowed_sender_prefixes
+		RETURNING updated_at
+	`, customDomain.ID, settings.OpenTracking, settings.ClickTracking, settings.RequireTLS,
+		settings.DefaultFromName, settings.DefaultReplyTo, pq.Array(settings.AllowedSenderPrefixes)).Scan(&settings.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save domain settings: %w", err)
+	}
+	return settings, nil
+}
+
+// CheckSender fails if the domain's settings don't allow sending from address
+func (s *DomainSettingsService) CheckSender(customDomainID uuid.UUID, address string) error {
+	settings, err := s.GetSettings(customDomainID)
+	if err != nil {
+		return err
+	}
+	if !senderAllowed(settings, address) {
+		return fmt.Errorf("custom domain does not allow sending from %s; allowed prefixes: %s", address, strings.Join(settings.AllowedSenderPrefixes, ", "))
+	}
+	return nil
+}
+
+// ApplyToEmail applies the settings of the sender's custom domain to an email about
+// to be sent. The domain is the one the email's from address belongs to, never another
+// account's domain of the same name. Emails from other domains are left alone.
+func (s *DomainSettingsService) ApplyToEmail(emailID uuid.UUID, msg *email.Email) error {
+	var customDomainID *uuid.UUID
+	err := s.db.QueryRow(`
+		SELECT a.custom_domain_id
+		FROM sent_emails e
+		JOIN email_addresses a ON a.id = e.from_email_id AND a.account_id = e.account_id
+		WHERE e.id = $1
+	`, emailID).Scan(&customDomainID)
+	if err == sql.ErrNoRows || (err == nil && customDomainID == nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get custom domain: %w", err)
+	}
+
+	settings, err := s.GetSettings(*customDomainID)
+	if err != nil {
+		return err
+	}
+	applySettingsToEmail(settings, msg)
+	if s.trackingService != nil && msg.HTMLContent != "" && (settings.OpenTracking || settings.ClickTracking) {
+		msg.HTMLContent = s.trackingService.Instrument(emailID, msg.HTMLContent, settings.OpenTracking, settings.ClickTracking)
+	}
+	return nil
+}
+
+// applySettingsToEmail fills in the from name and reply-to an email doesn't set
+// itself, and requires TLS if the domain does
+func applySettingsToEmail(settings *models.DomainSettings, msg *email.Email) {
+	if msg.FromName == "" && settings.DefaultFromName != nil {
+		msg.FromName = *settings.DefaultFromName
+	}
+	if msg.ReplyTo == "" && settings.DefaultReplyTo != nil {
+		hasReplyTo := false
+		for key := range msg.Headers {
+			if strings.EqualFold(key, "Reply-To") {
+				hasReplyTo = true
+			}
+		}
+		if !hasReplyTo {
+			msg.ReplyTo = *settings.DefaultReplyTo
+		}
+	}
+	msg.RequireTLS = msg.RequireTLS || settings.RequireTLS
+}
+
+// applyDomainSettings validates the request's changes and applies them to settings
+func applyDomainSettings(settings *models.DomainSettings, domain string, req *models.UpdateDomainSettingsRequest) error {
+	if req.OpenTracking != nil {
+		settings.OpenTracking = *req.OpenTracking
+	}
+	if req.ClickTracking != nil {
+		settings.ClickTracking = *req.ClickTracking
+	}
+	if req.RequireTLS != nil {
+		settings.RequireTLS = *req.RequireTLS
+	}
+
+	if req.DefaultFromName != nil {
+		name := strings.TrimSpace(*req.DefaultFromName)
+		if len(name) > maxDefaultFromNameLength {
+			return fmt.Errorf("default_from_name must be at most %d characters", maxDefaultFromNameLength)
+		}
+		if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return fmt.Errorf("default_from_name must not contain control characters")
+		}
+		settings.DefaultFromName = nil
+		if name != "" {
+			settings.DefaultFromName = &name
+		}
+	}
+
+	if req.DefaultReplyTo != nil {
+		replyTo := strings.TrimSpace(*req.DefaultReplyTo)
+		settings.DefaultReplyTo = nil
+		if replyTo != "" {
+			address, err := mail.ParseAddress(replyTo)
+			if err != nil || address.Name != "" {
+				return fmt.Errorf("default_reply_to must be an email address")
+			}
+			settings.DefaultReplyTo = &address.Address
+		}
+	}
+
+	if req.AllowedSenderPrefixes != nil {
+		if len(*req.AllowedSenderPrefixes) > maxSenderPrefixes {
+			return fmt.Errorf("at most %d allowed_sender_prefixes can be set", maxSenderPrefixes)
+		}
+		prefixes := []string{}
+		seen := make(map[string]bool)
+		for _, prefix := range *req.AllowedSenderPrefixes {
+			prefix = strings.ToLower(strings.TrimSpace(prefix))
+			prefix = strings.TrimSuffix(prefix, "@"+domain)
+			if !senderPrefixPattern.MatchString(prefix) {
+				return fmt.Errorf("invalid sender prefix %q: use letters, digits, '.', '_', '+' or '-'", prefix)
+			}
+			if !seen[prefix] {
+				seen[prefix] = true
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		settings.AllowedSenderPrefixes = prefixes
+	}
+	return nil
+}
+
+// senderAllowed reports whether the address's local part starts with an allowed prefix
+func senderAllowed(settings *models.DomainSettings, address string) bool {
+	if len(settings.AllowedSenderPrefixes) == 0 {
+		return true
+	}
+	localPart := strings.ToLower(address)
+	if at := strings.LastIndex(localPart, "@"); at >= 0 {
+		localPart = localPart[:at]
+	}
+	for _, prefix := range settings.AllowedSenderPrefixes {
+		if strings.HasPrefix(localPart, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDomainSettings(t *testing.T) {
+	settings := &models.DomainSettings{AllowedSenderPrefixes: []string{}}
+	on := true
+	name := "  Acme Support "
+	replyTo := "help@acme.com"
+	prefixes := []string{"Billing", "noreply@acme.com", "billing"}
+
+	err := applyDomainSettings(settings, "acme.com", &models.UpdateDomainSettingsRequest{
+		ClickTracking:         &on,
+		DefaultFromName:       &name,
+		DefaultReplyTo:        &replyTo,
+		AllowedSenderPrefixes: &prefixes,
+	})
+	require.NoError(t, err)
+	assert.True(t, settings.ClickTracking)
+	assert.False(t, settings.OpenTracking)
+	assert.Equal(t, "Acme Support", *settings.DefaultFromName)
+	assert.Equal(t, "help@acme.com", *settings.DefaultReplyTo)
+	assert.Equal(t, []string{"billing", "noreply"}, settings.AllowedSenderPrefixes)
+
+	empty := ""
+	require.NoError(t, applyDomainSettings(settings, "acme.com", &models.UpdateDomainSettingsRequest{DefaultReplyTo: &empty}))
+	assert.Nil(t, settings.DefaultReplyTo)
+	assert.NotNil(t, settings.DefaultFromName)
+}
+
+func TestApplyDomainSettings_Invalid(t *testing.T) {
+	badReplyTo := "Support <help@acme.com>"
+	badName := "Acme\r\nBcc: x@evil.com"
+	badPrefixes := []string{"no reply"}
+
+	for _, req := range []*models.UpdateDomainSettingsRequest{
+		{DefaultReplyTo: &badReplyTo},
+		{DefaultFromName: &badName},
+		{AllowedSenderPrefixes: &badPrefixes},
+	} {
+		settings := &models.DomainSettings{}
+		assert.Error(t, applyDomainSettings(settings, "acme.com", req))
+	}
+}
+
+func TestSenderAllowed(t *testing.T) {
+	settings := &models.DomainSettings{}
+	assert.True(t, senderAllowed(settings, "anyone@acme.com"))
+
+	settings.AllowedSenderPrefixes = []string{"billing", "noreply"}
+	assert.True(t, senderAllowed(settings, "Billing-EU@acme.com"))
+	assert.True(t, senderAllowed(settings, "noreply@acme.com"))
+	assert.False(t, senderAllowed(settings, "ceo@acme.com"))
+}
+
+func TestApplySettingsToEmail(t *testing.T) {
+	name := "Acme"
+	replyTo := "help@acme.com"
+	settings := &models.DomainSettings{DefaultFromName: &name, DefaultReplyTo: &replyTo, RequireTLS: true}
+
+	msg := &email.Email{FromEmail: "billing@acme.com"}
+	applySettingsToEmail(settings, msg)
+	assert.Equal(t, "Acme", msg.FromName)
+	assert.Equal(t, "help@acme.com", msg.ReplyTo)
+	assert.True(t, msg.RequireTLS)
+
+	// The email's own values win
+	msg = &email.Email{FromName: "Billing", Headers: map[string]string{"reply-to": "billing@acme.com"}}
+	applySettingsToEmail(settings, msg)
+	assert.Equal(t, "Billing", msg.FromName)
+	assert.Empty(t, msg.ReplyTo)
+}
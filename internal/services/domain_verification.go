@@ -1,6 +1,10 @@
 package services
 
 import (
+	"fmt"
+	"log"
+	"strings"
+
 	"github.com/maylng/backend/internal/models"
 )
 
@@ -20,49 +24,175 @@ type DomainVerificationProvider interface {
 
 	// RetryVerification retries the verification process
 	RetryVerification(customDomain *models.CustomDomain) error
+
+	// Capabilities describes what the provider supports beyond verification
+	Capabilities() DomainVerificationCapabilities
 }
 
-// DomainVerificationService is a wrapper that uses the appropriate provider
+// DomainVerificationCapabilities describes the optional features of a verification provider
+type DomainVerificationCapabilities struct {
+	SettingsUpdate bool   `json:"settings_update"` // Domain settings can be changed at the provider
+	Tracking       bool   `json:"tracking"`        // The provider tracks opens and clicks for the domain
+	DKIMSigning    bool   `json:"dkim_signing"`    // Mail is sent raw, so self-hosted DKIM keys can sign it
+	Region         string `json:"region,omitempty"`
+}
+
+// DomainVerificationProviderInfo describes a configured verification provider
+type DomainVerificationProviderInfo struct {
+	Name         string                         `json:"name"`
+	Default      bool                           `json:"default"`
+	Capabilities DomainVerificationCapabilities `json:"capabilities"`
+}
+
+// domainRemover is implemented by providers that delete a domain by the ID they
+// assigned it rather than by name
+type domainRemover interface {
+	DeleteDomainByID(customDomain *models.CustomDomain) error
+}
+
+// DomainVerificationService is a registry of the configured verification providers,
+// keyed by name. It dispatches each domain to the provider it was created with, so a
+// new verification backend only needs registering.
 type DomainVerificationService struct {
-	provider            DomainVerificationProvider
 	customDomainService *CustomDomainService
+	providers           map[string]DomainVerificationProvider
+	names               []string
+	defaultProvider     string
 }
 
-// NewDomainVerificationService creates a new domain verification service
-func NewDomainVerificationService(provider DomainVerificationProvider, customDomainService *CustomDomainService) *DomainVerificationService {
-	return &DomainVerificationService{
-		provider:            provider,
+// NewDomainVerificationService creates a registry of the given providers. New domains
+// use defaultProvider, or the first provider when that one isn't configured.
+func NewDomainVerificationService(customDomainService *CustomDomainService, defaultProvider string, providers ...DomainVerificationProvider) *DomainVerificationService {
+	s := &DomainVerificationService{
 		customDomainService: customDomainService,
+		providers:           make(map[string]DomainVerificationProvider, len(providers)),
+		defaultProvider:     defaultProvider,
+	}
+	for _, provider := range providers {
+		s.Register(provider)
 	}
+	return s
 }
 
-// InitiateDomainVerification starts the domain verification process
-func (s *DomainVerificationService) InitiateDomainVerification(customDomain *models.CustomDomain) error {
-	// Set the provider type in metadata
-	if customDomain.Metadata == nil {
-		customDomain.Metadata = make(map[string]interface{})
+// Register adds a provider, replacing one registered under the same name
+func (s *DomainVerificationService) Register(provider DomainVerificationProvider) {
+	name := provider.GetProviderType()
+	if _, exists := s.providers[name]; !exists {
+		s.names = append(s.names, name)
 	}
-	customDomain.Metadata["verification_provider"] = s.provider.GetProviderType()
+	s.providers[name] = provider
+}
 
-	return s.provider.InitiateDomainVerification(customDomain)
+// Provider returns the provider registered under name
+func (s *DomainVerificationService) Provider(name string) (DomainVerificationProvider, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("verification provider '%s' is not available", name)
+	}
+	return provider, nil
 }
 
-// CheckVerificationStatus checks the current verification status
-func (s *DomainVerificationService) CheckVerificationStatus(customDomain *models.CustomDomain) error {
-	return s.provider.CheckVerificationStatus(customDomain)
+// DefaultProvider returns the name of the provider new domains use
+func (s *DomainVerificationService) DefaultProvider() string {
+	if _, ok := s.providers[s.defaultProvider]; ok || len(s.names) == 0 {
+		return s.defaultProvider
+	}
+	return s.names[0]
+}
+
+// Providers lists the configured providers in the order they were registered
+func (s *DomainVerificationService) Providers() []DomainVerificationProviderInfo {
+	defaultProvider := s.DefaultProvider()
+	infos := make([]DomainVerificationProviderInfo, 0, len(s.names))
+	for _, name := range s.names {
+		infos = append(infos, DomainVerificationProviderInfo{
+			Name:         name,
+			Default:      name == defaultProvider,
+			Capabilities: s.providers[name].Capabilities(),
+		})
+	}
+	return infos
+}
+
+// InitiateDomainVerification starts verification with the domain's provider
+func (s *DomainVerificationService) InitiateDomainVerification(customDomain *models.CustomDomain) error {
+	provider, err := s.Provider(customDomain.VerificationProvider)
+	if err != nil {
+		return err
+	}
+	return provider.InitiateDomainVerification(customDomain)
 }
 
-// DeleteDomainIdentity removes the domain from the provider
-func (s *DomainVerificationService) DeleteDomainIdentity(domain string) error {
-	return s.provider.DeleteDomainIdentity(domain)
+// CheckVerificationStatus refreshes the domain's status from its provider
+func (s *DomainVerificationService) CheckVerificationStatus(customDomain *models.CustomDomain) error {
+	provider, err := s.Provider(customDomain.VerificationProvider)
+	if err != nil {
+		return err
+	}
+	return provider.CheckVerificationStatus(customDomain)
 }
 
-// RetryVerification retries the verification process
-func (s *DomainVerificationService) RetryVerification(customDomain *models.CustomDomain) error {
-	return s.provider.RetryVerification(customDomain)
+// DeleteDomain removes the domain from its provider
+func (s *DomainVerificationService) DeleteDomain(customDomain *models.CustomDomain) error {
+	provider, err := s.Provider(customDomain.VerificationProvider)
+	if err != nil {
+		return err
+	}
+	if remover, ok := provider.(domainRemover); ok {
+		return remover.DeleteDomainByID(customDomain)
+	}
+	return provider.DeleteDomainIdentity(customDomain.Domain)
 }
 
-// GetProviderType returns the provider type
-func (s *DomainVerificationService) GetProviderType() string {
-	return s.provider.GetProviderType()
+// MigrateDomain moves the domain to another verification provider. The new provider
+// issues its own DNS records and the domain is pending until it verifies them; the
+// domain is then removed from the old provider. Records published for other purposes
+// (self-hosted DKIM, MTA-STS) are kept. If the new provider rejects the domain,
+// nothing changes.
+//
+// Mail is sent through the provider matching the email provider for every domain, so a
+// verified domain can't leave that provider: its sends depend on the identity there.
+func (s *DomainVerificationService) MigrateDomain(customDomain *models.CustomDomain, target string) error {
+	if customDomain.VerificationProvider == target {
+		return fmt.Errorf("custom domain already uses verification provider '%s'", target)
+	}
+	if customDomain.VerificationProvider == s.defaultProvider && customDomain.IsVerified() {
+		return fmt.Errorf("custom domain sends through verification provider '%s' and can't be migrated away from it", s.defaultProvider)
+	}
+	provider, err := s.Provider(target)
+	if err != nil {
+		return err
+	}
+
+	previous := *customDomain
+	customDomain.VerificationProvider = target
+	customDomain.Status = models.CustomDomainStatusPending
+	customDomain.ProviderDomainID = nil
+	customDomain.ProviderVerificationStatus = nil
+	customDomain.SESVerificationStatus = nil
+	customDomain.SESDKIMVerificationStatus = nil
+	customDomain.DKIMTokens = map[string]interface{}{}
+	customDomain.VerifiedAt = nil
+	customDomain.FailureReason = nil
+	if err := provider.InitiateDomainVerification(customDomain); err != nil {
+		*customDomain = previous
+		return fmt.Errorf("failed to initiate verification with %s: %w", target, err)
+	}
+
+	// Best effort: a leftover identity at the old provider doesn't affect sending
+	if err := s.DeleteDomain(&previous); err != nil {
+		log.Printf("Failed to remove domain %s from %s: %v", previous.Domain, previous.VerificationProvider, err)
+	}
+
+	for key := range customDomain.Metadata {
+		if strings.HasPrefix(key, previous.VerificationProvider+"_") {
+			delete(customDomain.Metadata, key)
+		}
+	}
+	if err := s.customDomainService.UpdateCustomDomain(customDomain); err != nil {
+		return err
+	}
+
+	// The worker polls the new provider on its next run with a fresh timeout
+	return s.customDomainService.RestartVerification(customDomain)
 }
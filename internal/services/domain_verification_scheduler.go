@@ -0,0 +1,214 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/models"
+)
+
+const (
+	domainVerificationBaseBackoff = 2 * time.Minute
+	domainVerificationMaxBackoff  = time.Hour
+)
+
+// DomainVerificationScheduler checks pending custom domains with their verification
+// provider, backing off per domain between checks. Domains that stay pending past
+// the timeout fail. Every verification outcome is recorded as a domain.verified or
+// domain.failed audit event and emailed to the account's owners and admins.
+type DomainVerificationScheduler struct {
+	db                   *sql.DB
+	customDomainService  *CustomDomainService
+	dnsValidationService *DNSValidationService
+	notifier             *domainNotifier
+	timeout              time.Duration
+	verificationService  *DomainVerificationService
+}
+
+// NewDomainVerificationScheduler creates a scheduler for the registered providers. Domains
+// whose verification provider is not configured are left alone.
+func NewDomainVerificationScheduler(db *sql.DB, customDomainService *CustomDomainService, dnsValidationService *DNSValidationService, auditService *AuditService, emailService *email.Service, fromEmail string, timeout time.Duration, verificationService *DomainVerificationService) *DomainVerificationScheduler {
+	return &DomainVerificationScheduler{
+		db:                   db,
+		customDomainService:  customDomainService,
+		dnsValidationService: dnsValidationService,
+		notifier:             newDomainNotifier(db, auditService, emailService, fromEmail),
+		timeout:              timeout,
+		verificationService:  verificationService,
+	}
+}
+
+// RunBatch times out stale domains and then checks up to limit domains that are due.
+// It returns the number of domains checked.
+func (s *DomainVerificationScheduler) RunBatch(limit int) (int, error) {
+	if err := s.expireStaleDomains(); err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, verification_attempts FROM custom_domains
+		WHERE status = 'pending'
+		AND (next_verification_at IS NULL OR next_verification_at <= $1)
+		ORDER BY next_verification_at ASC NULLS FIRST
+		LIMIT $2
+	`, time.Now(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending domains: %w", err)
+	}
+
+	type dueDomain struct {
+		id       uuid.UUID
+		attempts int
+	}
+	var due []dueDomain
+	for rows.Next() {
+		var d dueDomain
+		if err := rows.Scan(&d.id, &d.attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan pending domain: %w", err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query pending domains: %w", err)
+	}
+
+	checked := 0
+	for _, d := range due {
+		customDomain, err := s.customDomainService.GetCustomDomainByID(d.id)
+		if err != nil {
+			log.Printf("Failed to get domain %s: %v", d.id, err)
+			continue
+		}
+
+		provider, err := s.verificationService.Provider(customDomain.VerificationProvider)
+		if err != nil {
+			continue
+		}
+
+		if err := s.checkDomain(provider, customDomain, d.attempts); err != nil {
+			log.Printf("Failed to check verification for domain %s: %v", customDomain.Domain, err)
+		}
+		checked++
+	}
+
+	return checked, nil
+}
+
+func (s *DomainVerificationScheduler) checkDomain(provider DomainVerificationProvider, customDomain *models.CustomDomain, attempts int) error {
+	// The provider can't see records that haven't propagated yet, so don't ask it
+	if s.dnsValidationService != nil {
+		dnsStatus, _ := s.dnsValidationService.ValidateDomainDNS(customDomain)
+		if dnsStatus == nil || !dnsStatus.AllRecordsPresent {
+			message, _ := s.dnsValidationService.GetDNSPropagationStatus(customDomain)
+			if message != "" {
+				customDomain.FailureReason = &message
+				if err := s.customDomainService.UpdateCustomDomain(customDomain); err != nil {
+					return err
+				}
+			}
+			return s.scheduleNextCheck(customDomain.ID, attempts)
+		}
+	}
+
+	if err := provider.CheckVerificationStatus(customDomain); err != nil {
+		if scheduleErr := s.scheduleNextCheck(customDomain.ID, attempts); scheduleErr != nil {
+			log.Printf("Failed to reschedule domain %s: %v", customDomain.Domain, scheduleErr)
+		}
+		return err
+	}
+
+	switch customDomain.Status {
+	case models.CustomDomainStatusVerified:
+		if _, err := s.db.Exec(`UPDATE custom_domains SET next_verification_at = NULL WHERE id = $1`, customDomain.ID); err != nil {
+			return fmt.Errorf("failed to clear verification schedule: %w", err)
+		}
+		s.notifier.notify(customDomain, "domain.verified")
+	case models.CustomDomainStatusFailed:
+		s.notifier.notify(customDomain, "domain.failed")
+	default:
+		return s.scheduleNextCheck(customDomain.ID, attempts)
+	}
+	return nil
+}
+
+// expireStaleDomains fails pending domains whose verification has run past the timeout
+func (s *DomainVerificationScheduler) expireStaleDomains() error {
+	if s.timeout <= 0 {
+		return nil
+	}
+
+	reason := fmt.Sprintf("Domain was not verified within %s; check the DNS records and verify again", formatVerificationTimeout(s.timeout))
+	rows, err := s.db.Query(`
+		UPDATE custom_domains SET status = 'failed', failure_reason = $1, next_verification_at = NULL
+		WHERE status = 'pending' AND verification_started_at <= $2
+		RETURNING id
+	`, reason, time.Now().Add(-s.timeout))
+	if err != nil {
+		return fmt.Errorf("failed to expire pending domains: %w", err)
+	}
+
+	var expired []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan expired domain: %w", err)
+		}
+		expired = append(expired, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to expire pending domains: %w", err)
+	}
+
+	for _, id := range expired {
+		customDomain, err := s.customDomainService.GetCustomDomainByID(id)
+		if err != nil {
+			log.Printf("Failed to get expired domain %s: %v", id, err)
+			continue
+		}
+		s.notifier.notify(customDomain, "domain.failed")
+	}
+	return nil
+}
+
+func (s *DomainVerificationScheduler) scheduleNextCheck(domainID uuid.UUID, attempts int) error {
+	next := time.Now().Add(domainVerificationBackoff(attempts))
+	_, err := s.db.Exec(`
+		UPDATE custom_domains SET verification_attempts = verification_attempts + 1, next_verification_at = $1
+		WHERE id = $2
+	`, next, domainID)
+	if err != nil {
+		return fmt.Errorf("failed to schedule domain verification: %w", err)
+	}
+	return nil
+}
+
+// domainVerificationBackoff is the wait before the next check after the given number
+// of unsuccessful attempts: two minutes, doubling up to an hour.
+func domainVerificationBackoff(attempts int) time.Duration {
+	backoff := domainVerificationBaseBackoff
+	for i := 0; i < attempts && backoff < domainVerificationMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > domainVerificationMaxBackoff {
+		backoff = domainVerificationMaxBackoff
+	}
+	return backoff
+}
+
+func formatVerificationTimeout(timeout time.Duration) string {
+	if days := int(timeout / (24 * time.Hour)); days > 0 && timeout%(24*time.Hour) == 0 {
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	return timeout.String()
+}
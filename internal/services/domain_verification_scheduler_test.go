@@ -0,0 +1,16 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainVerificationBackoff(t *testing.T) {
+	assert.Equal(t, 2*time.Minute, domainVerificationBackoff(0))
+	assert.Equal(t, 4*time.Minute, domainVerificationBackoff(1))
+	assert.Equal(t, 32*time.Minute, domainVerificationBackoff(4))
+	assert.Equal(t, time.Hour, domainVerificationBackoff(5))
+	assert.Equal(t, time.Hour, domainVerificationBackoff(100))
+}
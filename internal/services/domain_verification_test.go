@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVerificationProvider struct {
+	name        string
+	initiateErr error
+	deleted     []string
+	dkimSigning bool
+}
+
+func (p *fakeVerificationProvider) InitiateDomainVerification(customDomain *models.CustomDomain) error {
+	return p.initiateErr
+}
+
+func (p *fakeVerificationProvider) CheckVerificationStatus(customDomain *models.CustomDomain) error {
+	return nil
+}
+
+func (p *fakeVerificationProvider) DeleteDomainIdentity(domain string) error {
+	p.deleted = append(p.deleted, domain)
+	return nil
+}
+
+func (p *fakeVerificationProvider) GetProviderType() string {
+	return p.name
+}
+
+func (p *fakeVerificationProvider) RetryVerification(customDomain *models.CustomDomain) error {
+	return nil
+}
+
+func (p *fakeVerificationProvider) Capabilities() DomainVerificationCapabilities {
+	return DomainVerificationCapabilities{DKIMSigning: p.dkimSigning, Region: "eu-west-1"}
+}
+
+// fakeIDVerificationProvider deletes domains by the ID it assigned them
+type fakeIDVerificationProvider struct {
+	fakeVerificationProvider
+}
+
+func (p *fakeIDVerificationProvider) DeleteDomainByID(customDomain *models.CustomDomain) error {
+	p.deleted = append(p.deleted, *customDomain.ProviderDomainID)
+	return nil
+}
+
+func TestDomainVerificationService_Registry(t *testing.T) {
+	ses := &fakeVerificationProvider{name: "ses"}
+	resend := &fakeIDVerificationProvider{fakeVerificationProvider{name: "resend"}}
+
+	s := NewDomainVerificationService(nil, "resend", ses, resend)
+	assert.Equal(t, "resend", s.DefaultProvider())
+
+	providers := s.Providers()
+	require.Len(t, providers, 2)
+	assert.Equal(t, "ses", providers[0].Name)
+	assert.False(t, providers[0].Default)
+	assert.True(t, providers[1].Default)
+	assert.Equal(t, "eu-west-1", providers[1].Capabilities.Region)
+
+	_, err := s.Provider("postmark")
+	assert.EqualError(t, err, "verification provider 'postmark' is not available")
+
+	// An unconfigured default falls back to the first provider
+	assert.Equal(t, "ses", NewDomainVerificationService(nil, "postmark", ses).DefaultProvider())
+
+	id := "re_123"
+	require.NoError(t, s.DeleteDomain(&models.CustomDomain{Domain: "a.example.com", VerificationProvider: "ses"}))
+	require.NoError(t, s.DeleteDomain(&models.CustomDomain{Domain: "b.example.com", VerificationProvider: "resend", ProviderDomainID: &id}))
+	assert.Equal(t, []string{"a.example.com"}, ses.deleted)
+	assert.Equal(t, []string{"re_123"}, resend.deleted)
+}
+
+func TestDomainVerificationService_MigrateDomain(t *testing.T) {
+	ses := &fakeVerificationProvider{name: "ses"}
+	resend := &fakeVerificationProvider{name: "resend", initiateErr: errors.New("domain is invalid")}
+	s := NewDomainVerificationService(nil, "resend", ses, resend)
+
+	id := "identity"
+	customDomain := &models.CustomDomain{
+		Domain:               "example.com",
+		Status:               models.CustomDomainStatusVerified,
+		VerificationProvider: "ses",
+		ProviderDomainID:     &id,
+	}
+
+	err := s.MigrateDomain(customDomain, "ses")
+	assert.EqualError(t, err, "custom domain already uses verification provider 'ses'")
+
+	err = s.MigrateDomain(customDomain, "postmark")
+	assert.EqualError(t, err, "verification provider 'postmark' is not available")
+
+	// A provider that rejects the domain leaves it as it was
+	err = s.MigrateDomain(customDomain, "resend")
+	assert.EqualError(t, err, "failed to initiate verification with resend: domain is invalid")
+	assert.Equal(t, "ses", customDomain.VerificationProvider)
+	assert.Equal(t, models.CustomDomainStatusVerified, customDomain.Status)
+	assert.Equal(t, &id, customDomain.ProviderDomainID)
+	assert.Empty(t, ses.deleted)
+
+	// Sends depend on the identity at the provider mail goes through
+	sending := NewDomainVerificationService(nil, "ses", ses, resend)
+	err = sending.MigrateDomain(customDomain, "resend")
+	assert.EqualError(t, err, "custom domain sends through verification provider 'ses' and can't be migrated away from it")
+	assert.Equal(t, "ses", customDomain.VerificationProvider)
+}
@@ -2,8 +2,11 @@ package services
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,14 +15,22 @@ import (
 )
 
 type EmailService struct {
-	db           *sql.DB
-	emailService *email.Service
+	db                    *sql.DB
+	emailService          *email.Service
+	dkimService           *DKIMService
+	domainSettingsService *DomainSettingsService
+	// blockDegradedDomains refuses sends from degraded custom domains instead of
+	// sending with a warning
+	blockDegradedDomains bool
 }
 
-func NewEmailService(db *sql.DB, emailService *email.Service) *EmailService {
+func NewEmailService(db *sql.DB, emailService *email.Service, dkimService *DKIMService, domainSettingsService *DomainSettingsService, blockDegradedDomains bool) *EmailService {
 	return &EmailService{
-		db:           db,
-		emailService: emailService,
+		db:                    db,
+		emailService:          emailService,
+		dkimService:           dkimService,
+		domainSettingsService: domainSettingsService,
+		blockDegradedDomains:  blockDegradedDomains,
 	}
 }
 
@@ -40,24 +51,30 @@ func (s *EmailService) SendEmail(accountID uuid.UUID, req *models.SendEmailReque
 	}
 
 	// If the email address uses a custom domain, verify that the domain is verified
+	var warnings []string
 	if customDomainID != nil {
-		var domainStatus string
-		err = s.db.QueryRow(
-			"SELECT status FROM custom_domains WHERE id = $1 AND account_id = $2",
-			customDomainID, accountID,
-		).Scan(&domainStatus)
-
+		warnings, err = s.checkCustomDomain(accountID, *customDomainID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to validate custom domain: %w", err)
+			return nil, err
 		}
 
-		if domainStatus != string(models.CustomDomainStatusVerified) {
-			return nil, fmt.Errorf("custom domain must be verified before sending emails (current status: %s)", domainStatus)
+		if s.domainSettingsService != nil {
+			if err := s.domainSettingsService.CheckSender(*customDomainID, fromEmailAddress); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	// Check rate limits (simplified for MVP)
-	// TODO: Implement proper rate limiting with Redis
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Enforce the account's plan quotas
+	if err := s.checkSendLimits(tx, accountID, req.ScheduledAt != nil && req.ScheduledAt.After(time.Now())); err != nil {
+		return nil, err
+	}
 
 	// Convert recipients to JSON
 	toRecipientsJSON, _ := json.Marshal(req.ToRecipients)
@@ -80,7 +97,7 @@ func (s *EmailService) SendEmail(accountID uuid.UUID, req *models.SendEmailReque
 		status = models.EmailStatusScheduled
 	}
 
-	err = s.db.QueryRow(
+	err = tx.QueryRow(
 		query,
 		accountID,
 		req.FromEmailID,
@@ -102,9 +119,17 @@ func (s *EmailService) SendEmail(accountID uuid.UUID, req *models.SendEmailReque
 		return nil, fmt.Errorf("failed to create email record: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit email record: %w", err)
+	}
+
+	if err := recordUsage(s.db, accountID, models.UsageMetricStorageBytes, storedEmailSize(req, toRecipientsJSON, ccRecipientsJSON, bccRecipientsJSON), time.Now()); err != nil {
+		log.Printf("Failed to record storage usage for email %s: %v", sentEmail.ID, err)
+	}
+
 	// If not scheduled, send immediately
 	if status == models.EmailStatusQueued {
-		go s.sendEmailAsync(sentEmail.ID, fromEmailAddress, req)
+		go s.sendEmailAsync(accountID, sentEmail.ID, fromEmailAddress, req)
 	}
 
 	return &models.EmailResponse{
@@ -119,36 +144,129 @@ func (s *EmailService) SendEmail(accountID uuid.UUID, req *models.SendEmailReque
 		ThreadID:      req.ThreadID,
 		ScheduledAt:   req.ScheduledAt,
 		Status:        status,
+		Warnings:      warnings,
 		CreatedAt:     sentEmail.CreatedAt,
 		UpdatedAt:     sentEmail.UpdatedAt,
 	}, nil
 }
 
-func (s *EmailService) GetEmails(accountID uuid.UUID, limit, offset int) ([]*models.EmailResponse, error) {
-	if limit <= 0 {
-		limit = 50
+// checkCustomDomain fails when the sender's custom domain may not send, and returns
+// warnings for sends that go out anyway
+func (s *EmailService) checkCustomDomain(accountID, customDomainID uuid.UUID) ([]string, error) {
+	var domainStatus string
+	err := s.db.QueryRow(
+		"SELECT status FROM custom_domains WHERE id = $1 AND account_id = $2",
+		customDomainID, accountID,
+	).Scan(&domainStatus)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate custom domain: %w", err)
+	}
+
+	switch models.CustomDomainStatus(domainStatus) {
+	case models.CustomDomainStatusVerified:
+		return nil, nil
+	case models.CustomDomainStatusDegraded:
+		if s.blockDegradedDomains {
+			return nil, fmt.Errorf("custom domain is degraded; fix the problems in its health check before sending emails")
+		}
+		return []string{"The custom domain is degraded, so this email may be rejected or filtered as spam; see the domain's health check"}, nil
+	default:
+		return nil, fmt.Errorf("custom domain must be verified before sending emails (current status: %s)", domainStatus)
+	}
+}
+
+// CheckQueuedSender checks the sender's custom domain again just before a queued or
+// scheduled email goes out, since the domain may have been degraded or lost its
+// verification after the email was accepted
+func (s *EmailService) CheckQueuedSender(accountID, fromEmailID uuid.UUID) error {
+	var customDomainID *uuid.UUID
+	err := s.db.QueryRow(
+		"SELECT custom_domain_id FROM email_addresses WHERE id = $1 AND account_id = $2",
+		fromEmailID, accountID,
+	).Scan(&customDomainID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("from email address not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to validate from email address: %w", err)
+	}
+
+	if customDomainID == nil {
+		return nil
 	}
-	if limit > 100 {
-		limit = 100
+	_, err = s.checkCustomDomain(accountID, *customDomainID)
+	return err
+}
+
+// checkSendLimits fails when the account has used up its hourly, daily or monthly sends.
+// Hours and days are rolling windows; months are calendar months. Failed sends don't count.
+// It locks the account row so concurrent sends are counted one after another; the caller
+// inserts the email in the same transaction.
+func (s *EmailService) checkSendLimits(tx *sql.Tx, accountID uuid.UUID, scheduled bool) error {
+	if _, err := tx.Exec("SELECT 1 FROM accounts WHERE id = $1 FOR UPDATE", accountID); err != nil {
+		return fmt.Errorf("failed to lock account: %w", err)
 	}
 
+	accountLimits, err := getAccountLimits(tx, accountID)
+	if err != nil {
+		return err
+	}
+	limits := accountLimits.Limits
+
+	if scheduled && !limits.HasFeature(models.FeatureScheduledSends) {
+		return fmt.Errorf("scheduled sends are not available on your plan")
+	}
+
+	var hourCount, dayCount, monthCount int
 	query := `
-		SELECT id, from_email_id, to_recipients, cc_recipients, bcc_recipients,
-			   subject, text_content, html_content, thread_id, scheduled_at, sent_at,
-			   status, provider_message_id, failure_reason, created_at, updated_at
-		FROM sent_emails 
-		WHERE account_id = $1 
-		ORDER BY created_at DESC 
-		LIMIT $2 OFFSET $3
+		SELECT COUNT(*) FILTER (WHERE created_at >= NOW() - INTERVAL '1 hour'),
+		       COUNT(*) FILTER (WHERE created_at >= NOW() - INTERVAL '1 day'),
+		       COUNT(*) FILTER (WHERE created_at >= date_trunc('month', NOW()))
+		FROM sent_emails
+		WHERE account_id = $1 AND status != 'failed'
+		  AND created_at >= LEAST(date_trunc('month', NOW()), NOW() - INTERVAL '1 day')
 	`
+	err = tx.QueryRow(query, accountID).Scan(&hourCount, &dayCount, &monthCount)
+	if err != nil {
+		return fmt.Errorf("failed to count sent emails: %w", err)
+	}
 
-	rows, err := s.db.Query(query, accountID, limit, offset)
+	switch {
+	case hourCount >= limits.EmailLimitPerHour:
+		return fmt.Errorf("hourly email limit reached (%d/%d)", hourCount, limits.EmailLimitPerHour)
+	case dayCount >= limits.EmailLimitPerDay:
+		return fmt.Errorf("daily email limit reached (%d/%d)", dayCount, limits.EmailLimitPerDay)
+	case monthCount >= limits.EmailLimitPerMonth:
+		return fmt.Errorf("monthly email limit reached (%d/%d)", monthCount, limits.EmailLimitPerMonth)
+	}
+
+	return nil
+}
+
+func (s *EmailService) GetEmails(accountID uuid.UUID, filter *models.EmailFilter) (*models.EmailListResponse, error) {
+	if filter == nil {
+		filter = &models.EmailFilter{}
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	query, args, err := buildEmailListQuery(accountID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get emails: %w", err)
 	}
 	defer rows.Close()
 
-	var emails []*models.EmailResponse
+	emails := []*models.EmailResponse{}
 	for rows.Next() {
 		var email models.SentEmail
 		var toRecipientsJSON, ccRecipientsJSON, bccRecipientsJSON []byte
@@ -200,7 +318,131 @@ func (s *EmailService) GetEmails(accountID uuid.UUID, limit, offset int) ([]*mod
 		})
 	}
 
-	return emails, nil
+	// One extra row is fetched to find out whether another page exists
+	result := &models.EmailListResponse{Emails: emails}
+	if len(emails) > filter.Limit {
+		result.Emails = emails[:filter.Limit]
+		result.HasMore = true
+		last := result.Emails[len(result.Emails)-1]
+		result.NextCursor = encodeEmailCursor(last.CreatedAt, last.ID)
+	}
+
+	return result, nil
+}
+
+// emailCursor is the decoded form of the opaque pagination cursor
+type emailCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// encodeEmailCursor builds an opaque cursor pointing after the given email
+func encodeEmailCursor(createdAt time.Time, id uuid.UUID) string {
+	data, _ := json.Marshal(emailCursor{CreatedAt: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeEmailCursor parses a cursor produced by encodeEmailCursor
+func decodeEmailCursor(cursor string) (*emailCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var c emailCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &c, nil
+}
+
+// buildEmailListQuery builds the filtered, keyset-paginated sent_emails query
+func buildEmailListQuery(accountID uuid.UUID, filter *models.EmailFilter) (string, []interface{}, error) {
+	conditions := []string{"account_id = $1"}
+	args := []interface{}{accountID}
+	argIndex := 2
+
+	if filter.Status != nil {
+		conditions = append(conditions, fmt.Sprintf("status = $%d", argIndex))
+		args = append(args, string(*filter.Status))
+		argIndex++
+	}
+
+	if filter.FromEmailID != nil {
+		conditions = append(conditions, fmt.Sprintf("from_email_id = $%d", argIndex))
+		args = append(args, *filter.FromEmailID)
+		argIndex++
+	}
+
+	if filter.Recipient != "" {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(
+				COALESCE(to_recipients, '[]'::jsonb) || COALESCE(cc_recipients, '[]'::jsonb) || COALESCE(bcc_recipients, '[]'::jsonb)
+			) AS r WHERE lower(r) = lower($%d)
+		)`, argIndex))
+		args = append(args, filter.Recipient)
+		argIndex++
+	}
+
+	if filter.ThreadID != nil {
+		conditions = append(conditions, fmt.Sprintf("thread_id = $%d", argIndex))
+		args = append(args, *filter.ThreadID)
+		argIndex++
+	}
+
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argIndex))
+		args = append(args, *filter.CreatedAfter)
+		argIndex++
+	}
+
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", argIndex))
+		args = append(args, *filter.CreatedBefore)
+		argIndex++
+	}
+
+	if len(filter.Metadata) > 0 {
+		metadataJSON, err := json.Marshal(filter.Metadata)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid metadata filter: %w", err)
+		}
+		conditions = append(conditions, fmt.Sprintf("metadata @> $%d::jsonb", argIndex))
+		args = append(args, string(metadataJSON))
+		argIndex++
+	}
+
+	if filter.Query != "" {
+		conditions = append(conditions, fmt.Sprintf("search_vector @@ websearch_to_tsquery('english', $%d)", argIndex))
+		args = append(args, filter.Query)
+		argIndex++
+	}
+
+	offset := filter.Offset
+	if filter.Cursor != "" {
+		cursor, err := decodeEmailCursor(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", argIndex, argIndex+1))
+		args = append(args, cursor.CreatedAt, cursor.ID)
+		argIndex += 2
+		offset = 0
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, from_email_id, to_recipients, cc_recipients, bcc_recipients,
+			   subject, text_content, html_content, thread_id, scheduled_at, sent_at,
+			   status, provider_message_id, failure_reason, created_at, updated_at
+		FROM sent_emails
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, strings.Join(conditions, " AND "), argIndex, argIndex+1)
+	args = append(args, filter.Limit+1, offset)
+
+	return query, args, nil
 }
 
 func (s *EmailService) GetEmail(accountID, emailID uuid.UUID) (*models.EmailResponse, error) {
@@ -266,7 +508,28 @@ func (s *EmailService) GetEmail(accountID, emailID uuid.UUID) (*models.EmailResp
 	}, nil
 }
 
-func (s *EmailService) sendEmailAsync(emailID uuid.UUID, fromEmailAddress string, req *models.SendEmailRequest) {
+// storedEmailSize is the number of bytes of message content kept for an email
+func storedEmailSize(req *models.SendEmailRequest, recipientsJSON ...[]byte) int64 {
+	size := int64(len(req.Subject))
+	if req.TextContent != nil {
+		size += int64(len(*req.TextContent))
+	}
+	if req.HTMLContent != nil {
+		size += int64(len(*req.HTMLContent))
+	}
+	for _, r := range recipientsJSON {
+		size += int64(len(r))
+	}
+	for _, m := range []models.Metadata{req.Attachments, req.Headers} {
+		if len(m) > 0 {
+			encoded, _ := json.Marshal(m)
+			size += int64(len(encoded))
+		}
+	}
+	return size
+}
+
+func (s *EmailService) sendEmailAsync(accountID, emailID uuid.UUID, fromEmailAddress string, req *models.SendEmailRequest) {
 	// Convert to email format
 	emailToSend := &email.Email{
 		FromEmail:     fromEmailAddress,
@@ -293,8 +556,26 @@ func (s *EmailService) sendEmailAsync(emailID uuid.UUID, fromEmailAddress string
 		}
 	}
 
+	// Sign with the sender domain's own DKIM key when it has one
+	if s.dkimService != nil {
+		signer, err := s.dkimService.SignerForAddress(accountID, fromEmailAddress)
+		if err != nil {
+			log.Printf("Failed to load DKIM key for %s: %v", fromEmailAddress, err)
+		}
+		emailToSend.Signer = signer
+	}
+
+	// Apply the sender domain's settings; an email that can't honor them isn't sent
+	var result *email.SendResult
+	var err error
+	if s.domainSettingsService != nil {
+		err = s.domainSettingsService.ApplyToEmail(emailID, emailToSend)
+	}
+
 	// Send email
-	result, err := s.emailService.SendEmail(emailToSend)
+	if err == nil {
+		result, err = s.emailService.SendEmail(emailToSend)
+	}
 
 	// Update email status
 	var status models.EmailStatus
@@ -323,4 +604,10 @@ func (s *EmailService) sendEmailAsync(emailID uuid.UUID, fromEmailAddress string
 	if updateErr != nil {
 		fmt.Printf("Failed to update email status: %v\n", updateErr)
 	}
+
+	if status == models.EmailStatusSent {
+		if err := recordUsage(s.db, accountID, models.UsageMetricEmailsSent, 1, time.Now()); err != nil {
+			log.Printf("Failed to record send usage for email %s: %v", emailID, err)
+		}
+	}
 }
@@ -3,6 +3,7 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -83,6 +84,10 @@ func (s *EmailAddressService) CreateEmailAddress(accountID uuid.UUID, req *model
 		return nil, fmt.Errorf("failed to create email address: %w", err)
 	}
 
+	if err := recordUsage(s.db, accountID, models.UsageMetricAddressesCreated, 1, time.Now()); err != nil {
+		log.Printf("Failed to record address usage for %s: %v", emailAddr.ID, err)
+	}
+
 	return &models.EmailAddressResponse{
 		ID:         emailAddr.ID,
 		Email:      email,
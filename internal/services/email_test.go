@@ -0,0 +1,70 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 7, 6, 10, 0, 0, 123456000, time.UTC)
+	id := uuid.New()
+
+	cursor := encodeEmailCursor(createdAt, id)
+	decoded, err := decodeEmailCursor(cursor)
+
+	assert.NoError(t, err)
+	assert.Equal(t, id, decoded.ID)
+	assert.True(t, createdAt.Equal(decoded.CreatedAt))
+}
+
+func TestDecodeEmailCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{"not base64!", "e30", "bm9wZQ"} {
+		_, err := decodeEmailCursor(cursor)
+		assert.Error(t, err, cursor)
+	}
+}
+
+func TestBuildEmailListQuery(t *testing.T) {
+	accountID := uuid.New()
+	status := models.EmailStatusSent
+	after := time.Now().Add(-24 * time.Hour)
+
+	filter := &models.EmailFilter{
+		Status:       &status,
+		Recipient:    "someone@example.com",
+		CreatedAfter: &after,
+		Metadata:     map[string]string{"campaign": "launch"},
+		Query:        "invoice",
+		Cursor:       encodeEmailCursor(time.Now(), uuid.New()),
+		Limit:        25,
+		Offset:       10,
+	}
+
+	query, args, err := buildEmailListQuery(accountID, filter)
+	assert.NoError(t, err)
+
+	assert.Contains(t, query, "status = $2")
+	assert.Contains(t, query, "lower(r) = lower($3)")
+	assert.Contains(t, query, "created_at >= $4")
+	assert.Contains(t, query, "metadata @> $5::jsonb")
+	assert.Contains(t, query, "websearch_to_tsquery('english', $6)")
+	assert.Contains(t, query, "(created_at, id) < ($7, $8)")
+	assert.Contains(t, query, "LIMIT $9 OFFSET $10")
+	assert.True(t, strings.Contains(query, "ORDER BY created_at DESC, id DESC"))
+
+	assert.Len(t, args, 10)
+	assert.Equal(t, `{"campaign":"launch"}`, args[4])
+	// Fetch one extra row to detect another page; cursor resets the offset
+	assert.Equal(t, 26, args[8])
+	assert.Equal(t, 0, args[9])
+}
+
+func TestBuildEmailListQueryRejectsBadCursor(t *testing.T) {
+	_, _, err := buildEmailListQuery(uuid.New(), &models.EmailFilter{Cursor: "garbage", Limit: 10})
+	assert.EqualError(t, err, "invalid cursor")
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+// Tracking event types; the first letter marks the tokens issued for them
+const (
+	trackingEventOpen  = "open"
+	trackingEventClick = "click"
+)
+
+// trackingSignatureSize is the number of HMAC bytes kept in a tracking token
+const trackingSignatureSize = 16
+
+// trackedLinkPattern matches the absolute http(s) links of an HTML body
+var trackedLinkPattern = regexp.MustCompile(`(?i)(<a\s[^>]*?href\s*=\s*)(?:"(https?://[^"]+)"|'(https?://[^']+)')`)
+
+// bodyClosePattern matches the closing body tag the open pixel goes before
+var bodyClosePattern = regexp.MustCompile(`(?i)</body\s*>`)
+
+// EmailTrackingService tracks opens and clicks of emails sent from domains with
+// tracking on. Links are rewritten to redirect through the API and a pixel is added
+// for opens. Tokens are signed, so they can't be forged to redirect elsewhere.
+type EmailTrackingService struct {
+	db      *sql.DB
+	baseURL string
+	secret  []byte
+}
+
+func NewEmailTrackingService(db *sql.DB, baseURL, secret string) *EmailTrackingService {
+	return &EmailTrackingService{
+		db:      db,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  []byte(secret),
+	}
+}
+
+// Instrument returns the HTML body of an email with its links rewritten for click
+// tracking and an open pixel added, as enabled
+func (s *EmailTrackingService) Instrument(emailID uuid.UUID, body string, opens, clicks bool) string {
+	if clicks {
+		body = trackedLinkPattern.ReplaceAllStringFunc(body, func(link string) string {
+			match := trackedLinkPattern.FindStringSubmatch(link)
+			target := match[2]
+			if target == "" {
+				target = match[3]
+			}
+			return fmt.Sprintf(`%s"%s/t/c/%s"`, match[1], s.baseURL, s.token(trackingEventClick, emailID, html.UnescapeString(target)))
+		})
+	}
+
+	if opens {
+		pixel := fmt.Sprintf(`<img src="%s/t/o/%s" width="1" height="1" alt="" style="display:none">`, s.baseURL, s.token(trackingEventOpen, emailID, ""))
+		if loc := bodyClosePattern.FindAllStringIndex(body, -1); len(loc) > 0 {
+			last := loc[len(loc)-1][0]
+			body = body[:last] + pixel + body[last:]
+		} else {
+			body += pixel
+		}
+	}
+	return body
+}
+
+// RecordOpen records an open of the email the token was issued for
+func (s *EmailTrackingService) RecordOpen(token, userAgent string) error {
+	emailID, _, err := s.parseToken(trackingEventOpen, token)
+	if err != nil {
+		return err
+	}
+	return s.recordEvent(emailID, trackingEventOpen, nil, userAgent)
+}
+
+// RecordClick records a click of the tracked link and returns where it goes
+func (s *EmailTrackingService) RecordClick(token, userAgent string) (string, error) {
+	emailID, target, err := s.parseToken(trackingEventClick, token)
+	if err != nil {
+		return "", err
+	}
+	if target == "" {
+		return "", fmt.Errorf("invalid tracking token")
+	}
+	return target, s.recordEvent(emailID, trackingEventClick, &target, userAgent)
+}
+
+// GetTracking summarizes the opens and clicks of an email of the account
+func (s *EmailTrackingService) GetTracking(accountID, emailID uuid.UUID) (*models.EmailTracking, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sent_emails WHERE id = $1 AND account_id = $2)`, emailID, accountID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("email not found")
+	}
+
+	tracking := &models.EmailTracking{EmailID: emailID, Links: []models.EmailTrackingLink{}}
+	err = s.db.QueryRow(`
+		SELECT COUNT(*), MIN(created_at), MAX(created_at)
+		FROM email_tracking_events WHERE sent_email_id = $1 AND event_type = 'open'
+	`, emailID).Scan(&tracking.Opens, &tracking.FirstOpenedAt, &tracking.LastOpenedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count opens: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT url, COUNT(*) FROM email_tracking_events
+		WHERE sent_email_id = $1 AND event_type = 'click'
+		GROUP BY url ORDER BY COUNT(*) DESC, url
+	`, emailID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count clicks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link models.EmailTrackingLink
+		if err := rows.Scan(&link.URL, &link.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan clicks: %w", err)
+		}
+		tracking.Clicks += link.Clicks
+		tracking.Links = append(tracking.Links, link)
+	}
+	return tracking, rows.Err()
+}
+
+func (s *EmailTrackingService) recordEvent(emailID uuid.UUID, eventType string, url *string, userAgent string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO email_tracking_events (sent_email_id, event_type, url, user_agent)
+		SELECT id, $2, $3, $4 FROM sent_emails WHERE id = $1
+	`, emailID, eventType, url, userAgent)
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", eventType, err)
+	}
+	return nil
+}
+
+// token signs the event type, the email ID and, for links, the link's target
+func (s *EmailTrackingService) token(eventType string, emailID uuid.UUID, target string) string {
+	payload := append([]byte{eventType[0]}, emailID[:]...)
+	payload = append(payload, target...)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+func (s *EmailTrackingService) parseToken(eventType, token string) (uuid.UUID, string, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, "", fmt.Errorf("invalid tracking token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) < 1+len(uuid.Nil) || payload[0] != eventType[0] {
+		return uuid.Nil, "", fmt.Errorf("invalid tracking token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.sign(payload)) {
+		return uuid.Nil, "", fmt.Errorf("invalid tracking token")
+	}
+
+	var emailID uuid.UUID
+	copy(emailID[:], payload[1:])
+	return emailID, string(payload[1+len(emailID):]), nil
+}
+
+func (s *EmailTrackingService) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)[:trackingSignatureSize]
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailTrackingService_Instrument(t *testing.T) {
+	s := NewEmailTrackingService(nil, "https://api.mayl.ng/", "secret")
+	emailID := uuid.New()
+
+	body := s.Instrument(emailID, `<html><body><a href="https://acme.com/?a=1&amp;b=2">Go</a> <a href="mailto:x@acme.com">Mail</a></body></html>`, true, true)
+	assert.Contains(t, body, `href="mailto:x@acme.com"`)
+	assert.NotContains(t, body, "https://acme.com")
+	assert.True(t, strings.HasSuffix(body, `style="display:none"></body></html>`))
+
+	click := regexp.MustCompile(`/t/c/([^"]+)"`).FindStringSubmatch(body)
+	require.Len(t, click, 2)
+	id, target, err := s.parseToken(trackingEventClick, click[1])
+	require.NoError(t, err)
+	assert.Equal(t, emailID, id)
+	assert.Equal(t, "https://acme.com/?a=1&b=2", target)
+
+	open := regexp.MustCompile(`/t/o/([^"]+)"`).FindStringSubmatch(body)
+	require.Len(t, open, 2)
+	id, _, err = s.parseToken(trackingEventOpen, open[1])
+	require.NoError(t, err)
+	assert.Equal(t, emailID, id)
+
+	// An open token can't be used as a click, and other keys don't verify it
+	_, _, err = s.parseToken(trackingEventClick, open[1])
+	assert.Error(t, err)
+	_, _, err = NewEmailTrackingService(nil, "https://api.mayl.ng", "other").parseToken(trackingEventClick, click[1])
+	assert.Error(t, err)
+}
+
+func TestEmailTrackingService_InstrumentDisabled(t *testing.T) {
+	s := NewEmailTrackingService(nil, "https://api.mayl.ng", "secret")
+	body := `<a href="https://acme.com">Go</a>`
+	assert.Equal(t, body, s.Instrument(uuid.New(), body, false, false))
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+// MTA-STS policy lifetimes senders may cache a policy for. A policy that withdraws
+// an earlier one only needs to outlive the caches it replaces.
+const (
+	mtaSTSMaxAge     = 7 * 24 * 60 * 60
+	mtaSTSNoneMaxAge = 24 * 60 * 60
+)
+
+// mtaSTSHostPrefix is the label senders fetch a domain's policy from
+const mtaSTSHostPrefix = "mta-sts."
+
+// MTASTSService publishes MTA-STS policies (RFC 8461) for custom domains that receive
+// mail through Maylng, along with the TLS-RPT record (RFC 8460) that asks senders to
+// report TLS failures. Each domain's mta-sts host is a CNAME to policyHost, which
+// serves the policy for whichever domain the request's Host names.
+type MTASTSService struct {
+	db                  *sql.DB
+	customDomainService *CustomDomainService
+	policyHost          string
+	mxHosts             []string
+	tlsReportAddress    string
+}
+
+func NewMTASTSService(db *sql.DB, customDomainService *CustomDomainService, policyHost string, mxHosts []string, tlsReportAddress string) *MTASTSService {
+	return &MTASTSService{
+		db:                  db,
+		customDomainService: customDomainService,
+		policyHost:          policyHost,
+		mxHosts:             mxHosts,
+		tlsReportAddress:    tlsReportAddress,
+	}
+}
+
+// SetPolicy publishes the domain's policy in the given mode, or changes the mode of
+// the published one. Every change gets a new policy ID so senders refetch it.
+func (s *MTASTSService) SetPolicy(customDomain *models.CustomDomain, mode models.MTASTSMode) (*models.MTASTSPolicy, error) {
+	if !customDomain.IsVerified() {
+		return nil, fmt.Errorf("custom domain is not verified")
+	}
+	if len(s.mxHosts) == 0 {
+		return nil, fmt.Errorf("no inbound MX hosts are configured")
+	}
+
+	maxAge := mtaSTSMaxAge
+	if mode == models.MTASTSModeNone {
+		maxAge = mtaSTSNoneMaxAge
+	}
+
+	policy := &models.MTASTSPolicy{CustomDomainID: customDomain.ID}
+	err := s.db.QueryRow(`
+		INSERT INTO mta_sts_policies (custom_domain_id, mode, policy_id, max_age)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (custom_domain_id) DO UPDATE SET mode = EXCLUDED.mode, policy_id = EXCLUDED.policy_id, max_age = EXCLUDED.max_age
+		RETURNING mode, policy_id, max_age, created_at, updated_at
+	`, customDomain.ID, mode, newMTASTSPolicyID(time.Now()), maxAge).Scan(
+		&policy.Mode, &policy.PolicyID, &policy.MaxAge, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save MTA-STS policy: %w", err)
+	}
+
+	customDomain.ReplaceDNSRecords(models.DNSRecordPurposeMTASTS, s.dnsRecords(customDomain.Domain, policy.PolicyID))
+	if err := s.customDomainService.UpdateCustomDomain(customDomain); err != nil {
+		return nil, err
+	}
+
+	s.describe(customDomain, policy)
+	return policy, nil
+}
+
+// GetPolicy returns the domain's published policy
+func (s *MTASTSService) GetPolicy(customDomain *models.CustomDomain) (*models.MTASTSPolicy, error) {
+	policy, err := s.getPolicy(customDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.describe(customDomain, policy)
+	return policy, nil
+}
+
+// DisablePolicy stops serving the domain's policy and removes its records. Senders
+// that cached an enforce policy keep applying it until it expires, so the docs
+// recommend switching to mode none for max_age first.
+func (s *MTASTSService) DisablePolicy(customDomain *models.CustomDomain) error {
+	result, err := s.db.Exec(`DELETE FROM mta_sts_policies WHERE custom_domain_id = $1`, customDomain.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete MTA-STS policy: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("MTA-STS is not enabled")
+	}
+
+	customDomain.ReplaceDNSRecords(models.DNSRecordPurposeMTASTS, nil)
+	return s.customDomainService.UpdateCustomDomain(customDomain)
+}
+
+// PolicyForHost returns the policy file for a request to mta-sts.<domain>
+func (s *MTASTSService) PolicyForHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if !strings.HasPrefix(host, mtaSTSHostPrefix) {
+		return "", fmt.Errorf("MTA-STS policy not found")
+	}
+
+	customDomain, err := s.customDomainService.GetVerifiedCustomDomainByDomain(strings.TrimPrefix(host, mtaSTSHostPrefix))
+	if err != nil {
+		return "", fmt.Errorf("MTA-STS policy not found")
+	}
+
+	policy, err := s.getPolicy(customDomain.ID)
+	if err != nil {
+		return "", fmt.Errorf("MTA-STS policy not found")
+	}
+	return mtaSTSPolicyText(policy.Mode, s.mxHosts, policy.MaxAge), nil
+}
+
+func (s *MTASTSService) getPolicy(customDomainID uuid.UUID) (*models.MTASTSPolicy, error) {
+	policy := &models.MTASTSPolicy{CustomDomainID: customDomainID}
+	err := s.db.QueryRow(`
+		SELECT mode, policy_id, max_age, created_at, updated_at FROM mta_sts_policies WHERE custom_domain_id = $1
+	`, customDomainID).Scan(&policy.Mode, &policy.PolicyID, &policy.MaxAge, &policy.CreatedAt, &policy.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("MTA-STS is not enabled")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MTA-STS policy: %w", err)
+	}
+	return policy, nil
+}
+
+// describe fills in the policy file and the records that publish it
+func (s *MTASTSService) describe(customDomain *models.CustomDomain, policy *models.MTASTSPolicy) {
+	policy.MXHosts = s.mxHosts
+	policy.Policy = mtaSTSPolicyText(policy.Mode, s.mxHosts, policy.MaxAge)
+	policy.DNSRecords = s.dnsRecords(customDomain.Domain, policy.PolicyID)
+}
+
+// dnsRecords returns the policy host CNAME, the policy TXT record and the TLS-RPT record
+func (s *MTASTSService) dnsRecords(domain, policyID string) []models.DNSRecord {
+	records := []models.DNSRecord{
+		{
+			Type:    "CNAME",
+			Name:    mtaSTSHostPrefix + domain,
+			Value:   s.policyHost,
+			TTL:     3600,
+			Purpose: models.DNSRecordPurposeMTASTS,
+		},
+		{
+			Type:    "TXT",
+			Name:    "_mta-sts." + domain,
+			Value:   "v=STSv1; id=" + policyID,
+			TTL:     3600,
+			Purpose: models.DNSRecordPurposeMTASTS,
+		},
+	}
+	if s.tlsReportAddress != "" {
+		records = append(records, models.DNSRecord{
+			Type:    "TXT",
+			Name:    "_smtp._tls." + domain,
+			Value:   "v=TLSRPTv1; rua=mailto:" + s.tlsReportAddress,
+			TTL:     3600,
+			Purpose: models.DNSRecordPurposeMTASTS,
+		})
+	}
+	return records
+}
+
+// mtaSTSPolicyText renders a policy file (RFC 8461 section 3.2)
+func mtaSTSPolicyText(mode models.MTASTSMode, mxHosts []string, maxAge int) string {
+	var b strings.Builder
+	b.WriteString("version: STSv1\r\n")
+	b.WriteString("mode: " + string(mode) + "\r\n")
+	for _, host := range mxHosts {
+		b.WriteString("mx: " + strings.TrimSuffix(host, ".") + "\r\n")
+	}
+	b.WriteString("max_age: " + strconv.Itoa(maxAge) + "\r\n")
+	return b.String()
+}
+
+// newMTASTSPolicyID derives a policy ID from the time of the change. IDs only need to
+// differ between versions of a domain's policy; the random suffix keeps two changes in
+// the same second apart.
+func newMTASTSPolicyID(now time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return now.UTC().Format("20060102T150405") + strconv.FormatInt(int64(now.Nanosecond()), 36)
+	}
+	return now.UTC().Format("20060102T150405") + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMTASTSPolicyText(t *testing.T) {
+	policy := mtaSTSPolicyText(models.MTASTSModeEnforce, []string{"inbound-smtp.us-east-1.amazonaws.com."}, mtaSTSMaxAge)
+
+	assert.Equal(t, "version: STSv1\r\nmode: enforce\r\nmx: inbound-smtp.us-east-1.amazonaws.com\r\nmax_age: 604800\r\n", policy)
+}
+
+func TestMTASTSDNSRecords(t *testing.T) {
+	service := NewMTASTSService(nil, nil, "mta-sts.mayl.ng", []string{"inbound-smtp.us-east-1.amazonaws.com"}, "tls-reports@mayl.ng")
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	policyID := newMTASTSPolicyID(now)
+	assert.Regexp(t, `^20261018T093000[0-9a-f]{8}$`, policyID)
+	assert.NotEqual(t, policyID, newMTASTSPolicyID(now))
+
+	customDomain := &models.CustomDomain{Domain: "example.com", DNSRecords: []models.DNSRecord{
+		{Type: "TXT", Name: "_mta-sts.example.com", Value: "v=STSv1; id=old", Purpose: models.DNSRecordPurposeMTASTS},
+		{Type: "TXT", Name: "_amazonses.example.com", Value: "token"},
+	}}
+	customDomain.ReplaceDNSRecords(models.DNSRecordPurposeMTASTS, service.dnsRecords(customDomain.Domain, policyID))
+
+	assert.Equal(t, []models.DNSRecord{
+		{Type: "TXT", Name: "_amazonses.example.com", Value: "token"},
+		{Type: "CNAME", Name: "mta-sts.example.com", Value: "mta-sts.mayl.ng", TTL: 3600, Purpose: models.DNSRecordPurposeMTASTS},
+		{Type: "TXT", Name: "_mta-sts.example.com", Value: "v=STSv1; id=" + policyID, TTL: 3600, Purpose: models.DNSRecordPurposeMTASTS},
+		{Type: "TXT", Name: "_smtp._tls.example.com", Value: "v=TLSRPTv1; rua=mailto:tls-reports@mayl.ng", TTL: 3600, Purpose: models.DNSRecordPurposeMTASTS},
+	}, customDomain.DNSRecords)
+}
+
+func TestFinishSessionStats(t *testing.T) {
+	stats := models.TLSSessionStats{Successful: 5326, Failed: 303}
+	finishSessionStats(&stats)
+	assert.Equal(t, 5, stats.FailureRatePct)
+}
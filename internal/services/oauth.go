@@ -0,0 +1,252 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/auth"
+	"github.com/maylng/backend/internal/models"
+)
+
+// OAuth error codes from RFC 6749 section 5.2, returned as error strings
+const (
+	OAuthErrInvalidClient      = "invalid_client"
+	OAuthErrInvalidScope       = "invalid_scope"
+	OAuthErrUnauthorizedClient = "unauthorized_client"
+)
+
+type OAuthService struct {
+	db        *sql.DB
+	hasher    *auth.KeyHasher
+	jwtSecret string
+	tokenTTL  time.Duration
+}
+
+func NewOAuthService(db *sql.DB, hasher *auth.KeyHasher, jwtSecret string, tokenTTL time.Duration) *OAuthService {
+	return &OAuthService{
+		db:        db,
+		hasher:    hasher,
+		jwtSecret: jwtSecret,
+		tokenTTL:  tokenTTL,
+	}
+}
+
+// CreateClient registers an OAuth client. Like API keys, a client can only hold scopes
+// the caller holds, and a client created by a member acts for that member.
+func (s *OAuthService) CreateClient(accountID uuid.UUID, memberID *uuid.UUID, callerScopes []string, req *models.CreateOAuthClientRequest) (*models.OAuthClientResponse, error) {
+	if req.Name == "" || len(req.Name) > 100 {
+		return nil, fmt.Errorf("name must be between 1 and 100 characters")
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range scopes {
+		if !models.HasScope(callerScopes, scope) {
+			return nil, fmt.Errorf("cannot grant scope %s: not held by the calling key", scope)
+		}
+	}
+
+	clientID, clientSecret, err := auth.GenerateClientCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate client credentials: %w", err)
+	}
+	secretHash, pepperVersion := s.hasher.Hash(clientSecret)
+
+	client := &models.OAuthClientResponse{
+		Name:         req.Name,
+		MemberID:     memberID,
+		ClientID:     clientID,
+		Scopes:       scopes,
+		ClientSecret: clientSecret,
+	}
+
+	query := `
+		INSERT INTO oauth_clients (account_id, member_id, name, client_id, client_secret_hash, pepper_version, scopes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	err = s.db.QueryRow(query, accountID, memberID, req.Name, clientID, secretHash, pepperVersion, pq.Array(scopes)).Scan(
+		&client.ID,
+		&client.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OAuth client: %w", err)
+	}
+
+	return client, nil
+}
+
+// ListClients returns the account's OAuth clients without secrets
+func (s *OAuthService) ListClients(accountID uuid.UUID) ([]*models.OAuthClientResponse, error) {
+	query := `
+		SELECT id, name, member_id, client_id, scopes, last_used_at, revoked_at, created_at
+		FROM oauth_clients
+		WHERE account_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OAuth clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*models.OAuthClientResponse{}
+	for rows.Next() {
+		var client models.OAuthClientResponse
+		if err := rows.Scan(
+			&client.ID,
+			&client.Name,
+			&client.MemberID,
+			&client.ClientID,
+			pq.Array(&client.Scopes),
+			&client.LastUsedAt,
+			&client.RevokedAt,
+			&client.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan OAuth client: %w", err)
+		}
+		clients = append(clients, &client)
+	}
+
+	return clients, nil
+}
+
+// RevokeClient revokes a client. Tokens it already issued stop working on their next request.
+func (s *OAuthService) RevokeClient(accountID, id uuid.UUID) error {
+	result, err := s.db.Exec(
+		"UPDATE oauth_clients SET revoked_at = NOW() WHERE id = $1 AND account_id = $2 AND revoked_at IS NULL",
+		id, accountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke OAuth client: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("OAuth client not found")
+	}
+
+	return nil
+}
+
+// IssueToken implements the client_credentials grant. requestedScope is the
+// space-separated scope parameter; when empty, all of the client's scopes are granted.
+func (s *OAuthService) IssueToken(clientID, clientSecret, requestedScope string) (*models.OAuthTokenResponse, error) {
+	var client models.OAuthClient
+	var memberRole *string
+	var accountStatus models.AccountStatus
+	query := `
+		SELECT c.id, c.account_id, c.member_id, c.client_secret_hash, c.pepper_version, c.scopes, c.revoked_at, m.role, a.status
+		FROM oauth_clients c
+		JOIN accounts a ON a.id = c.account_id
+		LEFT JOIN organization_members m ON m.id = c.member_id
+		WHERE c.client_id = $1
+	`
+	err := s.db.QueryRow(query, clientID).Scan(
+		&client.ID,
+		&client.AccountID,
+		&client.MemberID,
+		&client.ClientSecretHash,
+		&client.PepperVersion,
+		pq.Array(&client.Scopes),
+		&client.RevokedAt,
+		&memberRole,
+		&accountStatus,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf(OAuthErrInvalidClient)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+
+	if !s.hasher.Verify(clientSecret, client.ClientSecretHash, client.PepperVersion) || client.RevokedAt != nil {
+		return nil, fmt.Errorf(OAuthErrInvalidClient)
+	}
+
+	// Re-hash under the current pepper so old peppers can eventually be retired
+	if s.hasher.NeedsRehash(client.PepperVersion) {
+		newHash, version := s.hasher.Hash(clientSecret)
+		if _, err := s.db.Exec(
+			"UPDATE oauth_clients SET client_secret_hash = $1, pepper_version = $2 WHERE id = $3 AND pepper_version = $4",
+			newHash, version, client.ID, client.PepperVersion,
+		); err != nil {
+			log.Printf("Failed to re-hash secret of OAuth client %s: %v", client.ID, err)
+		}
+	}
+
+	// Suspended and closed accounts get no new tokens
+	if accountStatus != models.AccountStatusActive {
+		return nil, fmt.Errorf(OAuthErrUnauthorizedClient)
+	}
+
+	available := client.Scopes
+	if client.MemberID != nil {
+		if memberRole == nil {
+			return nil, fmt.Errorf(OAuthErrInvalidClient)
+		}
+		available = models.IntersectScopes(available, models.RolePermissions[*memberRole])
+	}
+
+	granted, err := grantOAuthScopes(available, requestedScope)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := auth.IssueToken(auth.TokenClaims{
+		Subject:   client.ID.String(),
+		AccountID: client.AccountID.String(),
+		Scope:     strings.Join(granted, " "),
+		TokenType: auth.TokenTypeClient,
+	}, s.tokenTTL, s.jwtSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue access token: %w", err)
+	}
+
+	if _, err := s.db.Exec("UPDATE oauth_clients SET last_used_at = NOW() WHERE id = $1", client.ID); err != nil {
+		log.Printf("Failed to record use of OAuth client %s: %v", client.ID, err)
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.tokenTTL.Seconds()),
+		Scope:       strings.Join(granted, " "),
+	}, nil
+}
+
+// grantOAuthScopes narrows the available scopes to the requested ones. Requesting a
+// scope the client does not hold is an error rather than being silently dropped.
+func grantOAuthScopes(available []string, requestedScope string) ([]string, error) {
+	requested := strings.Fields(requestedScope)
+	if len(requested) == 0 {
+		if len(available) == 0 {
+			return nil, fmt.Errorf(OAuthErrInvalidScope)
+		}
+		return available, nil
+	}
+
+	var granted []string
+	for _, scope := range requested {
+		if !models.HasScope(available, scope) {
+			return nil, fmt.Errorf(OAuthErrInvalidScope)
+		}
+		if !models.HasScope(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return granted, nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantOAuthScopes(t *testing.T) {
+	available := []string{models.ScopeEmailsRead, models.ScopeEmailsSend}
+
+	granted, err := grantOAuthScopes(available, "")
+	assert.NoError(t, err)
+	assert.Equal(t, available, granted)
+
+	granted, err = grantOAuthScopes(available, "emails:send  emails:send")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.ScopeEmailsSend}, granted)
+
+	_, err = grantOAuthScopes(available, "emails:send account:write")
+	assert.EqualError(t, err, OAuthErrInvalidScope)
+
+	_, err = grantOAuthScopes(nil, "")
+	assert.EqualError(t, err, OAuthErrInvalidScope)
+}
+
+func TestCreateOAuthClientCannotEscalate(t *testing.T) {
+	service := NewOAuthService(nil, nil, "secret", 0)
+
+	_, err := service.CreateClient(uuid.New(), nil, []string{models.ScopeEmailsRead}, &models.CreateOAuthClientRequest{
+		Name:   "sync",
+		Scopes: []string{models.ScopeEmailsSend},
+	})
+	assert.EqualError(t, err, "cannot grant scope emails:send: not held by the calling key")
+}
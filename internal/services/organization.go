@@ -0,0 +1,307 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/models"
+)
+
+type OrganizationService struct {
+	db *sql.DB
+}
+
+func NewOrganizationService(db *sql.DB) *OrganizationService {
+	return &OrganizationService{
+		db: db,
+	}
+}
+
+// GetOrganization returns the organization that owns the account
+func (s *OrganizationService) GetOrganization(accountID uuid.UUID) (*models.OrganizationResponse, error) {
+	query := `
+		SELECT o.id, o.name, o.created_at, o.updated_at,
+		       (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id)
+		FROM organizations o
+		WHERE o.account_id = $1
+	`
+
+	var org models.OrganizationResponse
+	err := s.db.QueryRow(query, accountID).Scan(
+		&org.ID,
+		&org.Name,
+		&org.CreatedAt,
+		&org.UpdatedAt,
+		&org.MembersCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("organization not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return &org, nil
+}
+
+// UpdateOrganization renames the account's organization
+func (s *OrganizationService) UpdateOrganization(accountID uuid.UUID, req *models.UpdateOrganizationRequest) (*models.OrganizationResponse, error) {
+	if req.Name == "" || len(req.Name) > 255 {
+		return nil, fmt.Errorf("name must be between 1 and 255 characters")
+	}
+
+	result, err := s.db.Exec("UPDATE organizations SET name = $1 WHERE account_id = $2", req.Name, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("organization not found")
+	}
+
+	return s.GetOrganization(accountID)
+}
+
+// ListMembers returns the organization's members, owners first
+func (s *OrganizationService) ListMembers(accountID uuid.UUID) ([]*models.OrganizationMember, error) {
+	query := `
+		SELECT m.id, m.organization_id, m.email, m.name, m.role, m.created_at, m.updated_at
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE o.account_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 WHEN 'developer' THEN 2 ELSE 3 END, m.created_at
+	`
+
+	rows, err := s.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []*models.OrganizationMember{}
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(
+			&member.ID,
+			&member.OrganizationID,
+			&member.Email,
+			&member.Name,
+			&member.Role,
+			&member.CreatedAt,
+			&member.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, &member)
+	}
+
+	return members, nil
+}
+
+// GetMember returns a single member of the account's organization
+func (s *OrganizationService) GetMember(accountID, memberID uuid.UUID) (*models.OrganizationMember, error) {
+	return getMember(s.db, accountID, memberID, false)
+}
+
+// AddMember adds a person to the organization. actorRole is the role of the member making
+// the change, or empty for keys that act for the account holder rather than a member.
+func (s *OrganizationService) AddMember(accountID uuid.UUID, actorRole string, req *models.AddMemberRequest) (*models.OrganizationMember, error) {
+	if !models.IsValidRole(req.Role) {
+		return nil, fmt.Errorf("invalid role: %s", req.Role)
+	}
+	if req.Role == models.RoleOwner && !canManageOwners(actorRole) {
+		return nil, fmt.Errorf("only owners can manage owners")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("invalid email")
+	}
+
+	query := `
+		INSERT INTO organization_members (organization_id, email, name, role)
+		SELECT id, $2, $3, $4 FROM organizations WHERE account_id = $1
+		RETURNING id, organization_id, email, name, role, created_at, updated_at
+	`
+
+	var member models.OrganizationMember
+	err := s.db.QueryRow(query, accountID, email, req.Name, req.Role).Scan(
+		&member.ID,
+		&member.OrganizationID,
+		&member.Email,
+		&member.Name,
+		&member.Role,
+		&member.CreatedAt,
+		&member.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("organization not found")
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, fmt.Errorf("member already exists")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	return &member, nil
+}
+
+// UpdateMemberRole changes a member's role. Keys acting for the member pick up the new
+// role's permissions on their next request.
+func (s *OrganizationService) UpdateMemberRole(accountID uuid.UUID, actorRole string, memberID uuid.UUID, req *models.UpdateMemberRequest) (*models.OrganizationMember, error) {
+	if !models.IsValidRole(req.Role) {
+		return nil, fmt.Errorf("invalid role: %s", req.Role)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	member, err := getMember(tx, accountID, memberID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if (member.Role == models.RoleOwner || req.Role == models.RoleOwner) && !canManageOwners(actorRole) {
+		return nil, fmt.Errorf("only owners can manage owners")
+	}
+	if member.Role == models.RoleOwner && req.Role != models.RoleOwner {
+		if err := ensureAnotherOwner(tx, member.OrganizationID, member.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRow(
+		"UPDATE organization_members SET role = $1 WHERE id = $2 RETURNING updated_at",
+		req.Role, member.ID,
+	).Scan(&member.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", err)
+	}
+	member.Role = req.Role
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit member update: %w", err)
+	}
+
+	return member, nil
+}
+
+// RemoveMember removes a member; their API keys and OAuth clients are deleted with them
+func (s *OrganizationService) RemoveMember(accountID uuid.UUID, actorRole string, memberID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	member, err := getMember(tx, accountID, memberID, true)
+	if err != nil {
+		return err
+	}
+
+	if member.Role == models.RoleOwner {
+		if !canManageOwners(actorRole) {
+			return fmt.Errorf("only owners can manage owners")
+		}
+		if err := ensureAnotherOwner(tx, member.OrganizationID, member.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM organization_members WHERE id = $1", member.ID); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit member removal: %w", err)
+	}
+
+	return nil
+}
+
+// canManageOwners reports whether the actor may add, change or remove owners.
+// Keys without a member act for the account holder and keep full control.
+func canManageOwners(actorRole string) bool {
+	return actorRole == "" || actorRole == models.RoleOwner
+}
+
+// roleRanks orders roles by the permissions they hold
+var roleRanks = map[string]int{
+	models.RoleViewer:    1,
+	models.RoleDeveloper: 2,
+	models.RoleAdmin:     3,
+	models.RoleOwner:     4,
+}
+
+// canManageAPIKey reports whether the actor may rotate, revoke or restrict a key. Members
+// manage their own keys and those of members whose role is not higher than theirs; keys
+// without a member act for the account holder and count as an owner's.
+func canManageAPIKey(actorMemberID *uuid.UUID, actorRole string, keyMemberID *uuid.UUID, keyRole string) bool {
+	if actorRole == "" {
+		return true
+	}
+	if keyMemberID == nil {
+		keyRole = models.RoleOwner
+	} else if actorMemberID != nil && *actorMemberID == *keyMemberID {
+		return true
+	}
+	return roleRanks[keyRole] <= roleRanks[actorRole]
+}
+
+// ensureAnotherOwner fails if memberID is the organization's only owner
+func ensureAnotherOwner(q queryRower, organizationID, memberID uuid.UUID) error {
+	var owners int
+	err := q.QueryRow(
+		"SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = 'owner' AND id <> $2",
+		organizationID, memberID,
+	).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("failed to count owners: %w", err)
+	}
+	if owners == 0 {
+		return fmt.Errorf("organization must keep at least one owner")
+	}
+	return nil
+}
+
+func getMember(q queryRower, accountID, memberID uuid.UUID, forUpdate bool) (*models.OrganizationMember, error) {
+	query := `
+		SELECT m.id, m.organization_id, m.email, m.name, m.role, m.created_at, m.updated_at
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.id = $1 AND o.account_id = $2
+	`
+	if forUpdate {
+		query += " FOR UPDATE OF m"
+	}
+
+	var member models.OrganizationMember
+	err := q.QueryRow(query, memberID, accountID).Scan(
+		&member.ID,
+		&member.OrganizationID,
+		&member.Email,
+		&member.Name,
+		&member.Role,
+		&member.CreatedAt,
+		&member.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("member not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	return &member, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolePermissionsAreNested(t *testing.T) {
+	roles := []string{models.RoleViewer, models.RoleDeveloper, models.RoleAdmin, models.RoleOwner}
+	for i := 1; i < len(roles); i++ {
+		for _, scope := range models.RolePermissions[roles[i-1]] {
+			assert.True(t, models.HasScope(models.RolePermissions[roles[i]], scope), "%s should include %s", roles[i], scope)
+		}
+	}
+
+	assert.False(t, models.HasScope(models.RolePermissions[models.RoleViewer], models.ScopeTPSReadSecrets))
+	assert.False(t, models.HasScope(models.RolePermissions[models.RoleDeveloper], models.ScopeBillingWrite))
+	assert.False(t, models.HasScope(models.RolePermissions[models.RoleAdmin], models.ScopeBillingWrite))
+}
+
+func TestAddMemberOwnerRequiresOwner(t *testing.T) {
+	service := NewOrganizationService(nil)
+
+	_, err := service.AddMember(uuid.New(), models.RoleAdmin, &models.AddMemberRequest{
+		Email: "someone@example.com",
+		Role:  models.RoleOwner,
+	})
+	assert.EqualError(t, err, "only owners can manage owners")
+
+	_, err = service.AddMember(uuid.New(), models.RoleAdmin, &models.AddMemberRequest{
+		Email: "someone@example.com",
+		Role:  "superuser",
+	})
+	assert.EqualError(t, err, "invalid role: superuser")
+}
+
+func TestCanManageOwners(t *testing.T) {
+	assert.True(t, canManageOwners(""))
+	assert.True(t, canManageOwners(models.RoleOwner))
+	assert.False(t, canManageOwners(models.RoleAdmin))
+	assert.False(t, canManageOwners(models.RoleDeveloper))
+}
+
+func TestCanManageAPIKeyAdminActingOnOwnerKey(t *testing.T) {
+	admin := uuid.New()
+	owner := uuid.New()
+
+	// An admin must not rotate, revoke or unrestrict the owner's keys
+	assert.False(t, canManageAPIKey(&admin, models.RoleAdmin, &owner, models.RoleOwner))
+	assert.False(t, canManageAPIKey(&admin, models.RoleAdmin, nil, ""))
+
+	// Their own keys and those of equal or lower roles stay manageable
+	assert.True(t, canManageAPIKey(&admin, models.RoleAdmin, &admin, models.RoleAdmin))
+	other := uuid.New()
+	assert.True(t, canManageAPIKey(&admin, models.RoleAdmin, &other, models.RoleAdmin))
+	assert.True(t, canManageAPIKey(&admin, models.RoleAdmin, &other, models.RoleDeveloper))
+
+	// Owners and the account holder manage every key
+	assert.True(t, canManageAPIKey(&owner, models.RoleOwner, &admin, models.RoleAdmin))
+	assert.True(t, canManageAPIKey(nil, "", &owner, models.RoleOwner))
+}
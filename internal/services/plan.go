@@ -0,0 +1,355 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+var planIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// dbExecutor is satisfied by both *sql.DB and *sql.Tx
+type dbExecutor interface {
+	queryRower
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type PlanService struct {
+	db *sql.DB
+}
+
+func NewPlanService(db *sql.DB) *PlanService {
+	return &PlanService{
+		db: db,
+	}
+}
+
+// ListPlans returns every plan, public and private
+func (s *PlanService) ListPlans() ([]*models.Plan, error) {
+	rows, err := s.db.Query("SELECT " + planColumns + " FROM plans ORDER BY email_limit_per_month, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+	defer rows.Close()
+
+	plans := []*models.Plan{}
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+// GetPlan returns a single plan
+func (s *PlanService) GetPlan(id string) (*models.Plan, error) {
+	return getPlan(s.db, id)
+}
+
+// UpsertPlan creates or replaces a plan. Accounts on the plan get their limits
+// recomputed in the same transaction.
+func (s *PlanService) UpsertPlan(id string, req *models.UpsertPlanRequest) (*models.Plan, error) {
+	if !planIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid plan id: %s", id)
+	}
+	if req.Name == "" || len(req.Name) > 100 {
+		return nil, fmt.Errorf("name must be between 1 and 100 characters")
+	}
+	if err := validatePlanLimits(&req.PlanLimits); err != nil {
+		return nil, err
+	}
+
+	features, err := json.Marshal(nonNilFeatures(req.Features))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode features: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO plans (
+			id, name, is_public, email_limit_per_month, email_limit_per_day, email_limit_per_hour,
+			email_address_limit, tps_per_address_limit, custom_domain_limit, api_calls_per_hour, features
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			is_public = EXCLUDED.is_public,
+			email_limit_per_month = EXCLUDED.email_limit_per_month,
+			email_limit_per_day = EXCLUDED.email_limit_per_day,
+			email_limit_per_hour = EXCLUDED.email_limit_per_hour,
+			email_address_limit = EXCLUDED.email_address_limit,
+			tps_per_address_limit = EXCLUDED.tps_per_address_limit,
+			custom_domain_limit = EXCLUDED.custom_domain_limit,
+			api_calls_per_hour = EXCLUDED.api_calls_per_hour,
+			features = EXCLUDED.features
+		RETURNING ` + planColumns
+	plan, err := scanPlan(tx.QueryRow(query,
+		id,
+		req.Name,
+		req.IsPublic,
+		req.EmailLimitPerMonth,
+		req.EmailLimitPerDay,
+		req.EmailLimitPerHour,
+		req.EmailAddressLimit,
+		req.TPSPerAddressLimit,
+		req.CustomDomainLimit,
+		req.APICallsPerHour,
+		string(features),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(recomputeAccountLimitsQuery+"a.plan = $1", id); err != nil {
+		return nil, fmt.Errorf("failed to recompute account limits: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit plan: %w", err)
+	}
+
+	return plan, nil
+}
+
+// GetAccountLimits returns the account's plan, overrides and effective limits
+func (s *PlanService) GetAccountLimits(accountID uuid.UUID) (*models.AccountLimitsResponse, error) {
+	return getAccountLimits(s.db, accountID)
+}
+
+// ChangeAccountPlan moves an account to another plan, optionally replacing its
+// overrides, and recomputes its limits
+func (s *PlanService) ChangeAccountPlan(accountID uuid.UUID, req *models.ChangeAccountPlanRequest) (*models.AccountLimitsResponse, error) {
+	if _, err := getPlan(s.db, req.Plan); err != nil {
+		return nil, err
+	}
+	if req.LimitOverrides != nil {
+		if err := validateLimitOverrides(req.LimitOverrides); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE accounts SET plan = $1 WHERE id = $2", req.Plan, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to change plan: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil, fmt.Errorf("account not found")
+	}
+
+	if req.LimitOverrides != nil {
+		if err := setLimitOverrides(tx, accountID, req.LimitOverrides); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(recomputeAccountLimitsQuery+"a.id = $1", accountID); err != nil {
+		return nil, fmt.Errorf("failed to recompute account limits: %w", err)
+	}
+
+	limits, err := getAccountLimits(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit plan change: %w", err)
+	}
+
+	return limits, nil
+}
+
+// SetLimitOverrides replaces the account's overrides and recomputes its limits
+func (s *PlanService) SetLimitOverrides(accountID uuid.UUID, overrides *models.LimitOverrides) (*models.AccountLimitsResponse, error) {
+	if err := validateLimitOverrides(overrides); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setLimitOverrides(tx, accountID, overrides); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(recomputeAccountLimitsQuery+"a.id = $1", accountID); err != nil {
+		return nil, fmt.Errorf("failed to recompute account limits: %w", err)
+	}
+
+	limits, err := getAccountLimits(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit limit overrides: %w", err)
+	}
+
+	return limits, nil
+}
+
+const planColumns = `id, name, is_public, email_limit_per_month, email_limit_per_day, email_limit_per_hour,
+	email_address_limit, tps_per_address_limit, custom_domain_limit, api_calls_per_hour, features::text, created_at, updated_at`
+
+// recomputeAccountLimitsQuery refreshes the effective limit columns kept on accounts.
+// Callers append the WHERE condition for the accounts to refresh.
+const recomputeAccountLimitsQuery = `
+	UPDATE accounts a
+	SET email_limit_per_month = COALESCE((a.limit_overrides->>'email_limit_per_month')::int, p.email_limit_per_month),
+		email_address_limit = COALESCE((a.limit_overrides->>'email_address_limit')::int, p.email_address_limit)
+	FROM plans p
+	WHERE p.id = a.plan AND `
+
+func scanPlan(row rowScanner) (*models.Plan, error) {
+	var plan models.Plan
+	var features string
+	err := row.Scan(
+		&plan.ID,
+		&plan.Name,
+		&plan.IsPublic,
+		&plan.EmailLimitPerMonth,
+		&plan.EmailLimitPerDay,
+		&plan.EmailLimitPerHour,
+		&plan.EmailAddressLimit,
+		&plan.TPSPerAddressLimit,
+		&plan.CustomDomainLimit,
+		&plan.APICallsPerHour,
+		&features,
+		&plan.CreatedAt,
+		&plan.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("plan not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan plan: %w", err)
+	}
+	if err := json.Unmarshal([]byte(features), &plan.Features); err != nil {
+		return nil, fmt.Errorf("failed to decode plan features: %w", err)
+	}
+	plan.Features = nonNilFeatures(plan.Features)
+	return &plan, nil
+}
+
+func getPlan(q queryRower, id string) (*models.Plan, error) {
+	plan, err := scanPlan(q.QueryRow("SELECT "+planColumns+" FROM plans WHERE id = $1", id))
+	if err != nil && err.Error() == "plan not found" {
+		return nil, fmt.Errorf("invalid plan: %s", id)
+	}
+	return plan, err
+}
+
+// getAccountLimits loads the account's plan and overrides and combines them
+func getAccountLimits(q queryRower, accountID uuid.UUID) (*models.AccountLimitsResponse, error) {
+	var planID, overridesJSON string
+	err := q.QueryRow("SELECT plan, limit_overrides::text FROM accounts WHERE id = $1", accountID).Scan(&planID, &overridesJSON)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("account not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account limits: %w", err)
+	}
+
+	plan, err := getPlan(q, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides models.LimitOverrides
+	if err := json.Unmarshal([]byte(overridesJSON), &overrides); err != nil {
+		return nil, fmt.Errorf("failed to decode limit overrides: %w", err)
+	}
+
+	return &models.AccountLimitsResponse{
+		Plan:      planID,
+		Limits:    plan.PlanLimits.WithOverrides(&overrides),
+		Overrides: overrides,
+	}, nil
+}
+
+func setLimitOverrides(q dbExecutor, accountID uuid.UUID, overrides *models.LimitOverrides) error {
+	overridesJSON, err := json.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to encode limit overrides: %w", err)
+	}
+
+	result, err := q.Exec("UPDATE accounts SET limit_overrides = $1 WHERE id = $2", string(overridesJSON), accountID)
+	if err != nil {
+		return fmt.Errorf("failed to set limit overrides: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("account not found")
+	}
+	return nil
+}
+
+func validatePlanLimits(limits *models.PlanLimits) error {
+	values := map[string]int{
+		"email_limit_per_month": limits.EmailLimitPerMonth,
+		"email_limit_per_day":   limits.EmailLimitPerDay,
+		"email_limit_per_hour":  limits.EmailLimitPerHour,
+		"email_address_limit":   limits.EmailAddressLimit,
+		"tps_per_address_limit": limits.TPSPerAddressLimit,
+		"custom_domain_limit":   limits.CustomDomainLimit,
+		"api_calls_per_hour":    limits.APICallsPerHour,
+	}
+	for name, value := range values {
+		if value < 0 {
+			return fmt.Errorf("invalid limit: %s must not be negative", name)
+		}
+	}
+	return validateFeatures(limits.Features)
+}
+
+func validateLimitOverrides(overrides *models.LimitOverrides) error {
+	values := map[string]*int{
+		"email_limit_per_month": overrides.EmailLimitPerMonth,
+		"email_limit_per_day":   overrides.EmailLimitPerDay,
+		"email_limit_per_hour":  overrides.EmailLimitPerHour,
+		"email_address_limit":   overrides.EmailAddressLimit,
+		"tps_per_address_limit": overrides.TPSPerAddressLimit,
+		"custom_domain_limit":   overrides.CustomDomainLimit,
+		"api_calls_per_hour":    overrides.APICallsPerHour,
+	}
+	for name, value := range values {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("invalid limit: %s must not be negative", name)
+		}
+	}
+	return validateFeatures(overrides.Features)
+}
+
+func validateFeatures(features map[string]bool) error {
+	for feature := range features {
+		if !models.IsValidPlanFeature(feature) {
+			return fmt.Errorf("invalid feature: %s", feature)
+		}
+	}
+	return nil
+}
+
+func nonNilFeatures(features map[string]bool) map[string]bool {
+	if features == nil {
+		return map[string]bool{}
+	}
+	return features
+}
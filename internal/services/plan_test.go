@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanLimitsWithOverrides(t *testing.T) {
+	limits := models.PlanLimits{
+		EmailLimitPerMonth: 5000,
+		EmailAddressLimit:  5,
+		Features:           map[string]bool{models.FeatureCustomDomains: true},
+	}
+
+	monthly := 20000
+	effective := limits.WithOverrides(&models.LimitOverrides{
+		EmailLimitPerMonth: &monthly,
+		Features:           map[string]bool{models.FeatureCustomDomains: false},
+	})
+
+	assert.Equal(t, 20000, effective.EmailLimitPerMonth)
+	assert.Equal(t, 5, effective.EmailAddressLimit)
+	assert.False(t, effective.HasFeature(models.FeatureCustomDomains))
+	assert.True(t, limits.HasFeature(models.FeatureCustomDomains), "plan features must not be modified")
+}
+
+func TestValidateLimitOverrides(t *testing.T) {
+	negative := -1
+	assert.EqualError(t, validateLimitOverrides(&models.LimitOverrides{TPSPerAddressLimit: &negative}),
+		"invalid limit: tps_per_address_limit must not be negative")
+	assert.EqualError(t, validateLimitOverrides(&models.LimitOverrides{Features: map[string]bool{"teleport": true}}),
+		"invalid feature: teleport")
+	assert.NoError(t, validateLimitOverrides(&models.LimitOverrides{}))
+}
@@ -38,10 +38,11 @@ func (s *ResendVerificationService) InitiateDomainVerification(customDomain *mod
 		return fmt.Errorf("resend client not configured")
 	}
 
-	// 1. Create domain in Resend
+	// 1. Create domain in Resend with a custom return path so SPF aligns with the From domain
 	createParams := &resend.CreateDomainRequest{
-		Name:   customDomain.Domain,
-		Region: s.region,
+		Name:             customDomain.Domain,
+		Region:           s.region,
+		CustomReturnPath: models.MailFromSubdomain,
 	}
 
 	createResp, err := s.client.Domains.Create(createParams)
@@ -80,6 +81,11 @@ func (s *ResendVerificationService) InitiateDomainVerification(customDomain *mod
 			TTL:   3600, // Default TTL
 		}
 
+		// Resend's SPF records (MX and TXT) live on the return path domain
+		if record.Record == "SPF" {
+			dnsRecord.Purpose = models.DNSRecordPurposeMailFrom
+		}
+
 		// Handle MX record priority - Resend API may return it as string
 		if record.Priority != "" {
 			// Priority is a string in the SDK, try to parse it
@@ -93,8 +99,10 @@ func (s *ResendVerificationService) InitiateDomainVerification(customDomain *mod
 	}
 
 	// 4. Update custom domain with verification details
-	customDomain.DNSRecords = dnsRecords
+	customDomain.ReplaceProviderDNSRecords(dnsRecords)
 	customDomain.Status = models.CustomDomainStatusPending
+	customDomain.MailFromDomain = stringPtr(customDomain.DefaultMailFromDomain())
+	customDomain.MailFromStatus = stringPtr(resendMailFromStatus(createResp.Records))
 
 	// Store Resend-specific verification status in both provider field and metadata
 	resendStatus := string(createResp.Status)
@@ -149,10 +157,17 @@ func (s *ResendVerificationService) CheckVerificationStatus(customDomain *models
 	}
 	customDomain.Metadata["resend_verification_status"] = resendStatus
 
+	if customDomain.MailFromDomain != nil {
+		customDomain.MailFromStatus = stringPtr(resendMailFromStatus(domain.Records))
+	}
+
 	// Map Resend status to our internal status
 	switch string(domain.Status) {
 	case "verified":
-		customDomain.Status = models.CustomDomainStatusVerified
+		// Only the health check clears degraded
+		if customDomain.Status != models.CustomDomainStatusDegraded {
+			customDomain.Status = models.CustomDomainStatusVerified
+		}
 		if customDomain.VerifiedAt == nil {
 			now := time.Now()
 			customDomain.VerifiedAt = &now
@@ -176,6 +191,31 @@ func (s *ResendVerificationService) CheckVerificationStatus(customDomain *models
 	return s.customDomainService.UpdateCustomDomain(customDomain)
 }
 
+// resendMailFromStatus derives the return path status from Resend's SPF records: verified
+// once all of them are, failed if any failed
+func resendMailFromStatus(records []resend.Record) string {
+	status := models.MailFromStatusPending
+	verified := 0
+	total := 0
+	for _, record := range records {
+		if record.Record != "SPF" {
+			continue
+		}
+		total++
+		switch record.Status {
+		case "verified":
+			verified++
+		case "failed":
+			status = models.MailFromStatusFailed
+		}
+	}
+
+	if status == models.MailFromStatusPending && total > 0 && verified == total {
+		return models.MailFromStatusVerified
+	}
+	return status
+}
+
 // DeleteDomainIdentity removes the domain from Resend using domain ID
 func (s *ResendVerificationService) DeleteDomainIdentity(domain string) error {
 	if s.client == nil {
@@ -240,67 +280,6 @@ func (s *ResendVerificationService) DeleteDomainByID(customDomain *models.Custom
 	return nil
 }
 
-// UpdateDomainSettings updates domain configuration in Resend (tracking, TLS, etc.)
-func (s *ResendVerificationService) UpdateDomainSettings(customDomain *models.CustomDomain, settings map[string]interface{}) error {
-	if s.client == nil {
-		return fmt.Errorf("resend client not configured")
-	}
-
-	// Get Resend domain ID from provider_domain_id field or fallback to metadata
-	var resendDomainID string
-	if customDomain.ProviderDomainID != nil {
-		resendDomainID = *customDomain.ProviderDomainID
-	} else if customDomain.Metadata != nil {
-		if id, ok := customDomain.Metadata["resend_domain_id"].(string); ok {
-			resendDomainID = id
-		}
-	}
-
-	if resendDomainID == "" {
-		return fmt.Errorf("resend domain ID not found")
-	}
-
-	// Build update request
-	updateParams := &resend.UpdateDomainRequest{}
-
-	// Handle click tracking
-	if clickTracking, ok := settings["click_tracking"].(bool); ok {
-		updateParams.ClickTracking = clickTracking
-	}
-
-	// Handle open tracking
-	if openTracking, ok := settings["open_tracking"].(bool); ok {
-		updateParams.OpenTracking = openTracking
-	}
-
-	// Handle TLS setting
-	if tlsStr, ok := settings["tls"].(string); ok {
-		switch tlsStr {
-		case "enforced":
-			updateParams.Tls = resend.Enforced
-		case "opportunistic":
-			updateParams.Tls = resend.Opportunistic
-		}
-	}
-
-	// Update domain in Resend
-	_, err := s.client.Domains.Update(resendDomainID, updateParams)
-	if err != nil {
-		return fmt.Errorf("failed to update domain in Resend: %w", err)
-	}
-
-	// Store updated settings in metadata
-	if customDomain.Metadata == nil {
-		customDomain.Metadata = make(map[string]interface{})
-	}
-	for key, value := range settings {
-		customDomain.Metadata["resend_"+key] = value
-	}
-
-	// Save to database
-	return s.customDomainService.UpdateCustomDomain(customDomain)
-}
-
 // RetryVerification retries the verification process for a domain
 func (s *ResendVerificationService) RetryVerification(customDomain *models.CustomDomain) error {
 	// Reset some fields
@@ -342,6 +321,15 @@ func (s *ResendVerificationService) GetProviderType() string {
 	return "resend"
 }
 
+// Capabilities reports that Resend can update tracking and TLS settings of a domain
+func (s *ResendVerificationService) Capabilities() DomainVerificationCapabilities {
+	return DomainVerificationCapabilities{
+		SettingsUpdate: true,
+		Tracking:       true,
+		Region:         s.region,
+	}
+}
+
 // Helper function to convert string to string pointer
 func stringPtr(s string) *string {
 	return &s
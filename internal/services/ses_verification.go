@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -107,8 +108,27 @@ func (s *SESVerificationService) InitiateDomainVerification(customDomain *models
 		}
 	}
 
+	// 4. Configure the custom MAIL FROM domain so SPF aligns with the From domain. The
+	// identity already exists, so a failure here only costs SPF alignment: the domain
+	// still verifies and sends with SES's default MAIL FROM domain.
+	mailFromDomain := customDomain.DefaultMailFromDomain()
+	_, err = s.client.PutEmailIdentityMailFromAttributes(ctx, &sesv2.PutEmailIdentityMailFromAttributesInput{
+		EmailIdentity:       aws.String(customDomain.Domain),
+		MailFromDomain:      aws.String(mailFromDomain),
+		BehaviorOnMxFailure: types.BehaviorOnMxFailureUseDefaultValue,
+	})
+	if err != nil {
+		log.Printf("Failed to configure MAIL FROM domain for %s: %v", customDomain.Domain, err)
+		customDomain.MailFromDomain = nil
+		customDomain.MailFromStatus = aws.String(models.MailFromStatusFailed)
+	} else {
+		dnsRecords = append(dnsRecords, s.mailFromDNSRecords(mailFromDomain)...)
+		customDomain.MailFromDomain = aws.String(mailFromDomain)
+		customDomain.MailFromStatus = aws.String(models.MailFromStatusPending)
+	}
+
 	// Update domain with verification details
-	customDomain.DNSRecords = dnsRecords
+	customDomain.ReplaceProviderDNSRecords(dnsRecords)
 	customDomain.SESVerificationStatus = aws.String(string(getResp.VerificationStatus))
 
 	if getResp.DkimAttributes != nil {
@@ -144,11 +164,18 @@ func (s *SESVerificationService) CheckVerificationStatus(customDomain *models.Cu
 		customDomain.SESDKIMVerificationStatus = aws.String(string(resp.DkimAttributes.Status))
 	}
 
+	if resp.MailFromAttributes != nil && customDomain.MailFromDomain != nil {
+		customDomain.MailFromStatus = aws.String(sesMailFromStatus(resp.MailFromAttributes.MailFromDomainStatus))
+	}
+
 	// Use tagged switch for SES verification status
 	switch resp.VerificationStatus {
 	case types.VerificationStatusSuccess:
 		if resp.DkimAttributes != nil && resp.DkimAttributes.Status == types.DkimStatusSuccess {
-			customDomain.Status = models.CustomDomainStatusVerified
+			// Only the health check clears degraded
+			if customDomain.Status != models.CustomDomainStatusDegraded {
+				customDomain.Status = models.CustomDomainStatusVerified
+			}
 			if customDomain.VerifiedAt == nil {
 				now := time.Now()
 				customDomain.VerifiedAt = &now
@@ -176,6 +203,40 @@ func (s *SESVerificationService) CheckVerificationStatus(customDomain *models.Cu
 	return s.customDomainService.UpdateCustomDomain(customDomain)
 }
 
+// mailFromDNSRecords returns the MX and SPF records SES needs on the MAIL FROM domain
+func (s *SESVerificationService) mailFromDNSRecords(mailFromDomain string) []models.DNSRecord {
+	return []models.DNSRecord{
+		{
+			Type:     "MX",
+			Name:     mailFromDomain,
+			Value:    fmt.Sprintf("feedback-smtp.%s.amazonses.com", s.region),
+			TTL:      1800,
+			Priority: 10,
+			Purpose:  models.DNSRecordPurposeMailFrom,
+		},
+		{
+			Type:    "TXT",
+			Name:    mailFromDomain,
+			Value:   "v=spf1 include:amazonses.com ~all",
+			TTL:     1800,
+			Purpose: models.DNSRecordPurposeMailFrom,
+		},
+	}
+}
+
+// sesMailFromStatus maps an SES MAIL FROM domain status to ours. A temporary failure
+// is still pending: SES keeps checking the records.
+func sesMailFromStatus(status types.MailFromDomainStatus) string {
+	switch status {
+	case types.MailFromDomainStatusSuccess:
+		return models.MailFromStatusVerified
+	case types.MailFromDomainStatusFailed:
+		return models.MailFromStatusFailed
+	default:
+		return models.MailFromStatusPending
+	}
+}
+
 // DeleteDomainIdentity removes the domain from SES
 func (s *SESVerificationService) DeleteDomainIdentity(domain string) error {
 	ctx := context.TODO()
@@ -223,3 +284,12 @@ func (s *SESVerificationService) RetryVerification(customDomain *models.CustomDo
 func (s *SESVerificationService) GetProviderType() string {
 	return "ses"
 }
+
+// Capabilities reports the region SES identities are created in and that SES sends the
+// raw messages self-hosted DKIM signs; domain settings are not managed through SES
+func (s *SESVerificationService) Capabilities() DomainVerificationCapabilities {
+	return DomainVerificationCapabilities{
+		DKIMSigning: true,
+		Region:      s.region,
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/auth"
+	"github.com/maylng/backend/internal/config"
+	"github.com/maylng/backend/internal/email"
+	"github.com/maylng/backend/internal/models"
+)
+
+// maxMagicLinksPerWindow caps login emails per member within one link lifetime
+const maxMagicLinksPerWindow = 5
+
+type SessionService struct {
+	db           *sql.DB
+	emailService *email.Service
+	jwtSecret    string
+	loginURL     string
+	fromEmail    string
+	magicLinkTTL time.Duration
+	accessTTL    time.Duration
+	refreshTTL   time.Duration
+}
+
+func NewSessionService(db *sql.DB, emailService *email.Service, cfg *config.Config) *SessionService {
+	return &SessionService{
+		db:           db,
+		emailService: emailService,
+		jwtSecret:    cfg.JWTSecret,
+		loginURL:     cfg.DashboardLoginURL,
+		fromEmail:    cfg.AuthEmailFrom,
+		magicLinkTTL: time.Duration(cfg.MagicLinkTTLMinutes) * time.Minute,
+		accessTTL:    time.Duration(cfg.AccessTokenTTLMinutes) * time.Minute,
+		refreshTTL:   time.Duration(cfg.RefreshTokenTTLDays) * 24 * time.Hour,
+	}
+}
+
+type loginMembership struct {
+	memberID         uuid.UUID
+	organizationName string
+}
+
+// RequestMagicLink emails a login link for every organization the address belongs to.
+// Unknown addresses are ignored without error so callers cannot probe for members.
+func (s *SessionService) RequestMagicLink(emailAddress, ip string) error {
+	emailAddress = strings.ToLower(strings.TrimSpace(emailAddress))
+
+	query := `
+		SELECT m.id, o.name
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.email = $1
+		ORDER BY o.name
+	`
+	rows, err := s.db.Query(query, emailAddress)
+	if err != nil {
+		return fmt.Errorf("failed to look up members: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []loginMembership
+	for rows.Next() {
+		var m loginMembership
+		if err := rows.Scan(&m.memberID, &m.organizationName); err != nil {
+			return fmt.Errorf("failed to scan member: %w", err)
+		}
+		memberships = append(memberships, m)
+	}
+	rows.Close()
+
+	var links []string
+	for _, m := range memberships {
+		var recent int
+		err := s.db.QueryRow(
+			"SELECT COUNT(*) FROM magic_link_tokens WHERE member_id = $1 AND created_at > $2",
+			m.memberID, time.Now().UTC().Add(-s.magicLinkTTL),
+		).Scan(&recent)
+		if err != nil {
+			return fmt.Errorf("failed to check login rate: %w", err)
+		}
+		if recent >= maxMagicLinksPerWindow {
+			continue
+		}
+
+		token, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			return fmt.Errorf("failed to generate login token: %w", err)
+		}
+
+		_, err = s.db.Exec(
+			"INSERT INTO magic_link_tokens (member_id, token_hash, expires_at, requested_ip) VALUES ($1, $2, $3, $4)",
+			m.memberID, auth.HashToken(token), time.Now().UTC().Add(s.magicLinkTTL), nullIfEmpty(ip),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store login token: %w", err)
+		}
+
+		links = append(links, fmt.Sprintf("%s: %s", m.organizationName, s.magicLinkURL(token)))
+	}
+
+	if len(links) == 0 {
+		return nil
+	}
+
+	minutes := int(s.magicLinkTTL.Minutes())
+	text := fmt.Sprintf(
+		"Use the link below to sign in. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not ask to sign in, you can ignore this email.\n",
+		minutes, strings.Join(links, "\n"),
+	)
+
+	_, err = s.emailService.SendEmail(&email.Email{
+		FromEmail:    s.fromEmail,
+		FromName:     "Maylng",
+		ToRecipients: []string{emailAddress},
+		Subject:      "Your sign-in link",
+		TextContent:  text,
+	})
+	if err != nil {
+		// Don't reveal to the caller that the address exists
+		log.Printf("Failed to send login email: %v", err)
+	}
+
+	return nil
+}
+
+// VerifyMagicLink consumes a login link and starts a session
+func (s *SessionService) VerifyMagicLink(token, ip, userAgent string) (*models.SessionTokens, error) {
+	var memberID uuid.UUID
+	err := s.db.QueryRow(
+		"UPDATE magic_link_tokens SET used_at = NOW() WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2 RETURNING member_id",
+		auth.HashToken(token), time.Now().UTC(),
+	).Scan(&memberID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invalid or expired login link")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify login link: %w", err)
+	}
+
+	refreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	var sessionID uuid.UUID
+	err = s.db.QueryRow(
+		`INSERT INTO sessions (member_id, refresh_token_hash, expires_at, ip_address, user_agent)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		memberID, auth.HashToken(refreshToken), time.Now().UTC().Add(s.refreshTTL), nullIfEmpty(ip), nullIfEmpty(userAgent),
+	).Scan(&sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueTokens(s.db, sessionID, refreshToken)
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working immediately.
+func (s *SessionService) RefreshSession(refreshToken string) (*models.SessionTokens, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sessionID uuid.UUID
+	var expiresAt time.Time
+	var revokedAt *time.Time
+	err = tx.QueryRow(
+		"SELECT id, expires_at, revoked_at FROM sessions WHERE refresh_token_hash = $1 FOR UPDATE",
+		auth.HashToken(refreshToken),
+	).Scan(&sessionID, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if revokedAt != nil || !expiresAt.After(time.Now().UTC()) {
+		return nil, fmt.Errorf("session has ended")
+	}
+
+	newRefreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE sessions SET refresh_token_hash = $1, last_used_at = NOW() WHERE id = $2",
+		auth.HashToken(newRefreshToken), sessionID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	tokens, err := s.issueTokens(tx, sessionID, newRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit session refresh: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// ListSessions returns the member's active sessions
+func (s *SessionService) ListSessions(memberID uuid.UUID) ([]*models.Session, error) {
+	query := `
+		SELECT id, member_id, expires_at, last_used_at, revoked_at, ip_address, user_agent, created_at
+		FROM sessions
+		WHERE member_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC
+	`
+	rows, err := s.db.Query(query, memberID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.MemberID,
+			&session.ExpiresAt,
+			&session.LastUsedAt,
+			&session.RevokedAt,
+			&session.IPAddress,
+			&session.UserAgent,
+			&session.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends one of the member's sessions. Access tokens for it stop working
+// on their next request.
+func (s *SessionService) RevokeSession(memberID, sessionID uuid.UUID) error {
+	result, err := s.db.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND member_id = $2 AND revoked_at IS NULL",
+		sessionID, memberID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	return nil
+}
+
+// RevokeAllSessions ends every session of the member
+func (s *SessionService) RevokeAllSessions(memberID uuid.UUID) error {
+	if _, err := s.db.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE member_id = $1 AND revoked_at IS NULL",
+		memberID,
+	); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// issueTokens signs an access token for the session's member
+func (s *SessionService) issueTokens(q queryRower, sessionID uuid.UUID, refreshToken string) (*models.SessionTokens, error) {
+	tokens := &models.SessionTokens{
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+		RefreshToken: refreshToken,
+		SessionID:    sessionID,
+	}
+
+	query := `
+		SELECT m.id, m.role, o.account_id
+		FROM sessions s
+		JOIN organization_members m ON m.id = s.member_id
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE s.id = $1
+	`
+	if err := q.QueryRow(query, sessionID).Scan(&tokens.MemberID, &tokens.Role, &tokens.AccountID); err != nil {
+		return nil, fmt.Errorf("failed to load session member: %w", err)
+	}
+
+	accessToken, err := auth.IssueToken(auth.TokenClaims{
+		Subject:   tokens.MemberID.String(),
+		AccountID: tokens.AccountID.String(),
+		SessionID: sessionID.String(),
+		TokenType: auth.TokenTypeSession,
+	}, s.accessTTL, s.jwtSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue access token: %w", err)
+	}
+	tokens.AccessToken = accessToken
+
+	return tokens, nil
+}
+
+func (s *SessionService) magicLinkURL(token string) string {
+	separator := "?"
+	if strings.Contains(s.loginURL, "?") {
+		separator = "&"
+	}
+	return s.loginURL + separator + "token=" + url.QueryEscape(token)
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/maylng/backend/internal/email/tlsrpt"
+	"github.com/maylng/backend/internal/models"
+)
+
+// maxTLSFailureGroups caps the failure groups returned in a stats response
+const maxTLSFailureGroups = 100
+
+// TLSReportService stores SMTP TLS reports sent to the TLS-RPT address and summarizes
+// them per custom domain, so failures of inbound TLS become visible
+type TLSReportService struct {
+	db                  *sql.DB
+	customDomainService *CustomDomainService
+}
+
+func NewTLSReportService(db *sql.DB, customDomainService *CustomDomainService) *TLSReportService {
+	return &TLSReportService{
+		db:                  db,
+		customDomainService: customDomainService,
+	}
+}
+
+// IngestMessage stores the reports attached to a report email
+func (s *TLSReportService) IngestMessage(raw []byte) (*ReportIngestResult, error) {
+	reports, err := tlsrpt.ParseMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.Ingest(reports)
+}
+
+// IngestFile stores a JSON or gzip report file
+func (s *TLSReportService) IngestFile(data []byte) (*ReportIngestResult, error) {
+	report, err := tlsrpt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.Ingest([]*tlsrpt.Report{report})
+}
+
+// Ingest stores reports for domains that are registered as custom domains, skipping
+// reports already stored
+func (s *TLSReportService) Ingest(reports []*tlsrpt.Report) (*ReportIngestResult, error) {
+	result := &ReportIngestResult{}
+	for _, report := range reports {
+		domain := report.Policies[0].Policy.Domain
+		customDomain := findReportDomain(s.customDomainService, domain)
+		if customDomain == nil {
+			result.UnknownDomain = append(result.UnknownDomain, domain)
+			continue
+		}
+
+		stored, err := s.storeReport(customDomain.ID, report)
+		if err != nil {
+			return result, err
+		}
+		if stored {
+			result.Stored++
+		} else {
+			result.Duplicates++
+		}
+	}
+	return result, nil
+}
+
+func (s *TLSReportService) storeReport(customDomainID uuid.UUID, report *tlsrpt.Report) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var reportID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO tls_reports (custom_domain_id, org_name, report_id, contact_info, date_begin, date_end)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (custom_domain_id, org_name, report_id) DO NOTHING
+		RETURNING id
+	`, customDomainID, report.OrganizationName, report.ReportID, report.ContactInfo,
+		report.DateRange.Start.UTC(), report.DateRange.End.UTC()).Scan(&reportID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to store TLS report: %w", err)
+	}
+
+	for _, result := range report.Policies {
+		var policyID uuid.UUID
+		err := tx.QueryRow(`
+			INSERT INTO tls_report_policies (report_id, policy_type, policy_domain, mx_hosts, successful_sessions, failed_sessions)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, reportID, result.Policy.Type, strings.ToLower(result.Policy.Domain), pq.Array([]string(result.Policy.MXHost)),
+			result.Summary.SuccessfulSessions, result.Summary.FailedSessions).Scan(&policyID)
+		if err != nil {
+			return false, fmt.Errorf("failed to store TLS report policy: %w", err)
+		}
+
+		for _, failure := range result.FailureDetails {
+			_, err := tx.Exec(`
+				INSERT INTO tls_report_failures (policy_id, result_type, sending_mta_ip, receiving_mx_hostname,
+					receiving_ip, failed_sessions, failure_reason_code)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+			`, policyID, failure.ResultType, failure.SendingMTAIP, strings.ToLower(strings.TrimSuffix(failure.ReceivingMXHostname, ".")),
+				failure.ReceivingIP, failure.FailedSessions, failure.FailureReasonCode)
+			if err != nil {
+				return false, fmt.Errorf("failed to store TLS report failure: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit TLS report: %w", err)
+	}
+	return true, nil
+}
+
+// GetStats summarizes the reports covering the period from since to until
+func (s *TLSReportService) GetStats(customDomain *models.CustomDomain, since, until time.Time) (*models.TLSReportStatsResponse, error) {
+	response := &models.TLSReportStatsResponse{
+		Domain:   customDomain.Domain,
+		From:     since,
+		To:       until,
+		Daily:    []models.TLSDailyStats{},
+		Failures: []models.TLSFailureStats{},
+	}
+
+	const period = `r.custom_domain_id = $1 AND r.date_begin >= $2 AND r.date_begin < $3`
+
+	err := s.db.QueryRow(`
+		SELECT COUNT(DISTINCT r.id), COALESCE(SUM(p.successful_sessions), 0), COALESCE(SUM(p.failed_sessions), 0)
+		FROM tls_reports r
+		LEFT JOIN tls_report_policies p ON p.report_id = r.id
+		WHERE `+period, customDomain.ID, since, until).Scan(&response.Reports, &response.Totals.Successful, &response.Totals.Failed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TLS report totals: %w", err)
+	}
+	finishSessionStats(&response.Totals)
+
+	rows, err := s.db.Query(`
+		SELECT TO_CHAR(DATE_TRUNC('day', r.date_begin), 'YYYY-MM-DD') AS day,
+			COALESCE(SUM(p.successful_sessions), 0), COALESCE(SUM(p.failed_sessions), 0)
+		FROM tls_reports r
+		JOIN tls_report_policies p ON p.report_id = r.id
+		WHERE `+period+`
+		GROUP BY day ORDER BY day
+	`, customDomain.ID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TLS daily stats: %w", err)
+	}
+	for rows.Next() {
+		var day models.TLSDailyStats
+		if err := rows.Scan(&day.Date, &day.Successful, &day.Failed); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan TLS daily stats: %w", err)
+		}
+		finishSessionStats(&day.TLSSessionStats)
+		response.Daily = append(response.Daily, day)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get TLS daily stats: %w", err)
+	}
+
+	rows, err = s.db.Query(`
+		SELECT f.result_type, COALESCE(f.receiving_mx_hostname, ''), SUM(f.failed_sessions), ARRAY_AGG(DISTINCT r.org_name)
+		FROM tls_reports r
+		JOIN tls_report_policies p ON p.report_id = r.id
+		JOIN tls_report_failures f ON f.policy_id = p.id
+		WHERE `+period+`
+		GROUP BY f.result_type, COALESCE(f.receiving_mx_hostname, '')
+		ORDER BY SUM(f.failed_sessions) DESC, f.result_type
+		LIMIT $4
+	`, customDomain.ID, since, until, maxTLSFailureGroups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TLS failures: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var failure models.TLSFailureStats
+		if err := rows.Scan(&failure.ResultType, &failure.ReceivingMXHostname, &failure.FailedSessions, pq.Array(&failure.Reporters)); err != nil {
+			return nil, fmt.Errorf("failed to scan TLS failure: %w", err)
+		}
+		response.Failures = append(response.Failures, failure)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get TLS failures: %w", err)
+	}
+
+	return response, nil
+}
+
+// finishSessionStats fills in the failure rate
+func finishSessionStats(stats *models.TLSSessionStats) {
+	if total := stats.Successful + stats.Failed; total > 0 {
+		stats.FailureRatePct = stats.Failed * 100 / total
+	}
+}
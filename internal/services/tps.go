@@ -23,20 +23,6 @@ func NewTPSService(db *sql.DB, encryptionKey string) *TPSService {
 	}
 }
 
-// GetTPSLimitByPlan returns the 3rd Party Software limit per agent email address for a given plan
-func GetTPSLimitByPlan(plan string) int {
-	switch plan {
-	case "starter":
-		return 1
-	case "pro":
-		return 3
-	case "enterprise":
-		return 20
-	default:
-		return 1
-	}
-}
-
 // CountTPSForEmail returns the number of 3rd Party Software records for a given agent email address
 func (s *TPSService) CountTPSForEmail(emailAddressID uuid.UUID) (int, error) {
 	var count int
@@ -44,14 +30,18 @@ func (s *TPSService) CountTPSForEmail(emailAddressID uuid.UUID) (int, error) {
 	return count, err
 }
 
-// CreateTPS creates a new 3rd Party Software record, enforcing the plan limit
-func (s *TPSService) CreateTPS(accountPlan string, req *models.CreateTPSRequest) (*models.TPSResponse, error) {
+// CreateTPS creates a new 3rd Party Software record, enforcing the account's per-address limit
+func (s *TPSService) CreateTPS(accountID uuid.UUID, req *models.CreateTPSRequest) (*models.TPSResponse, error) {
 	// Check TPS limit for this email address
 	count, err := s.CountTPSForEmail(req.EmailAddressID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count TPS: %w", err)
 	}
-	limit := GetTPSLimitByPlan(accountPlan)
+	accountLimits, err := getAccountLimits(s.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+	limit := accountLimits.Limits.TPSPerAddressLimit
 	if count >= limit {
 		return nil, fmt.Errorf("TPS limit reached for this agent email address (%d/%d)", count, limit)
 	}
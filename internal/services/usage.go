@@ -0,0 +1,200 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+)
+
+// UsageDateLayout is the format of usage dates in requests and responses
+const UsageDateLayout = "2006-01-02"
+
+// maxUsageRangeDays caps how many days one usage query may span
+const maxUsageRangeDays = 366
+
+type UsageService struct {
+	db *sql.DB
+}
+
+func NewUsageService(db *sql.DB) *UsageService {
+	return &UsageService{
+		db: db,
+	}
+}
+
+// RecordUsage adds quantity to the account's total for metric on today's UTC date
+func (s *UsageService) RecordUsage(accountID uuid.UUID, metric string, quantity int64) error {
+	return recordUsage(s.db, accountID, metric, quantity, time.Now())
+}
+
+// GetUsage returns the account's daily usage and per-metric totals for the range
+func (s *UsageService) GetUsage(accountID uuid.UUID, filter *models.UsageFilter) (*models.UsageResponse, error) {
+	filter.AccountID = &accountID
+	records, err := s.ListUsageRecords(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.UsageResponse{
+		From:   filter.From.Format(UsageDateLayout),
+		To:     filter.To.Format(UsageDateLayout),
+		Totals: make(map[string]int64),
+		Usage:  make([]models.UsageDay, 0, len(records)),
+	}
+	for _, metric := range models.AllUsageMetrics {
+		if filter.Metric == "" || filter.Metric == metric {
+			resp.Totals[metric] = 0
+		}
+	}
+	for _, r := range records {
+		resp.Totals[r.Metric] += r.Quantity
+		resp.Usage = append(resp.Usage, models.UsageDay{Date: r.Date, Metric: r.Metric, Quantity: r.Quantity})
+	}
+
+	return resp, nil
+}
+
+// ListUsageRecords returns daily usage records for the filter, ordered by account, date and metric
+func (s *UsageService) ListUsageRecords(filter *models.UsageFilter) ([]*models.UsageRecord, error) {
+	if err := validateUsageFilter(filter); err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"usage_date >= $1", "usage_date <= $2"}
+	args := []interface{}{filter.From.Format(UsageDateLayout), filter.To.Format(UsageDateLayout)}
+	if filter.Metric != "" {
+		args = append(args, filter.Metric)
+		conditions = append(conditions, fmt.Sprintf("metric = $%d", len(args)))
+	}
+	if filter.AccountID != nil {
+		args = append(args, *filter.AccountID)
+		conditions = append(conditions, fmt.Sprintf("account_id = $%d", len(args)))
+	}
+
+	query := `
+		SELECT account_id, usage_date, metric, quantity
+		FROM usage_records
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY account_id, usage_date, metric
+	`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*models.UsageRecord
+	for rows.Next() {
+		var r models.UsageRecord
+		var date time.Time
+		if err := rows.Scan(&r.AccountID, &date, &r.Metric, &r.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		r.Date = date.Format(UsageDateLayout)
+		records = append(records, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+
+	return records, nil
+}
+
+// WriteUsageCSV writes records as CSV with a header row
+func WriteUsageCSV(w io.Writer, records []*models.UsageRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"account_id", "date", "metric", "quantity"}); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := cw.Write([]string{r.AccountID.String(), r.Date, r.Metric, strconv.FormatInt(r.Quantity, 10)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ToStripeUsageRecords converts daily records to Stripe-style usage records stamped at
+// the start of each day
+func ToStripeUsageRecords(records []*models.UsageRecord) ([]models.StripeUsageRecord, error) {
+	out := make([]models.StripeUsageRecord, 0, len(records))
+	for _, r := range records {
+		day, err := time.Parse(UsageDateLayout, r.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid usage date: %s", r.Date)
+		}
+		out = append(out, models.StripeUsageRecord{
+			Customer:       r.AccountID.String(),
+			Metric:         r.Metric,
+			Quantity:       r.Quantity,
+			Timestamp:      day.Unix(),
+			Action:         "set",
+			IdempotencyKey: fmt.Sprintf("%s:%s:%s", r.AccountID, r.Metric, r.Date),
+		})
+	}
+	return out, nil
+}
+
+// recordUsage upserts into the daily rollup. It takes an executor so usage can be
+// recorded inside the caller's transaction.
+func recordUsage(q dbExecutor, accountID uuid.UUID, metric string, quantity int64, at time.Time) error {
+	if !models.IsValidUsageMetric(metric) {
+		return fmt.Errorf("invalid metric: %s", metric)
+	}
+	if quantity <= 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO usage_records (account_id, usage_date, metric, quantity)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id, usage_date, metric) DO UPDATE SET quantity = usage_records.quantity + EXCLUDED.quantity
+	`
+	if _, err := q.Exec(query, accountID, at.UTC().Format(UsageDateLayout), metric, quantity); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+func validateUsageFilter(filter *models.UsageFilter) error {
+	if filter.Metric != "" && !models.IsValidUsageMetric(filter.Metric) {
+		return fmt.Errorf("invalid metric: %s", filter.Metric)
+	}
+	if filter.To.Before(filter.From) {
+		return fmt.Errorf("invalid range: to is before from")
+	}
+	if filter.To.Sub(filter.From) >= maxUsageRangeDays*24*time.Hour {
+		return fmt.Errorf("invalid range: at most %d days", maxUsageRangeDays)
+	}
+	return nil
+}
+
+// ParseUsageRange reads from/to as YYYY-MM-DD. from defaults to the first of the current
+// UTC month and to defaults to today.
+func ParseUsageRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var err error
+	if fromStr != "" {
+		if from, err = time.Parse(UsageDateLayout, fromStr); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: must be YYYY-MM-DD")
+		}
+	}
+	if toStr != "" {
+		if to, err = time.Parse(UsageDateLayout, toStr); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: must be YYYY-MM-DD")
+		}
+	}
+	return from, to, nil
+}
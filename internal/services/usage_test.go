@@ -0,0 +1,43 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maylng/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUsageFilter(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, validateUsageFilter(&models.UsageFilter{From: from, To: from}))
+	assert.NoError(t, validateUsageFilter(&models.UsageFilter{From: from, To: from.AddDate(0, 0, 365), Metric: models.UsageMetricEmailsSent}))
+	assert.EqualError(t, validateUsageFilter(&models.UsageFilter{From: from, To: from.AddDate(0, 0, -1)}), "invalid range: to is before from")
+	assert.EqualError(t, validateUsageFilter(&models.UsageFilter{From: from, To: from.AddDate(0, 0, 366)}), "invalid range: at most 366 days")
+	assert.EqualError(t, validateUsageFilter(&models.UsageFilter{From: from, To: from, Metric: "api_calls"}), "invalid metric: api_calls")
+}
+
+func TestUsageExportFormats(t *testing.T) {
+	accountID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	records := []*models.UsageRecord{
+		{AccountID: accountID, Date: "2025-03-02", Metric: models.UsageMetricEmailsSent, Quantity: 42},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteUsageCSV(&buf, records))
+	assert.Equal(t, "account_id,date,metric,quantity\n11111111-1111-1111-1111-111111111111,2025-03-02,emails_sent,42\n", buf.String())
+
+	stripe, err := ToStripeUsageRecords(records)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.StripeUsageRecord{{
+		Customer:       accountID.String(),
+		Metric:         models.UsageMetricEmailsSent,
+		Quantity:       42,
+		Timestamp:      time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC).Unix(),
+		Action:         "set",
+		IdempotencyKey: "11111111-1111-1111-1111-111111111111:emails_sent:2025-03-02",
+	}}, stripe)
+}
@@ -0,0 +1,6 @@
+-- Remove search and pagination indexes from sent_emails
+DROP INDEX IF EXISTS idx_sent_emails_metadata;
+DROP INDEX IF EXISTS idx_sent_emails_account_created_id;
+DROP INDEX IF EXISTS idx_sent_emails_search_vector;
+
+ALTER TABLE sent_emails DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,15 @@
+-- Full-text search vector over subject and body content
+ALTER TABLE sent_emails
+ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(subject, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(text_content, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(html_content, '')), 'C')
+) STORED;
+
+CREATE INDEX idx_sent_emails_search_vector ON sent_emails USING GIN (search_vector);
+
+-- Keyset pagination index (account_id, created_at DESC, id DESC)
+CREATE INDEX idx_sent_emails_account_created_id ON sent_emails(account_id, created_at DESC, id DESC);
+
+-- Metadata key/value filtering
+CREATE INDEX idx_sent_emails_metadata ON sent_emails USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,13 @@
+-- Restore the single-key column from each account's oldest active key
+UPDATE accounts a SET api_key_hash = k.key_hash
+FROM (
+    SELECT DISTINCT ON (account_id) account_id, key_hash
+    FROM api_keys
+    WHERE revoked_at IS NULL
+    ORDER BY account_id, created_at ASC
+) k
+WHERE a.id = k.account_id AND a.api_key_hash IS NULL;
+
+DROP TRIGGER IF EXISTS update_api_keys_updated_at ON api_keys;
+DROP INDEX IF EXISTS idx_api_keys_account_id;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,32 @@
+-- Create api_keys table: multiple named, scoped keys per account
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(32) NOT NULL,
+    key_hash VARCHAR(255) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_account_id ON api_keys(account_id);
+
+CREATE TRIGGER update_api_keys_updated_at BEFORE UPDATE ON api_keys FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Move existing account keys into api_keys with the access they had: every route, and
+-- the admin routes only for admin accounts
+INSERT INTO api_keys (account_id, name, key_prefix, key_hash, scopes)
+SELECT id, 'default', 'maylng_', api_key_hash,
+       ARRAY['account:read', 'account:write', 'addresses:read', 'addresses:write',
+             'emails:read', 'emails:send', 'domains:read', 'domains:write',
+             'tps:read', 'tps:write', 'tps:read-secrets']
+       || CASE WHEN is_admin THEN ARRAY['admin'] ELSE ARRAY[]::TEXT[] END
+FROM accounts
+WHERE api_key_hash IS NOT NULL;
+
+-- accounts.api_key_hash is no longer written; keys live in api_keys
+ALTER TABLE accounts ALTER COLUMN api_key_hash DROP NOT NULL;
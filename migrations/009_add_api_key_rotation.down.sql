@@ -0,0 +1,3 @@
+ALTER TABLE api_keys
+DROP COLUMN IF EXISTS rotated_at,
+DROP COLUMN IF EXISTS replaced_by_id;
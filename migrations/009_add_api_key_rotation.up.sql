@@ -0,0 +1,4 @@
+-- Track key rotation: the old key points at its replacement and stays valid until expires_at
+ALTER TABLE api_keys
+ADD COLUMN replaced_by_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+ADD COLUMN rotated_at TIMESTAMP;
@@ -0,0 +1,6 @@
+-- Keys with a key_id cannot be verified by the legacy lookup, so they are removed
+DELETE FROM api_keys WHERE key_id IS NOT NULL;
+
+ALTER TABLE api_keys
+DROP COLUMN IF EXISTS pepper_version,
+DROP COLUMN IF EXISTS key_id;
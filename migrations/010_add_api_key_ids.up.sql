@@ -0,0 +1,6 @@
+-- Keys issued from now on are maylng_<key_id>_<secret>: looked up by key_id and verified
+-- against an HMAC of the secret made with the pepper version recorded on the row.
+-- Existing keys keep key_id NULL and are verified with the legacy salted hash.
+ALTER TABLE api_keys
+ADD COLUMN key_id VARCHAR(32) UNIQUE,
+ADD COLUMN pepper_version INTEGER;
@@ -0,0 +1,12 @@
+UPDATE api_keys
+SET scopes = array_remove(array_remove(array_remove(array_remove(scopes,
+    'organization:read'), 'organization:write'), 'billing:read'), 'billing:write');
+
+DROP INDEX IF EXISTS idx_api_keys_member_id;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS member_id;
+
+DROP TRIGGER IF EXISTS update_organization_members_updated_at ON organization_members;
+DROP TRIGGER IF EXISTS update_organizations_updated_at ON organizations;
+DROP INDEX IF EXISTS idx_organization_members_organization_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,39 @@
+-- Each account is owned by one organization; humans join it as members with a role
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL UNIQUE REFERENCES accounts(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(255),
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'developer', 'viewer')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (organization_id, email)
+);
+
+CREATE INDEX idx_organization_members_organization_id ON organization_members(organization_id);
+
+CREATE TRIGGER update_organizations_updated_at BEFORE UPDATE ON organizations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_organization_members_updated_at BEFORE UPDATE ON organization_members FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Keys issued by or for a member act with at most that member's role permissions.
+-- They are deleted with their member however it is removed, so they never outlive the
+-- role that bounds them.
+ALTER TABLE api_keys ADD COLUMN member_id UUID REFERENCES organization_members(id) ON DELETE CASCADE;
+CREATE INDEX idx_api_keys_member_id ON api_keys(member_id);
+
+-- Give every existing account an organization
+INSERT INTO organizations (account_id, name)
+SELECT id, 'Default' FROM accounts;
+
+-- Full-access keys keep full access under the new organization and billing scopes
+UPDATE api_keys
+SET scopes = scopes || ARRAY['organization:read', 'organization:write', 'billing:read', 'billing:write']
+WHERE 'account:write' = ANY(scopes);
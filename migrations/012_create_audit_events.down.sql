@@ -0,0 +1,6 @@
+UPDATE api_keys SET scopes = array_remove(scopes, 'audit:read');
+
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+DROP FUNCTION IF EXISTS prevent_audit_event_changes();
+DROP TABLE IF EXISTS audit_events;
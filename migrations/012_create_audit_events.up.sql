@@ -0,0 +1,40 @@
+-- Append-only audit trail. Rows are chained per account: each hash covers the row's
+-- content and the previous row's hash, so edits or gaps are detectable.
+-- There is no foreign key to accounts so events outlive the account they describe.
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    seq BIGSERIAL UNIQUE NOT NULL,
+    account_id UUID NOT NULL,
+    actor_api_key_id UUID,
+    actor_member_id UUID,
+    action VARCHAR(100) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255),
+    ip_address VARCHAR(64),
+    user_agent TEXT,
+    request_id VARCHAR(128),
+    before JSONB,
+    after JSONB,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_events_account_seq ON audit_events(account_id, seq DESC);
+CREATE INDEX idx_audit_events_account_resource ON audit_events(account_id, resource_type, resource_id);
+CREATE INDEX idx_audit_events_request_id ON audit_events(request_id);
+
+CREATE OR REPLACE FUNCTION prevent_audit_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events FOR EACH ROW EXECUTE FUNCTION prevent_audit_event_changes();
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events FOR EACH STATEMENT EXECUTE FUNCTION prevent_audit_event_changes();
+
+-- Owners and full-access keys can read the audit log
+UPDATE api_keys
+SET scopes = scopes || ARRAY['audit:read']
+WHERE 'account:write' = ANY(scopes);
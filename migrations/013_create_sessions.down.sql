@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_sessions_updated_at ON sessions;
+DROP INDEX IF EXISTS idx_sessions_member_id;
+DROP TABLE IF EXISTS sessions;
+DROP INDEX IF EXISTS idx_magic_link_tokens_member_id;
+DROP TABLE IF EXISTS magic_link_tokens;
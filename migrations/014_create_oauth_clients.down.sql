@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_oauth_clients_updated_at ON oauth_clients;
+DROP INDEX IF EXISTS idx_oauth_clients_account_id;
+DROP TABLE IF EXISTS oauth_clients;
@@ -0,0 +1,19 @@
+-- OAuth2 clients that mint short-lived access tokens with the client_credentials grant
+CREATE TABLE oauth_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    member_id UUID REFERENCES organization_members(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    client_id VARCHAR(64) UNIQUE NOT NULL,
+    client_secret_hash VARCHAR(255) NOT NULL,
+    pepper_version INTEGER NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_oauth_clients_account_id ON oauth_clients(account_id);
+
+CREATE TRIGGER update_oauth_clients_updated_at BEFORE UPDATE ON oauth_clients FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
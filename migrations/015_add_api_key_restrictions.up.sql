@@ -0,0 +1,4 @@
+-- Optional per-key network restrictions; empty arrays mean unrestricted
+ALTER TABLE api_keys
+ADD COLUMN allowed_cidrs TEXT[] NOT NULL DEFAULT '{}',
+ADD COLUMN allowed_origins TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_sent_emails_account_created_at;
+
+ALTER TABLE accounts
+DROP COLUMN IF EXISTS limit_overrides,
+DROP CONSTRAINT IF EXISTS accounts_plan_fkey,
+ALTER COLUMN plan DROP NOT NULL;
+
+DROP TABLE IF EXISTS plans;
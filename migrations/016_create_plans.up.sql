@@ -0,0 +1,47 @@
+-- Plans hold every quota and feature flag; accounts reference a plan and may override
+-- individual limits. accounts.email_limit_per_month and email_address_limit are kept as
+-- the effective values (plan plus overrides) and recomputed whenever either changes.
+CREATE TABLE plans (
+    id VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    is_public BOOLEAN NOT NULL DEFAULT TRUE,
+    email_limit_per_month INTEGER NOT NULL CHECK (email_limit_per_month >= 0),
+    email_limit_per_day INTEGER NOT NULL CHECK (email_limit_per_day >= 0),
+    email_limit_per_hour INTEGER NOT NULL CHECK (email_limit_per_hour >= 0),
+    email_address_limit INTEGER NOT NULL CHECK (email_address_limit >= 0),
+    tps_per_address_limit INTEGER NOT NULL CHECK (tps_per_address_limit >= 0),
+    custom_domain_limit INTEGER NOT NULL CHECK (custom_domain_limit >= 0),
+    api_calls_per_hour INTEGER NOT NULL CHECK (api_calls_per_hour >= 0),
+    features JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_plans_updated_at BEFORE UPDATE ON plans FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+INSERT INTO plans (id, name, email_limit_per_month, email_limit_per_day, email_limit_per_hour, email_address_limit, tps_per_address_limit, custom_domain_limit, api_calls_per_hour, features) VALUES
+    ('starter', 'Starter', 5000, 2500, 250, 5, 1, 1, 1000, '{"custom_domains": true, "scheduled_sends": true}'),
+    ('pro', 'Pro', 50000, 25000, 2500, 50, 3, 10, 10000, '{"custom_domains": true, "scheduled_sends": true}'),
+    ('enterprise', 'Enterprise', 175000, 100000, 10000, 500, 20, 100, 50000, '{"custom_domains": true, "scheduled_sends": true}');
+
+-- Accounts without a plan were on the starter default; any other unknown plan needs a
+-- decision from an operator, so stop rather than guess
+UPDATE accounts SET plan = 'starter' WHERE plan IS NULL;
+
+DO $$
+DECLARE
+    unknown TEXT;
+BEGIN
+    SELECT string_agg(DISTINCT plan, ', ') INTO unknown FROM accounts WHERE plan NOT IN (SELECT id FROM plans);
+    IF unknown IS NOT NULL THEN
+        RAISE EXCEPTION 'accounts reference unknown plans (%); map them to starter, pro or enterprise before migrating', unknown;
+    END IF;
+END $$;
+
+ALTER TABLE accounts
+ALTER COLUMN plan SET NOT NULL,
+ADD CONSTRAINT accounts_plan_fkey FOREIGN KEY (plan) REFERENCES plans(id) ON UPDATE CASCADE,
+ADD COLUMN limit_overrides JSONB NOT NULL DEFAULT '{}';
+
+-- Send quotas count an account's emails per hour, day and month
+CREATE INDEX idx_sent_emails_account_created_at ON sent_emails(account_id, created_at);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_usage_records_updated_at ON usage_records;
+DROP TABLE IF EXISTS usage_records;
@@ -0,0 +1,16 @@
+-- Metered usage rolled up per account, metric and UTC day. Rows are incremented as
+-- usage happens and are the source for billing exports. There is no foreign key to
+-- accounts so usage of a deleted account can still be billed.
+CREATE TABLE usage_records (
+    account_id UUID NOT NULL,
+    usage_date DATE NOT NULL,
+    metric VARCHAR(50) NOT NULL,
+    quantity BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, usage_date, metric)
+);
+
+CREATE INDEX idx_usage_records_usage_date ON usage_records(usage_date);
+
+CREATE TRIGGER update_usage_records_updated_at BEFORE UPDATE ON usage_records FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,8 @@
+-- Accounts can be suspended (temporarily blocked, e.g. for abuse review) or closed
+-- without deleting any of their data
+ALTER TABLE accounts
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended', 'closed')),
+    ADD COLUMN status_reason TEXT,
+    ADD COLUMN status_changed_at TIMESTAMP;
+
+CREATE INDEX idx_accounts_status ON accounts(status) WHERE status != 'active';
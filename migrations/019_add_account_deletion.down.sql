@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_accounts_deletion_scheduled_at;
+
+ALTER TABLE accounts
+    DROP COLUMN IF EXISTS deletion_scheduled_at,
+    DROP COLUMN IF EXISTS deletion_requested_at;
@@ -0,0 +1,8 @@
+-- Self-service deletion is scheduled rather than immediate: the account keeps working
+-- during a cooling-off period in which the deletion can be cancelled, after which the
+-- worker erases it
+ALTER TABLE accounts
+    ADD COLUMN deletion_requested_at TIMESTAMP,
+    ADD COLUMN deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX idx_accounts_deletion_scheduled_at ON accounts(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_custom_domains_next_verification_at;
+
+ALTER TABLE custom_domains
+    DROP COLUMN IF EXISTS next_verification_at,
+    DROP COLUMN IF EXISTS verification_attempts,
+    DROP COLUMN IF EXISTS verification_started_at;
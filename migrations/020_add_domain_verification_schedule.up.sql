@@ -0,0 +1,13 @@
+-- Per-domain scheduling for the worker's verification checks. Each unsuccessful check
+-- pushes next_verification_at further out; verification_started_at starts the clock
+-- for the verification timeout and is reset when verification is triggered again.
+ALTER TABLE custom_domains
+    ADD COLUMN verification_started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN verification_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN next_verification_at TIMESTAMP;
+
+-- Existing domains start the clock now; backdating it would time out every pending
+-- domain on the worker's first run after deploy
+UPDATE custom_domains SET verification_started_at = NOW();
+
+CREATE INDEX idx_custom_domains_next_verification_at ON custom_domains(next_verification_at) WHERE status = 'pending';
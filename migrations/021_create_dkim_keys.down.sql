@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dkim_keys;
@@ -0,0 +1,24 @@
+-- Self-hosted DKIM keys per custom domain. A new key starts pending while its selector
+-- record propagates, becomes active once published, and stays published as retired for
+-- a while after the next key takes over so mail in flight still verifies.
+CREATE TABLE dkim_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    custom_domain_id UUID NOT NULL REFERENCES custom_domains(id) ON DELETE CASCADE,
+    selector VARCHAR(63) NOT NULL,
+    algorithm VARCHAR(20) NOT NULL CHECK (algorithm IN ('rsa-sha256', 'ed25519-sha256')),
+    public_key_record TEXT NOT NULL,
+    encrypted_private_key TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'active', 'retired')),
+    activated_at TIMESTAMP,
+    retired_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (custom_domain_id, selector)
+);
+
+CREATE INDEX idx_dkim_keys_custom_domain_id ON dkim_keys(custom_domain_id);
+CREATE INDEX idx_dkim_keys_status ON dkim_keys(status);
+CREATE UNIQUE INDEX idx_dkim_keys_one_active ON dkim_keys(custom_domain_id) WHERE status = 'active';
+CREATE UNIQUE INDEX idx_dkim_keys_one_pending ON dkim_keys(custom_domain_id) WHERE status = 'pending';
+
+CREATE TRIGGER update_dkim_keys_updated_at BEFORE UPDATE ON dkim_keys FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
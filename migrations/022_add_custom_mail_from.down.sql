@@ -0,0 +1,3 @@
+ALTER TABLE custom_domains
+    DROP COLUMN IF EXISTS mail_from_status,
+    DROP COLUMN IF EXISTS mail_from_domain;
@@ -0,0 +1,6 @@
+-- Custom MAIL FROM (return-path) subdomain, e.g. bounce.example.com, so SPF passes
+-- aligned with the From domain under DMARC. Its verification is tracked separately
+-- from the domain's own status because the domain can send without it.
+ALTER TABLE custom_domains
+    ADD COLUMN mail_from_domain VARCHAR(255),
+    ADD COLUMN mail_from_status VARCHAR(20) CHECK (mail_from_status IN ('pending', 'verified', 'failed'));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS dmarc_report_records;
+DROP TABLE IF EXISTS dmarc_reports;
@@ -0,0 +1,34 @@
+-- DMARC aggregate reports received for custom domains. Each report has one row per
+-- source IP and evaluation result; count is the number of messages the row covers.
+CREATE TABLE dmarc_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    custom_domain_id UUID NOT NULL REFERENCES custom_domains(id) ON DELETE CASCADE,
+    org_name VARCHAR(255) NOT NULL,
+    reporter_email VARCHAR(255),
+    report_id VARCHAR(255) NOT NULL,
+    date_begin TIMESTAMP NOT NULL,
+    date_end TIMESTAMP NOT NULL,
+    policy VARCHAR(20),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (custom_domain_id, org_name, report_id)
+);
+
+CREATE INDEX idx_dmarc_reports_domain_date ON dmarc_reports(custom_domain_id, date_begin);
+
+CREATE TABLE dmarc_report_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id UUID NOT NULL REFERENCES dmarc_reports(id) ON DELETE CASCADE,
+    source_ip VARCHAR(45) NOT NULL,
+    message_count INTEGER NOT NULL,
+    disposition VARCHAR(20),
+    dkim_aligned BOOLEAN NOT NULL DEFAULT FALSE,
+    spf_aligned BOOLEAN NOT NULL DEFAULT FALSE,
+    header_from VARCHAR(255),
+    envelope_from VARCHAR(255),
+    dkim_domain VARCHAR(255),
+    dkim_result VARCHAR(20),
+    spf_domain VARCHAR(255),
+    spf_result VARCHAR(20)
+);
+
+CREATE INDEX idx_dmarc_report_records_report_id ON dmarc_report_records(report_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS tls_report_failures;
+DROP TABLE IF EXISTS tls_report_policies;
+DROP TABLE IF EXISTS tls_reports;
+DROP TABLE IF EXISTS mta_sts_policies;
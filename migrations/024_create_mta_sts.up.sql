@@ -0,0 +1,53 @@
+-- MTA-STS policies served for custom domains. policy_id changes whenever the policy
+-- does, which tells senders to fetch it again.
+CREATE TABLE mta_sts_policies (
+    custom_domain_id UUID PRIMARY KEY REFERENCES custom_domains(id) ON DELETE CASCADE,
+    mode VARCHAR(20) NOT NULL CHECK (mode IN ('testing', 'enforce', 'none')),
+    policy_id VARCHAR(32) NOT NULL,
+    max_age INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_mta_sts_policies_updated_at BEFORE UPDATE ON mta_sts_policies FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- SMTP TLS reports (TLS-RPT) received for custom domains: one row per policy the
+-- sender applied, with the failed sessions grouped by cause.
+CREATE TABLE tls_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    custom_domain_id UUID NOT NULL REFERENCES custom_domains(id) ON DELETE CASCADE,
+    org_name VARCHAR(255) NOT NULL,
+    report_id VARCHAR(255) NOT NULL,
+    contact_info VARCHAR(255),
+    date_begin TIMESTAMP NOT NULL,
+    date_end TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (custom_domain_id, org_name, report_id)
+);
+
+CREATE INDEX idx_tls_reports_domain_date ON tls_reports(custom_domain_id, date_begin);
+
+CREATE TABLE tls_report_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id UUID NOT NULL REFERENCES tls_reports(id) ON DELETE CASCADE,
+    policy_type VARCHAR(20) NOT NULL,
+    policy_domain VARCHAR(255),
+    mx_hosts TEXT[],
+    successful_sessions INTEGER NOT NULL DEFAULT 0,
+    failed_sessions INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_tls_report_policies_report_id ON tls_report_policies(report_id);
+
+CREATE TABLE tls_report_failures (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    policy_id UUID NOT NULL REFERENCES tls_report_policies(id) ON DELETE CASCADE,
+    result_type VARCHAR(64) NOT NULL,
+    sending_mta_ip VARCHAR(45),
+    receiving_mx_hostname VARCHAR(255),
+    receiving_ip VARCHAR(45),
+    failed_sessions INTEGER NOT NULL DEFAULT 0,
+    failure_reason_code VARCHAR(255)
+);
+
+CREATE INDEX idx_tls_report_failures_policy_id ON tls_report_failures(policy_id);
@@ -0,0 +1,16 @@
+-- DNS providers customers delegated access to, so Maylng can publish their custom
+-- domains' records. provisioned_records holds what was last published, so records
+-- the domain no longer needs can be removed.
+CREATE TABLE dns_provider_connections (
+    custom_domain_id UUID PRIMARY KEY REFERENCES custom_domains(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL CHECK (provider IN ('cloudflare', 'route53', 'rfc2136')),
+    zone VARCHAR(255) NOT NULL,
+    encrypted_credentials TEXT NOT NULL,
+    provisioned_records JSONB NOT NULL DEFAULT '[]',
+    last_synced_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_dns_provider_connections_updated_at BEFORE UPDATE ON dns_provider_connections FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS custom_domain_health;
+
+UPDATE custom_domains SET status = 'verified' WHERE status = 'degraded';
+
+ALTER TABLE custom_domains
+DROP CONSTRAINT custom_domains_status_check;
+
+ALTER TABLE custom_domains
+ADD CONSTRAINT custom_domains_status_check
+CHECK (status IN ('pending', 'verified', 'failed', 'disabled'));
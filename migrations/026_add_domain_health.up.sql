@@ -0,0 +1,21 @@
+-- Verified domains a health check finds problems with are demoted to 'degraded'
+ALTER TABLE custom_domains
+DROP CONSTRAINT custom_domains_status_check;
+
+ALTER TABLE custom_domains
+ADD CONSTRAINT custom_domains_status_check
+CHECK (status IN ('pending', 'verified', 'failed', 'disabled', 'degraded'));
+
+-- The latest health check of each verified domain: its DNS records re-validated and
+-- the domain looked up on DNS blocklists
+CREATE TABLE custom_domain_health (
+    custom_domain_id UUID PRIMARY KEY REFERENCES custom_domains(id) ON DELETE CASCADE,
+    score INTEGER NOT NULL CHECK (score BETWEEN 0 AND 100),
+    reasons JSONB NOT NULL DEFAULT '[]',
+    blocklisted_on TEXT[] NOT NULL DEFAULT '{}',
+    checked_at TIMESTAMP NOT NULL,
+    next_check_at TIMESTAMP NOT NULL,
+    degraded_since TIMESTAMP
+);
+
+CREATE INDEX idx_custom_domain_health_next_check ON custom_domain_health(next_check_at);
@@ -0,0 +1,3 @@
+ALTER TABLE custom_domains
+ADD CONSTRAINT custom_domains_verification_provider_check
+CHECK (verification_provider IN ('ses', 'resend'));
@@ -0,0 +1,4 @@
+-- Verification providers are validated against the configured provider registry, so
+-- adding one no longer needs a schema change
+ALTER TABLE custom_domains
+DROP CONSTRAINT IF EXISTS custom_domains_verification_provider_check;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS email_tracking_events;
+DROP TABLE IF EXISTS custom_domain_settings;
@@ -0,0 +1,36 @@
+-- Sending settings of custom domains, applied by the send pipeline whatever the email
+-- provider. A domain without a row uses the defaults.
+CREATE TABLE custom_domain_settings (
+    custom_domain_id UUID PRIMARY KEY REFERENCES custom_domains(id) ON DELETE CASCADE,
+    open_tracking BOOLEAN NOT NULL DEFAULT FALSE,
+    click_tracking BOOLEAN NOT NULL DEFAULT FALSE,
+    require_tls BOOLEAN NOT NULL DEFAULT FALSE,
+    default_from_name VARCHAR(100),
+    default_reply_to VARCHAR(255),
+    allowed_sender_prefixes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_custom_domain_settings_updated_at BEFORE UPDATE ON custom_domain_settings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Carry over the tracking and TLS settings previously stored in domain metadata
+INSERT INTO custom_domain_settings (custom_domain_id, open_tracking, click_tracking, require_tls)
+SELECT id,
+    COALESCE(metadata->'resend_open_tracking' = 'true' OR metadata->'ses_open_tracking' = 'true', FALSE),
+    COALESCE(metadata->'resend_click_tracking' = 'true' OR metadata->'ses_click_tracking' = 'true', FALSE),
+    COALESCE(metadata->'resend_tls' = '"enforced"' OR metadata->'ses_tls' = '"enforced"', FALSE)
+FROM custom_domains
+WHERE metadata ?| ARRAY['resend_open_tracking', 'ses_open_tracking', 'resend_click_tracking', 'ses_click_tracking', 'resend_tls', 'ses_tls'];
+
+-- Opens and clicks of tracked emails
+CREATE TABLE email_tracking_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sent_email_id UUID NOT NULL REFERENCES sent_emails(id) ON DELETE CASCADE,
+    event_type VARCHAR(10) NOT NULL CHECK (event_type IN ('open', 'click')),
+    url TEXT,
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_tracking_events_email ON email_tracking_events(sent_email_id, created_at);
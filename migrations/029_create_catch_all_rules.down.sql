@@ -0,0 +1 @@
+DROP TABLE IF EXISTS catch_all_rules;
@@ -0,0 +1,18 @@
+-- Catch-all rules of custom domains: mail for a local part no address exists for is
+-- accepted if it matches a rule's pattern, and either creates the address or goes to
+-- the rule's target address.
+CREATE TABLE catch_all_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    custom_domain_id UUID NOT NULL REFERENCES custom_domains(id) ON DELETE CASCADE,
+    pattern VARCHAR(64) NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'route')),
+    target_email_address_id UUID REFERENCES email_addresses(id) ON DELETE SET NULL,
+    address_type VARCHAR(20) CHECK (address_type IN ('temporary', 'persistent')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (custom_domain_id, pattern)
+);
+
+CREATE INDEX idx_catch_all_rules_target ON catch_all_rules(target_email_address_id);
+
+CREATE TRIGGER update_catch_all_rules_updated_at BEFORE UPDATE ON catch_all_rules FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
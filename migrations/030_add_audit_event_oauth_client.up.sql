@@ -0,0 +1,2 @@
+-- Changes made with an OAuth client_credentials token are attributed to the client
+ALTER TABLE audit_events ADD COLUMN actor_oauth_client_id UUID;
@@ -0,0 +1,8 @@
+CREATE OR REPLACE FUNCTION prevent_audit_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE audit_events DROP COLUMN IF EXISTS redacted_at;
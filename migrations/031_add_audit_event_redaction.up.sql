@@ -0,0 +1,24 @@
+-- Erasing an account clears the personal data in its audit events. The rest of the event,
+-- including its place in the hash chain, stays as recorded.
+ALTER TABLE audit_events ADD COLUMN redacted_at TIMESTAMP;
+
+CREATE OR REPLACE FUNCTION prevent_audit_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- The only change allowed is a one-time redaction that clears the payloads and client details
+    IF TG_OP = 'UPDATE' THEN
+        IF OLD.redacted_at IS NULL AND NEW.redacted_at IS NOT NULL
+            AND NEW.before IS NULL AND NEW.after IS NULL
+            AND NEW.ip_address IS NULL AND NEW.user_agent IS NULL
+            AND (NEW.id, NEW.seq, NEW.account_id, NEW.actor_api_key_id, NEW.actor_member_id, NEW.actor_oauth_client_id,
+                 NEW.action, NEW.resource_type, NEW.resource_id, NEW.request_id, NEW.prev_hash, NEW.hash, NEW.created_at)
+                IS NOT DISTINCT FROM
+                (OLD.id, OLD.seq, OLD.account_id, OLD.actor_api_key_id, OLD.actor_member_id, OLD.actor_oauth_client_id,
+                 OLD.action, OLD.resource_type, OLD.resource_id, OLD.request_id, OLD.prev_hash, OLD.hash, OLD.created_at)
+        THEN
+            RETURN NEW;
+        END IF;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ language 'plpgsql';